@@ -0,0 +1,319 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api provides an API server for controlling the Dice core.
+package api
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"github.com/dominikbraun/dice/version"
+	"github.com/go-chi/render"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// openAPIVersion is the version of the OpenAPI specification that the
+	// generated API description conforms to.
+	openAPIVersion = "3.0.3"
+)
+
+// endpoint describes a single API endpoint for the OpenAPI document. The
+// request and response fields hold zero values of the types that will be
+// decoded from the request body and encoded into the response body. A nil
+// request indicates that the endpoint doesn't expect a request body.
+type endpoint struct {
+	method   string
+	path     string
+	summary  string
+	request  interface{}
+	response interface{}
+}
+
+// endpoints lists all versioned API endpoints mounted by mountRoutes. The
+// paths are relative to the version route. This list has to be kept in
+// sync with the router, which is ensured by TestServer_openAPI_routes.
+var endpoints = []endpoint{
+	{http.MethodPost, "/nodes/create", "Create a node", types.NodeCreate{}, types.Response{}},
+	{http.MethodPost, "/nodes/list", "List nodes", types.NodeListOptions{}, types.NodeListResponse{}},
+	{http.MethodPost, "/nodes/{ref}/attach", "Attach a node", nil, types.Response{}},
+	{http.MethodPost, "/nodes/{ref}/detach", "Detach a node", nil, types.Response{}},
+	{http.MethodPost, "/nodes/{ref}/cordon", "Cordon a node", nil, types.Response{}},
+	{http.MethodPost, "/nodes/{ref}/uncordon", "Uncordon a node", nil, types.Response{}},
+	{http.MethodPost, "/nodes/{ref}/drain", "Drain a node", types.NodeDrainOptions{}, types.Response{}},
+	{http.MethodPost, "/nodes/{ref}/remove", "Remove a node", types.NodeRemoveOptions{}, types.Response{}},
+	{http.MethodPost, "/nodes/{ref}/info", "Get node information", nil, types.NodeInfoResponse{}},
+	{http.MethodPost, "/nodes/{ref}/events", "Get the state changes of a node", nil, types.EventListResponse{}},
+
+	{http.MethodPost, "/services/create", "Create a service", types.ServiceCreate{}, types.Response{}},
+	{http.MethodPost, "/services/list", "List services", types.ServiceListOptions{}, types.ServiceListResponse{}},
+	{http.MethodPost, "/services/enable", "Enable multiple services", types.ServiceSelectOptions{}, types.ServiceResultsResponse{}},
+	{http.MethodPost, "/services/disable", "Disable multiple services", types.ServiceSelectOptions{}, types.ServiceResultsResponse{}},
+	{http.MethodPost, "/services/{ref}/enable", "Enable a service", nil, types.Response{}},
+	{http.MethodPost, "/services/{ref}/disable", "Disable a service", nil, types.Response{}},
+	{http.MethodPost, "/services/{ref}/update", "Update a service", types.ServiceUpdate{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/patch", "Partially update a service", types.ServicePatch{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/info", "Get service information", nil, types.ServiceInfoResponse{}},
+	{http.MethodPost, "/services/{ref}/metrics", "Get service metrics", nil, types.ServiceMetricsResponse{}},
+	{http.MethodPost, "/services/{ref}/url", "Set the URLs of a service", types.ServiceURL{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/default", "Make a service the default service", nil, types.Response{}},
+	{http.MethodPost, "/services/{ref}/healthcheck", "Configure the health check of a service", types.ServiceHealthCheckOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/maintenance", "Configure the maintenance mode of a service", types.ServiceMaintenanceOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/sticky", "Configure sticky sessions of a service", types.ServiceStickyOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/outliers", "Configure the outlier detection of a service", types.ServiceOutliersOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/mirror", "Configure traffic mirroring of a service", types.ServiceMirrorOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/accesslog", "Configure the access log of a service", types.ServiceAccessLogOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/cors", "Configure CORS for a service", types.ServiceCORSOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/auth", "Configure the authentication of a service", types.ServiceAuthOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/allowlist", "Configure the allow list of a service", types.ServiceAllowListOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/schedule", "Schedule the enabling and disabling of a service", types.ServiceScheduleOptions{}, types.Response{}},
+	{http.MethodPost, "/services/{ref}/replace", "Replace the instances of a service", types.ServiceReplace{}, types.Response{}},
+
+	{http.MethodPost, "/instances/create", "Create an instance", types.InstanceCreate{}, types.Response{}},
+	{http.MethodPost, "/instances/create/nodes", "Create instances on multiple nodes", types.InstancesCreate{}, types.InstanceResultsResponse{}},
+	{http.MethodPost, "/instances/list", "List instances", types.InstanceListOptions{}, types.InstanceListResponse{}},
+	{http.MethodPost, "/instances/{ref}/attach", "Attach an instance", types.InstanceAttachOptions{}, types.Response{}},
+	{http.MethodPost, "/instances/{ref}/detach", "Detach an instance", nil, types.Response{}},
+	{http.MethodPost, "/instances/{ref}/remove", "Remove an instance", types.InstanceRemoveOptions{}, types.Response{}},
+	{http.MethodPost, "/instances/{ref}/info", "Get instance information", nil, types.InstanceInfoResponse{}},
+	{http.MethodPost, "/instances/{ref}/describe", "Describe an instance", nil, types.InstanceDescribeResponse{}},
+	{http.MethodPost, "/instances/{ref}/stats", "Get instance statistics", nil, types.InstanceStatsResponse{}},
+	{http.MethodPost, "/instances/{ref}/events", "Get the state changes of an instance", nil, types.EventListResponse{}},
+	{http.MethodPost, "/instances/{ref}/clone", "Clone an instance onto another node", types.InstanceClone{}, types.Response{}},
+
+	{http.MethodPost, "/routes/list", "List routes", nil, types.RouteListResponse{}},
+	{http.MethodPost, "/routes/weights", "Split the requests to a URL across services", types.RouteWeightsOptions{}, types.Response{}},
+
+	{http.MethodPost, "/config/print", "Print the configuration", nil, types.ConfigResponse{}},
+	{http.MethodPost, "/config/reload", "Reload the configuration", nil, types.Response{}},
+
+	{http.MethodPost, "/admin/healthcheck/run", "Run a health check", types.HealthCheckRunOptions{}, types.HealthCheckResponse{}},
+	{http.MethodPost, "/admin/health/services", "Get the health of all services", nil, types.ServiceHealthResponse{}},
+	{http.MethodPost, "/admin/audit/log", "Get the audit log", types.AuditLogOptions{}, types.AuditLogResponse{}},
+	{http.MethodPost, "/admin/logs", "Get the proxy logs", types.LogsOptions{}, types.LogsResponse{}},
+	{http.MethodPost, "/admin/prune", "Prune unused entities", types.PruneOptions{}, types.PruneResponse{}},
+	{http.MethodPost, "/admin/route/test", "Test which instance serves a request", types.RouteTestOptions{}, types.RouteTestResponse{}},
+}
+
+// unversionedEndpoints lists all endpoints that are mounted independently
+// from the version route.
+var unversionedEndpoints = []endpoint{
+	{http.MethodPost, "/version", "Get the build information", nil, types.VersionResponse{}},
+	{http.MethodGet, "/openapi.json", "Get the OpenAPI description of the API", nil, nil},
+}
+
+// openAPI handles a GET request for retrieving the OpenAPI document that
+// describes all API endpoints.
+func (s *Server) openAPI() http.HandlerFunc {
+	spec := newOpenAPISpec(version.APIVersions)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		render.JSON(w, r, spec)
+	}
+}
+
+// newOpenAPISpec creates an OpenAPI document describing all endpoints. The
+// versioned endpoints are listed once for each of the given API versions.
+// Request and response types are reflected into JSON schemas, which are
+// placed in the document's components.
+func newOpenAPISpec(apiVersions []string) map[string]interface{} {
+	g := schemaGenerator{
+		schemas: make(map[string]interface{}),
+	}
+
+	paths := make(map[string]interface{})
+
+	addPath := func(path string, e endpoint) {
+		if _, ok := paths[path]; !ok {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path].(map[string]interface{})[strings.ToLower(e.method)] = g.operation(path, e)
+	}
+
+	for _, v := range apiVersions {
+		for _, e := range endpoints {
+			addPath("/"+v+e.path, e)
+		}
+	}
+
+	for _, e := range unversionedEndpoints {
+		addPath(e.path, e)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":   "Dice API",
+			"version": version.Version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": g.schemas,
+		},
+	}
+}
+
+// schemaGenerator reflects Go types into JSON schemas. Named struct types
+// are stored in schemas and referenced by their name.
+type schemaGenerator struct {
+	schemas map[string]interface{}
+}
+
+// operation creates an OpenAPI operation object for the given endpoint.
+// Each path segment in curly braces becomes a required path parameter.
+func (g *schemaGenerator) operation(path string, e endpoint) map[string]interface{} {
+	op := map[string]interface{}{
+		"summary": e.summary,
+	}
+
+	var parameters []interface{}
+
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+			parameters = append(parameters, map[string]interface{}{
+				"name":        strings.Trim(segment, "{}"),
+				"in":          "path",
+				"required":    true,
+				"description": "The ID or name of the entity.",
+				"schema":      map[string]interface{}{"type": "string"},
+			})
+		}
+	}
+
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	if e.request != nil {
+		op["requestBody"] = map[string]interface{}{
+			"content": g.content(e.request),
+		}
+	}
+
+	success := map[string]interface{}{
+		"description": "The request has been successful.",
+	}
+	if e.response != nil {
+		success["content"] = g.content(e.response)
+	}
+
+	op["responses"] = map[string]interface{}{
+		"200": success,
+		"default": map[string]interface{}{
+			"description": "The request has failed.",
+			"content":     g.content(types.Response{}),
+		},
+	}
+
+	return op
+}
+
+// content creates an OpenAPI content object for a JSON body of v's type.
+func (g *schemaGenerator) content(v interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": g.schema(reflect.TypeOf(v)),
+		},
+	}
+}
+
+// schema returns the JSON schema for the given type. For named structs, a
+// reference to the schema in the document's components is returned.
+func (g *schemaGenerator) schema(t reflect.Type) map[string]interface{} {
+	switch t {
+	case reflect.TypeOf(time.Duration(0)):
+		return map[string]interface{}{
+			"type":        "integer",
+			"format":      "int64",
+			"description": "A duration in nanoseconds.",
+		}
+	case reflect.TypeOf(time.Time{}):
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return g.schema(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		if _, ok := g.schemas[t.Name()]; !ok {
+			// Register the name before reflecting the fields so that
+			// recursive types don't cause an infinite recursion.
+			g.schemas[t.Name()] = nil
+			g.schemas[t.Name()] = g.structSchema(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + t.Name()}
+	}
+
+	// Interfaces may hold any value, which is expressed by an empty schema.
+	return map[string]interface{}{}
+}
+
+// structSchema creates an object schema containing all fields of the given
+// struct type that are encoded by encoding/json. Fields of embedded structs
+// are promoted to the object itself unless they're shadowed by own fields.
+func (g *schemaGenerator) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.Anonymous || field.Type.Kind() != reflect.Struct || field.Tag.Get("json") != "" {
+			continue
+		}
+		embedded := g.structSchema(field.Type)["properties"].(map[string]interface{})
+		for name, property := range embedded {
+			properties[name] = property
+		}
+	}
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.PkgPath != "" || (field.Anonymous && field.Type.Kind() == reflect.Struct && field.Tag.Get("json") == "") {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		properties[name] = g.schema(field.Type)
+	}
+
+	return map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api provides an API server for controlling the Dice core.
+package api
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/controller"
+	"github.com/go-chi/chi"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestServer_openAPI_routes checks that every route registered on the
+// router appears in the OpenAPI document and vice versa.
+func TestServer_openAPI_routes(t *testing.T) {
+	s := NewServer(ServerConfig{}, controller.New(nil, nil, nil))
+
+	request := httptest.NewRequest(http.MethodGet, "/openapi.json", nil)
+	recorder := httptest.NewRecorder()
+
+	s.router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+
+	var spec struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+
+	if err := json.NewDecoder(recorder.Body).Decode(&spec); err != nil {
+		t.Fatal(err)
+	}
+
+	routes := 0
+
+	err := chi.Walk(s.router, func(method string, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		routes++
+		if _, ok := spec.Paths[route][strings.ToLower(method)]; !ok {
+			t.Errorf("route %s %s is missing in the OpenAPI document", method, route)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operations := 0
+
+	for _, methods := range spec.Paths {
+		operations += len(methods)
+	}
+
+	if operations != routes {
+		t.Errorf("expected %d operations in the OpenAPI document, got %d", routes, operations)
+	}
+}
+
+// TestNewOpenAPISpec_schemas checks that request and response types are
+// reflected into schemas, including fields promoted from embedded structs.
+func TestNewOpenAPISpec_schemas(t *testing.T) {
+	spec := newOpenAPISpec([]string{"v1"})
+	schemas := spec["components"].(map[string]interface{})["schemas"].(map[string]interface{})
+
+	response, ok := schemas["NodeListResponse"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected a schema for NodeListResponse")
+	}
+
+	properties := response["properties"].(map[string]interface{})
+
+	for _, name := range []string{"success", "message", "data"} {
+		if _, ok := properties[name]; !ok {
+			t.Errorf("expected property %s in NodeListResponse", name)
+		}
+	}
+
+	data := properties["data"].(map[string]interface{})
+	if data["type"] != "array" {
+		t.Errorf("expected data to be an array, got %v", data["type"])
+	}
+
+	if _, ok := schemas["NodeCreate"]; !ok {
+		t.Error("expected a schema for the NodeCreate request type")
+	}
+}
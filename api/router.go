@@ -16,17 +16,33 @@
 package api
 
 import (
+	"crypto/subtle"
+	"github.com/dominikbraun/dice/types"
+	"github.com/dominikbraun/dice/version"
 	"github.com/go-chi/chi"
 	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
+	"net/http"
+	"strings"
+	"time"
 )
 
+// requestIDHeader is the response header carrying the request ID.
+const requestIDHeader = "X-Request-Id"
+
 // newRouter creates a new Router instance and sets default middleware.
-func newRouter() chi.Router {
+//
+// Each request is assigned a request ID, which is logged by the request
+// logger and returned in the X-Request-Id header. The controller includes it
+// in the response body as well. Requests are logged using the server's
+// logger, see logRequest.
+func (s *Server) newRouter() chi.Router {
 	r := chi.NewRouter()
 
 	r.Use(
-		middleware.Logger,
+		middleware.RequestID,
+		setRequestIDHeader,
+		s.logRequest,
 		middleware.DefaultCompress,
 		middleware.RedirectSlashes,
 		middleware.Recoverer,
@@ -49,26 +65,47 @@ func (s *Server) mountRoutes() {
 		r.Route("/{ref}", func(r chi.Router) {
 			r.Post("/attach", s.controller.AttachNode())
 			r.Post("/detach", s.controller.DetachNode())
+			r.Post("/cordon", s.controller.CordonNode())
+			r.Post("/uncordon", s.controller.UncordonNode())
+			r.Post("/drain", s.controller.DrainNode())
 			r.Post("/remove", s.controller.RemoveNode())
 			r.Post("/info", s.controller.NodeInfo())
+			r.Post("/events", s.controller.NodeEvents())
 		})
 	})
 
 	r.Route("/services", func(r chi.Router) {
 		r.Post("/create", s.controller.CreateService())
 		r.Post("/list", s.controller.ListServices())
+		r.Post("/enable", s.controller.EnableServices())
+		r.Post("/disable", s.controller.DisableServices())
 
 		r.Route("/{ref}", func(r chi.Router) {
 			r.Post("/enable", s.controller.EnableService())
 			r.Post("/disable", s.controller.DisableService())
 			r.Post("/update", s.controller.UpdateService())
+			r.Post("/patch", s.controller.PatchService())
 			r.Post("/info", s.controller.ServiceInfo())
+			r.Post("/metrics", s.controller.ServiceMetrics())
 			r.Post("/url", s.controller.SetServiceURL())
+			r.Post("/default", s.controller.SetDefaultService())
+			r.Post("/healthcheck", s.controller.SetServiceHealthCheck())
+			r.Post("/maintenance", s.controller.SetServiceMaintenance())
+			r.Post("/sticky", s.controller.SetServiceSticky())
+			r.Post("/outliers", s.controller.SetServiceOutliers())
+			r.Post("/mirror", s.controller.SetServiceMirror())
+			r.Post("/accesslog", s.controller.SetServiceAccessLog())
+			r.Post("/cors", s.controller.SetServiceCORS())
+			r.Post("/auth", s.controller.SetServiceAuth())
+			r.Post("/allowlist", s.controller.SetServiceAllowList())
+			r.Post("/schedule", s.controller.ScheduleService())
+			r.Post("/replace", s.controller.RollingReplace())
 		})
 	})
 
 	r.Route("/instances", func(r chi.Router) {
 		r.Post("/create", s.controller.CreateInstance())
+		r.Post("/create/nodes", s.controller.CreateInstances())
 		r.Post("/list", s.controller.ListInstances())
 
 		r.Route("/{ref}", func(r chi.Router) {
@@ -76,12 +113,121 @@ func (s *Server) mountRoutes() {
 			r.Post("/detach", s.controller.DetachInstance())
 			r.Post("/remove", s.controller.RemoveInstance())
 			r.Post("/info", s.controller.InstanceInfo())
+			r.Post("/describe", s.controller.InstanceDescribe())
+			r.Post("/stats", s.controller.InstanceStats())
+			r.Post("/events", s.controller.InstanceEvents())
+			r.Post("/clone", s.controller.CloneInstance())
 		})
 	})
 
+	r.Route("/routes", func(r chi.Router) {
+		r.Post("/list", s.controller.ListRoutes())
+		r.Post("/weights", s.controller.SetRouteWeights())
+	})
+
 	r.Route("/config", func(r chi.Router) {
+		r.Post("/print", s.controller.PrintConfig())
 		r.Post("/reload", s.controller.ReloadConfig())
 	})
 
-	s.router.Mount("/v1", r)
+	r.Route("/admin", func(r chi.Router) {
+		r.Post("/healthcheck/run", s.controller.RunHealthCheck())
+		r.Post("/health/services", s.controller.ServiceHealth())
+		r.Post("/audit/log", s.controller.AuditLog())
+		r.With(s.requireToken).Post("/logs", s.controller.ProxyLogs())
+		r.Post("/prune", s.controller.Prune())
+		r.Post("/route/test", s.controller.TestRoute())
+	})
+
+	for _, v := range version.APIVersions {
+		s.router.Mount("/"+v, r)
+	}
+
+	// The build information doesn't depend on the API version and thus
+	// is available independently from the version route.
+	s.router.Post("/version", s.controller.Version())
+
+	// The OpenAPI document describes the endpoints of all API versions.
+	s.router.Get("/openapi.json", s.openAPI())
+}
+
+// setRequestIDHeader is a middleware that returns the ID assigned to the
+// request by middleware.RequestID in the X-Request-Id response header.
+func setRequestIDHeader(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if requestID := middleware.GetReqID(r.Context()); requestID != "" {
+			w.Header().Set(requestIDHeader, requestID)
+		}
+		next.ServeHTTP(w, r)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// requireToken is a middleware that only passes requests carrying the API
+// token configured for the server as bearer token. If no token has been
+// configured, all requests are refused.
+func (s *Server) requireToken(next http.Handler) http.Handler {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		var err error
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		switch {
+		case s.config.Token == "":
+			err = ErrTokenNotConfigured
+		case subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1:
+			err = ErrInvalidToken
+		default:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		response := types.Response{
+			Success:   false,
+			Message:   err.Error(),
+			RequestID: middleware.GetReqID(r.Context()),
+		}
+
+		w.WriteHeader(http.StatusUnauthorized)
+		render.JSON(w, r, response)
+	}
+
+	return http.HandlerFunc(fn)
+}
+
+// logRequest is a middleware that logs each request once it has been
+// handled, including the status code, the response size, the duration and
+// the request ID. Requests resulting in a server error are logged as errors.
+// If the request log has been disabled, the requests are passed through.
+func (s *Server) logRequest(next http.Handler) http.Handler {
+	if s.config.DisableRequestLog {
+		return next
+	}
+
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		start := time.Now()
+
+		next.ServeHTTP(ww, r)
+
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		format := "API request %s %s from %s - %d %dB in %v [%s]"
+		args := []interface{}{
+			r.Method, r.URL.RequestURI(), r.RemoteAddr, status, ww.BytesWritten(),
+			time.Since(start), middleware.GetReqID(r.Context()),
+		}
+
+		if status >= http.StatusInternalServerError {
+			s.logger.Errorf(format, args...)
+		} else {
+			s.logger.Infof(format, args...)
+		}
+	}
+
+	return http.HandlerFunc(fn)
 }
@@ -17,39 +17,91 @@ package api
 
 import (
 	"context"
+	"errors"
 	"github.com/dominikbraun/dice/controller"
+	"github.com/dominikbraun/dice/log"
 	"github.com/go-chi/chi"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"os"
+	"time"
+)
+
+const (
+	// socketMode restricts the access to the Unix socket to its owner.
+	socketMode os.FileMode = 0600
+	// shutdownTimeout is the time active requests have for finishing when
+	// the server is shut down.
+	shutdownTimeout = 5 * time.Second
+)
+
+var (
+	ErrNoListeners        = errors.New("neither an address nor a socket has been configured")
+	ErrTokenNotConfigured = errors.New("no API token has been configured, the endpoint is disabled")
+	ErrInvalidToken       = errors.New("the endpoint requires a valid API token")
 )
 
 // ServerConfig concludes properties that are configurable by the user.
+//
+// If Socket is set, the server listens on a Unix domain socket at that path.
+// Address may be left empty in order to disable the TCP listener.
+//
+// Requests whose headers exceed MaxHeaderBytes are rejected with 431. If it
+// is 0, http.DefaultMaxHeaderBytes is used.
+//
+// Token is the API token required for privileged endpoints like reading the
+// proxy logs. If no token has been configured, these endpoints are disabled.
+//
+// If DisableRequestLog is set, requests won't be logged at all.
 type ServerConfig struct {
-	Address string `json:"address"`
-	Logfile string `json:"logfile"`
+	Address           string `json:"address"`
+	Socket            string `json:"socket"`
+	Logfile           string `json:"logfile"`
+	MaxHeaderBytes    int    `json:"max_header_bytes"`
+	Token             string `json:"token"`
+	DisableRequestLog bool   `json:"disable_request_log"`
 }
 
 // Server is the actual HTTP server exposing a REST API. It will accept
-// requests on the specified TCP address and handles these requests using
-// the provided controller.Controller instance. The listening port has to
-// be secured against remote access.
+// requests on the specified TCP address and Unix socket and handles these
+// requests using the provided controller.Controller instance. The listening
+// port has to be secured against remote access, while the socket is only
+// accessible for its owner.
+//
+// All requests are logged using the logger set with SetLogger, unless the
+// request log has been disabled. Until then, the log lines are discarded.
+//
+// The contexts of all requests are derived from the server's context, which
+// is cancelled on shutdown. This ends streaming requests that would run
+// until the client disconnects otherwise.
 type Server struct {
 	config     ServerConfig
 	router     chi.Router
 	server     *http.Server
 	controller *controller.Controller
+	logger     log.Logger
+	ctx        context.Context
+	cancel     context.CancelFunc
 }
 
 // NewServer creates a new Server instance and initializes all routes.
 func NewServer(config ServerConfig, controller *controller.Controller) *Server {
 	s := Server{
 		config:     config,
-		router:     newRouter(),
 		controller: controller,
+		logger:     log.NewLogger(ioutil.Discard, log.ErrorLevel),
 	}
+	s.router = s.newRouter()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	s.server = &http.Server{
-		Addr:    s.config.Address,
-		Handler: s.router,
+		Addr:           s.config.Address,
+		Handler:        s.router,
+		MaxHeaderBytes: s.config.MaxHeaderBytes,
+		BaseContext: func(_ net.Listener) context.Context {
+			return s.ctx
+		},
 	}
 
 	s.mountRoutes()
@@ -57,26 +109,107 @@ func NewServer(config ServerConfig, controller *controller.Controller) *Server {
 	return &s
 }
 
-// Run makes the API server listen on the specified TCP address and accept
-// incoming requests. This function should be called in an extra goroutine
-// since Run is a blocking function.
+// SetLogger sets the logger used for logging requests. It has to be called
+// before running the server.
+func (s *Server) SetLogger(logger log.Logger) {
+	s.logger = logger
+}
+
+// Run makes the API server listen on the specified TCP address and Unix
+// socket and accept incoming requests. This function should be called in an
+// extra goroutine since Run is a blocking function.
 //
 // Unlike ListenAndServe from net/http, Run only returns real errors, meaning
-// that it won't return an error when shutting down.
+// that it won't return an error when shutting down. If serving on one of the
+// listeners fails, the server will be shut down entirely.
 func (s *Server) Run() error {
-	err := s.server.ListenAndServe()
-
-	if err != nil && err != http.ErrServerClosed {
+	listeners, err := s.listen()
+	if err != nil {
 		return err
 	}
 
-	return nil
+	errs := make(chan error, len(listeners))
+
+	for _, listener := range listeners {
+		go func(listener net.Listener) {
+			err := s.server.Serve(listener)
+
+			if err != nil && err != http.ErrServerClosed {
+				errs <- err
+				_ = s.Shutdown()
+				return
+			}
+
+			errs <- nil
+		}(listener)
+	}
+
+	var result error
+
+	for range listeners {
+		if err := <-errs; err != nil && result == nil {
+			result = err
+		}
+	}
+
+	return result
+}
+
+// listen creates a listener for the TCP address and the Unix socket if they
+// have been configured. A stale socket file from a previous run is removed.
+func (s *Server) listen() ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, 2)
+
+	closeAll := func() {
+		for _, l := range listeners {
+			_ = l.Close()
+		}
+	}
+
+	if s.config.Address != "" {
+		listener, err := net.Listen("tcp", s.config.Address)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	if s.config.Socket != "" {
+		if info, err := os.Stat(s.config.Socket); err == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(s.config.Socket)
+		}
+
+		listener, err := net.Listen("unix", s.config.Socket)
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+		listeners = append(listeners, listener)
+
+		if err := os.Chmod(s.config.Socket, socketMode); err != nil {
+			closeAll()
+			return nil, err
+		}
+	}
+
+	if len(listeners) == 0 {
+		return nil, ErrNoListeners
+	}
+
+	return listeners, nil
 }
 
-// Shutdown attempts a graceful shutdown. Active connections will not be
-// interrupted until the context used inside Shutdown expires.
+// Shutdown attempts a graceful shutdown. The contexts of active requests are
+// cancelled, so that streaming requests end immediately. All other requests
+// have until shutdownTimeout expires for finishing before their connections
+// are closed.
 func (s *Server) Shutdown() error {
-	err := s.server.Shutdown(context.Background())
+	s.cancel()
+
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	err := s.server.Shutdown(ctx)
 	_ = s.server.Close()
 
 	return err
@@ -0,0 +1,274 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package api provides an API server for controlling the Dice core.
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"github.com/dominikbraun/dice/controller"
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestServer_Run_socket starts an API server that only listens on a Unix
+// socket and performs a request over that socket.
+func TestServer_Run_socket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dice-api")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "dice.sock")
+
+	s := NewServer(ServerConfig{Socket: socket}, controller.New(nil, nil, nil))
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- s.Run()
+	}()
+
+	client := http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+		Timeout: time.Second,
+	}
+
+	var response *http.Response
+
+	// The server might not be listening yet, so try for a while.
+	for i := 0; i < 50; i++ {
+		if response, err = client.Post("http://dice/version", "application/json", nil); err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("got status %d, expected %d", response.StatusCode, http.StatusOK)
+	}
+
+	info, err := os.Stat(socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != socketMode {
+		t.Errorf("got socket mode %v, expected %v", info.Mode().Perm(), socketMode)
+	}
+
+	if err := s.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-errs; err != nil {
+		t.Error(err)
+	}
+}
+
+// TestServer_Run_noListeners checks if Run fails if neither an address nor
+// a socket has been configured.
+func TestServer_Run_noListeners(t *testing.T) {
+	s := NewServer(ServerConfig{}, controller.New(nil, nil, nil))
+
+	if err := s.Run(); err != ErrNoListeners {
+		t.Errorf("got error %v, expected %v", err, ErrNoListeners)
+	}
+}
+
+// TestServer_requestID checks if each API response carries a request ID in
+// the X-Request-Id header as well as in the response body, and if the IDs of
+// two requests differ.
+func TestServer_requestID(t *testing.T) {
+	s := NewServer(ServerConfig{}, controller.New(nil, nil, nil))
+	requestIDs := make(map[string]bool)
+
+	for i := 0; i < 2; i++ {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/version", nil)
+
+		s.router.ServeHTTP(recorder, request)
+
+		var response types.Response
+
+		if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		requestID := recorder.Header().Get(requestIDHeader)
+
+		if requestID == "" {
+			t.Fatal("response doesn't have a request ID")
+		}
+
+		if response.RequestID != requestID {
+			t.Errorf("got request ID %q in the body, expected %q", response.RequestID, requestID)
+		}
+
+		if requestIDs[requestID] {
+			t.Errorf("request ID %q has been returned twice", requestID)
+		}
+		requestIDs[requestID] = true
+	}
+}
+
+// TestServer_logRequest checks if API requests are logged using the Dice
+// logger set for the server, including the request ID.
+func TestServer_logRequest(t *testing.T) {
+	var output bytes.Buffer
+
+	s := NewServer(ServerConfig{}, controller.New(nil, nil, nil))
+	s.SetLogger(log.NewLogger(&output, log.InfoLevel))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/version", nil)
+
+	s.router.ServeHTTP(recorder, request)
+
+	line := output.String()
+	requestID := recorder.Header().Get(requestIDHeader)
+
+	if !strings.Contains(line, "POST /version") || !strings.Contains(line, " 200 ") {
+		t.Errorf("got log output %q, expected a line for POST /version with status 200", line)
+	}
+
+	if requestID == "" || !strings.Contains(line, requestID) {
+		t.Errorf("got log output %q, expected it to contain request ID %q", line, requestID)
+	}
+}
+
+// TestServer_logRequest_disabled checks if no requests are logged once the
+// request log has been disabled.
+func TestServer_logRequest_disabled(t *testing.T) {
+	var output bytes.Buffer
+
+	s := NewServer(ServerConfig{DisableRequestLog: true}, controller.New(nil, nil, nil))
+	s.SetLogger(log.NewLogger(&output, log.InfoLevel))
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/version", nil)
+
+	s.router.ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("got status %d, expected %d", recorder.Code, http.StatusOK)
+	}
+
+	if output.Len() != 0 {
+		t.Errorf("got log output %q, expected no output", output.String())
+	}
+}
+
+// TestServer_requireToken checks if the proxy logs endpoint refuses requests
+// without a valid API token, as well as all requests if no token has been
+// configured. Valid requests are passed to the controller, which rejects the
+// invalid request body.
+func TestServer_requireToken(t *testing.T) {
+	tests := []struct {
+		token    string
+		header   string
+		expected int
+	}{
+		{token: "", header: "", expected: http.StatusUnauthorized},
+		{token: "", header: "Bearer ", expected: http.StatusUnauthorized},
+		{token: "secret", header: "", expected: http.StatusUnauthorized},
+		{token: "secret", header: "Bearer wrong", expected: http.StatusUnauthorized},
+		{token: "secret", header: "Bearer secret", expected: http.StatusUnprocessableEntity},
+	}
+
+	for _, test := range tests {
+		s := NewServer(ServerConfig{Token: test.token}, controller.New(nil, nil, nil))
+
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(http.MethodPost, "/v1/admin/logs", strings.NewReader("{"))
+
+		if test.header != "" {
+			request.Header.Set("Authorization", test.header)
+		}
+
+		s.router.ServeHTTP(recorder, request)
+
+		if recorder.Code != test.expected {
+			t.Errorf("token %q, header %q: got status %d, expected %d", test.token, test.header, recorder.Code, test.expected)
+		}
+	}
+}
+
+// TestServer_Shutdown_streaming checks if shutting down the server ends a
+// streaming request instead of waiting for the client to disconnect.
+func TestServer_Shutdown_streaming(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(ServerConfig{}, controller.New(nil, nil, nil))
+	streaming := make(chan struct{})
+
+	s.router.Post("/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		close(streaming)
+		<-r.Context().Done()
+	})
+
+	go func() {
+		_ = s.server.Serve(listener)
+	}()
+
+	go func() {
+		response, err := http.Post("http://"+listener.Addr().String()+"/stream", "text/plain", nil)
+		if err == nil {
+			_, _ = ioutil.ReadAll(response.Body)
+			_ = response.Body.Close()
+		}
+	}()
+
+	<-streaming
+
+	done := make(chan error, 1)
+	go func() {
+		done <- s.Shutdown()
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Error(err)
+		}
+	case <-time.After(shutdownTimeout / 2):
+		t.Fatal("shutdown is blocked by the streaming request")
+	}
+}
@@ -0,0 +1,203 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit provides an append-only log of management actions.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+)
+
+// Action describes a management action performed on an entity.
+type Action string
+
+const (
+	CreateAction         Action = "create"
+	RemoveAction         Action = "remove"
+	AttachAction         Action = "attach"
+	DetachAction         Action = "detach"
+	CordonAction         Action = "cordon"
+	UncordonAction       Action = "uncordon"
+	EnableAction         Action = "enable"
+	DisableAction        Action = "disable"
+	UpdateAction         Action = "update"
+	SetURLAction         Action = "set_url"
+	SetHealthCheckAction Action = "set_healthcheck"
+	SetMaintenanceAction Action = "set_maintenance"
+	SetCORSAction        Action = "set_cors"
+	SetAuthAction        Action = "set_auth"
+	SetAllowListAction   Action = "set_allow_list"
+	SetStickyAction      Action = "set_sticky"
+	SetOutliersAction    Action = "set_outliers"
+	SetMirrorAction      Action = "set_mirror"
+	SetAccessLogAction   Action = "set_access_log"
+	SetWeightsAction     Action = "set_weights"
+	ScheduleAction       Action = "schedule"
+	PruneAction          Action = "prune"
+)
+
+// EntityType describes the type of the entity affected by an action.
+type EntityType string
+
+const (
+	NodeEntity     EntityType = "node"
+	ServiceEntity  EntityType = "service"
+	InstanceEntity EntityType = "instance"
+)
+
+// bufferSize is the number of entries that can be queued for writing. If
+// the buffer is full, new entries will be dropped instead of blocking.
+const bufferSize = 256
+
+// Actor identifies the caller that has performed an action. For actions
+// performed through the API, this is the API request and its sender.
+type Actor struct {
+	RequestID     string `json:"request_id"`
+	RemoteAddress string `json:"remote_address"`
+}
+
+// Entry represents a single management action in the audit log.
+type Entry struct {
+	Timestamp  time.Time  `json:"timestamp"`
+	Action     Action     `json:"action"`
+	EntityType EntityType `json:"entity_type"`
+	EntityID   string     `json:"entity_id"`
+	EntityName string     `json:"entity_name"`
+	Actor      Actor      `json:"actor"`
+}
+
+// Log is an append-only audit log that stores each entry as a JSON line in
+// a file. Entries are written by a background goroutine, so that recording
+// an entry never blocks the recording operation.
+//
+// Operations that are still running when the Log is closed may record
+// further entries. These entries are dropped instead of being written.
+type Log struct {
+	path    string
+	file    *os.File
+	entries chan Entry
+	done    chan bool
+	closed  bool
+	mutex   sync.RWMutex
+}
+
+// New creates a new Log instance that appends to the file at the given path.
+// The file will be created if it doesn't exist.
+func New(path string) (*Log, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	l := Log{
+		path:    path,
+		file:    file,
+		entries: make(chan Entry, bufferSize),
+		done:    make(chan bool),
+	}
+
+	go l.write()
+
+	return &l, nil
+}
+
+// Record queues an entry for writing. The timestamp will be set if it has
+// not been set yet. If the write buffer is full or the Log has been closed,
+// the entry will be dropped.
+func (l *Log) Record(entry Entry) {
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+
+	if l.closed {
+		return
+	}
+
+	select {
+	case l.entries <- entry:
+	default:
+	}
+}
+
+// Entries returns the most recent entries from the audit log, the latest
+// entry coming last. If limit is 0, all entries will be returned. Entries
+// that are still queued for writing are not included.
+func (l *Log) Entries(limit int) ([]Entry, error) {
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	entries := make([]Entry, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		var entry Entry
+
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if limit > 0 && len(entries) > limit {
+		entries = entries[len(entries)-limit:]
+	}
+
+	return entries, nil
+}
+
+// Close writes all queued entries and closes the audit log file. Entries
+// recorded after calling Close are dropped, and closing the Log again is a
+// no-op.
+func (l *Log) Close() error {
+	l.mutex.Lock()
+
+	if l.closed {
+		l.mutex.Unlock()
+		return nil
+	}
+
+	l.closed = true
+	close(l.entries)
+	l.mutex.Unlock()
+
+	<-l.done
+
+	return l.file.Close()
+}
+
+// write writes all queued entries to the audit log file until the entry
+// channel is closed. Entries that can't be written are lost.
+func (l *Log) write() {
+	encoder := json.NewEncoder(l.file)
+
+	for entry := range l.entries {
+		_ = encoder.Encode(entry)
+	}
+
+	l.done <- true
+}
@@ -0,0 +1,80 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package audit provides an append-only log of management actions.
+package audit
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// TestLog_Close tests recording entries while and after closing the Log.
+// Recording must neither panic nor block, and only the entries recorded
+// before closing the Log may be written.
+func TestLog_Close(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dice-audit-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	l, err := New(filepath.Join(dir, "audit.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Record(Entry{Action: CreateAction, EntityType: NodeEntity, EntityID: "n1"})
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			l.Record(Entry{Action: AttachAction, EntityType: NodeEntity, EntityID: "n1"})
+		}()
+	}
+
+	if err := l.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	wg.Wait()
+
+	l.Record(Entry{Action: RemoveAction, EntityType: NodeEntity, EntityID: "n1"})
+
+	if err := l.Close(); err != nil {
+		t.Errorf("got error %v when closing the log again, expected none", err)
+	}
+
+	entries, err := l.Entries(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) == 0 || entries[0].Action != CreateAction {
+		t.Fatalf("got entries %v, expected the first one to be %s", entries, CreateAction)
+	}
+
+	for _, e := range entries {
+		if e.Action == RemoveAction {
+			t.Errorf("got entry %v, expected entries recorded after closing to be dropped", e)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/spf13/cobra"
+)
+
+// auditCmd creates and implements the `audit` command. The audit command
+// itself does not have any functionality.
+func (c *CLI) auditCmd() *cobra.Command {
+	auditCmd := cobra.Command{
+		Use:   "audit",
+		Short: `Inspect the audit log`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &auditCmd
+}
+
+// auditLogCmd creates and implements the `audit log` command.
+func (c *CLI) auditLogCmd() *cobra.Command {
+	var options types.AuditLogOptions
+
+	auditLogCmd := cobra.Command{
+		Use:   "log",
+		Short: `Print recent management actions`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/admin/audit/log"
+			var auditLogResponse types.AuditLogResponse
+
+			if err := c.client.POST(route, options, &auditLogResponse); err != nil {
+				return err
+			}
+
+			if !auditLogResponse.Success {
+				return responseError(auditLogResponse.Response)
+			}
+
+			for _, e := range auditLogResponse.Data {
+				fmt.Printf("%v\n", e)
+			}
+
+			return nil
+		},
+	}
+
+	auditLogCmd.Flags().IntVarP(&options.Limit, "limit", "n", 20, `limit the number of entries`)
+
+	return &auditLogCmd
+}
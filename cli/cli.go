@@ -18,6 +18,8 @@ package cli
 import (
 	"github.com/dominikbraun/dice/client"
 	"github.com/spf13/cobra"
+	"io"
+	"os"
 )
 
 // CLI represents the Dice command line interface. It includes all commands
@@ -26,12 +28,15 @@ import (
 type CLI struct {
 	client  *client.Client
 	rootCmd *cobra.Command
+	output  string
+	errOut  io.Writer
 }
 
 // New creates a new CLI instance that uses the provided HTTP client.
 func New(client *client.Client) *CLI {
 	c := CLI{
 		client: client,
+		errOut: os.Stderr,
 	}
 	c.buildCommands()
 
@@ -47,8 +52,12 @@ func (c *CLI) buildCommands() {
 	nodeCmd.AddCommand(c.nodeCreateCmd())
 	nodeCmd.AddCommand(c.nodeAttachCmd())
 	nodeCmd.AddCommand(c.nodeDetachCmd())
+	nodeCmd.AddCommand(c.nodeCordonCmd())
+	nodeCmd.AddCommand(c.nodeUncordonCmd())
+	nodeCmd.AddCommand(c.nodeDrainCmd())
 	nodeCmd.AddCommand(c.nodeRemoveCmd())
 	nodeCmd.AddCommand(c.nodeInfoCmd())
+	nodeCmd.AddCommand(c.nodeEventsCmd())
 	nodeCmd.AddCommand(c.nodeListCmd())
 
 	serviceCmd := c.serviceCmd()
@@ -57,35 +66,128 @@ func (c *CLI) buildCommands() {
 	serviceCmd.AddCommand(c.serviceEnableCmd())
 	serviceCmd.AddCommand(c.serviceDisableCmd())
 	serviceCmd.AddCommand(c.serviceUpdateCmd())
+	serviceCmd.AddCommand(c.servicePatchCmd())
 	serviceCmd.AddCommand(c.serviceInfoCmd())
 	serviceCmd.AddCommand(c.serviceListCmd())
+	serviceCmd.AddCommand(c.serviceHealthCmd())
+	serviceCmd.AddCommand(c.serviceMetricsCmd())
 	serviceCmd.AddCommand(c.serviceURLCmd())
+	serviceCmd.AddCommand(c.serviceSetDefaultCmd())
+	serviceCmd.AddCommand(c.serviceReplaceCmd())
+	serviceCmd.AddCommand(c.serviceScheduleCmd())
+
+	serviceHealthCheckCmd := c.serviceHealthCheckCmd()
+
+	serviceHealthCheckCmd.AddCommand(c.serviceHealthCheckSetCmd())
+	serviceCmd.AddCommand(serviceHealthCheckCmd)
+
+	serviceMaintenanceCmd := c.serviceMaintenanceCmd()
+
+	serviceMaintenanceCmd.AddCommand(c.serviceMaintenanceOnCmd())
+	serviceMaintenanceCmd.AddCommand(c.serviceMaintenanceOffCmd())
+	serviceCmd.AddCommand(serviceMaintenanceCmd)
+
+	serviceStickyCmd := c.serviceStickyCmd()
+
+	serviceStickyCmd.AddCommand(c.serviceStickyOnCmd())
+	serviceStickyCmd.AddCommand(c.serviceStickyOffCmd())
+	serviceCmd.AddCommand(serviceStickyCmd)
+
+	serviceOutliersCmd := c.serviceOutliersCmd()
+
+	serviceOutliersCmd.AddCommand(c.serviceOutliersSetCmd())
+	serviceCmd.AddCommand(serviceOutliersCmd)
+
+	serviceMirrorCmd := c.serviceMirrorCmd()
+
+	serviceMirrorCmd.AddCommand(c.serviceMirrorSetCmd())
+	serviceCmd.AddCommand(serviceMirrorCmd)
+
+	serviceAccessLogCmd := c.serviceAccessLogCmd()
+
+	serviceAccessLogCmd.AddCommand(c.serviceAccessLogSetCmd())
+	serviceCmd.AddCommand(serviceAccessLogCmd)
+
+	serviceCORSCmd := c.serviceCORSCmd()
+
+	serviceCORSCmd.AddCommand(c.serviceCORSSetCmd())
+	serviceCORSCmd.AddCommand(c.serviceCORSClearCmd())
+	serviceCmd.AddCommand(serviceCORSCmd)
+
+	serviceAuthCmd := c.serviceAuthCmd()
+
+	serviceAuthCmd.AddCommand(c.serviceAuthSetCmd())
+	serviceAuthCmd.AddCommand(c.serviceAuthClearCmd())
+	serviceCmd.AddCommand(serviceAuthCmd)
+
+	serviceAllowListCmd := c.serviceAllowListCmd()
+
+	serviceAllowListCmd.AddCommand(c.serviceAllowListSetCmd())
+	serviceAllowListCmd.AddCommand(c.serviceAllowListClearCmd())
+	serviceCmd.AddCommand(serviceAllowListCmd)
 
 	instanceCmd := c.instanceCmd()
 
 	instanceCmd.AddCommand(c.instanceCreateCmd())
+	instanceCmd.AddCommand(c.instanceCloneCmd())
 	instanceCmd.AddCommand(c.instanceAttachCmd())
 	instanceCmd.AddCommand(c.instanceDetachCmd())
 	instanceCmd.AddCommand(c.instanceRemoveCmd())
 	instanceCmd.AddCommand(c.instanceInfoCmd())
+	instanceCmd.AddCommand(c.instanceDescribeCmd())
+	instanceCmd.AddCommand(c.instanceStatsCmd())
+	instanceCmd.AddCommand(c.instanceEventsCmd())
 	instanceCmd.AddCommand(c.instanceListCmd())
 
+	routeCmd := c.routeCmd()
+
+	routeCmd.AddCommand(c.routeListCmd())
+	routeCmd.AddCommand(c.routeTestCmd())
+	routeCmd.AddCommand(c.routeWeightsCmd())
+
 	configCmd := c.configCmd()
 
+	configCmd.AddCommand(c.configPrintCmd())
+	configCmd.AddCommand(c.configKeysCmd())
 	configCmd.AddCommand(c.configReloadCmd())
 
+	healthCheckCmd := c.healthCheckCmd()
+
+	healthCheckCmd.AddCommand(c.healthCheckRunCmd())
+
+	auditCmd := c.auditCmd()
+
+	auditCmd.AddCommand(c.auditLogCmd())
+
 	diceCmd := c.diceCmd()
 
 	diceCmd.AddCommand(nodeCmd)
 	diceCmd.AddCommand(serviceCmd)
 	diceCmd.AddCommand(instanceCmd)
+	diceCmd.AddCommand(routeCmd)
 	diceCmd.AddCommand(configCmd)
+	diceCmd.AddCommand(healthCheckCmd)
+	diceCmd.AddCommand(auditCmd)
+	diceCmd.AddCommand(c.logsCmd())
+	diceCmd.AddCommand(c.pruneCmd())
+	diceCmd.AddCommand(c.versionCmd())
+	diceCmd.AddCommand(c.completionCmd())
+	diceCmd.AddCommand(c.completeInstancesCmd())
 
 	c.rootCmd = diceCmd
 }
 
 // Execute runs the CLI. This means that the command line arguments used
 // for running the binary get parsed and processed by cobra.
+//
+// If a command fails, the error is printed and returned. Use ExitCode for
+// determining the corresponding exit code.
 func (c *CLI) Execute() error {
-	return c.rootCmd.Execute()
+	err := c.rootCmd.Execute()
+
+	if err != nil {
+		printError(c.errOut, err, c.output)
+	}
+
+	return err
 }
@@ -0,0 +1,104 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ErrUnsupportedShell = errors.New("shell is not supported, use bash, zsh or powershell")
+)
+
+// bashCompletionFunction is invoked by the generated bash completion if no
+// static completions are available. For commands expecting an instance, it
+// suggests the IDs and names of all instances using `dice __instances`.
+const bashCompletionFunction = `
+__dice_custom_func() {
+    case ${last_command} in
+        dice_instance_attach | dice_instance_detach | dice_instance_remove | \
+        dice_instance_info | dice_instance_describe | dice_instance_stats)
+            local instances
+            instances=$(dice __instances --timeout 2s 2>/dev/null)
+            COMPREPLY=( $(compgen -W "${instances}" -- "${cur}") )
+            ;;
+    esac
+}
+`
+
+// completionCmd creates and implements the `completion` command, which
+// prints the completion script for the given shell.
+func (c *CLI) completionCmd() *cobra.Command {
+	completionCmd := cobra.Command{
+		Use:       "completion <bash|zsh|powershell>",
+		Short:     `Print the shell completion script`,
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"bash", "zsh", "powershell"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root, w := cmd.Root(), cmd.OutOrStdout()
+
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletion(w)
+			case "zsh":
+				return root.GenZshCompletion(w)
+			case "powershell":
+				return root.GenPowerShellCompletion(w)
+			default:
+				return fmt.Errorf("%w: %s", ErrUnsupportedShell, args[0])
+			}
+		},
+	}
+
+	return &completionCmd
+}
+
+// completeInstancesCmd creates and implements the hidden `__instances`
+// command used by the bash completion. It prints the IDs and names of all
+// instances. If they can't be retrieved, nothing is printed so that the
+// completion doesn't break if the Dice daemon is unreachable.
+func (c *CLI) completeInstancesCmd() *cobra.Command {
+	completeInstancesCmd := cobra.Command{
+		Use:    "__instances",
+		Hidden: true,
+		Args:   cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/instances/list"
+			options := types.InstanceListOptions{All: true}
+
+			var instanceListResponse types.InstanceListResponse
+
+			if err := c.client.POST(route, options, &instanceListResponse); err != nil {
+				return nil
+			}
+
+			for _, i := range instanceListResponse.Data {
+				_, _ = fmt.Fprintln(cmd.OutOrStdout(), i.ID)
+
+				if i.Name != "" {
+					_, _ = fmt.Fprintln(cmd.OutOrStdout(), i.Name)
+				}
+			}
+
+			return nil
+		},
+	}
+
+	return &completeInstancesCmd
+}
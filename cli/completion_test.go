@@ -0,0 +1,73 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"bytes"
+	"github.com/dominikbraun/dice/client"
+	"net/http/httptest"
+	"testing"
+)
+
+// executeTestCLI runs the CLI with the given arguments and returns the
+// error along with everything printed to the output.
+func executeTestCLI(t *testing.T, args ...string) (string, error) {
+	diceClient, err := client.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out bytes.Buffer
+
+	c := New(diceClient)
+	c.errOut = &out
+	c.rootCmd.SetOutput(&out)
+	c.rootCmd.SetArgs(args)
+
+	err = c.Execute()
+
+	return out.String(), err
+}
+
+// TestCLI_completionCmd_bash checks if the completion command prints a
+// completion script for bash.
+func TestCLI_completionCmd_bash(t *testing.T) {
+	out, err := executeTestCLI(t, "completion", "bash")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(out) == 0 {
+		t.Errorf("expected bash completion script, got empty output")
+	}
+}
+
+// TestCLI_completeInstancesCmd_unreachable checks if the instance completion
+// silently prints nothing if the daemon can't be reached.
+func TestCLI_completeInstancesCmd_unreachable(t *testing.T) {
+	server := httptest.NewServer(nil)
+	address := server.URL
+	server.Close()
+
+	out, err := executeTestCLI(t, "__instances", "--address", address)
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	if out != "" {
+		t.Errorf("expected no suggestions, got %q", out)
+	}
+}
@@ -16,7 +16,8 @@
 package cli
 
 import (
-	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/config"
 	"github.com/dominikbraun/dice/types"
 	"github.com/spf13/cobra"
 )
@@ -52,7 +53,7 @@ func (c *CLI) configReloadCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -61,3 +62,51 @@ func (c *CLI) configReloadCmd() *cobra.Command {
 
 	return &configReloadCmd
 }
+
+// configPrintCmd creates and implements the `config print` command.
+func (c *CLI) configPrintCmd() *cobra.Command {
+	configPrintCmd := cobra.Command{
+		Use:   "print",
+		Short: `Print the effective configuration of the daemon`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/config/print"
+			var configResponse types.ConfigResponse
+
+			if err := c.client.POST(route, nil, &configResponse); err != nil {
+				return err
+			}
+
+			if !configResponse.Success {
+				return responseError(configResponse.Response)
+			}
+
+			for _, e := range configResponse.Data {
+				fmt.Printf("%v\n", e)
+			}
+
+			return nil
+		},
+	}
+
+	return &configPrintCmd
+}
+
+// configKeysCmd creates and implements the `config keys` command. Since the
+// recognized keys are compiled into Dice, no request to the daemon is sent.
+func (c *CLI) configKeysCmd() *cobra.Command {
+	configKeysCmd := cobra.Command{
+		Use:   "keys",
+		Short: `List all recognized configuration keys and their defaults`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			for _, k := range config.Keys() {
+				fmt.Printf("%v\n", k)
+			}
+
+			return nil
+		},
+	}
+
+	return &configKeysCmd
+}
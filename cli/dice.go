@@ -16,7 +16,9 @@
 package cli
 
 import (
+	"github.com/dominikbraun/dice/version"
 	"github.com/spf13/cobra"
+	"time"
 )
 
 // diceCmd creates and implements the `dice` command, which is also the
@@ -24,16 +26,27 @@ import (
 //
 // Each time the  `dice` command is executed, the --address option is being
 // parsed. If an address has been specified, the client's target address
-// will be overridden by that address.
+// will be overridden by that address before any request is sent. Without
+// --address, the address is read from DICE_ADDRESS or the defaults. The
+// same applies to --retries and --timeout and the configured values for
+// retries and timeouts.
+//
+// Errors are printed by CLI.Execute instead of cobra, so that they can be
+// printed as JSON if --output json has been specified.
 func (c *CLI) diceCmd() *cobra.Command {
 	var address string
+	var retries int
+	var timeout time.Duration
 
 	diceCmd := cobra.Command{
-		Use:          "dice",
-		Short:        `Simple load balancing for non-microservice infrastructures`,
-		Long:         `🎲 Dice is an ergonomic, flexible, easy to use load balancer designed for non-microservice infrastructures.`,
-		Version:      "0.0.0",
-		SilenceUsage: true,
+		Use:           "dice",
+		Short:         `Simple load balancing for non-microservice infrastructures`,
+		Long:          `🎲 Dice is an ergonomic, flexible, easy to use load balancer designed for non-microservice infrastructures.`,
+		Version:       version.Version,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		// Instance references are completed dynamically in bash.
+		BashCompletionFunction: bashCompletionFunction,
 		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 			// The API connection data from the environment variables can be
 			// overridden via CLI flags. If the address is specified, force
@@ -41,6 +54,15 @@ func (c *CLI) diceCmd() *cobra.Command {
 			if address != "" {
 				c.client.OverrideAddress(address)
 			}
+			// Subcommands may define their own flags with the same names, for
+			// example `service healthcheck set --timeout`. Therefore, only the
+			// root command's flags are checked.
+			if cmd.Root().PersistentFlags().Changed("retries") {
+				c.client.SetRetries(retries)
+			}
+			if cmd.Root().PersistentFlags().Changed("timeout") {
+				c.client.SetTimeout(timeout)
+			}
 			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
@@ -49,7 +71,10 @@ func (c *CLI) diceCmd() *cobra.Command {
 		},
 	}
 
-	diceCmd.PersistentFlags().StringVar(&address, "address", "", `specify the address of the Dice API`)
+	diceCmd.PersistentFlags().StringVar(&address, "address", "", `specify the address of the Dice API, overrides DICE_ADDRESS`)
+	diceCmd.PersistentFlags().IntVar(&retries, "retries", 0, `retry failed requests up to this number of times`)
+	diceCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, `abort requests after this duration, e.g. 10s`)
+	diceCmd.PersistentFlags().StringVar(&c.output, "output", "text", `print errors as text or json`)
 
 	return &diceCmd
 }
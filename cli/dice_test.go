@@ -0,0 +1,93 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/client"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+)
+
+// newAddressTestServer creates a stub Dice daemon that counts the requests
+// for attaching a node.
+func newAddressTestServer(requests *int32) *httptest.Server {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v1/nodes/n1/attach", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		_ = json.NewEncoder(w).Encode(types.Response{Success: true})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// TestCLI_diceCmd_address tests if the Dice daemon is targeted using the
+// DICE_ADDRESS environment variable and if the --address flag takes
+// precedence over that variable.
+func TestCLI_diceCmd_address(t *testing.T) {
+	var envRequests, flagRequests int32
+
+	envServer := newAddressTestServer(&envRequests)
+	defer envServer.Close()
+
+	flagServer := newAddressTestServer(&flagRequests)
+	defer flagServer.Close()
+
+	previous, wasSet := os.LookupEnv("DICE_ADDRESS")
+	defer func() {
+		if wasSet {
+			_ = os.Setenv("DICE_ADDRESS", previous)
+		} else {
+			_ = os.Unsetenv("DICE_ADDRESS")
+		}
+	}()
+
+	if err := os.Setenv("DICE_ADDRESS", envServer.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args         []string
+		envRequests  int32
+		flagRequests int32
+	}{
+		{args: []string{"node", "attach", "n1"}, envRequests: 1, flagRequests: 0},
+		{args: []string{"node", "attach", "n1", "--address", flagServer.URL}, envRequests: 1, flagRequests: 1},
+	}
+
+	for _, test := range tests {
+		diceClient, err := client.New()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		c := New(diceClient)
+		c.rootCmd.SetArgs(test.args)
+
+		if err := c.Execute(); err != nil {
+			t.Errorf("%v: unexpected error: %v", test.args, err)
+		}
+
+		if env, flag := atomic.LoadInt32(&envRequests), atomic.LoadInt32(&flagRequests); env != test.envRequests || flag != test.flagRequests {
+			t.Errorf("%v: expected %d and %d requests, got %d and %d", test.args, test.envRequests, test.flagRequests, env, flag)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/client"
+	"github.com/dominikbraun/dice/types"
+	"io"
+)
+
+// Exit codes returned by the CLI. Each exit code represents a category of
+// errors, so that scripts can react to particular errors.
+const (
+	ExitSuccess    = 0
+	ExitGeneric    = 1
+	ExitNotFound   = 4
+	ExitConnection = 7
+	ExitConflict   = 9
+)
+
+// jsonOutput is the value of the --output flag for printing JSON.
+const jsonOutput = "json"
+
+// errorOutput is the JSON representation of an error printed by the CLI.
+type errorOutput struct {
+	Error    string              `json:"error"`
+	Category types.ErrorCategory `json:"category,omitempty"`
+	ExitCode int                 `json:"exit_code"`
+}
+
+// responseError creates an error from an unsuccessful API response. The
+// error keeps the category of the response, see ExitCode.
+func responseError(response types.Response) error {
+	return response.Err()
+}
+
+// ExitCode returns the process exit code for an error returned by Execute.
+// Errors that can't be categorized result in ExitGeneric.
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitSuccess
+	}
+
+	if errors.Is(err, client.ErrDaemonUnreachable) {
+		return ExitConnection
+	}
+
+	switch types.CategoryOf(err) {
+	case types.NotFoundError:
+		return ExitNotFound
+	case types.ConflictError:
+		return ExitConflict
+	default:
+		return ExitGeneric
+	}
+}
+
+// printError prints an error to w. If the JSON output has been requested,
+// the error is printed as JSON object including its category and exit code.
+func printError(w io.Writer, err error, output string) {
+	if output != jsonOutput {
+		_, _ = fmt.Fprintf(w, "Error: %s\n", err.Error())
+		return
+	}
+
+	errorOutput := errorOutput{
+		Error:    err.Error(),
+		Category: types.CategoryOf(err),
+		ExitCode: ExitCode(err),
+	}
+
+	_ = json.NewEncoder(w).Encode(errorOutput)
+}
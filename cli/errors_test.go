@@ -0,0 +1,136 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/dominikbraun/dice/client"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newTestServer creates a stub Dice daemon. It doesn't provide /version, so
+// that the client falls back to v1. Creating a node fails with a conflict,
+// attaching node `missing` fails with not_found and detaching any node fails
+// with an uncategorized error.
+func newTestServer() *httptest.Server {
+	mux := http.NewServeMux()
+
+	respondError := func(w http.ResponseWriter, category types.ErrorCategory, message string) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		_ = json.NewEncoder(w).Encode(types.Response{
+			Success:  false,
+			Message:  message,
+			Category: category,
+		})
+	}
+
+	mux.HandleFunc("/v1/nodes/create", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, types.ConflictError, "node already exists")
+	})
+	mux.HandleFunc("/v1/nodes/missing/attach", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, types.NotFoundError, "node could not be found")
+	})
+	mux.HandleFunc("/v1/nodes/n1/detach", func(w http.ResponseWriter, r *http.Request) {
+		respondError(w, "", "node is not attached")
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// runTestCLI runs the CLI with the given arguments against address and
+// returns the exit code along with everything printed to the error output.
+func runTestCLI(t *testing.T, address string, args ...string) (int, string) {
+	diceClient, err := client.New()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var errOut bytes.Buffer
+
+	c := New(diceClient)
+	c.errOut = &errOut
+	c.rootCmd.SetArgs(append(args, "--address", address))
+
+	return ExitCode(c.Execute()), errOut.String()
+}
+
+// TestCLI_Execute_exitCodes checks if failed commands result in the exit
+// code of the respective error category.
+func TestCLI_Execute_exitCodes(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	tests := []struct {
+		args     []string
+		exitCode int
+	}{
+		{args: []string{"node", "create", "n1"}, exitCode: ExitConflict},
+		{args: []string{"node", "attach", "missing"}, exitCode: ExitNotFound},
+		{args: []string{"node", "detach", "n1"}, exitCode: ExitGeneric},
+	}
+
+	for _, test := range tests {
+		exitCode, errOut := runTestCLI(t, server.URL, test.args...)
+
+		if exitCode != test.exitCode {
+			t.Errorf("%v: expected exit code %d, got %d", test.args, test.exitCode, exitCode)
+		}
+		if !strings.HasPrefix(errOut, "Error: ") {
+			t.Errorf("%v: expected error to be printed, got %q", test.args, errOut)
+		}
+	}
+}
+
+// TestCLI_Execute_unreachable checks if a daemon that can't be reached
+// results in ExitConnection.
+func TestCLI_Execute_unreachable(t *testing.T) {
+	server := newTestServer()
+	address := server.URL
+	server.Close()
+
+	exitCode, _ := runTestCLI(t, address, "node", "attach", "n1")
+
+	if exitCode != ExitConnection {
+		t.Errorf("expected exit code %d, got %d", ExitConnection, exitCode)
+	}
+}
+
+// TestCLI_Execute_jsonOutput checks if errors are printed as JSON objects
+// if --output json has been specified.
+func TestCLI_Execute_jsonOutput(t *testing.T) {
+	server := newTestServer()
+	defer server.Close()
+
+	exitCode, errOut := runTestCLI(t, server.URL, "node", "attach", "missing", "--output", "json")
+
+	var output errorOutput
+
+	if err := json.Unmarshal([]byte(errOut), &output); err != nil {
+		t.Fatalf("expected JSON error output, got %q", errOut)
+	}
+
+	if output.ExitCode != exitCode || output.Category != types.NotFoundError {
+		t.Errorf("unexpected error output: %+v", output)
+	}
+	if output.Error != "node could not be found" {
+		t.Errorf("expected error message, got %q", output.Error)
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/spf13/cobra"
+)
+
+// healthCheckCmd creates and implements the `healthcheck` command. The
+// healthcheck command itself does not have any functionality.
+func (c *CLI) healthCheckCmd() *cobra.Command {
+	healthCheckCmd := cobra.Command{
+		Use:   "healthcheck",
+		Short: `Manage health checks`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &healthCheckCmd
+}
+
+// healthCheckRunCmd creates and implements the `healthcheck run` command.
+// The --check-timeout flag bounds the check on the daemon side, while the
+// request itself is still bound to the global --timeout flag.
+func (c *CLI) healthCheckRunCmd() *cobra.Command {
+	var options types.HealthCheckRunOptions
+
+	healthCheckRunCmd := cobra.Command{
+		Use:   "run",
+		Short: `Run a health check immediately`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/admin/healthcheck/run"
+			var healthCheckResponse types.HealthCheckResponse
+
+			if err := c.client.POST(route, options, &healthCheckResponse); err != nil {
+				return err
+			}
+
+			if !healthCheckResponse.Success {
+				return responseError(healthCheckResponse.Response)
+			}
+
+			for _, h := range healthCheckResponse.Data {
+				fmt.Printf("%v\n", h)
+			}
+
+			return nil
+		},
+	}
+
+	healthCheckRunCmd.Flags().DurationVar(&options.Timeout, "check-timeout", 0, `abort the health check after this duration, e.g. 10s`)
+
+	return &healthCheckRunCmd
+}
@@ -16,7 +16,6 @@
 package cli
 
 import (
-	"errors"
 	"fmt"
 	"github.com/dominikbraun/dice/types"
 	"github.com/spf13/cobra"
@@ -38,15 +37,29 @@ func (c *CLI) instanceCmd() *cobra.Command {
 }
 
 // instanceCreateCmd creates and implements the `instance create` command.
+// If --selector or --all-nodes has been specified, an instance is created on
+// each matching node. In that case, the node is omitted and the URL is a
+// template like {node}:8080, where {node} is replaced with the node name.
 func (c *CLI) instanceCreateCmd() *cobra.Command {
 	var options types.InstanceCreateOptions
+	var nodeSelector types.NodeSelectOptions
 
 	instanceCreateCmd := cobra.Command{
-		Use:   "create <SERVICE> <NODE> <URL>",
+		Use:   "create <SERVICE> [NODE] <URL>",
 		Short: `Create a new service instance`,
-		Args:  cobra.ExactArgs(3),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if nodeSelector.Selector != "" || nodeSelector.All {
+				return cobra.ExactArgs(2)(cmd, args)
+			}
+			return cobra.ExactArgs(3)(cmd, args)
+		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			serviceRef := args[0]
+
+			if nodeSelector.Selector != "" || nodeSelector.All {
+				return c.createInstances(serviceRef, args[1], nodeSelector, options)
+			}
+
 			nodeRef := args[1]
 			instanceURL := args[2]
 			route := "/instances/create"
@@ -65,7 +78,7 @@ func (c *CLI) instanceCreateCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -75,12 +88,92 @@ func (c *CLI) instanceCreateCmd() *cobra.Command {
 	instanceCreateCmd.Flags().StringVarP(&options.Name, "name", "n", "", `assign a name to the instance`)
 	instanceCreateCmd.Flags().StringVarP(&options.Version, "version", "v", "", `specify the deployed service version`)
 	instanceCreateCmd.Flags().BoolVarP(&options.Attach, "attach", "a", false, `immediately attach the instance`)
+	instanceCreateCmd.Flags().StringVar(&options.IDKey, "id-key", "", `derive the instance ID from the given key`)
+	instanceCreateCmd.Flags().IntVar(&options.MaxConnections, "max-connections", 0, `limit the concurrent requests to the instance`)
+	instanceCreateCmd.Flags().BoolVar(&options.Standby, "standby", false, `only use the instance if no other instance is available`)
+	instanceCreateCmd.Flags().StringVarP(&nodeSelector.Selector, "selector", "s", "", `create an instance on all nodes whose name starts with the selector`)
+	instanceCreateCmd.Flags().BoolVar(&nodeSelector.All, "all-nodes", false, `create an instance on all nodes`)
 
 	return &instanceCreateCmd
 }
 
+// instanceCloneCmd creates and implements the `instance clone` command. The
+// new instance gets the configuration of the source instance, but is deployed
+// to the given node and reachable under the given URL.
+func (c *CLI) instanceCloneCmd() *cobra.Command {
+	instanceCloneCmd := cobra.Command{
+		Use:   "clone <ID|NAME> <NODE> <URL>",
+		Short: `Create a new instance with the configuration of an existing one`,
+		Args:  cobra.ExactArgs(3),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := args[0]
+			route := "/instances/" + instanceRef + "/clone"
+
+			body := types.InstanceClone{
+				NodeRef: args[1],
+				URL:     args[2],
+			}
+
+			var response types.Response
+
+			if err := c.client.POST(route, body, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	return &instanceCloneCmd
+}
+
+// createInstances sends the request for creating an instance on each node
+// selected by nodeSelector and prints the result for each node. An error is
+// returned if the instance couldn't be created on any of the nodes.
+func (c *CLI) createInstances(serviceRef, urlTemplate string, nodeSelector types.NodeSelectOptions, options types.InstanceCreateOptions) error {
+	route := "/instances/create/nodes"
+
+	body := types.InstancesCreate{
+		ServiceRef:            serviceRef,
+		URLTemplate:           urlTemplate,
+		NodeSelectOptions:     nodeSelector,
+		InstanceCreateOptions: options,
+	}
+
+	var instanceResultsResponse types.InstanceResultsResponse
+
+	if err := c.client.POST(route, body, &instanceResultsResponse); err != nil {
+		return err
+	}
+
+	if !instanceResultsResponse.Success {
+		return responseError(instanceResultsResponse.Response)
+	}
+
+	failed := 0
+
+	for _, result := range instanceResultsResponse.Data {
+		if !result.Success {
+			failed++
+		}
+		fmt.Printf("%v\n", result)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("instance creation failed for %d of %d nodes", failed, len(instanceResultsResponse.Data))
+	}
+
+	return nil
+}
+
 // instanceAttachCmd creates and implements the `instance attach` command.
 func (c *CLI) instanceAttachCmd() *cobra.Command {
+	var options types.InstanceAttachOptions
+
 	instanceAttachCmd := cobra.Command{
 		Use:   "attach <ID|NAME|URL>",
 		Short: `Attach an existing service instance`,
@@ -91,18 +184,20 @@ func (c *CLI) instanceAttachCmd() *cobra.Command {
 
 			var response types.Response
 
-			if err := c.client.POST(route, nil, &response); err != nil {
+			if err := c.client.POST(route, options, &response); err != nil {
 				return err
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
 		},
 	}
 
+	instanceAttachCmd.Flags().BoolVarP(&options.Force, "force", "f", false, `attach the instance even if its node is detached`)
+
 	return &instanceAttachCmd
 }
 
@@ -123,7 +218,7 @@ func (c *CLI) instanceDetachCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -153,7 +248,7 @@ func (c *CLI) instanceRemoveCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -184,7 +279,7 @@ func (c *CLI) instanceInfoCmd() *cobra.Command {
 			}
 
 			if !instanceInfoResponse.Success {
-				return errors.New(instanceInfoResponse.Message)
+				return responseError(instanceInfoResponse.Response)
 			}
 
 			fmt.Printf("%v\n", instanceInfoResponse.Data)
@@ -197,9 +292,100 @@ func (c *CLI) instanceInfoCmd() *cobra.Command {
 	return &instanceInfoCmd
 }
 
+// instanceDescribeCmd creates and implements the `instance describe` command.
+// Compared to `instance info`, it also prints the service and node names and
+// the live status of the instance.
+func (c *CLI) instanceDescribeCmd() *cobra.Command {
+	instanceDescribeCmd := cobra.Command{
+		Use:   "describe <ID|NAME|URL>",
+		Short: `Print detailed information for a service instance`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := args[0]
+			route := "/instances/" + instanceRef + "/describe"
+
+			var instanceDescribeResponse types.InstanceDescribeResponse
+
+			if err := c.client.POST(route, nil, &instanceDescribeResponse); err != nil {
+				return err
+			}
+
+			if !instanceDescribeResponse.Success {
+				return responseError(instanceDescribeResponse.Response)
+			}
+
+			fmt.Printf("%v\n", instanceDescribeResponse.Data)
+			return nil
+		},
+	}
+
+	return &instanceDescribeCmd
+}
+
+// instanceStatsCmd creates and implements the `instance stats` command. The
+// printed stats are cumulative since the Dice proxy has been started.
+func (c *CLI) instanceStatsCmd() *cobra.Command {
+	instanceStatsCmd := cobra.Command{
+		Use:   "stats <ID|NAME|URL>",
+		Short: `Print proxy stats for a service instance`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := args[0]
+			route := "/instances/" + instanceRef + "/stats"
+
+			var instanceStatsResponse types.InstanceStatsResponse
+
+			if err := c.client.POST(route, nil, &instanceStatsResponse); err != nil {
+				return err
+			}
+
+			if !instanceStatsResponse.Success {
+				return responseError(instanceStatsResponse.Response)
+			}
+
+			fmt.Printf("%v\n", instanceStatsResponse.Data)
+			return nil
+		},
+	}
+
+	return &instanceStatsCmd
+}
+
+// instanceEventsCmd creates and implements the `instance events` command. It
+// prints the recent state changes of an instance, the latest coming last.
+func (c *CLI) instanceEventsCmd() *cobra.Command {
+	instanceEventsCmd := cobra.Command{
+		Use:   "events <ID|NAME|URL>",
+		Short: `Print the recent state changes of a service instance`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instanceRef := args[0]
+			route := "/instances/" + instanceRef + "/events"
+
+			var eventListResponse types.EventListResponse
+
+			if err := c.client.POST(route, nil, &eventListResponse); err != nil {
+				return err
+			}
+
+			if !eventListResponse.Success {
+				return responseError(eventListResponse.Response)
+			}
+
+			for _, e := range eventListResponse.Data {
+				fmt.Printf("%v\n", e)
+			}
+			return nil
+		},
+	}
+
+	return &instanceEventsCmd
+}
+
 // instanceListCmd creates and implements the `instance list` command.
 func (c *CLI) instanceListCmd() *cobra.Command {
 	var options types.InstanceListOptions
+	var watch watchOptions
 
 	instanceListCmd := cobra.Command{
 		Use:     "list",
@@ -207,26 +393,33 @@ func (c *CLI) instanceListCmd() *cobra.Command {
 		Aliases: []string{"ls"},
 		Args:    cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			route := "/instances/list"
-			var instanceListResponse types.InstanceListResponse
+			return c.runList(cmd, watch, func() error {
+				route := "/instances/list"
+				var instanceListResponse types.InstanceListResponse
 
-			if err := c.client.POST(route, options, &instanceListResponse); err != nil {
-				return err
-			}
+				if err := c.client.POST(route, options, &instanceListResponse); err != nil {
+					return err
+				}
 
-			if !instanceListResponse.Success {
-				return errors.New(instanceListResponse.Message)
-			}
+				if !instanceListResponse.Success {
+					return responseError(instanceListResponse.Response)
+				}
 
-			for _, n := range instanceListResponse.Data {
-				fmt.Printf("%v\n", n)
-			}
+				for _, n := range instanceListResponse.Data {
+					fmt.Printf("%v\n", n)
+				}
 
-			return nil
+				return nil
+			})
 		},
 	}
 
 	instanceListCmd.Flags().BoolVarP(&options.All, "all", "a", false, `list all instances`)
+	instanceListCmd.Flags().BoolVar(&options.AliveOnly, "alive", false, `only list alive instances`)
+	instanceListCmd.Flags().BoolVar(&options.DeadOnly, "dead", false, `only list dead instances`)
+	instanceListCmd.Flags().BoolVar(&options.Outdated, "outdated", false, `only list instances not running the target version`)
+
+	watchFlags(&instanceListCmd, &watch)
 
 	return &instanceListCmd
 }
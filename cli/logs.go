@@ -0,0 +1,64 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+// logsCmd creates and implements the `logs` command. Reading the proxy logs
+// requires the API token configured on the daemon, which is read from the
+// DICE_TOKEN environment variable.
+func (c *CLI) logsCmd() *cobra.Command {
+	var options types.LogsOptions
+
+	logsCmd := cobra.Command{
+		Use:   "logs",
+		Short: `Print the proxy logs`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/admin/logs"
+
+			if options.Follow {
+				return c.client.Stream(route, options, os.Stdout)
+			}
+
+			var logsResponse types.LogsResponse
+
+			if err := c.client.POST(route, options, &logsResponse); err != nil {
+				return err
+			}
+
+			if !logsResponse.Success {
+				return responseError(logsResponse.Response)
+			}
+
+			for _, l := range logsResponse.Data {
+				fmt.Println(l)
+			}
+
+			return nil
+		},
+	}
+
+	logsCmd.Flags().IntVarP(&options.Lines, "lines", "n", 10, `specify the number of lines`)
+	logsCmd.Flags().BoolVarP(&options.Follow, "follow", "f", false, `stream new lines`)
+
+	return &logsCmd
+}
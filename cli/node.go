@@ -16,7 +16,6 @@
 package cli
 
 import (
-	"errors"
 	"fmt"
 	"github.com/dominikbraun/dice/types"
 	"github.com/spf13/cobra"
@@ -61,7 +60,7 @@ func (c *CLI) nodeCreateCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -70,6 +69,8 @@ func (c *CLI) nodeCreateCmd() *cobra.Command {
 
 	nodeCreateCmd.Flags().Uint8VarP(&options.Weight, "weight", "w", 1, `specify the node's weight`)
 	nodeCreateCmd.Flags().BoolVarP(&options.Attach, "attach", "a", false, `immediately attach the node`)
+	nodeCreateCmd.Flags().StringVar(&options.Zone, "zone", "", `assign the node to a zone`)
+	nodeCreateCmd.Flags().StringVar(&options.IDKey, "id-key", "", `derive the node ID from the given key`)
 
 	return &nodeCreateCmd
 }
@@ -91,7 +92,7 @@ func (c *CLI) nodeAttachCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -118,7 +119,7 @@ func (c *CLI) nodeDetachCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -128,6 +129,91 @@ func (c *CLI) nodeDetachCmd() *cobra.Command {
 	return &nodeDetachCmd
 }
 
+// nodeCordonCmd creates and implements the `node cordon` command.
+func (c *CLI) nodeCordonCmd() *cobra.Command {
+	nodeCordonCmd := cobra.Command{
+		Use:   "cordon <ID|NAME>",
+		Short: `Prevent new instances on an existing node`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeRef := args[0]
+			route := "/nodes/" + nodeRef + "/cordon"
+
+			var response types.Response
+
+			if err := c.client.POST(route, nil, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	return &nodeCordonCmd
+}
+
+// nodeUncordonCmd creates and implements the `node uncordon` command.
+func (c *CLI) nodeUncordonCmd() *cobra.Command {
+	nodeUncordonCmd := cobra.Command{
+		Use:   "uncordon <ID|NAME>",
+		Short: `Allow new instances on a cordoned node`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeRef := args[0]
+			route := "/nodes/" + nodeRef + "/uncordon"
+
+			var response types.Response
+
+			if err := c.client.POST(route, nil, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	return &nodeUncordonCmd
+}
+
+// nodeDrainCmd creates and implements the `node drain` command.
+func (c *CLI) nodeDrainCmd() *cobra.Command {
+	var options types.NodeDrainOptions
+
+	nodeDrainCmd := cobra.Command{
+		Use:   "drain <ID|NAME>",
+		Short: `Cordon a node and detach its instances one by one`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeRef := args[0]
+			route := "/nodes/" + nodeRef + "/drain"
+
+			var response types.Response
+
+			if err := c.client.POST(route, options, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	nodeDrainCmd.Flags().DurationVar(&options.Interval, "interval", 0, `pause between detaching two instances`)
+
+	return &nodeDrainCmd
+}
+
 // nodeRemoveCmd creates and implements the `node remove` command.
 func (c *CLI) nodeRemoveCmd() *cobra.Command {
 	var options types.NodeRemoveOptions
@@ -148,7 +234,7 @@ func (c *CLI) nodeRemoveCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -179,7 +265,7 @@ func (c *CLI) nodeInfoCmd() *cobra.Command {
 			}
 
 			if !nodeInfoResponse.Success {
-				return errors.New(nodeInfoResponse.Message)
+				return responseError(nodeInfoResponse.Response)
 			}
 
 			fmt.Printf("%v\n", nodeInfoResponse.Data)
@@ -192,9 +278,41 @@ func (c *CLI) nodeInfoCmd() *cobra.Command {
 	return &nodeInfoCmd
 }
 
+// nodeEventsCmd creates and implements the `node events` command. It prints
+// the recent state changes of a node, the latest coming last.
+func (c *CLI) nodeEventsCmd() *cobra.Command {
+	nodeEventsCmd := cobra.Command{
+		Use:   "events <ID|NAME>",
+		Short: `Print the recent state changes of a node`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			nodeRef := args[0]
+			route := "/nodes/" + nodeRef + "/events"
+
+			var eventListResponse types.EventListResponse
+
+			if err := c.client.POST(route, nil, &eventListResponse); err != nil {
+				return err
+			}
+
+			if !eventListResponse.Success {
+				return responseError(eventListResponse.Response)
+			}
+
+			for _, e := range eventListResponse.Data {
+				fmt.Printf("%v\n", e)
+			}
+			return nil
+		},
+	}
+
+	return &nodeEventsCmd
+}
+
 // nodeListCmd creates and implements the `node list` command.
 func (c *CLI) nodeListCmd() *cobra.Command {
 	var options types.NodeListOptions
+	var watch watchOptions
 
 	nodeListCmd := cobra.Command{
 		Use:     "list",
@@ -202,26 +320,32 @@ func (c *CLI) nodeListCmd() *cobra.Command {
 		Aliases: []string{"ls"},
 		Args:    cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			route := "/nodes/list"
-			var nodeListResponse types.NodeListResponse
+			return c.runList(cmd, watch, func() error {
+				route := "/nodes/list"
+				var nodeListResponse types.NodeListResponse
 
-			if err := c.client.POST(route, options, &nodeListResponse); err != nil {
-				return err
-			}
+				if err := c.client.POST(route, options, &nodeListResponse); err != nil {
+					return err
+				}
 
-			if !nodeListResponse.Success {
-				return errors.New(nodeListResponse.Message)
-			}
+				if !nodeListResponse.Success {
+					return responseError(nodeListResponse.Response)
+				}
 
-			for _, n := range nodeListResponse.Data {
-				fmt.Printf("%v\n", n)
-			}
+				for _, n := range nodeListResponse.Data {
+					fmt.Printf("%v\n", n)
+				}
 
-			return nil
+				return nil
+			})
 		},
 	}
 
 	nodeListCmd.Flags().BoolVarP(&options.All, "all", "a", false, `list all nodes`)
+	nodeListCmd.Flags().BoolVar(&options.AliveOnly, "alive", false, `only list alive nodes`)
+	nodeListCmd.Flags().BoolVar(&options.DeadOnly, "dead", false, `only list dead nodes`)
+
+	watchFlags(&nodeListCmd, &watch)
 
 	return &nodeListCmd
 }
@@ -0,0 +1,52 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/spf13/cobra"
+)
+
+// pruneCmd creates and implements the `prune` command.
+func (c *CLI) pruneCmd() *cobra.Command {
+	var options types.PruneOptions
+
+	pruneCmd := cobra.Command{
+		Use:   "prune",
+		Short: `Remove orphaned instances and unused services`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/admin/prune"
+			var pruneResponse types.PruneResponse
+
+			if err := c.client.POST(route, options, &pruneResponse); err != nil {
+				return err
+			}
+
+			if !pruneResponse.Success {
+				return responseError(pruneResponse.Response)
+			}
+
+			fmt.Printf("%v\n", pruneResponse.Data)
+			return nil
+		},
+	}
+
+	pruneCmd.Flags().BoolVar(&options.DryRun, "dry-run", false, `only print what would be removed`)
+
+	return &pruneCmd
+}
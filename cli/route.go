@@ -0,0 +1,155 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/spf13/cobra"
+	"strconv"
+)
+
+// routeCmd creates and implements the `route` command. The route command
+// itself does not have any functionality.
+func (c *CLI) routeCmd() *cobra.Command {
+	routeCmd := cobra.Command{
+		Use:   "route",
+		Short: `Inspect the routing table`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &routeCmd
+}
+
+// routeListCmd creates and implements the `route list` command.
+func (c *CLI) routeListCmd() *cobra.Command {
+	var watch watchOptions
+
+	routeListCmd := cobra.Command{
+		Use:     "list",
+		Short:   `List all routes and their target services`,
+		Aliases: []string{"ls"},
+		Args:    cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.runList(cmd, watch, func() error {
+				route := "/routes/list"
+				var routeListResponse types.RouteListResponse
+
+				if err := c.client.POST(route, nil, &routeListResponse); err != nil {
+					return err
+				}
+
+				if !routeListResponse.Success {
+					return responseError(routeListResponse.Response)
+				}
+
+				for _, r := range routeListResponse.Data {
+					fmt.Printf("%v\n", r)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	watchFlags(&routeListCmd, &watch)
+
+	return &routeListCmd
+}
+
+// routeTestCmd creates and implements the `route test` command. It doesn't
+// send a request to the host, it only asks the daemon for its decision. With
+// the --probe flag, the daemon sends a HEAD request to the selected instance.
+func (c *CLI) routeTestCmd() *cobra.Command {
+	var options types.RouteTestOptions
+
+	routeTestCmd := cobra.Command{
+		Use:   "test <HOST|URL>",
+		Short: `Show which instance a request to a host would be routed to`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options.Host = args[0]
+			route := "/admin/route/test"
+
+			var routeTestResponse types.RouteTestResponse
+
+			if err := c.client.POST(route, options, &routeTestResponse); err != nil {
+				return err
+			}
+
+			if !routeTestResponse.Success {
+				return responseError(routeTestResponse.Response)
+			}
+
+			fmt.Printf("%v\n", routeTestResponse.Data)
+
+			return nil
+		},
+	}
+
+	routeTestCmd.Flags().StringToStringVar(&options.Headers, "header", nil, `add a request header, e.g. Cookie=dice_affinity=token`)
+	routeTestCmd.Flags().BoolVar(&options.Probe, "probe", false, `send a HEAD request to the selected instance`)
+
+	return &routeTestCmd
+}
+
+// routeWeightsCmd creates and implements the `route weights` command. Each
+// --weight flag assigns a weight to a service, and the requests to the URL
+// are split across these services accordingly. Without any --weight flag,
+// the split is removed.
+func (c *CLI) routeWeightsCmd() *cobra.Command {
+	var weights map[string]string
+
+	routeWeightsCmd := cobra.Command{
+		Use:   "weights <URL>",
+		Short: `Split the requests to a URL across multiple services`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := types.RouteWeightsOptions{
+				URL:     args[0],
+				Weights: make(map[string]int, len(weights)),
+			}
+
+			for serviceRef, weight := range weights {
+				w, err := strconv.Atoi(weight)
+				if err != nil {
+					return fmt.Errorf("invalid weight for service %s: %s", serviceRef, weight)
+				}
+				options.Weights[serviceRef] = w
+			}
+
+			route := "/routes/weights"
+			var response types.Response
+
+			if err := c.client.POST(route, options, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	routeWeightsCmd.Flags().StringToStringVar(&weights, "weight", nil, `weight of a service, e.g. my-service=90`)
+
+	return &routeWeightsCmd
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"github.com/dominikbraun/dice/types"
 	"github.com/spf13/cobra"
+	"time"
 )
 
 // serviceCmd creates and implements the `service` command. The service
@@ -61,7 +62,7 @@ func (c *CLI) serviceCreateCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -69,19 +70,30 @@ func (c *CLI) serviceCreateCmd() *cobra.Command {
 	}
 
 	serviceCreateCmd.Flags().StringVar(&options.URLs, "urls", "", `add one or more public URLs`)
-	serviceCreateCmd.Flags().StringVar(&options.Balancing, "balancing", "weighted_round_robin", `specify a balancing method`)
+	serviceCreateCmd.Flags().StringVar(&options.Balancing, "balancing", "", `specify a balancing method, defaults to the configured default`)
 	serviceCreateCmd.Flags().BoolVar(&options.Enable, "enable", false, `immediately enable the service`)
+	serviceCreateCmd.Flags().StringVar(&options.IDKey, "id-key", "", `derive the service ID from the given key`)
+	serviceCreateCmd.Flags().BoolVar(&options.Force, "force", false, `reassign URLs that are used by another service`)
 
 	return &serviceCreateCmd
 }
 
 // serviceEnableCmd creates and implements the `service enable` command.
+//
+// If --selector is specified, all services whose name starts with the given
+// selector will be enabled instead of a single service.
 func (c *CLI) serviceEnableCmd() *cobra.Command {
+	var options types.ServiceSelectOptions
+
 	serviceEnableCmd := cobra.Command{
 		Use:   "enable <ID|NAME>",
 		Short: `Enable an existing service`,
-		Args:  cobra.ExactArgs(1),
+		Args:  selectorArgs(&options),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.Selector != "" {
+				return c.applyToServices("/services/enable", options)
+			}
+
 			serviceRef := args[0]
 			route := "/services/" + serviceRef + "/enable"
 
@@ -92,23 +104,34 @@ func (c *CLI) serviceEnableCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
 		},
 	}
 
+	serviceEnableCmd.Flags().StringVarP(&options.Selector, "selector", "s", "", `enable all services whose name starts with the selector`)
+
 	return &serviceEnableCmd
 }
 
 // serviceDisableCmd creates and implements the `service disable` command.
+//
+// If --selector is specified, all services whose name starts with the given
+// selector will be disabled instead of a single service.
 func (c *CLI) serviceDisableCmd() *cobra.Command {
+	var options types.ServiceSelectOptions
+
 	serviceDisableCmd := cobra.Command{
 		Use:   "disable <ID|NAME>",
 		Short: `Disable an existing service`,
-		Args:  cobra.ExactArgs(1),
+		Args:  selectorArgs(&options),
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.Selector != "" {
+				return c.applyToServices("/services/disable", options)
+			}
+
 			serviceRef := args[0]
 			route := "/services/" + serviceRef + "/disable"
 
@@ -119,16 +142,59 @@ func (c *CLI) serviceDisableCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
 		},
 	}
 
+	serviceDisableCmd.Flags().StringVarP(&options.Selector, "selector", "s", "", `disable all services whose name starts with the selector`)
+
 	return &serviceDisableCmd
 }
 
+// selectorArgs requires a service reference as the only argument, unless a
+// selector has been specified. In that case, no arguments are accepted.
+func selectorArgs(options *types.ServiceSelectOptions) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if options.Selector != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	}
+}
+
+// applyToServices sends the request for applying an operation to all
+// services selected by options and prints the result for each service. An
+// error is returned if the operation has failed for any of the services.
+func (c *CLI) applyToServices(route string, options types.ServiceSelectOptions) error {
+	var serviceResultsResponse types.ServiceResultsResponse
+
+	if err := c.client.POST(route, options, &serviceResultsResponse); err != nil {
+		return err
+	}
+
+	if !serviceResultsResponse.Success {
+		return responseError(serviceResultsResponse.Response)
+	}
+
+	failed := 0
+
+	for _, result := range serviceResultsResponse.Data {
+		if !result.Success {
+			failed++
+		}
+		fmt.Printf("%v\n", result)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("operation failed for %d of %d services", failed, len(serviceResultsResponse.Data))
+	}
+
+	return nil
+}
+
 // serviceUpdateCmd creates and implemented the `service update` command.
 func (c *CLI) serviceUpdateCmd() *cobra.Command {
 	serviceUpdateCmd := cobra.Command{
@@ -156,6 +222,56 @@ func (c *CLI) serviceUpdateCmd() *cobra.Command {
 	return &serviceUpdateCmd
 }
 
+// servicePatchCmd creates and implements the `service patch` command. Only
+// the options that have been provided are changed.
+func (c *CLI) servicePatchCmd() *cobra.Command {
+	var (
+		balancing          string
+		urls               string
+		healthCheckTimeout time.Duration
+	)
+
+	servicePatchCmd := cobra.Command{
+		Use:   "patch <ID|NAME>",
+		Short: `Change individual settings of a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serviceRef := args[0]
+			route := "/services/" + serviceRef + "/patch"
+
+			var patch types.ServicePatch
+
+			if cmd.Flags().Changed("balancing") {
+				patch.Balancing = &balancing
+			}
+			if cmd.Flags().Changed("urls") {
+				patch.URLs = &urls
+			}
+			if cmd.Flags().Changed("healthcheck-timeout") {
+				patch.HealthCheckTimeout = &healthCheckTimeout
+			}
+
+			var response types.Response
+
+			if err := c.client.POST(route, patch, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	servicePatchCmd.Flags().StringVar(&balancing, "balancing", "", `set the balancing method`)
+	servicePatchCmd.Flags().StringVar(&urls, "urls", "", `replace the URLs with a comma-separated list`)
+	servicePatchCmd.Flags().DurationVar(&healthCheckTimeout, "healthcheck-timeout", time.Duration(0), `set the health check timeout`)
+
+	return &servicePatchCmd
+}
+
 // serviceInfoCmd creates and implements the `service info` command.
 func (c *CLI) serviceInfoCmd() *cobra.Command {
 	var options types.ServiceInfoOptions
@@ -175,7 +291,7 @@ func (c *CLI) serviceInfoCmd() *cobra.Command {
 			}
 
 			if !serviceInfoResponse.Success {
-				return errors.New(serviceInfoResponse.Message)
+				return responseError(serviceInfoResponse.Response)
 			}
 
 			fmt.Printf("%v\n", serviceInfoResponse.Data)
@@ -191,6 +307,7 @@ func (c *CLI) serviceInfoCmd() *cobra.Command {
 // serviceListCmd creates and implements the `service list` command.
 func (c *CLI) serviceListCmd() *cobra.Command {
 	var options types.ServiceListOptions
+	var watch watchOptions
 
 	serviceListCmd := cobra.Command{
 		Use:     "list",
@@ -198,28 +315,90 @@ func (c *CLI) serviceListCmd() *cobra.Command {
 		Aliases: []string{"ls"},
 		Args:    cobra.ExactArgs(0),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			route := "/services/list"
-			var serviceListResponse types.ServiceListResponse
+			return c.runList(cmd, watch, func() error {
+				route := "/services/list"
+				var serviceListResponse types.ServiceListResponse
+
+				if err := c.client.POST(route, options, &serviceListResponse); err != nil {
+					return err
+				}
+
+				if !serviceListResponse.Success {
+					return responseError(serviceListResponse.Response)
+				}
+
+				for _, n := range serviceListResponse.Data {
+					fmt.Printf("%v\n", n)
+				}
+
+				return nil
+			})
+		},
+	}
+
+	serviceListCmd.Flags().BoolVarP(&options.All, "all", "a", false, `list all services`)
+
+	watchFlags(&serviceListCmd, &watch)
+
+	return &serviceListCmd
+}
+
+// serviceMetricsCmd creates and implements the `service metrics` command.
+// The printed metrics are cumulative since the Dice proxy has been started.
+func (c *CLI) serviceMetricsCmd() *cobra.Command {
+	serviceMetricsCmd := cobra.Command{
+		Use:   "metrics <ID|NAME>",
+		Short: `Print proxy metrics for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serviceRef := args[0]
+			route := "/services/" + serviceRef + "/metrics"
+
+			var serviceMetricsResponse types.ServiceMetricsResponse
+
+			if err := c.client.POST(route, nil, &serviceMetricsResponse); err != nil {
+				return err
+			}
+
+			if !serviceMetricsResponse.Success {
+				return responseError(serviceMetricsResponse.Response)
+			}
+
+			fmt.Printf("%v\n", serviceMetricsResponse.Data)
+			return nil
+		},
+	}
 
-			if err := c.client.POST(route, options, &serviceListResponse); err != nil {
+	return &serviceMetricsCmd
+}
+
+// serviceHealthCmd creates and implements the `service health` command.
+func (c *CLI) serviceHealthCmd() *cobra.Command {
+	serviceHealthCmd := cobra.Command{
+		Use:   "health",
+		Short: `Show the health status of all services`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			route := "/admin/health/services"
+			var serviceHealthResponse types.ServiceHealthResponse
+
+			if err := c.client.POST(route, nil, &serviceHealthResponse); err != nil {
 				return err
 			}
 
-			if !serviceListResponse.Success {
-				return errors.New(serviceListResponse.Message)
+			if !serviceHealthResponse.Success {
+				return responseError(serviceHealthResponse.Response)
 			}
 
-			for _, n := range serviceListResponse.Data {
-				fmt.Printf("%v\n", n)
+			for _, h := range serviceHealthResponse.Data {
+				fmt.Printf("%v\n", h)
 			}
 
 			return nil
 		},
 	}
 
-	serviceListCmd.Flags().BoolVarP(&options.All, "all", "a", false, `list all services`)
-
-	return &serviceListCmd
+	return &serviceHealthCmd
 }
 
 // serviceURLCmd creates and implements the `service url` command.
@@ -247,7 +426,7 @@ func (c *CLI) serviceURLCmd() *cobra.Command {
 			}
 
 			if !response.Success {
-				return errors.New(response.Message)
+				return responseError(response)
 			}
 
 			return nil
@@ -258,3 +437,552 @@ func (c *CLI) serviceURLCmd() *cobra.Command {
 
 	return &serviceURLCmd
 }
+
+// serviceSetDefaultCmd creates and implements the `service set-default`
+// command.
+func (c *CLI) serviceSetDefaultCmd() *cobra.Command {
+	serviceSetDefaultCmd := cobra.Command{
+		Use:   "set-default <ID|NAME>",
+		Short: `Route all requests without a matching URL to a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serviceRef := args[0]
+			route := "/services/" + serviceRef + "/default"
+
+			var response types.Response
+
+			if err := c.client.POST(route, nil, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	return &serviceSetDefaultCmd
+}
+
+// serviceHealthCheckCmd creates and implements the `service healthcheck`
+// command. The service healthcheck command itself does not have any
+// functionality.
+func (c *CLI) serviceHealthCheckCmd() *cobra.Command {
+	serviceHealthCheckCmd := cobra.Command{
+		Use:   "healthcheck",
+		Short: `Manage health checks for a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceHealthCheckCmd
+}
+
+// serviceHealthCheckSetCmd creates and implements the `service healthcheck
+// set` command. Omitted options fall back to the global configuration.
+func (c *CLI) serviceHealthCheckSetCmd() *cobra.Command {
+	var options types.ServiceHealthCheckOptions
+
+	serviceHealthCheckSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Configure the health checks for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "healthcheck", options)
+		},
+	}
+
+	serviceHealthCheckSetCmd.Flags().StringVar(&options.Path, "path", "", `probe the given HTTP path, starting with a slash`)
+	serviceHealthCheckSetCmd.Flags().DurationVar(&options.Interval, "interval", time.Duration(0), `specify the check interval`)
+	serviceHealthCheckSetCmd.Flags().DurationVar(&options.Timeout, "timeout", time.Duration(0), `specify the check timeout`)
+	serviceHealthCheckSetCmd.Flags().IntVar(&options.HealthyThreshold, "healthy-threshold", 0, `successful checks until an instance is alive`)
+	serviceHealthCheckSetCmd.Flags().IntVar(&options.UnhealthyThreshold, "unhealthy-threshold", 0, `failed checks until an instance is dead`)
+
+	return &serviceHealthCheckSetCmd
+}
+
+// serviceMaintenanceCmd creates and implements the `service maintenance`
+// command. The service maintenance command itself does not have any
+// functionality.
+func (c *CLI) serviceMaintenanceCmd() *cobra.Command {
+	serviceMaintenanceCmd := cobra.Command{
+		Use:   "maintenance",
+		Short: `Manage the maintenance mode of a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceMaintenanceCmd
+}
+
+// serviceMaintenanceOnCmd creates and implements the `service maintenance on`
+// command.
+func (c *CLI) serviceMaintenanceOnCmd() *cobra.Command {
+	options := types.ServiceMaintenanceOptions{
+		Enable: true,
+	}
+
+	serviceMaintenanceOnCmd := cobra.Command{
+		Use:   "on <ID|NAME>",
+		Short: `Put a service under maintenance`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "maintenance", options)
+		},
+	}
+
+	serviceMaintenanceOnCmd.Flags().StringVarP(&options.Message, "message", "m", "", `specify the maintenance page`)
+
+	return &serviceMaintenanceOnCmd
+}
+
+// serviceMaintenanceOffCmd creates and implements the `service maintenance
+// off` command.
+func (c *CLI) serviceMaintenanceOffCmd() *cobra.Command {
+	serviceMaintenanceOffCmd := cobra.Command{
+		Use:   "off <ID|NAME>",
+		Short: `End the maintenance of a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			options := types.ServiceMaintenanceOptions{
+				Enable: false,
+			}
+
+			return c.setServiceOption(args[0], "maintenance", options)
+		},
+	}
+
+	return &serviceMaintenanceOffCmd
+}
+
+// serviceOutliersCmd creates and implements the `service outliers` command.
+// The service outliers command itself does not have any functionality.
+func (c *CLI) serviceOutliersCmd() *cobra.Command {
+	serviceOutliersCmd := cobra.Command{
+		Use:   "outliers",
+		Short: `Manage the outlier detection for a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceOutliersCmd
+}
+
+// serviceOutliersSetCmd creates and implements the `service outliers set`
+// command. A threshold of 0 disables the outlier detection.
+func (c *CLI) serviceOutliersSetCmd() *cobra.Command {
+	var options types.ServiceOutliersOptions
+
+	serviceOutliersSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Configure the outlier detection for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "outliers", options)
+		},
+	}
+
+	serviceOutliersSetCmd.Flags().IntVar(&options.Threshold, "threshold", 0, `eject instances after this number of consecutive failures`)
+	serviceOutliersSetCmd.Flags().DurationVar(&options.EjectionTime, "ejection-time", time.Duration(0), `specify the base ejection time, e.g. 30s`)
+
+	return &serviceOutliersSetCmd
+}
+
+// serviceMirrorCmd creates and implements the `service mirror` command. The
+// service mirror command itself does not have any functionality.
+func (c *CLI) serviceMirrorCmd() *cobra.Command {
+	serviceMirrorCmd := cobra.Command{
+		Use:   "mirror",
+		Short: `Manage traffic mirroring for a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceMirrorCmd
+}
+
+// serviceMirrorSetCmd creates and implements the `service mirror set`
+// command. A fraction of 0 disables mirroring.
+func (c *CLI) serviceMirrorSetCmd() *cobra.Command {
+	var options types.ServiceMirrorOptions
+
+	serviceMirrorSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Configure traffic mirroring for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "mirror", options)
+		},
+	}
+
+	serviceMirrorSetCmd.Flags().StringVar(&options.Version, "version", "", `mirror requests to instances with this version`)
+	serviceMirrorSetCmd.Flags().Float64Var(&options.Fraction, "fraction", 0, `the fraction of requests to mirror, e.g. 0.1`)
+
+	return &serviceMirrorSetCmd
+}
+
+// serviceAccessLogCmd creates and implements the `service accesslog`
+// command. The service accesslog command itself does not have any
+// functionality.
+func (c *CLI) serviceAccessLogCmd() *cobra.Command {
+	serviceAccessLogCmd := cobra.Command{
+		Use:   "accesslog",
+		Short: `Manage the access log of a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceAccessLogCmd
+}
+
+// serviceAccessLogSetCmd creates and implements the `service accesslog set`
+// command. Without any flags, every request to the service is logged.
+func (c *CLI) serviceAccessLogSetCmd() *cobra.Command {
+	var options types.ServiceAccessLogOptions
+
+	serviceAccessLogSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Configure the access log of a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "accesslog", options)
+		},
+	}
+
+	serviceAccessLogSetCmd.Flags().BoolVar(&options.Disable, "disable", false, `don't log any requests to the service`)
+	serviceAccessLogSetCmd.Flags().IntVar(&options.SampleRate, "sample-rate", 0, `only log 1 in N requests, e.g. 10`)
+
+	return &serviceAccessLogSetCmd
+}
+
+// serviceStickyCmd creates and implements the `service sticky` command. The
+// service sticky command itself does not have any functionality.
+func (c *CLI) serviceStickyCmd() *cobra.Command {
+	serviceStickyCmd := cobra.Command{
+		Use:   "sticky",
+		Short: `Manage sticky sessions of a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceStickyCmd
+}
+
+// serviceStickyOnCmd creates and implements the `service sticky on` command.
+func (c *CLI) serviceStickyOnCmd() *cobra.Command {
+	serviceStickyOnCmd := cobra.Command{
+		Use:   "on <ID|NAME>",
+		Short: `Pin clients to a single instance`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "sticky", types.ServiceStickyOptions{Enable: true})
+		},
+	}
+
+	return &serviceStickyOnCmd
+}
+
+// serviceStickyOffCmd creates and implements the `service sticky off`
+// command.
+func (c *CLI) serviceStickyOffCmd() *cobra.Command {
+	serviceStickyOffCmd := cobra.Command{
+		Use:   "off <ID|NAME>",
+		Short: `Stop pinning clients to a single instance`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "sticky", types.ServiceStickyOptions{Enable: false})
+		},
+	}
+
+	return &serviceStickyOffCmd
+}
+
+// setServiceOption sends the options for a setting of the service identified
+// by serviceRef, where setting is the last path segment of the API route,
+// e.g. "sticky". The set, clear, on and off subcommands of all service
+// settings are implemented using this function.
+func (c *CLI) setServiceOption(serviceRef, setting string, options interface{}) error {
+	route := "/services/" + serviceRef + "/" + setting
+
+	var response types.Response
+
+	if err := c.client.POST(route, options, &response); err != nil {
+		return err
+	}
+
+	if !response.Success {
+		return responseError(response)
+	}
+
+	return nil
+}
+
+// serviceCORSCmd creates and implements the `service cors` command. The
+// service cors command itself does not have any functionality.
+func (c *CLI) serviceCORSCmd() *cobra.Command {
+	serviceCORSCmd := cobra.Command{
+		Use:   "cors",
+		Short: `Manage the CORS configuration of a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceCORSCmd
+}
+
+// serviceCORSSetCmd creates and implements the `service cors set` command.
+// The configuration replaces any previous CORS configuration of the service.
+func (c *CLI) serviceCORSSetCmd() *cobra.Command {
+	var options types.ServiceCORSOptions
+
+	serviceCORSSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Configure CORS for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "cors", options)
+		},
+	}
+
+	serviceCORSSetCmd.Flags().StringVar(&options.Origins, "origins", "", `comma-separated list of allowed origins, * allows all`)
+	serviceCORSSetCmd.Flags().StringVar(&options.Methods, "methods", "", `comma-separated list of allowed methods`)
+	serviceCORSSetCmd.Flags().StringVar(&options.Headers, "headers", "", `comma-separated list of allowed headers`)
+	serviceCORSSetCmd.Flags().BoolVar(&options.Credentials, "credentials", false, `allow credentials`)
+
+	return &serviceCORSSetCmd
+}
+
+// serviceCORSClearCmd creates and implements the `service cors clear`
+// command. It disables CORS for the service.
+func (c *CLI) serviceCORSClearCmd() *cobra.Command {
+	serviceCORSClearCmd := cobra.Command{
+		Use:   "clear <ID|NAME>",
+		Short: `Disable CORS for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "cors", types.ServiceCORSOptions{})
+		},
+	}
+
+	return &serviceCORSClearCmd
+}
+
+// serviceAuthCmd creates and implements the `service auth` command. The
+// service auth command itself does not have any functionality.
+func (c *CLI) serviceAuthCmd() *cobra.Command {
+	serviceAuthCmd := cobra.Command{
+		Use:   "auth",
+		Short: `Manage the basic auth credentials of a service`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceAuthCmd
+}
+
+// serviceAuthSetCmd creates and implements the `service auth set` command.
+// The credentials replace any previous credentials of the service.
+func (c *CLI) serviceAuthSetCmd() *cobra.Command {
+	var options types.ServiceAuthOptions
+
+	serviceAuthSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Require basic auth for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if options.Username == "" {
+				return errors.New("a username is required")
+			}
+
+			return c.setServiceOption(args[0], "auth", options)
+		},
+	}
+
+	serviceAuthSetCmd.Flags().StringVarP(&options.Username, "username", "u", "", `the username required for accessing the service`)
+	serviceAuthSetCmd.Flags().StringVarP(&options.Password, "password", "p", "", `the password required for accessing the service`)
+
+	return &serviceAuthSetCmd
+}
+
+// serviceAuthClearCmd creates and implements the `service auth clear`
+// command. It disables basic auth for the service.
+func (c *CLI) serviceAuthClearCmd() *cobra.Command {
+	serviceAuthClearCmd := cobra.Command{
+		Use:   "clear <ID|NAME>",
+		Short: `Disable basic auth for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "auth", types.ServiceAuthOptions{})
+		},
+	}
+
+	return &serviceAuthClearCmd
+}
+
+// serviceAllowListCmd creates and implements the `service allowlist`
+// command. The service allowlist command itself does not have any
+// functionality.
+func (c *CLI) serviceAllowListCmd() *cobra.Command {
+	serviceAllowListCmd := cobra.Command{
+		Use:   "allowlist",
+		Short: `Manage the methods and paths a service accepts`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_ = cmd.Help()
+			return nil
+		},
+	}
+
+	return &serviceAllowListCmd
+}
+
+// serviceAllowListSetCmd creates and implements the `service allowlist set`
+// command. The allow-list replaces any previous allow-list of the service.
+func (c *CLI) serviceAllowListSetCmd() *cobra.Command {
+	var options types.ServiceAllowListOptions
+
+	serviceAllowListSetCmd := cobra.Command{
+		Use:   "set <ID|NAME>",
+		Short: `Restrict the methods and paths a service accepts`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "allowlist", options)
+		},
+	}
+
+	serviceAllowListSetCmd.Flags().StringVar(&options.Methods, "methods", "", `comma-separated list of allowed methods`)
+	serviceAllowListSetCmd.Flags().StringVar(&options.Paths, "paths", "", `comma-separated list of allowed path globs, e.g. /api/**`)
+
+	return &serviceAllowListSetCmd
+}
+
+// serviceAllowListClearCmd creates and implements the `service allowlist
+// clear` command. It allows all methods and paths again.
+func (c *CLI) serviceAllowListClearCmd() *cobra.Command {
+	serviceAllowListClearCmd := cobra.Command{
+		Use:   "clear <ID|NAME>",
+		Short: `Allow all methods and paths for a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return c.setServiceOption(args[0], "allowlist", types.ServiceAllowListOptions{})
+		},
+	}
+
+	return &serviceAllowListClearCmd
+}
+
+// serviceReplaceCmd creates and implements the `service replace` command.
+// It replaces all instances of a service with new instances one batch at a
+// time, where each new instance is deployed to the node of the old one.
+//
+// Since replacing instances may take a while, the request timeout will be
+// disabled unless it has been set explicitly using --timeout.
+func (c *CLI) serviceReplaceCmd() *cobra.Command {
+	var options types.ServiceReplaceOptions
+
+	serviceReplaceCmd := cobra.Command{
+		Use:   "replace <ID|NAME> <VERSION>",
+		Short: `Replace the instances of a service with a new version`,
+		Args:  cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serviceRef := args[0]
+			route := "/services/" + serviceRef + "/replace"
+
+			serviceReplace := types.ServiceReplace{
+				Version:               args[1],
+				ServiceReplaceOptions: options,
+			}
+
+			if !cmd.Root().PersistentFlags().Changed("timeout") {
+				c.client.SetTimeout(0)
+			}
+
+			var response types.Response
+
+			if err := c.client.POST(route, serviceReplace, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	serviceReplaceCmd.Flags().StringToStringVar(&options.URLs, "url", nil, `map an old instance URL to a new one, e.g. old=new`)
+	serviceReplaceCmd.Flags().IntVar(&options.MaxUnavailable, "max-unavailable", 1, `the number of instances replaced at once`)
+	serviceReplaceCmd.Flags().DurationVar(&options.Timeout, "alive-timeout", time.Minute, `the time a new instance has for becoming alive`)
+
+	return &serviceReplaceCmd
+}
+
+// serviceScheduleCmd creates and implements the `service schedule` command.
+// The transition times are expected in RFC 3339 format. For example, a weekly
+// maintenance window can be scheduled using --disable-at, --enable-at and an
+// interval of 168h.
+func (c *CLI) serviceScheduleCmd() *cobra.Command {
+	var options types.ServiceScheduleOptions
+	var enableAt, disableAt string
+
+	serviceScheduleCmd := cobra.Command{
+		Use:   "schedule <ID|NAME>",
+		Short: `Schedule the enabling and disabling of a service`,
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			serviceRef := args[0]
+			route := "/services/" + serviceRef + "/schedule"
+
+			var err error
+
+			if enableAt != "" {
+				if options.EnableAt, err = time.Parse(time.RFC3339, enableAt); err != nil {
+					return fmt.Errorf("invalid time for --enable-at: %s", enableAt)
+				}
+			}
+
+			if disableAt != "" {
+				if options.DisableAt, err = time.Parse(time.RFC3339, disableAt); err != nil {
+					return fmt.Errorf("invalid time for --disable-at: %s", disableAt)
+				}
+			}
+
+			var response types.Response
+
+			if err := c.client.POST(route, options, &response); err != nil {
+				return err
+			}
+
+			if !response.Success {
+				return responseError(response)
+			}
+
+			return nil
+		},
+	}
+
+	serviceScheduleCmd.Flags().StringVar(&enableAt, "enable-at", "", `enable the service at the given time, e.g. 2020-01-01T04:00:00Z`)
+	serviceScheduleCmd.Flags().StringVar(&disableAt, "disable-at", "", `disable the service at the given time, e.g. 2020-01-01T02:00:00Z`)
+	serviceScheduleCmd.Flags().DurationVar(&options.Every, "every", time.Duration(0), `repeat the transitions in the given interval`)
+	serviceScheduleCmd.Flags().BoolVar(&options.Clear, "clear", false, `remove all previously scheduled transitions`)
+
+	return &serviceScheduleCmd
+}
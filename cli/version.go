@@ -0,0 +1,53 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/types"
+	"github.com/dominikbraun/dice/version"
+	"github.com/spf13/cobra"
+)
+
+// versionCmd creates and implements the `version` command. It prints the
+// build information of the CLI as well as of the Dice daemon.
+func (c *CLI) versionCmd() *cobra.Command {
+	versionCmd := cobra.Command{
+		Use:   "version",
+		Short: `Print the version of the CLI and the daemon`,
+		Args:  cobra.ExactArgs(0),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fmt.Printf("CLI:    %s\n", version.String())
+
+			var versionResponse types.VersionResponse
+
+			if err := c.client.BuildInfo(&versionResponse); err != nil {
+				return err
+			}
+
+			if !versionResponse.Success {
+				return responseError(versionResponse.Response)
+			}
+
+			v := versionResponse.Data
+			fmt.Printf("Daemon: %s (commit %s, built %s)\n", v.Version, v.Commit, v.BuildDate)
+
+			return nil
+		},
+	}
+
+	return &versionCmd
+}
@@ -0,0 +1,83 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/spf13/cobra"
+	"io"
+	"time"
+)
+
+const (
+	// clearScreen moves the cursor to the top left corner and clears the
+	// entire terminal screen.
+	clearScreen string = "\033[H\033[2J"
+	// defaultWatchInterval is the interval in which list commands re-query
+	// the API in watch mode.
+	defaultWatchInterval = 2 * time.Second
+)
+
+// watchOptions combines the options for running a list command in watch mode.
+type watchOptions struct {
+	enabled  bool
+	interval time.Duration
+}
+
+// watchFlags adds the --watch and --interval flags to a list command.
+func watchFlags(cmd *cobra.Command, options *watchOptions) {
+	cmd.Flags().BoolVarP(&options.enabled, "watch", "w", false, `re-query and redraw the list until interrupted`)
+	cmd.Flags().DurationVar(&options.interval, "interval", defaultWatchInterval, `specify the interval for --watch`)
+}
+
+// runList runs the given list function once, or repeatedly until interrupted
+// if the watch mode has been enabled.
+func (c *CLI) runList(cmd *cobra.Command, options watchOptions, list func() error) error {
+	if !options.enabled {
+		return list()
+	}
+
+	return watchList(c.client.Context(), cmd.OutOrStdout(), options.interval, list)
+}
+
+// watchList clears the screen and calls list in the given interval until ctx is
+// cancelled, for example by pressing Ctrl-C. Errors returned by list are
+// printed to w instead of stopping the watch, so that transient API errors
+// don't end a live dashboard.
+func watchList(ctx context.Context, w io.Writer, interval time.Duration, list func() error) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		_, _ = fmt.Fprint(w, clearScreen)
+		_, _ = fmt.Fprintf(w, "Every %v - %s\n\n", interval, time.Now().Format(time.RFC1123))
+
+		if err := list(); err != nil && ctx.Err() == nil {
+			_, _ = fmt.Fprintf(w, "Error: %v\n", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
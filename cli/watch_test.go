@@ -0,0 +1,71 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package cli provides the Dice CLI commands and their implementation.
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWatchList checks if watchList keeps polling after a failed poll and
+// returns as soon as the context is cancelled.
+func TestWatchList(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var output bytes.Buffer
+	polls := 0
+
+	list := func() error {
+		polls++
+		if polls == 1 {
+			return errors.New("daemon is unavailable")
+		}
+		cancel()
+		return nil
+	}
+
+	done := make(chan error)
+
+	go func() {
+		done <- watchList(ctx, &output, 10*time.Millisecond, list)
+	}()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatalf("expected no error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("watchList didn't return after cancellation")
+	}
+
+	if polls < 2 {
+		t.Errorf("expected at least 2 polls, got %d", polls)
+	}
+
+	if !strings.Contains(output.String(), "daemon is unavailable") {
+		t.Errorf("expected the error to be printed, got %q", output.String())
+	}
+
+	if strings.Count(output.String(), clearScreen) != polls {
+		t.Errorf("expected the screen to be cleared %d times", polls)
+	}
+}
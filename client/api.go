@@ -0,0 +1,228 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides the Dice client. While the core package provides
+// the daemon, the client is responsible for talking to the daemon's API.
+package client
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"net/url"
+)
+
+// API is a typed wrapper around Client, intended for Go programs that drive
+// Dice. Each method sends the request to the appropriate route and returns
+// the decoded output.
+//
+// If the daemon responds with an error, the returned error carries the error
+// category of the response, see types.CategoryOf.
+type API struct {
+	client *Client
+}
+
+// NewAPI creates a new API instance that sends all requests using client.
+func NewAPI(client *Client) *API {
+	a := API{
+		client: client,
+	}
+
+	return &a
+}
+
+// CreateNode creates a new node with the given name.
+func (a *API) CreateNode(name string, options types.NodeCreateOptions) error {
+	body := types.NodeCreate{
+		Name:              name,
+		NodeCreateOptions: options,
+	}
+
+	var response types.Response
+	return a.post("/nodes/create", body, &response, &response)
+}
+
+// AttachNode attaches an existing node.
+func (a *API) AttachNode(nodeRef string) error {
+	var response types.Response
+	return a.post(entityRoute("nodes", nodeRef, "attach"), nil, &response, &response)
+}
+
+// DetachNode detaches an existing node.
+func (a *API) DetachNode(nodeRef string) error {
+	var response types.Response
+	return a.post(entityRoute("nodes", nodeRef, "detach"), nil, &response, &response)
+}
+
+// RemoveNode removes an existing node.
+func (a *API) RemoveNode(nodeRef string, options types.NodeRemoveOptions) error {
+	var response types.Response
+	return a.post(entityRoute("nodes", nodeRef, "remove"), options, &response, &response)
+}
+
+// NodeInfo returns information for an existing node.
+func (a *API) NodeInfo(nodeRef string) (types.NodeInfoOutput, error) {
+	var response types.NodeInfoResponse
+	err := a.post(entityRoute("nodes", nodeRef, "info"), nil, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// ListNodes returns information for all nodes matching the options.
+func (a *API) ListNodes(options types.NodeListOptions) ([]types.NodeInfoOutput, error) {
+	var response types.NodeListResponse
+	err := a.post("/nodes/list", options, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// CreateService creates a new service with the given name and returns the
+// information for the created service.
+func (a *API) CreateService(name string, options types.ServiceCreateOptions) (types.ServiceInfoOutput, error) {
+	body := types.ServiceCreate{
+		Name:                 name,
+		ServiceCreateOptions: options,
+	}
+
+	var response types.Response
+
+	if err := a.post("/services/create", body, &response, &response); err != nil {
+		return types.ServiceInfoOutput{}, err
+	}
+
+	return a.ServiceInfo(name)
+}
+
+// EnableService enables an existing service.
+func (a *API) EnableService(serviceRef string) error {
+	var response types.Response
+	return a.post(entityRoute("services", serviceRef, "enable"), nil, &response, &response)
+}
+
+// DisableService disables an existing service.
+func (a *API) DisableService(serviceRef string) error {
+	var response types.Response
+	return a.post(entityRoute("services", serviceRef, "disable"), nil, &response, &response)
+}
+
+// UpdateService updates an existing service to the given version.
+func (a *API) UpdateService(serviceRef, version string) error {
+	body := types.ServiceUpdate{
+		TargetVersion: version,
+	}
+
+	var response types.Response
+	return a.post(entityRoute("services", serviceRef, "update"), body, &response, &response)
+}
+
+// SetServiceURL adds an URL to an existing service or removes it.
+func (a *API) SetServiceURL(serviceRef, serviceURL string, options types.ServiceURLOptions) error {
+	body := types.ServiceURL{
+		URL:               serviceURL,
+		ServiceURLOptions: options,
+	}
+
+	var response types.Response
+	return a.post(entityRoute("services", serviceRef, "url"), body, &response, &response)
+}
+
+// ServiceInfo returns information for an existing service.
+func (a *API) ServiceInfo(serviceRef string) (types.ServiceInfoOutput, error) {
+	var response types.ServiceInfoResponse
+	err := a.post(entityRoute("services", serviceRef, "info"), nil, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// ListServices returns information for all services matching the options.
+func (a *API) ListServices(options types.ServiceListOptions) ([]types.ServiceInfoOutput, error) {
+	var response types.ServiceListResponse
+	err := a.post("/services/list", options, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// CreateInstance creates a new instance of a service on the given node.
+func (a *API) CreateInstance(serviceRef, nodeRef, instanceURL string, options types.InstanceCreateOptions) error {
+	body := types.InstanceCreate{
+		ServiceRef:            serviceRef,
+		NodeRef:               nodeRef,
+		URL:                   instanceURL,
+		InstanceCreateOptions: options,
+	}
+
+	var response types.Response
+	return a.post("/instances/create", body, &response, &response)
+}
+
+// AttachInstance attaches an existing instance.
+func (a *API) AttachInstance(instanceRef string, options types.InstanceAttachOptions) error {
+	var response types.Response
+	return a.post(entityRoute("instances", instanceRef, "attach"), options, &response, &response)
+}
+
+// DetachInstance detaches an existing instance.
+func (a *API) DetachInstance(instanceRef string) error {
+	var response types.Response
+	return a.post(entityRoute("instances", instanceRef, "detach"), nil, &response, &response)
+}
+
+// RemoveInstance removes an existing instance.
+func (a *API) RemoveInstance(instanceRef string, options types.InstanceRemoveOptions) error {
+	var response types.Response
+	return a.post(entityRoute("instances", instanceRef, "remove"), options, &response, &response)
+}
+
+// InstanceInfo returns information for an existing instance.
+func (a *API) InstanceInfo(instanceRef string) (types.InstanceInfoOutput, error) {
+	var response types.InstanceInfoResponse
+	err := a.post(entityRoute("instances", instanceRef, "info"), nil, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// ListInstances returns information for all instances matching the options.
+func (a *API) ListInstances(options types.InstanceListOptions) ([]types.InstanceInfoOutput, error) {
+	var response types.InstanceListResponse
+	err := a.post("/instances/list", options, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// ListRoutes returns all routes along with their target services.
+func (a *API) ListRoutes() ([]types.RouteInfoOutput, error) {
+	var response types.RouteListResponse
+	err := a.post("/routes/list", nil, &response.Response, &response)
+
+	return response.Data, err
+}
+
+// post sends v to the given route and decodes the API response into dest.
+// response has to point to the types.Response embedded in dest. If it has
+// not been successful, an error with the response's category is returned.
+func (a *API) post(route string, v interface{}, response *types.Response, dest interface{}) error {
+	if err := a.client.POST(route, v, dest); err != nil {
+		return err
+	}
+
+	if !response.Success {
+		return response.Err()
+	}
+
+	return nil
+}
+
+// entityRoute builds the route for an action on the referenced entity, for
+// example /nodes/my-node/attach. The reference will be escaped.
+func entityRoute(collection, ref, action string) string {
+	return "/" + collection + "/" + url.PathEscape(ref) + "/" + action
+}
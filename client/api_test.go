@@ -0,0 +1,130 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides the Dice client. While the core package provides
+// the daemon, the client is responsible for talking to the daemon's API.
+package client
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+// newTestAPIServer creates a server mimicking the v1 API of the daemon. It
+// knows a single service s1 with a single instance. Requests for all other
+// entities are answered with a not_found error.
+func newTestAPIServer(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+
+	respond := func(w http.ResponseWriter, status int, response interface{}) {
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(response)
+	}
+
+	mux.HandleFunc("/v1/services/create", func(w http.ResponseWriter, r *http.Request) {
+		var serviceCreate types.ServiceCreate
+
+		if err := json.NewDecoder(r.Body).Decode(&serviceCreate); err != nil || serviceCreate.Name != "s1" {
+			t.Errorf("unexpected request body: %+v (%v)", serviceCreate, err)
+		}
+
+		respond(w, http.StatusOK, types.Response{Success: true})
+	})
+
+	mux.HandleFunc("/v1/services/s1/info", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK, types.ServiceInfoResponse{
+			Response: types.Response{Success: true},
+			Data:     types.ServiceInfoOutput{ID: "id1", Name: "s1", URLs: []string{"example.com"}},
+		})
+	})
+
+	mux.HandleFunc("/v1/instances/list", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusOK, types.InstanceListResponse{
+			Response: types.Response{Success: true},
+			Data:     []types.InstanceInfoOutput{{ID: "i1", ServiceID: "id1", URL: "n1:8000"}},
+		})
+	})
+
+	mux.HandleFunc("/v1/", func(w http.ResponseWriter, r *http.Request) {
+		respond(w, http.StatusUnprocessableEntity, types.Response{
+			Message:  "entity could not be found",
+			Category: types.NotFoundError,
+		})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+// TestAPI_CreateService tests if API.CreateService creates the service and
+// returns the service information.
+func TestAPI_CreateService(t *testing.T) {
+	server := newTestAPIServer(t)
+	defer server.Close()
+
+	api := NewAPI(newTestClient(server.URL, "v1"))
+
+	serviceInfo, err := api.CreateService("s1", types.ServiceCreateOptions{URLs: "example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := types.ServiceInfoOutput{ID: "id1", Name: "s1", URLs: []string{"example.com"}}
+
+	if !reflect.DeepEqual(serviceInfo, expected) {
+		t.Errorf("expected %+v, got %+v", expected, serviceInfo)
+	}
+}
+
+// TestAPI_ListInstances tests if API.ListInstances decodes the instances.
+func TestAPI_ListInstances(t *testing.T) {
+	server := newTestAPIServer(t)
+	defer server.Close()
+
+	api := NewAPI(newTestClient(server.URL, "v1"))
+
+	instances, err := api.ListInstances(types.InstanceListOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != 1 || instances[0].ID != "i1" || instances[0].URL != "n1:8000" {
+		t.Errorf("unexpected instances: %+v", instances)
+	}
+}
+
+// TestAPI_NodeInfo_notFound tests if an error response is returned as error
+// carrying the error message and category of the response.
+func TestAPI_NodeInfo_notFound(t *testing.T) {
+	server := newTestAPIServer(t)
+	defer server.Close()
+
+	api := NewAPI(newTestClient(server.URL, "v1"))
+
+	_, err := api.NodeInfo("missing")
+	if err == nil {
+		t.Fatal("expected an error, got nil")
+	}
+
+	if err.Error() != "entity could not be found" {
+		t.Errorf("expected error message from response, got %q", err.Error())
+	}
+
+	if category := types.CategoryOf(err); category != types.NotFoundError {
+		t.Errorf("expected category %s, got %s", types.NotFoundError, category)
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides the Dice client. While the core package provides
+// the daemon, the client is responsible for talking to the daemon's API.
+package client
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+// etagEntry is a cached response body together with its ETag.
+type etagEntry struct {
+	etag string
+	body []byte
+}
+
+// etagCache caches the bodies of responses that carry an ETag. Requests are
+// identified by their method, URL and body, so that requests with different
+// options don't share a cache entry.
+//
+// Once a response has been cached, subsequent requests will send its ETag
+// in the If-None-Match header. If the daemon responds with 304, the cached
+// body will be used instead.
+type etagCache struct {
+	entries map[string]etagEntry
+	mutex   sync.Mutex
+}
+
+// newETagCache creates a new, empty etagCache instance.
+func newETagCache() *etagCache {
+	ec := etagCache{
+		entries: make(map[string]etagEntry),
+	}
+
+	return &ec
+}
+
+// key creates the cache key for a request.
+func (ec *etagCache) key(method, url string, body []byte) string {
+	return method + " " + url + "\n" + string(body)
+}
+
+// etag returns the ETag of the cached response for the given key. Returns
+// an empty string if there is no cached response.
+func (ec *etagCache) etag(key string) string {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	return ec.entries[key].etag
+}
+
+// apply caches the response if it carries an ETag. If the response has
+// status 304, it will be replaced with the cached response. In both cases,
+// the returned response has a fully readable body.
+func (ec *etagCache) apply(key string, response *http.Response) (*http.Response, error) {
+	ec.mutex.Lock()
+	defer ec.mutex.Unlock()
+
+	if response.StatusCode == http.StatusNotModified {
+		entry, ok := ec.entries[key]
+		if !ok {
+			return response, nil
+		}
+
+		_ = response.Body.Close()
+
+		response.StatusCode = http.StatusOK
+		response.Body = ioutil.NopCloser(bytes.NewReader(entry.body))
+
+		return response, nil
+	}
+
+	etag := response.Header.Get("ETag")
+
+	if response.StatusCode != http.StatusOK || etag == "" {
+		return response, nil
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	_ = response.Body.Close()
+
+	if err != nil {
+		return nil, err
+	}
+
+	ec.entries[key] = etagEntry{etag: etag, body: body}
+	response.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	return response, nil
+}
@@ -18,29 +18,75 @@ package client
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/dominikbraun/dice/config"
+	"github.com/dominikbraun/dice/types"
+	"github.com/dominikbraun/dice/version"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
-	contentType string = "application/json"
+	contentType     string = "application/json"
+	requestIDHeader string = "X-Request-Id"
 )
 
 var (
-	ErrEndpointNotFound = errors.New("the API endpoint could not be found")
+	ErrEndpointNotFound   = errors.New("the API endpoint could not be found")
+	ErrAPIVersionMismatch = errors.New("the API version is not supported by the Dice daemon")
+	ErrDaemonUnreachable  = errors.New("the Dice daemon could not be reached")
 )
 
+// APIError is returned if the Dice daemon responded with an error that
+// couldn't be decoded into a regular API response. It carries the status
+// code, the raw response body and the ID the daemon assigned the request.
+type APIError struct {
+	StatusCode int
+	Body       string
+	RequestID  string
+}
+
+// newAPIError creates an APIError from the given response and its body,
+// which has to be read by the caller.
+func newAPIError(response *http.Response, body []byte) *APIError {
+	return &APIError{
+		StatusCode: response.StatusCode,
+		Body:       string(body),
+		RequestID:  response.Header.Get(requestIDHeader),
+	}
+}
+
+// Error implements the error interface.
+func (e *APIError) Error() string {
+	message := fmt.Sprintf("API error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+
+	if body := strings.TrimSpace(e.Body); body != "" {
+		message = fmt.Sprintf("%s: %s", message, body)
+	}
+
+	if e.RequestID != "" {
+		message = fmt.Sprintf("%s (request ID: %s)", message, e.RequestID)
+	}
+
+	return message
+}
+
 // APIConnection stores necessary information for establishing a connection
 // to the Dice API server. The values are read from the client's configuration
 // reader and can be set via the --address option as well.
+//
+// If a token has been configured, it is sent as bearer token with each
+// request. It is required for privileged endpoints like the proxy logs.
 type APIConnection struct {
 	Address string `json:"address"`
 	Version string `json:"root"`
+	Token   string `json:"token"`
 }
 
 // buildURL creates an appropriate URL that can be used to send a request.
@@ -58,10 +104,24 @@ func (ac *APIConnection) buildURL() string {
 // Client is the actual Dice client. It is a zero-configuration component
 // used by the CLI commands for sending requests and getting responses from
 // the API. Configuration values are read every time a command is executed.
+//
+// Before sending the first request, the client negotiates the API version
+// with the daemon. See negotiateVersion for details. Failed requests are
+// retried if that has been configured, see RetryConfig.
+//
+// All requests are bound to the client's context, which gets cancelled when
+// the process receives an interrupt signal. Except for streaming requests,
+// all requests are aborted when the configured timeout expires.
 type Client struct {
 	config        config.Reader
+	ctx           context.Context
+	cancel        context.CancelFunc
 	internal      *http.Client
+	streaming     *http.Client
 	apiConnection *APIConnection
+	retryConfig   *RetryConfig
+	etags         *etagCache
+	negotiated    bool
 }
 
 // New creates a new Client instance and sets up all components.
@@ -79,8 +139,10 @@ func New() (*Client, error) {
 func (c *Client) setup() error {
 	steps := []func() error{
 		c.setupConfig,
+		c.setupContext,
 		c.setupInternal,
 		c.setupAPIConnection,
+		c.setupRetryConfig,
 	}
 
 	for _, setup := range steps {
@@ -97,35 +159,73 @@ func (c *Client) setup() error {
 // for example by using the --address flag of a CLI command.
 func (c *Client) OverrideAddress(address string) {
 	c.apiConnection.Address = address
+	c.negotiated = false
+}
+
+// SetTimeout overrides the configured request timeout permanently, for
+// example if the --timeout flag has been provided. A timeout of 0 means
+// that requests never time out.
+func (c *Client) SetTimeout(timeout time.Duration) {
+	c.internal.Timeout = timeout
+}
+
+// Context returns the context all requests are bound to. It is cancelled
+// as soon as the process receives an interrupt signal.
+func (c *Client) Context() context.Context {
+	return c.ctx
 }
 
 // GET is the method used by the CLI for sending a GET request to the API.
 // If dest is not `nil`, the response body will be decoded into dest.
 func (c *Client) GET(route string, dest interface{}) error {
-	url := c.buildRequestURL(route)
-
-	response, err := c.internal.Get(url)
+	url, err := c.requestURL(route)
 	if err != nil {
 		return err
 	}
 
-	if response.StatusCode == 404 {
-		return ErrEndpointNotFound
-	}
-
-	if err := json.NewDecoder(response.Body).Decode(dest); err != nil && err != io.EOF {
+	response, err := c.send(c.internal, http.MethodGet, url, nil)
+	if err != nil {
 		return err
 	}
 
-	_ = response.Body.Close()
-	return nil
+	return decodeResponse(response, dest)
 }
 
 // POST is the method used by the CLI for sending a POST request to the API.
 // If v is not `nil`, it will be encoded into the request body. If dest is
 // not `nil`, the response body will be decoded into dest.
 func (c *Client) POST(route string, v interface{}, dest interface{}) error {
-	url := c.buildRequestURL(route)
+	url, err := c.requestURL(route)
+	if err != nil {
+		return err
+	}
+
+	body := bytes.NewBuffer(nil)
+
+	if v != nil {
+		if err := json.NewEncoder(body).Encode(v); err != nil {
+			return err
+		}
+	}
+
+	response, err := c.send(c.internal, http.MethodPost, url, body.Bytes())
+	if err != nil {
+		return err
+	}
+
+	return decodeResponse(response, dest)
+}
+
+// Stream sends a POST request to the API just like POST does. Instead of
+// decoding the response, the response body is copied to w as it arrives.
+// Stream blocks until the server closes the response or the client's
+// context is cancelled. The request timeout doesn't apply to Stream.
+func (c *Client) Stream(route string, v interface{}, w io.Writer) error {
+	url, err := c.requestURL(route)
+	if err != nil {
+		return err
+	}
+
 	body := bytes.NewBuffer(nil)
 
 	if v != nil {
@@ -134,26 +234,162 @@ func (c *Client) POST(route string, v interface{}, dest interface{}) error {
 		}
 	}
 
-	response, err := c.internal.Post(url, contentType, body)
+	response, err := c.send(c.streaming, http.MethodPost, url, body.Bytes())
 	if err != nil {
 		return err
 	}
+	defer response.Body.Close()
 
 	if response.StatusCode == 404 {
 		return ErrEndpointNotFound
 	}
 
-	if err := json.NewDecoder(response.Body).Decode(dest); err != nil && err != io.EOF {
+	if response.StatusCode >= http.StatusBadRequest {
+		body, _ := ioutil.ReadAll(response.Body)
+		return newAPIError(response, body)
+	}
+
+	_, err = io.Copy(w, response.Body)
+	return err
+}
+
+// BuildInfo requests the build information of the Dice daemon. Since the
+// build information is independent from the API version, the request is
+// sent to the unversioned /version route.
+func (c *Client) BuildInfo(dest interface{}) error {
+	url := fmt.Sprintf("%s/version", c.apiConnection.Address)
+
+	response, err := c.send(c.internal, http.MethodPost, url, nil)
+	if err != nil {
 		return err
 	}
 
-	// Do not handle the error since it is not relevant anymore. The JSON
-	// is already decoded and the user is happy.
-	_ = response.Body.Close()
+	return decodeResponse(response, dest)
+}
+
+// decodeResponse reads the response body, decodes it into dest and closes
+// the body afterwards.
+//
+// If the body can't be decoded or the response indicates an error without
+// providing an error message, an *APIError containing the status code and
+// the raw body will be returned so that the user sees what went wrong.
+func decodeResponse(response *http.Response, dest interface{}) error {
+	defer response.Body.Close()
+
+	if response.StatusCode == 404 {
+		return ErrEndpointNotFound
+	}
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDaemonUnreachable, err)
+	}
+
+	// Empty responses are accepted as long as the request was successful.
+	if len(bytes.TrimSpace(body)) == 0 {
+		if response.StatusCode >= http.StatusBadRequest {
+			return newAPIError(response, body)
+		}
+		return nil
+	}
+
+	var result types.Response
+
+	if err := json.Unmarshal(body, &result); err != nil {
+		return newAPIError(response, body)
+	}
+
+	if !result.Success && result.Message == "" {
+		return newAPIError(response, body)
+	}
+
+	if dest == nil {
+		return nil
+	}
+
+	if err := json.Unmarshal(body, dest); err != nil {
+		return newAPIError(response, body)
+	}
 
 	return nil
 }
 
+// requestURL negotiates the API version if that hasn't been done yet and
+// creates the URL for the given route using the negotiated version.
+func (c *Client) requestURL(route string) (string, error) {
+	if err := c.negotiateVersion(); err != nil {
+		return "", err
+	}
+
+	return c.buildRequestURL(route), nil
+}
+
+// negotiateVersion requests the API versions supported by the daemon and
+// selects the API version that will be used for all subsequent requests.
+//
+// If an API version has been configured, the daemon has to support exactly
+// this version. Otherwise, the latest version supported by both the client
+// and the daemon will be selected. Daemons that don't provide their build
+// information are assumed to support the configured version or v1.
+func (c *Client) negotiateVersion() error {
+	if c.negotiated {
+		return nil
+	}
+
+	var versionResponse types.VersionResponse
+
+	if err := c.BuildInfo(&versionResponse); err != nil {
+		if err != ErrEndpointNotFound {
+			return err
+		}
+		if c.apiConnection.Version == "" {
+			c.apiConnection.Version = version.APIVersions[0]
+		}
+		c.negotiated = true
+		return nil
+	}
+
+	supported := versionResponse.Data.APIVersions
+	selected, ok := selectVersion(c.apiConnection.Version, version.APIVersions, supported)
+
+	if !ok {
+		return fmt.Errorf("%w: client uses %s, daemon supports %s", ErrAPIVersionMismatch,
+			c.apiConnection.Version, strings.Join(supported, ", "))
+	}
+
+	c.apiConnection.Version = selected
+	c.negotiated = true
+
+	return nil
+}
+
+// selectVersion selects the API version to be used. If configured is set,
+// it has to be supported by the daemon. Otherwise, the latest version in
+// own that is supported by the daemon will be returned.
+func selectVersion(configured string, own, supported []string) (string, bool) {
+	isSupported := func(v string) bool {
+		for _, s := range supported {
+			if s == v {
+				return true
+			}
+		}
+		return false
+	}
+
+	if configured != "" {
+		configured = strings.TrimPrefix(configured, "/")
+		return configured, isSupported(configured)
+	}
+
+	for i := len(own) - 1; i >= 0; i-- {
+		if isSupported(own[i]) {
+			return own[i], true
+		}
+	}
+
+	return "", false
+}
+
 // buildRequestURL creates an entire URL that a request can be sent to. The
 // route should be in the form `/my-endpoint`.
 func (c *Client) buildRequestURL(route string) string {
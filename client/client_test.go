@@ -0,0 +1,345 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides the Dice client. While the core package provides
+// the daemon, the client is responsible for talking to the daemon's API.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newTestServer creates a server that supports the given API versions. See
+// newTestHandler for the available routes.
+func newTestServer(apiVersions []string) *httptest.Server {
+	return httptest.NewServer(newTestHandler(apiVersions))
+}
+
+// newTestHandler creates a handler that answers /version with the build
+// information and /<version>/ping with an empty response for each supported
+// version. All other routes return 404.
+func newTestHandler(apiVersions []string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(types.VersionResponse{
+			Response: types.Response{Success: true},
+			Data:     types.VersionOutput{Version: "test", APIVersions: apiVersions},
+		})
+	})
+
+	for _, v := range apiVersions {
+		mux.HandleFunc("/"+v+"/ping", func(w http.ResponseWriter, r *http.Request) {
+			_ = json.NewEncoder(w).Encode(types.Response{Success: true})
+		})
+	}
+
+	return mux
+}
+
+// newTestClient creates a client that talks to the given address using the
+// given API version, bypassing the environment configuration.
+func newTestClient(address, apiVersion string) *Client {
+	return &Client{
+		ctx:       context.Background(),
+		internal:  &http.Client{},
+		streaming: &http.Client{},
+		apiConnection: &APIConnection{
+			Address: address,
+			Version: apiVersion,
+		},
+		retryConfig: &RetryConfig{},
+		etags:       newETagCache(),
+	}
+}
+
+// TestClient_POST_matchingVersion checks if requests are sent to the routes
+// of the configured API version if the daemon supports that version.
+func TestClient_POST_matchingVersion(t *testing.T) {
+	server := newTestServer([]string{"v1", "v2"})
+	defer server.Close()
+
+	c := newTestClient(server.URL, "v1")
+
+	var response types.Response
+
+	if err := c.POST("/ping", nil, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Success {
+		t.Errorf("expected a successful response")
+	}
+
+	if c.apiConnection.Version != "v1" {
+		t.Errorf("got version %s, expected v1", c.apiConnection.Version)
+	}
+}
+
+// TestClient_POST_mismatchedVersion checks if a clear error is returned if
+// the configured API version isn't supported by the daemon.
+func TestClient_POST_mismatchedVersion(t *testing.T) {
+	server := newTestServer([]string{"v1"})
+	defer server.Close()
+
+	c := newTestClient(server.URL, "v2")
+
+	err := c.POST("/ping", nil, &types.Response{})
+
+	if !errors.Is(err, ErrAPIVersionMismatch) {
+		t.Errorf("got error %v, expected %v", err, ErrAPIVersionMismatch)
+	}
+}
+
+// TestClient_POST_negotiatedVersion checks if the latest API version that
+// is supported by both the client and the daemon will be selected if no
+// version has been configured.
+func TestClient_POST_negotiatedVersion(t *testing.T) {
+	server := newTestServer([]string{"v1", "v0"})
+	defer server.Close()
+
+	c := newTestClient(server.URL, "")
+
+	if err := c.POST("/ping", nil, &types.Response{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if c.apiConnection.Version != "v1" {
+		t.Errorf("got version %s, expected v1", c.apiConnection.Version)
+	}
+}
+
+// TestClient_POST_legacyDaemon checks if the configured API version is used
+// if the daemon doesn't provide any build information.
+func TestClient_POST_legacyDaemon(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/ping", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(types.Response{Success: true})
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	c := newTestClient(server.URL, "v1")
+
+	if err := c.POST("/ping", nil, &types.Response{}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestClient_POST_errorBodies checks if responses that can't be decoded are
+// returned as an APIError carrying the status code and the raw body.
+func TestClient_POST_errorBodies(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+	}{
+		{status: http.StatusInternalServerError, body: "something went wrong"},
+		{status: http.StatusOK, body: `{"success": true, "data": `},
+		{status: http.StatusBadGateway, body: `{"success": false}`},
+	}
+
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/version" {
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(test.status)
+			_, _ = w.Write([]byte(test.body))
+		}))
+
+		c := newTestClient(server.URL, "v1")
+		err := c.POST("/ping", nil, &types.Response{})
+
+		var apiErr *APIError
+
+		if !errors.As(err, &apiErr) {
+			t.Errorf("got error %v, expected an APIError", err)
+		} else if apiErr.StatusCode != test.status || apiErr.Body != test.body {
+			t.Errorf("got %d %s, expected %d %s", apiErr.StatusCode, apiErr.Body, test.status, test.body)
+		}
+
+		server.Close()
+	}
+}
+
+// TestClient_POST_networkError checks if network errors can be told apart
+// from API errors.
+func TestClient_POST_networkError(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	address := server.URL
+	server.Close()
+
+	c := newTestClient(address, "v1")
+	err := c.POST("/ping", nil, &types.Response{})
+
+	if !errors.Is(err, ErrDaemonUnreachable) {
+		t.Errorf("got error %v, expected %v", err, ErrDaemonUnreachable)
+	}
+}
+
+// TestClient_POST_timeout checks if requests to a slow daemon are aborted
+// as soon as the configured timeout expires.
+func TestClient_POST_timeout(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newTestClient(server.URL, "v1")
+	c.negotiated = true
+	c.SetTimeout(50 * time.Millisecond)
+
+	start := time.Now()
+	err := c.POST("/ping", nil, &types.Response{})
+
+	if !errors.Is(err, ErrDaemonUnreachable) {
+		t.Errorf("got error %v, expected %v", err, ErrDaemonUnreachable)
+	}
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("request took %v, expected it to time out after 50ms", elapsed)
+	}
+}
+
+// TestClient_Stream_cancel checks if a streaming request is aborted when the
+// client's context gets cancelled, as it happens on an interrupt signal.
+func TestClient_Stream_cancel(t *testing.T) {
+	release := make(chan struct{})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.(http.Flusher).Flush()
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	c := newTestClient(server.URL, "v1")
+	c.negotiated = true
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		c.cancel()
+	}()
+
+	done := make(chan error)
+
+	go func() {
+		done <- c.Stream("/logs", nil, ioutil.Discard)
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("expected an error for the cancelled request")
+		}
+	case <-time.After(time.Second):
+		t.Errorf("request hasn't been aborted")
+	}
+}
+
+// TestClient_POST_socket checks if the client is able to send requests to a
+// daemon listening on a Unix socket.
+func TestClient_POST_socket(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dice-client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socket := filepath.Join(dir, "dice.sock")
+
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewUnstartedServer(newTestHandler([]string{"v1"}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	c := newTestClient("http://dice", "v1")
+	c.internal.Transport = newSocketTransport(socket)
+
+	var response types.Response
+
+	if err := c.POST("/ping", nil, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Success {
+		t.Errorf("expected a successful response")
+	}
+}
+
+// TestClient_POST_etag checks if the client sends the ETag of a previous
+// response and uses the cached response if the daemon responds with 304.
+func TestClient_POST_etag(t *testing.T) {
+	data := "n1"
+	notModified := 0
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := `"` + data + `"`
+		w.Header().Set("ETag", etag)
+
+		if r.Header.Get("If-None-Match") == etag {
+			notModified++
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(types.Response{Success: true, Data: data})
+	}))
+	defer server.Close()
+
+	c := newTestClient(server.URL, "v1")
+	c.negotiated = true
+
+	for i, expected := range []string{"n1", "n1", "n2"} {
+		if i == 2 {
+			data = "n2"
+		}
+
+		var response types.Response
+
+		if err := c.POST("/nodes/list", types.NodeListOptions{}, &response); err != nil {
+			t.Fatal(err)
+		}
+
+		if response.Data != expected {
+			t.Errorf("request %d: got data %v, expected %s", i, response.Data, expected)
+		}
+	}
+
+	if notModified != 1 {
+		t.Errorf("got %d responses with 304, expected 1", notModified)
+	}
+}
@@ -0,0 +1,127 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides the Dice client. While the core package provides
+// the daemon, the client is responsible for talking to the daemon's API.
+package client
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// RetryConfig determines how failed requests are retried. Only transient
+// errors - connection errors and 5xx responses - will be retried.
+//
+// Retries is the number of retries after the first attempt, so 0 disables
+// retrying. The delay between two attempts starts at Backoff and doubles
+// after each retry. No retry will be started if it would exceed Deadline,
+// which is measured from the first attempt.
+type RetryConfig struct {
+	Retries  int           `json:"retries"`
+	Backoff  time.Duration `json:"backoff"`
+	Deadline time.Duration `json:"deadline"`
+}
+
+// SetRetries overrides the configured number of retries permanently, for
+// example if the --retries flag has been provided.
+func (c *Client) SetRetries(retries int) {
+	c.retryConfig.Retries = retries
+}
+
+// send sends a request with the given method and body to url. If the request
+// fails with a transient error, it will be retried according to the client's
+// retry configuration. The last response or error will be returned, where
+// errors are wrapped by ErrDaemonUnreachable. Responses carrying an ETag
+// are cached, see etagCache.
+//
+// The request will be aborted as soon as the client's context is cancelled,
+// even if it is waiting for a retry.
+func (c *Client) send(client *http.Client, method, url string, body []byte) (*http.Response, error) {
+	start := time.Now()
+	backoff := c.retryConfig.Backoff
+
+	key := c.etags.key(method, url, body)
+	etag := c.etags.etag(key)
+
+	for attempt := 0; ; attempt++ {
+		response, err := c.sendOnce(client, method, url, body, etag)
+
+		if !isTransient(response, err) || attempt >= c.retryConfig.Retries ||
+			time.Since(start)+backoff > c.retryConfig.Deadline {
+			if err != nil {
+				return response, fmt.Errorf("%w: %v", ErrDaemonUnreachable, err)
+			}
+			return c.etags.apply(key, response)
+		}
+
+		if response != nil {
+			_, _ = io.Copy(ioutil.Discard, response.Body)
+			_ = response.Body.Close()
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-c.ctx.Done():
+			return nil, fmt.Errorf("%w: %v", ErrDaemonUnreachable, c.ctx.Err())
+		}
+
+		backoff *= 2
+	}
+}
+
+// sendOnce sends a single request using the given HTTP client. If etag is
+// not empty, it will be sent in the If-None-Match header.
+func (c *Client) sendOnce(client *http.Client, method, url string, body []byte, etag string) (*http.Response, error) {
+	var reader io.Reader
+
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	request, err := http.NewRequest(method, url, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	request = request.WithContext(c.ctx)
+
+	if method == http.MethodPost {
+		request.Header.Set("Content-Type", contentType)
+	}
+
+	if etag != "" {
+		request.Header.Set("If-None-Match", etag)
+	}
+
+	if c.apiConnection.Token != "" {
+		request.Header.Set("Authorization", "Bearer "+c.apiConnection.Token)
+	}
+
+	return client.Do(request)
+}
+
+// isTransient indicates whether a request has failed with a transient error
+// and therefore may succeed when it is retried.
+func isTransient(response *http.Response, err error) bool {
+	if err != nil {
+		return true
+	}
+
+	return response.StatusCode >= http.StatusInternalServerError
+}
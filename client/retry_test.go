@@ -0,0 +1,183 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package client provides the Dice client. While the core package provides
+// the daemon, the client is responsible for talking to the daemon's API.
+package client
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer is an API server that fails the first failures requests with
+// the given status code and succeeds afterwards.
+type flakyServer struct {
+	failures int32
+	status   int
+	attempts int32
+}
+
+func (f *flakyServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/version" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if atomic.AddInt32(&f.attempts, 1) <= f.failures {
+		w.WriteHeader(f.status)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{"success": true})
+}
+
+// newRetryClient creates a test client for the given server address that
+// uses the given number of retries.
+func newRetryClient(address string, retries int) *Client {
+	c := newTestClient(address, "v1")
+	c.retryConfig = &RetryConfig{
+		Retries:  retries,
+		Backoff:  time.Millisecond,
+		Deadline: time.Second,
+	}
+	return c
+}
+
+// TestClient_POST_retry checks if 5xx responses are retried until the
+// request succeeds.
+func TestClient_POST_retry(t *testing.T) {
+	f := &flakyServer{failures: 2, status: http.StatusServiceUnavailable}
+
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	c := newRetryClient(server.URL, 3)
+
+	var response struct {
+		Success bool `json:"success"`
+	}
+
+	if err := c.POST("/ping", map[string]string{"key": "value"}, &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if !response.Success {
+		t.Errorf("expected a successful response")
+	}
+
+	if attempts := atomic.LoadInt32(&f.attempts); attempts != 3 {
+		t.Errorf("got %d attempts, expected 3", attempts)
+	}
+}
+
+// TestClient_POST_noRetry checks if requests aren't retried by default and
+// if 4xx responses aren't retried at all.
+func TestClient_POST_noRetry(t *testing.T) {
+	tests := []struct {
+		status  int
+		retries int
+	}{
+		{status: http.StatusServiceUnavailable, retries: 0},
+		{status: http.StatusUnprocessableEntity, retries: 3},
+	}
+
+	for _, test := range tests {
+		f := &flakyServer{failures: 1, status: test.status}
+		server := httptest.NewServer(f)
+
+		c := newRetryClient(server.URL, test.retries)
+		_ = c.POST("/ping", nil, &struct{}{})
+
+		if attempts := atomic.LoadInt32(&f.attempts); attempts != 1 {
+			t.Errorf("status %d: got %d attempts, expected 1", test.status, attempts)
+		}
+
+		server.Close()
+	}
+}
+
+// TestClient_POST_maxAttempts checks if the client gives up after the
+// configured number of retries.
+func TestClient_POST_maxAttempts(t *testing.T) {
+	f := &flakyServer{failures: 10, status: http.StatusInternalServerError}
+
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	c := newRetryClient(server.URL, 2)
+	_ = c.POST("/ping", nil, &struct{}{})
+
+	if attempts := atomic.LoadInt32(&f.attempts); attempts != 3 {
+		t.Errorf("got %d attempts, expected 3", attempts)
+	}
+}
+
+// TestClient_POST_deadline checks if the client stops retrying as soon as
+// the next retry would exceed the configured deadline.
+func TestClient_POST_deadline(t *testing.T) {
+	f := &flakyServer{failures: 10, status: http.StatusInternalServerError}
+
+	server := httptest.NewServer(f)
+	defer server.Close()
+
+	c := newRetryClient(server.URL, 10)
+	c.retryConfig.Backoff = 40 * time.Millisecond
+	c.retryConfig.Deadline = 100 * time.Millisecond
+
+	_ = c.POST("/ping", nil, &struct{}{})
+
+	// The first retry is started after 40ms, the second one would be
+	// started after another 80ms and thus exceed the deadline.
+	if attempts := atomic.LoadInt32(&f.attempts); attempts != 2 {
+		t.Errorf("got %d attempts, expected 2", attempts)
+	}
+}
+
+// TestClient_POST_connectionError checks if connection errors are retried
+// until the daemon becomes available.
+func TestClient_POST_connectionError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Close the listener immediately, so that the first attempts fail, and
+	// start the server on the same address a bit later.
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	server := http.Server{Handler: &flakyServer{}}
+	defer server.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		if listener, err := net.Listen("tcp", address); err == nil {
+			_ = server.Serve(listener)
+		}
+	}()
+
+	c := newRetryClient("http://"+address, 10)
+	c.retryConfig.Backoff = 20 * time.Millisecond
+	c.retryConfig.Deadline = 10 * time.Second
+
+	if err := c.POST("/ping", nil, &struct{}{}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -17,8 +17,13 @@
 package client
 
 import (
+	"context"
 	"github.com/dominikbraun/dice/config"
+	"net"
 	"net/http"
+	"os"
+	"os/signal"
+	"time"
 )
 
 // setupConfig sets up the environment variable reader and sets all default
@@ -38,12 +43,61 @@ func (c *Client) setupConfig() error {
 	return nil
 }
 
-// setupInternal sets up the internal HTTP client.
+// setupContext sets up the context all requests are bound to. As soon as
+// an interrupt signal is received, the context gets cancelled so that any
+// in-flight request is aborted. Subsequent signals are handled by Go's
+// default behavior again.
+func (c *Client) setupContext() error {
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+
+	go func() {
+		<-interrupt
+		signal.Stop(interrupt)
+		c.cancel()
+	}()
+
+	return nil
+}
+
+// setupInternal sets up the internal HTTP clients. The request timeout is
+// given in milliseconds and only applies to regular, non-streaming requests.
+//
+// If a Unix socket has been configured, all connections will be established
+// to that socket instead of the host of the configured address.
 func (c *Client) setupInternal() error {
-	c.internal = &http.Client{}
+	transport := http.DefaultTransport
+
+	if socket := c.config.GetString("dice-socket"); socket != "" {
+		transport = newSocketTransport(socket)
+	}
+
+	c.internal = &http.Client{
+		Transport: transport,
+		Timeout:   time.Duration(c.config.GetInt("dice-timeout")) * time.Millisecond,
+	}
+	c.streaming = &http.Client{
+		Transport: transport,
+	}
+	c.etags = newETagCache()
+
 	return nil
 }
 
+// newSocketTransport creates a transport that dials the given Unix socket
+// regardless of the requested address.
+func newSocketTransport(socket string) http.RoundTripper {
+	var dialer net.Dialer
+
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+}
+
 // setupAPIConnection reads the configured API connection data. These values
 // are read from environment variables since the client's config reader is a
 // config.Environment.
@@ -54,6 +108,19 @@ func (c *Client) setupAPIConnection() error {
 	c.apiConnection = &APIConnection{
 		Address: c.config.GetString("dice-address"),
 		Version: c.config.GetString("dice-api-version"),
+		Token:   c.config.GetString("dice-token"),
+	}
+
+	return nil
+}
+
+// setupRetryConfig reads the configured retry behavior. Retrying is disabled
+// by default. The backoff and deadline values are given in milliseconds.
+func (c *Client) setupRetryConfig() error {
+	c.retryConfig = &RetryConfig{
+		Retries:  c.config.GetInt("dice-retries"),
+		Backoff:  time.Duration(c.config.GetInt("dice-retry-backoff")) * time.Millisecond,
+		Deadline: time.Duration(c.config.GetInt("dice-retry-deadline")) * time.Millisecond,
 	}
 
 	return nil
@@ -17,9 +17,12 @@ package main
 import (
 	"github.com/dominikbraun/dice/cli"
 	"github.com/dominikbraun/dice/client"
+	"os"
 )
 
 func main() {
 	diceClient, _ := client.New()
-	_ = cli.New(diceClient).Execute()
+	err := cli.New(diceClient).Execute()
+
+	os.Exit(cli.ExitCode(err))
 }
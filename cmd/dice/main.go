@@ -15,15 +15,21 @@
 package main
 
 import (
+	"flag"
 	"github.com/dominikbraun/dice/core"
 	"log"
 )
 
 func main() {
+	strict := flag.Bool("strict", false, "refuse to start if the store contains conflicting entities")
+	flag.Parse()
+
 	dice, err := core.NewDice()
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	dice.SetStrict(*strict)
+
 	log.Fatal(dice.Run())
 }
@@ -19,20 +19,49 @@ package config
 // They serve as defaults in case the user hasn't specified any other
 // values - for the CLI, this can be done with environment variables.
 var CLIDefaults = map[string]interface{}{
-	"dice-address":     "http://127.0.0.1:9292",
-	"dice-api-version": "v1",
+	"dice-address":        "http://127.0.0.1:9292",
+	"dice-api-version":    "v1",
+	"dice-retries":        0,
+	"dice-retry-backoff":  100,
+	"dice-retry-deadline": 10000,
+	"dice-timeout":        30000,
+	"dice-socket":         "",
+	"dice-token":          "",
 }
 
 // DiceDefaults sets the defaults for core-related configuration values.
 // They serve as defaults in case the user hasn't specified any other
 // values - for the core, this can be done in the Dice config file.
 var DiceDefaults = map[string]interface{}{
-	"dice-logfile":         "dice.log",
-	"api-server-logfile":   "dice.log",
-	"proxy-logfile":        "dice.log",
-	"kv-store-file":        "dice-store",
-	"api-server-port":      "9292",
-	"proxy-port":           "8080",
-	"healthcheck-interval": 15000,
-	"healthcheck-timeout":  5000,
+	"dice-logfile":                "dice.log",
+	"api-server-logfile":          "dice.log",
+	"proxy-logfile":               "dice.log",
+	"store-backend":               "bolt",
+	"store-path":                  "dice-store",
+	"redis-address":               "127.0.0.1:6379",
+	"redis-password":              "",
+	"redis-db":                    0,
+	"redis-namespace":             "dice",
+	"redis-timeout":               5000,
+	"audit-logfile":               "dice-audit.log",
+	"event-retention":             100,
+	"api-server-port":             "9292",
+	"api-server-socket":           "",
+	"api-server-max-header-bytes": 65536,
+	"api-server-token":            "",
+	"api-server-request-log":      true,
+	"proxy-port":                  "8080",
+	"proxy-zone":                  "",
+	"proxy-write-timeout":         30000,
+	"proxy-retry-after":           5,
+	"proxy-max-header-bytes":      65536,
+	"proxy-dial-timeout":          0,
+	"default-balancing":           "weighted_round_robin",
+	"slow-start-window":           0,
+	"healthcheck-interval":        15000,
+	"healthcheck-timeout":         5000,
+	"healthcheck-concurrency":     10,
+	"healthcheck-log-window":      60000,
+	"healthcheck-healthy-count":   1,
+	"healthcheck-unhealthy-count": 1,
 }
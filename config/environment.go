@@ -17,12 +17,18 @@ package config
 
 import (
 	"os"
+	"sort"
 	"strconv"
+	"strings"
 )
 
 // Environment represents a set of environment variables. It can be used
 // as a config.Reader. Compared to a direct access to environment variables,
 // it takes defaults and provides values with different data types.
+//
+// A key can be set using a variable with the key's name or with the key's
+// upper-cased name, where dashes are replaced with underscores. For example,
+// dice-address can be set using DICE_ADDRESS.
 type Environment map[string]interface{}
 
 // Get implements Reader.Get. Get looks up the environment variable whose
@@ -30,7 +36,7 @@ type Environment map[string]interface{}
 // If it doesn't exist, it searches for a default value. If that fails as
 // well, `nil` will be returned.
 func (e Environment) Get(key string) interface{} {
-	if envVar := os.Getenv(key); envVar != "" {
+	if envVar := lookupEnv(key); envVar != "" {
 		return envVar
 	}
 
@@ -44,7 +50,7 @@ func (e Environment) Get(key string) interface{} {
 // GetString implements Reader.GetString. Does the same as Get, but returns
 // an empty string if the key cannot be found.
 func (e Environment) GetString(key string) string {
-	if envVar := os.Getenv(key); envVar != "" {
+	if envVar := lookupEnv(key); envVar != "" {
 		return envVar
 	}
 
@@ -58,8 +64,8 @@ func (e Environment) GetString(key string) string {
 // GetInt implements Reader.GetInt. Does the same as Get, but returns 0
 // (zero) if the key cannot be found.
 func (e Environment) GetInt(key string) int {
-	if envVar := os.Getenv(key); envVar != "" {
-		if value, err := strconv.Atoi(envVar); err != nil {
+	if envVar := lookupEnv(key); envVar != "" {
+		if value, err := strconv.Atoi(envVar); err == nil {
 			return value
 		}
 	}
@@ -74,8 +80,8 @@ func (e Environment) GetInt(key string) int {
 // GetBool implements Reader.GetBool. Does the same as Get, but returns false
 // if the key cannot be found.
 func (e Environment) GetBool(key string) bool {
-	if envVar := os.Getenv(key); envVar != "" {
-		if value, err := strconv.ParseBool(envVar); err != nil {
+	if envVar := lookupEnv(key); envVar != "" {
+		if value, err := strconv.ParseBool(envVar); err == nil {
 			return value
 		}
 	}
@@ -92,3 +98,36 @@ func (e Environment) GetBool(key string) bool {
 func (e Environment) SetDefault(key string, value interface{}) {
 	e[key] = value
 }
+
+// Keys implements Reader.Keys. Since environment variables can't be told
+// apart from other variables, only keys with a default value are returned.
+func (e Environment) Keys() []string {
+	keys := make([]string, 0, len(e))
+
+	for key := range e {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// Source implements Reader.Source.
+func (e Environment) Source(key string) Source {
+	if lookupEnv(key) != "" {
+		return EnvSource
+	}
+
+	return DefaultSource
+}
+
+// lookupEnv returns the value of the environment variable for the given key.
+// If there is no variable with the key's name, the variable with the key's
+// upper-cased name is used, see Environment.
+func lookupEnv(key string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+
+	return os.Getenv(strings.ToUpper(strings.ReplaceAll(key, "-", "_")))
+}
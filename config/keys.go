@@ -0,0 +1,131 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config provides configuration reader implementations.
+package config
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Key describes a configuration key recognized by the Dice daemon, along
+// with its default value and the type of its value.
+type Key struct {
+	Name        string      `json:"name"`
+	Default     interface{} `json:"default"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+}
+
+// descriptions holds a short description for each key in DiceDefaults.
+var descriptions = map[string]string{
+	"dice-logfile":                "logfile of the Dice core",
+	"api-server-logfile":          "logfile of the API server",
+	"proxy-logfile":               "logfile of the proxy",
+	"store-backend":               "key-value store backend: bolt, memory or redis",
+	"store-path":                  "path of the bolt database file",
+	"redis-address":               "address of the Redis server",
+	"redis-password":              "password for the Redis server",
+	"redis-db":                    "Redis database number",
+	"redis-namespace":             "prefix for all Redis keys",
+	"redis-timeout":               "timeout for Redis operations in milliseconds",
+	"audit-logfile":               "logfile of the audit log",
+	"event-retention":             "number of state change events kept for each instance and node",
+	"api-server-port":             "port the API server listens on",
+	"api-server-socket":           "Unix socket the API server listens on instead of the port",
+	"api-server-max-header-bytes": "maximum size of the request headers accepted by the API server",
+	"api-server-token":            "API token required for privileged endpoints like the proxy logs",
+	"api-server-request-log":      "whether API requests are logged, false disables the request log",
+	"proxy-port":                  "comma-separated ports or addresses the proxy listens on",
+	"proxy-zone":                  "zone the proxy is running in, preferred by all schedulers",
+	"proxy-write-timeout":         "time a client has for accepting each response chunk in milliseconds",
+	"proxy-retry-after":           "Retry-After value in seconds if a service has no available instance",
+	"proxy-max-header-bytes":      "maximum size of the request headers accepted by the proxy",
+	"proxy-dial-timeout":          "time for connecting to an instance in milliseconds, 0 uses the default",
+	"default-balancing":           "balancing method for services that don't specify one",
+	"slow-start-window":           "time in milliseconds in which the traffic for attached instances ramps up",
+	"healthcheck-interval":        "interval between two health checks in milliseconds",
+	"healthcheck-timeout":         "timeout for a single health check in milliseconds",
+	"healthcheck-concurrency":     "number of instances checked at the same time",
+	"healthcheck-log-window":      "time in milliseconds in which identical state change messages are collapsed",
+	"healthcheck-healthy-count":   "number of successful checks until a dead instance is marked as alive",
+	"healthcheck-unhealthy-count": "number of failed checks until an alive instance is marked as dead",
+}
+
+// renamedKeys maps keys that have been renamed to their current names. The
+// old names are deprecated, but their values are still read.
+var renamedKeys = map[string]string{
+	"kv-store-file": "store-path",
+}
+
+// Keys returns all configuration keys recognized by the Dice daemon, sorted
+// by their names. The type of a key is the type of its default value.
+func Keys() []Key {
+	keys := make([]Key, 0, len(DiceDefaults))
+
+	for name, value := range DiceDefaults {
+		keys = append(keys, Key{
+			Name:        name,
+			Default:     value,
+			Type:        fmt.Sprintf("%T", value),
+			Description: descriptions[name],
+		})
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+
+	return keys
+}
+
+// UnknownKeys returns all keys that have been set in the configuration file
+// but aren't recognized by the Dice daemon. Usually, these keys are typos.
+func UnknownKeys(r Reader) []string {
+	unknown := make([]string, 0)
+
+	for _, key := range r.Keys() {
+		_, renamed := renamedKeys[key]
+
+		if _, ok := DiceDefaults[key]; !ok && !renamed && r.Source(key) == FileSource {
+			unknown = append(unknown, key)
+		}
+	}
+
+	return unknown
+}
+
+// RenamedKeys returns all deprecated keys that have been set in the
+// configuration file or the environment, mapped to their current names.
+func RenamedKeys(r Reader) map[string]string {
+	renamed := make(map[string]string)
+
+	for key, current := range renamedKeys {
+		if r.Source(key) != DefaultSource {
+			renamed[key] = current
+		}
+	}
+
+	return renamed
+}
+
+// SetRenamedDefaults uses the value of each deprecated key that has been set
+// as the default value for its current name. This way, configurations using
+// the old names keep working unless the current name has been set as well.
+func SetRenamedDefaults(r Reader) {
+	for key, current := range RenamedKeys(r) {
+		r.SetDefault(current, r.Get(key))
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config provides configuration reader implementations.
+package config
+
+import "testing"
+
+// TestKeys checks if a recognized key is listed along with its default
+// value and type, and if all keys have a description.
+func TestKeys(t *testing.T) {
+	keys := Keys()
+
+	if len(keys) != len(DiceDefaults) {
+		t.Fatalf("expected %d keys, got %d", len(DiceDefaults), len(keys))
+	}
+
+	found := false
+
+	for _, k := range keys {
+		if k.Description == "" {
+			t.Errorf("expected a description for key %s", k.Name)
+		}
+
+		if k.Name == "proxy-port" {
+			found = true
+			expected := Key{Name: "proxy-port", Default: "8080", Type: "string", Description: descriptions["proxy-port"]}
+
+			if k != expected {
+				t.Errorf("expected %v, got %v", expected, k)
+			}
+		}
+	}
+
+	if !found {
+		t.Errorf("expected key proxy-port to be listed")
+	}
+}
@@ -17,16 +17,46 @@ package config
 
 import (
 	"github.com/spf13/viper"
+	"os"
+	"sort"
+	"strings"
+)
+
+const (
+	// envPrefix is the prefix of environment variables read by a File.
+	envPrefix string = "dice"
+)
+
+// Source indicates where a configuration value has been taken from.
+type Source string
+
+const (
+	DefaultSource Source = "default"
+	FileSource    Source = "file"
+	EnvSource     Source = "env"
 )
 
 // Reader represents a configuration reader. This can be a configuration
 // file, system environment variables or other configuration sources.
+//
+// Keys returns all known keys in alphabetical order, and Source returns
+// where the effective value for a given key comes from.
 type Reader interface {
 	Get(key string) interface{}
 	GetString(key string) string
 	GetInt(key string) int
 	GetBool(key string) bool
 	SetDefault(key string, value interface{})
+	Keys() []string
+	Source(key string) Source
+}
+
+// File is a configuration file reader. Values from the configuration file
+// can be overridden using environment variables: The variable name is the
+// upper-cased key prefixed with DICE_, where dashes are replaced with
+// underscores. For example, proxy-port becomes DICE_PROXY_PORT.
+type File struct {
+	*viper.Viper
 }
 
 // NewFile creates a new configuration file reader.
@@ -38,14 +68,46 @@ func NewFile(filename string) (Reader, error) {
 	r.AddConfigPath("$HOME/.dice")
 	r.AddConfigPath(".")
 
+	r.SetEnvPrefix(envPrefix)
+	r.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	r.AutomaticEnv()
+
 	if err := r.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); ok {
-			return r, nil
+			return &File{Viper: r}, nil
 		}
 		return nil, err
 	}
 
-	return r, nil
+	return &File{Viper: r}, nil
+}
+
+// Keys implements Reader.Keys.
+func (f *File) Keys() []string {
+	keys := f.AllKeys()
+	sort.Strings(keys)
+
+	return keys
+}
+
+// Source implements Reader.Source. Environment variables take precedence
+// over the configuration file, which takes precedence over defaults.
+func (f *File) Source(key string) Source {
+	if os.Getenv(envName(key)) != "" {
+		return EnvSource
+	}
+
+	if f.InConfig(key) {
+		return FileSource
+	}
+
+	return DefaultSource
+}
+
+// envName returns the name of the environment variable for the given key.
+func envName(key string) string {
+	name := strings.ReplaceAll(key, "-", "_")
+	return strings.ToUpper(envPrefix + "_" + name)
 }
 
 // NewFile creates a new environment variable reader.
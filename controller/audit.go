@@ -0,0 +1,43 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+)
+
+// AuditLog handles a POST request for retrieving the most recent entries of
+// the audit log. The request body has to contain valid AuditLogOptions.
+func (c *Controller) AuditLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.AuditLogOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		auditLog, err := c.backend.AuditLog(options)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: auditLog})
+	}
+}
@@ -27,3 +27,17 @@ func (c *Controller) ReloadConfig() http.HandlerFunc {
 		respond(w, r, http.StatusOK, types.Response{Success: true})
 	}
 }
+
+// PrintConfig handles a POST request for printing the effective configuration
+// of the Dice daemon. Secret values are redacted by the backend.
+func (c *Controller) PrintConfig() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		entries, err := c.backend.Config()
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: entries})
+	}
+}
@@ -16,8 +16,14 @@
 package controller
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/healthcheck"
 	"github.com/dominikbraun/dice/types"
+	"github.com/go-chi/chi/middleware"
 	"github.com/go-chi/render"
 	"net/http"
 )
@@ -33,22 +39,37 @@ var (
 // invoke the core functions and eventually return the core's responses.
 type Controller struct {
 	backend      Target
+	healthCheck  *healthcheck.HealthCheck
 	reloadConfig chan<- bool
 }
 
-// New creates a new Controller instance that uses the provided Target.
-func New(backend Target, reloadConfig chan<- bool) *Controller {
+// New creates a new Controller instance that uses the provided Target. The
+// health checker is used for triggering manual health checks.
+func New(backend Target, healthCheck *healthcheck.HealthCheck, reloadConfig chan<- bool) *Controller {
 	c := Controller{
 		backend:      backend,
+		healthCheck:  healthCheck,
 		reloadConfig: reloadConfig,
 	}
 
 	return &c
 }
 
+// backendFor returns the backend for performing the actions requested by r.
+// The backend records the ID and the remote address of r as the actor of
+// all actions in the audit log.
+func (c *Controller) backendFor(r *http.Request) Target {
+	return c.backend.WithActor(audit.Actor{
+		RequestID:     middleware.GetReqID(r.Context()),
+		RemoteAddress: r.RemoteAddr,
+	})
+}
+
 // respond sets an HTTP status code and renders any given response value.
+// The ID of the request is added to the response, see middleware.RequestID.
 // Note that a return statement is required after calling respond.
 func respond(w http.ResponseWriter, r *http.Request, status int, response types.Response) {
+	response.RequestID = middleware.GetReqID(r.Context())
 	w.WriteHeader(status)
 	render.JSON(w, r, response)
 }
@@ -57,8 +78,45 @@ func respond(w http.ResponseWriter, r *http.Request, status int, response types.
 // and creates an appropriate response on its own using that error.
 func respondError(w http.ResponseWriter, r *http.Request, status int, err error) {
 	response := types.Response{
-		Success: false,
-		Message: err.Error(),
+		Success:  false,
+		Message:  err.Error(),
+		Category: types.CategoryOf(err),
 	}
 	respond(w, r, status, response)
 }
+
+// respondWithETag does the same as respond, but additionally sets an ETag
+// header computed from the serialized response. If the request contains an
+// If-None-Match header with the same ETag, the response hasn't changed and
+// only status 304 will be sent.
+//
+// Since the request ID differs for each request, it is added only after the
+// ETag has been computed.
+func respondWithETag(w http.ResponseWriter, r *http.Request, status int, response types.Response) {
+	body, err := json.Marshal(response)
+	if err != nil {
+		respondError(w, r, http.StatusInternalServerError, ErrInternalServerError)
+		return
+	}
+
+	hash := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(hash[:]) + `"`
+
+	if response.RequestID = middleware.GetReqID(r.Context()); response.RequestID != "" {
+		if body, err = json.Marshal(response); err != nil {
+			respondError(w, r, http.StatusInternalServerError, ErrInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
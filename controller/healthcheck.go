@@ -0,0 +1,71 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/dominikbraun/dice/types"
+	"io"
+	"net/http"
+)
+
+// RunHealthCheck handles a POST request for running a manual health check.
+// The response contains the resulting alive state for each instance.
+//
+// The check is bound to the request context, so it will be aborted if the
+// client disconnects. An optional timeout can be set in the request body,
+// see HealthCheckRunOptions.
+func (c *Controller) RunHealthCheck() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.HealthCheckRunOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil && err != io.EOF {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		ctx := r.Context()
+
+		if options.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, options.Timeout)
+			defer cancel()
+		}
+
+		results, err := c.healthCheck.RunManually(ctx)
+		if err == context.DeadlineExceeded {
+			respondError(w, r, http.StatusGatewayTimeout, err)
+			return
+		} else if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		healthCheckList := make([]types.HealthCheckOutput, len(results))
+
+		for i, res := range results {
+			healthCheckList[i] = types.HealthCheckOutput{
+				ServiceID:  res.ServiceID,
+				NodeID:     res.NodeID,
+				InstanceID: res.InstanceID,
+				IsAlive:    res.IsAlive,
+			}
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: healthCheckList})
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/healthcheck"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/types"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestRegistry creates a service registry that contains the given
+// services.
+func newTestRegistry(services map[string]*registry.Service) *registry.ServiceRegistry {
+	serviceRegistry := registry.NewServiceRegistry(nil)
+	serviceRegistry.Services = services
+
+	return serviceRegistry
+}
+
+// TestController_RunHealthCheck tests Controller.RunHealthCheck. It sets up
+// a stub upstream for an alive instance and a closed port for a dead one,
+// runs a manual health check and asserts the reported alive states.
+func TestController_RunHealthCheck(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	aliveAddress := upstream.Listener.Addr().String()
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadAddress := closed.Addr().String()
+	_ = closed.Close()
+
+	node := &entity.Node{ID: "n1", Name: "127.0.0.1", IsAttached: true}
+	alive := &entity.Instance{ID: "i1", ServiceID: "s1", URL: aliveAddress, IsAttached: true}
+	dead := &entity.Instance{ID: "i2", ServiceID: "s1", URL: deadAddress, IsAttached: true, IsAlive: true}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity: &entity.Service{ID: "s1", IsEnabled: true},
+			Deployments: []registry.Deployment{
+				{Node: node, Instance: alive},
+				{Node: node, Instance: dead},
+			},
+		},
+	}
+
+	hc, err := healthcheck.New(healthcheck.Config{Timeout: time.Second, Concurrency: 2}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(nil, hc, nil)
+
+	w := httptest.NewRecorder()
+	c.RunHealthCheck()(w, httptest.NewRequest(http.MethodPost, "/admin/healthcheck/run", nil))
+
+	var response types.HealthCheckResponse
+
+	if err := json.NewDecoder(w.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"i1": true, "i2": false}
+
+	if len(response.Data) != len(expected) {
+		t.Fatalf("got %v results, expected %v", len(response.Data), len(expected))
+	}
+
+	for _, h := range response.Data {
+		if h.IsAlive != expected[h.InstanceID] {
+			t.Errorf("instance %s: got alive %v, expected %v", h.InstanceID, h.IsAlive, expected[h.InstanceID])
+		}
+	}
+
+	if dead.IsAlive {
+		t.Errorf("instance %s is still marked as alive", dead.ID)
+	}
+}
+
+// TestController_RunHealthCheck_timeout tests the timeout option of a manual
+// health check. The stub upstream never responds in time, so the check has
+// to be aborted and answered with 504.
+func TestController_RunHealthCheck_timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer upstream.Close()
+
+	address := upstream.Listener.Addr().String()
+
+	node := &entity.Node{ID: "n1", Name: "127.0.0.1", IsAttached: true}
+	instance := &entity.Instance{ID: "i1", ServiceID: "s1", URL: address, IsAttached: true}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity:      &entity.Service{ID: "s1", IsEnabled: true, HealthCheck: entity.HealthCheck{Path: "/health"}},
+			Deployments: []registry.Deployment{{Node: node, Instance: instance}},
+		},
+	}
+
+	hc, err := healthcheck.New(healthcheck.Config{Timeout: time.Minute, Concurrency: 1}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(nil, hc, nil)
+
+	body := strings.NewReader(`{"timeout": 100000000}`)
+	w := httptest.NewRecorder()
+	c.RunHealthCheck()(w, httptest.NewRequest(http.MethodPost, "/admin/healthcheck/run", body))
+
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("got status %v, expected %v", w.Code, http.StatusGatewayTimeout)
+	}
+}
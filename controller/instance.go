@@ -38,7 +38,57 @@ func (c *Controller) CreateInstance() http.HandlerFunc {
 		serviceRef := entity.ServiceReference(instanceCreate.ServiceRef)
 		nodeRef := entity.NodeReference(instanceCreate.NodeRef)
 
-		if err := c.backend.CreateInstance(serviceRef, nodeRef, instanceCreate.URL, instanceCreate.InstanceCreateOptions); err != nil {
+		if err := c.backendFor(r).CreateInstance(serviceRef, nodeRef, instanceCreate.URL, instanceCreate.InstanceCreateOptions); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// CreateInstances handles a POST request for creating an instance on each
+// of multiple nodes. The request body has to contain a valid InstancesCreate
+// instance. The result is reported for each node.
+func (c *Controller) CreateInstances() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var instancesCreate types.InstancesCreate
+
+		if err := json.NewDecoder(r.Body).Decode(&instancesCreate); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		serviceRef := entity.ServiceReference(instancesCreate.ServiceRef)
+
+		results, err := c.backendFor(r).CreateInstancesOnNodes(serviceRef, instancesCreate.NodeSelectOptions, instancesCreate.URLTemplate, instancesCreate.InstanceCreateOptions)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: results})
+	}
+}
+
+// CloneInstance handles a POST request for creating a new instance with the
+// configuration of an existing instance. The request URL has to contain a
+// valid instance reference, and the request body has to contain a valid
+// InstanceClone instance.
+func (c *Controller) CloneInstance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instanceRef := entity.InstanceReference(chi.URLParam(r, "ref"))
+
+		var instanceClone types.InstanceClone
+
+		if err := json.NewDecoder(r.Body).Decode(&instanceClone); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		nodeRef := entity.NodeReference(instanceClone.NodeRef)
+
+		if err := c.backendFor(r).CloneInstance(instanceRef, nodeRef, instanceClone.URL); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
@@ -53,8 +103,16 @@ func (c *Controller) AttachInstance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		instanceRef := entity.InstanceReference(chi.URLParam(r, "ref"))
 
-		if err := c.backend.AttachInstance(instanceRef); err != nil {
+		var options types.InstanceAttachOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).AttachInstance(instanceRef, options); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
 		}
 
 		respond(w, r, http.StatusOK, types.Response{Success: true})
@@ -67,7 +125,7 @@ func (c *Controller) DetachInstance() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		instanceRef := entity.InstanceReference(chi.URLParam(r, "ref"))
 
-		if err := c.backend.DetachInstance(instanceRef); err != nil {
+		if err := c.backendFor(r).DetachInstance(instanceRef); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -88,7 +146,7 @@ func (c *Controller) RemoveInstance() http.HandlerFunc {
 			return
 		}
 
-		if err := c.backend.RemoveInstance(instanceRef, options); err != nil {
+		if err := c.backendFor(r).RemoveInstance(instanceRef, options); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -112,8 +170,59 @@ func (c *Controller) InstanceInfo() http.HandlerFunc {
 	}
 }
 
+// InstanceDescribe handles a POST request for retrieving the enriched
+// information for an instance. The request URL has to contain a valid
+// instance reference.
+func (c *Controller) InstanceDescribe() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instanceRef := entity.InstanceReference(chi.URLParam(r, "ref"))
+
+		instanceDescribe, err := c.backend.InstanceDescribe(instanceRef)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: instanceDescribe})
+	}
+}
+
+// InstanceStats handles a POST request for retrieving the proxy stats of an
+// instance. The request URL has to contain a valid instance reference.
+func (c *Controller) InstanceStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instanceRef := entity.InstanceReference(chi.URLParam(r, "ref"))
+
+		instanceStats, err := c.backend.InstanceStats(instanceRef)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: instanceStats})
+	}
+}
+
+// InstanceEvents handles a POST request for retrieving the recent state
+// changes of an instance. The request URL has to contain a valid instance
+// reference.
+func (c *Controller) InstanceEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		instanceRef := entity.InstanceReference(chi.URLParam(r, "ref"))
+
+		eventList, err := c.backend.InstanceEvents(instanceRef)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: eventList})
+	}
+}
+
 // ListServices handles a POST request for retrieving a list of services. The
-// request body has to contain valid ServiceListOptions.
+// request body has to contain valid ServiceListOptions. Unchanged lists are
+// answered with 304 if the client provides the ETag of the previous response.
 func (c *Controller) ListInstances() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var options types.InstanceListOptions
@@ -129,6 +238,6 @@ func (c *Controller) ListInstances() http.HandlerFunc {
 			return
 		}
 
-		respond(w, r, http.StatusOK, types.Response{Success: true, Data: instanceList})
+		respondWithETag(w, r, http.StatusOK, types.Response{Success: true, Data: instanceList})
 	}
 }
@@ -0,0 +1,76 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+)
+
+// ProxyLogs handles a POST request for reading the last lines of the proxy
+// logfile. The request body has to contain valid LogsOptions.
+//
+// If the Follow option is set, the lines are returned as plain text instead
+// of JSON. New lines will be streamed to the client until it disconnects.
+func (c *Controller) ProxyLogs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.LogsOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		logfile := c.backend.ProxyLogfile()
+
+		lines, err := log.Tail(logfile, options.Lines)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		if !options.Follow {
+			respond(w, r, http.StatusOK, types.Response{Success: true, Data: lines})
+			return
+		}
+
+		flusher, _ := w.(http.Flusher)
+
+		writeLine := func(line string) error {
+			if _, err := fmt.Fprintln(w, line); err != nil {
+				return err
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+			return nil
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		for _, line := range lines {
+			if err := writeLine(line); err != nil {
+				return
+			}
+		}
+
+		_ = log.Follow(r.Context(), logfile, writeLine)
+	}
+}
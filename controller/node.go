@@ -34,7 +34,7 @@ func (c *Controller) CreateNode() http.HandlerFunc {
 			return
 		}
 
-		if err := c.backend.CreateNode(nodeCreate.Name, nodeCreate.NodeCreateOptions); err != nil {
+		if err := c.backendFor(r).CreateNode(nodeCreate.Name, nodeCreate.NodeCreateOptions); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
@@ -49,7 +49,7 @@ func (c *Controller) AttachNode() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		nodeRef := entity.NodeReference(chi.URLParam(r, "ref"))
 
-		if err := c.backend.AttachNode(nodeRef); err != nil {
+		if err := c.backendFor(r).AttachNode(nodeRef); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -63,7 +63,7 @@ func (c *Controller) DetachNode() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		nodeRef := entity.NodeReference(chi.URLParam(r, "ref"))
 
-		if err := c.backend.DetachNode(nodeRef); err != nil {
+		if err := c.backendFor(r).DetachNode(nodeRef); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -71,6 +71,60 @@ func (c *Controller) DetachNode() http.HandlerFunc {
 	}
 }
 
+// CordonNode handles a POST request for cordoning an existing node. The
+// request URL has to contain a valid node reference.
+func (c *Controller) CordonNode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeRef := entity.NodeReference(chi.URLParam(r, "ref"))
+
+		if err := c.backendFor(r).CordonNode(nodeRef); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// UncordonNode handles a POST request for uncordoning an existing node. The
+// request URL has to contain a valid node reference.
+func (c *Controller) UncordonNode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeRef := entity.NodeReference(chi.URLParam(r, "ref"))
+
+		if err := c.backendFor(r).UncordonNode(nodeRef); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// DrainNode handles a POST request for draining an existing node. The
+// request URL has to contain a valid node reference and the request body
+// has to contain valid NodeDrainOptions. The drain stops when the request
+// is cancelled, for example because the client went away.
+func (c *Controller) DrainNode() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeRef := entity.NodeReference(chi.URLParam(r, "ref"))
+
+		var options types.NodeDrainOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).DrainNode(r.Context(), nodeRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
 // RemoveNode handles a POST request for removing an existing node. The
 // request URL has to contain a valid node reference.
 func (c *Controller) RemoveNode() http.HandlerFunc {
@@ -84,7 +138,7 @@ func (c *Controller) RemoveNode() http.HandlerFunc {
 			return
 		}
 
-		if err := c.backend.RemoveNode(nodeRef, options); err != nil {
+		if err := c.backendFor(r).RemoveNode(nodeRef, options); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -108,8 +162,25 @@ func (c *Controller) NodeInfo() http.HandlerFunc {
 	}
 }
 
+// NodeEvents handles a POST request for retrieving the recent state changes
+// of a node. The request URL has to contain a valid node reference.
+func (c *Controller) NodeEvents() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		nodeRef := entity.NodeReference(chi.URLParam(r, "ref"))
+
+		eventList, err := c.backend.NodeEvents(nodeRef)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: eventList})
+	}
+}
+
 // ListNodes handles a POST request for retrieving a list of nodes. The request
-// body has to contain valid NodeListOptions.
+// body has to contain valid NodeListOptions. Unchanged lists are answered
+// with 304 if the client provides the ETag of the previous response.
 func (c *Controller) ListNodes() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var options types.NodeListOptions
@@ -125,6 +196,6 @@ func (c *Controller) ListNodes() http.HandlerFunc {
 			return
 		}
 
-		respond(w, r, http.StatusOK, types.Response{Success: true, Data: nodeList})
+		respondWithETag(w, r, http.StatusOK, types.Response{Success: true, Data: nodeList})
 	}
 }
@@ -0,0 +1,79 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// testTarget is a Target that only implements ListNodes. Calling any other
+// method will panic.
+type testTarget struct {
+	Target
+	nodes []types.NodeInfoOutput
+}
+
+func (tt *testTarget) ListNodes(options types.NodeListOptions) ([]types.NodeInfoOutput, error) {
+	return tt.nodes, nil
+}
+
+// TestController_ListNodes_etag tests the ETag support of the list handlers
+// using Controller.ListNodes. The first response has to carry an ETag. A
+// second request with that ETag has to be answered with 304 as long as the
+// node list doesn't change, and with 200 and a new ETag otherwise.
+func TestController_ListNodes_etag(t *testing.T) {
+	backend := &testTarget{nodes: []types.NodeInfoOutput{{ID: "n1", Name: "node1"}}}
+	c := New(backend, nil, nil)
+
+	list := func(etag string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodPost, "/nodes/list", strings.NewReader("{}"))
+		if etag != "" {
+			request.Header.Set("If-None-Match", etag)
+		}
+
+		recorder := httptest.NewRecorder()
+		c.ListNodes().ServeHTTP(recorder, request)
+
+		return recorder
+	}
+
+	first := list("")
+	etag := first.Header().Get("ETag")
+
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("got status %d and ETag %q, expected 200 with an ETag", first.Code, etag)
+	}
+
+	if second := list(etag); second.Code != http.StatusNotModified || second.Body.Len() != 0 {
+		t.Errorf("got status %d with %d bytes, expected 304 without body", second.Code, second.Body.Len())
+	}
+
+	backend.nodes = append(backend.nodes, types.NodeInfoOutput{ID: "n2", Name: "node2"})
+
+	third := list(etag)
+
+	if third.Code != http.StatusOK {
+		t.Errorf("got status %d, expected 200 for a changed list", third.Code)
+	}
+
+	if newETag := third.Header().Get("ETag"); newETag == etag {
+		t.Errorf("expected a new ETag for a changed list")
+	}
+}
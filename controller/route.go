@@ -0,0 +1,79 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+)
+
+// ListRoutes handles a POST request for listing all routes registered in
+// the service registry along with their target services.
+func (c *Controller) ListRoutes() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		routeList, err := c.backend.ListRoutes()
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: routeList})
+	}
+}
+
+// TestRoute handles a POST request for testing which instance a request to
+// a host would be routed to. The request body has to contain valid
+// RouteTestOptions.
+func (c *Controller) TestRoute() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.RouteTestOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		routeTest, err := c.backend.TestRoute(options)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: routeTest})
+	}
+}
+
+// SetRouteWeights handles a POST request for splitting the requests to a
+// URL across multiple services. The request body has to contain valid
+// RouteWeightsOptions.
+func (c *Controller) SetRouteWeights() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.RouteWeightsOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetRouteWeights(options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
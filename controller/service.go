@@ -34,7 +34,7 @@ func (c *Controller) CreateService() http.HandlerFunc {
 			return
 		}
 
-		if err := c.backend.CreateService(serviceCreate.Name, serviceCreate.ServiceCreateOptions); err != nil {
+		if err := c.backendFor(r).CreateService(serviceCreate.Name, serviceCreate.ServiceCreateOptions); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 			return
 		}
@@ -49,7 +49,7 @@ func (c *Controller) EnableService() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
 
-		if err := c.backend.EnableService(serviceRef); err != nil {
+		if err := c.backendFor(r).EnableService(serviceRef); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -57,13 +57,34 @@ func (c *Controller) EnableService() http.HandlerFunc {
 	}
 }
 
+// EnableServices handles a POST request for enabling all services matched
+// by a selector. The request body has to contain a ServiceSelectOptions JSON.
+func (c *Controller) EnableServices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.ServiceSelectOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		results, err := c.backendFor(r).EnableServices(options)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: results})
+	}
+}
+
 // DisableService handles a POST request for disabling an existing service.
 // The request URL has to contain a valid service reference.
 func (c *Controller) DisableService() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
 
-		if err := c.backend.DisableService(serviceRef); err != nil {
+		if err := c.backendFor(r).DisableService(serviceRef); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -71,6 +92,27 @@ func (c *Controller) DisableService() http.HandlerFunc {
 	}
 }
 
+// DisableServices handles a POST request for disabling all services matched
+// by a selector. The request body has to contain a ServiceSelectOptions JSON.
+func (c *Controller) DisableServices() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var options types.ServiceSelectOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		results, err := c.backendFor(r).DisableServices(options)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: results})
+	}
+}
+
 // UpdateService handles a POST request for updating a service. The request
 // URL has to contain a valid service reference, the body must provide a
 // valid instance of types.ServiceUpdate.
@@ -85,7 +127,7 @@ func (c *Controller) UpdateService() http.HandlerFunc {
 			return
 		}
 
-		if err := c.backend.UpdateService(serviceRef, serviceUpdate.TargetVersion); err != nil {
+		if err := c.backendFor(r).UpdateService(serviceRef, serviceUpdate.TargetVersion); err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 		}
 
@@ -93,6 +135,28 @@ func (c *Controller) UpdateService() http.HandlerFunc {
 	}
 }
 
+// PatchService handles a POST request for partially updating a service. The
+// request URL has to contain a valid service reference, the body must provide
+// a valid instance of types.ServicePatch.
+func (c *Controller) PatchService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var patch types.ServicePatch
+
+		if err := json.NewDecoder(r.Body).Decode(&patch); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).PatchService(serviceRef, patch); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
 // ServiceInfo handles a POST request for retrieving information for a
 // service. The request URL has to contain a valid service reference.
 func (c *Controller) ServiceInfo() http.HandlerFunc {
@@ -110,7 +174,8 @@ func (c *Controller) ServiceInfo() http.HandlerFunc {
 }
 
 // ListServices handles a POST request for retrieving a list of services. The
-// request body has to contain valid ServiceListOptions.
+// request body has to contain valid ServiceListOptions. Unchanged lists are
+// answered with 304 if the client provides the ETag of the previous response.
 func (c *Controller) ListServices() http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var options types.ServiceListOptions
@@ -126,7 +191,38 @@ func (c *Controller) ListServices() http.HandlerFunc {
 			return
 		}
 
-		respond(w, r, http.StatusOK, types.Response{Success: true, Data: serviceList})
+		respondWithETag(w, r, http.StatusOK, types.Response{Success: true, Data: serviceList})
+	}
+}
+
+// ServiceMetrics handles a POST request for retrieving the proxy metrics of
+// a service. The request URL has to contain a valid service reference.
+func (c *Controller) ServiceMetrics() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+
+		serviceMetrics, err := c.backend.ServiceMetrics(serviceRef)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: serviceMetrics})
+	}
+}
+
+// ServiceHealth handles a POST request for retrieving the health status of
+// all services. Unlike the proxy's health endpoint, the response contains
+// the status of each service.
+func (c *Controller) ServiceHealth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		healthList, err := c.backend.ServiceHealth()
+		if err != nil {
+			respondError(w, r, http.StatusInternalServerError, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: healthList})
 	}
 }
 
@@ -142,7 +238,261 @@ func (c *Controller) SetServiceURL() http.HandlerFunc {
 			return
 		}
 
-		err := c.backend.SetServiceURL(serviceRef, serviceURL.URL, serviceURL.ServiceURLOptions)
+		err := c.backendFor(r).SetServiceURL(serviceRef, serviceURL.URL, serviceURL.ServiceURLOptions)
+		if err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetDefaultService handles a POST request for making a service the default
+// service. The request URL has to contain a valid service reference.
+func (c *Controller) SetDefaultService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+
+		if err := c.backendFor(r).SetDefaultService(serviceRef); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceHealthCheck handles a POST request for configuring the health
+// checks of a given service. The request body has to contain valid
+// ServiceHealthCheckOptions.
+func (c *Controller) SetServiceHealthCheck() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceHealthCheckOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceHealthCheck(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceMaintenance handles a POST request for turning the maintenance
+// mode of a service on or off. The request body has to contain valid
+// ServiceMaintenanceOptions.
+func (c *Controller) SetServiceMaintenance() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceMaintenanceOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceMaintenance(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceSticky handles a POST request for turning sticky sessions for a
+// service on or off. The request body has to contain valid
+// ServiceStickyOptions.
+func (c *Controller) SetServiceSticky() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceStickyOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceSticky(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceOutliers handles a POST request for configuring the outlier
+// detection for a service. The request body has to contain valid
+// ServiceOutliersOptions.
+func (c *Controller) SetServiceOutliers() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceOutliersOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceOutliers(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceMirror handles a POST request for configuring traffic mirroring
+// for a service. The request body has to contain valid ServiceMirrorOptions.
+func (c *Controller) SetServiceMirror() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceMirrorOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceMirror(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceAccessLog handles a POST request for configuring the access log
+// of a service. The request body has to contain valid ServiceAccessLogOptions.
+func (c *Controller) SetServiceAccessLog() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceAccessLogOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceAccessLog(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceCORS handles a POST request for configuring CORS for a service.
+// The request body has to contain valid ServiceCORSOptions.
+func (c *Controller) SetServiceCORS() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceCORSOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceCORS(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceAuth handles a POST request for configuring basic auth for a
+// service. The request body has to contain valid ServiceAuthOptions.
+func (c *Controller) SetServiceAuth() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceAuthOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceAuth(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// SetServiceAllowList handles a POST request for restricting the requests
+// forwarded to a service. The request body has to contain valid
+// ServiceAllowListOptions.
+func (c *Controller) SetServiceAllowList() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceAllowListOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).SetServiceAllowList(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// ScheduleService handles a POST request for scheduling the enabling and
+// disabling of a service. The request body has to contain valid
+// ServiceScheduleOptions.
+func (c *Controller) ScheduleService() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var options types.ServiceScheduleOptions
+
+		if err := json.NewDecoder(r.Body).Decode(&options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		if err := c.backendFor(r).ScheduleService(serviceRef, options); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, err)
+			return
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true})
+	}
+}
+
+// RollingReplace handles a POST request for replacing all instances of a
+// service with instances of a new version. The request body has to contain
+// the new version as well as valid ServiceReplaceOptions.
+func (c *Controller) RollingReplace() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		serviceRef := entity.ServiceReference(chi.URLParam(r, "ref"))
+		var serviceReplace types.ServiceReplace
+
+		if err := json.NewDecoder(r.Body).Decode(&serviceReplace); err != nil {
+			respondError(w, r, http.StatusUnprocessableEntity, ErrInvalidFormData)
+			return
+		}
+
+		err := c.backendFor(r).RollingReplace(serviceRef, serviceReplace.Version, serviceReplace.ServiceReplaceOptions)
 		if err != nil {
 			respondError(w, r, http.StatusUnprocessableEntity, err)
 			return
@@ -16,6 +16,8 @@
 package controller
 
 import (
+	"context"
+	"github.com/dominikbraun/dice/audit"
 	"github.com/dominikbraun/dice/entity"
 	"github.com/dominikbraun/dice/types"
 )
@@ -26,6 +28,11 @@ type Target interface {
 	NodeTarget
 	ServiceTarget
 	InstanceTarget
+	RouteTarget
+	ConfigTarget
+	PruneTarget
+	AuditTarget
+	LogTarget
 }
 
 // NodeTarget prescribes methods for backends working with nodes.
@@ -33,8 +40,12 @@ type NodeTarget interface {
 	CreateNode(name string, options types.NodeCreateOptions) error
 	AttachNode(nodeRef entity.NodeReference) error
 	DetachNode(nodeRef entity.NodeReference) error
+	CordonNode(nodeRef entity.NodeReference) error
+	DrainNode(ctx context.Context, nodeRef entity.NodeReference, options types.NodeDrainOptions) error
+	UncordonNode(nodeRef entity.NodeReference) error
 	RemoveNode(nodeRef entity.NodeReference, options types.NodeRemoveOptions) error
 	NodeInfo(nodeRef entity.NodeReference) (types.NodeInfoOutput, error)
+	NodeEvents(nodeRef entity.NodeReference) ([]types.EventOutput, error)
 	ListNodes(options types.NodeListOptions) ([]types.NodeInfoOutput, error)
 }
 
@@ -42,19 +53,70 @@ type NodeTarget interface {
 type ServiceTarget interface {
 	CreateService(name string, options types.ServiceCreateOptions) error
 	EnableService(serviceRef entity.ServiceReference) error
+	EnableServices(options types.ServiceSelectOptions) ([]types.ServiceResultOutput, error)
 	DisableService(serviceRef entity.ServiceReference) error
+	DisableServices(options types.ServiceSelectOptions) ([]types.ServiceResultOutput, error)
 	UpdateService(serviceRef entity.ServiceReference, targetVersion string) error
+	PatchService(serviceRef entity.ServiceReference, patch types.ServicePatch) error
 	ServiceInfo(serviceRef entity.ServiceReference) (types.ServiceInfoOutput, error)
+	ServiceMetrics(serviceRef entity.ServiceReference) (types.ServiceMetricsOutput, error)
+	ServiceHealth() ([]types.ServiceHealthOutput, error)
 	ListServices(options types.ServiceListOptions) ([]types.ServiceInfoOutput, error)
 	SetServiceURL(serviceRef entity.ServiceReference, url string, options types.ServiceURLOptions) error
+	SetDefaultService(serviceRef entity.ServiceReference) error
+	SetServiceHealthCheck(serviceRef entity.ServiceReference, options types.ServiceHealthCheckOptions) error
+	SetServiceMaintenance(serviceRef entity.ServiceReference, options types.ServiceMaintenanceOptions) error
+	SetServiceSticky(serviceRef entity.ServiceReference, options types.ServiceStickyOptions) error
+	SetServiceOutliers(serviceRef entity.ServiceReference, options types.ServiceOutliersOptions) error
+	SetServiceMirror(serviceRef entity.ServiceReference, options types.ServiceMirrorOptions) error
+	SetServiceAccessLog(serviceRef entity.ServiceReference, options types.ServiceAccessLogOptions) error
+	SetServiceCORS(serviceRef entity.ServiceReference, options types.ServiceCORSOptions) error
+	SetServiceAuth(serviceRef entity.ServiceReference, options types.ServiceAuthOptions) error
+	SetServiceAllowList(serviceRef entity.ServiceReference, options types.ServiceAllowListOptions) error
+	ScheduleService(serviceRef entity.ServiceReference, options types.ServiceScheduleOptions) error
+	RollingReplace(serviceRef entity.ServiceReference, version string, options types.ServiceReplaceOptions) error
 }
 
 // InstanceTarget prescribes methods for backends working with instances.
 type InstanceTarget interface {
 	CreateInstance(serviceRef entity.ServiceReference, nodeRef entity.NodeReference, url string, options types.InstanceCreateOptions) error
-	AttachInstance(instanceRef entity.InstanceReference) error
+	CloneInstance(srcRef entity.InstanceReference, targetNodeRef entity.NodeReference, newURL string) error
+	CreateInstancesOnNodes(serviceRef entity.ServiceReference, nodeSelector types.NodeSelectOptions, urlTemplate string, options types.InstanceCreateOptions) ([]types.InstanceResultOutput, error)
+	AttachInstance(instanceRef entity.InstanceReference, options types.InstanceAttachOptions) error
 	DetachInstance(instanceRef entity.InstanceReference) error
 	RemoveInstance(instanceRef entity.InstanceReference, options types.InstanceRemoveOptions) error
 	InstanceInfo(instanceRef entity.InstanceReference) (types.InstanceInfoOutput, error)
+	InstanceDescribe(instanceRef entity.InstanceReference) (types.InstanceDescribeOutput, error)
+	InstanceStats(instanceRef entity.InstanceReference) (types.InstanceStatsOutput, error)
+	InstanceEvents(instanceRef entity.InstanceReference) ([]types.EventOutput, error)
 	ListInstances(options types.InstanceListOptions) ([]types.InstanceInfoOutput, error)
 }
+
+// RouteTarget prescribes methods for backends providing a routing table.
+type RouteTarget interface {
+	ListRoutes() ([]types.RouteInfoOutput, error)
+	TestRoute(options types.RouteTestOptions) (types.RouteTestOutput, error)
+	SetRouteWeights(options types.RouteWeightsOptions) error
+}
+
+// ConfigTarget prescribes methods for backends exposing their configuration.
+type ConfigTarget interface {
+	Config() ([]types.ConfigEntryOutput, error)
+}
+
+// PruneTarget prescribes methods for backends able to clean up their data.
+type PruneTarget interface {
+	Prune(options types.PruneOptions) (types.PruneOutput, error)
+}
+
+// AuditTarget prescribes methods for backends providing an audit log.
+// WithActor returns a Target that records the given actor for all actions.
+type AuditTarget interface {
+	AuditLog(options types.AuditLogOptions) ([]types.AuditEntryOutput, error)
+	WithActor(actor audit.Actor) Target
+}
+
+// LogTarget prescribes methods for backends providing access to logfiles.
+type LogTarget interface {
+	ProxyLogfile() string
+}
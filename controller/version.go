@@ -0,0 +1,37 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"github.com/dominikbraun/dice/version"
+	"net/http"
+)
+
+// Version handles a POST request for retrieving the build information of
+// the running Dice daemon, including the supported API versions.
+func (c *Controller) Version() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		versionOutput := types.VersionOutput{
+			Version:     version.Version,
+			Commit:      version.Commit,
+			BuildDate:   version.BuildDate,
+			APIVersions: version.APIVersions,
+		}
+
+		respond(w, r, http.StatusOK, types.Response{Success: true, Data: versionOutput})
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package controller provides methods for handling REST requests.
+package controller
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/types"
+	"github.com/dominikbraun/dice/version"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestController_Version tests Controller.Version. It injects some build
+// information just like -ldflags would do and asserts that the response
+// contains exactly this information.
+func TestController_Version(t *testing.T) {
+	v, commit, buildDate := version.Version, version.Commit, version.BuildDate
+	defer func() {
+		version.Version, version.Commit, version.BuildDate = v, commit, buildDate
+	}()
+
+	version.Version, version.Commit, version.BuildDate = "1.2.3", "a1b2c3d", "2019-12-24T18:00:00Z"
+
+	c := New(nil, nil, nil)
+
+	recorder := httptest.NewRecorder()
+	request := httptest.NewRequest(http.MethodPost, "/version", nil)
+
+	c.Version().ServeHTTP(recorder, request)
+
+	var response types.VersionResponse
+
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	data := response.Data
+
+	if !response.Success || data.Version != "1.2.3" || data.Commit != "a1b2c3d" || data.BuildDate != "2019-12-24T18:00:00Z" {
+		t.Errorf("got %v, expected the injected build information", data)
+	}
+
+	if len(data.APIVersions) != len(version.APIVersions) {
+		t.Errorf("got API versions %v, expected %v", data.APIVersions, version.APIVersions)
+	}
+}
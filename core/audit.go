@@ -0,0 +1,83 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/controller"
+	"github.com/dominikbraun/dice/types"
+)
+
+var (
+	ErrAuditLogUnavailable = errors.New("audit log is not available")
+)
+
+// AuditLog returns the most recent entries of the audit log. The number of
+// returned entries can be limited using the `Limit` option.
+func (d *Dice) AuditLog(options types.AuditLogOptions) ([]types.AuditEntryOutput, error) {
+	if d.auditLog == nil {
+		return nil, ErrAuditLogUnavailable
+	}
+
+	entries, err := d.auditLog.Entries(options.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLog := make([]types.AuditEntryOutput, len(entries))
+
+	for i, e := range entries {
+		auditLog[i] = types.AuditEntryOutput{
+			Timestamp:     e.Timestamp,
+			Action:        string(e.Action),
+			EntityType:    string(e.EntityType),
+			EntityID:      e.EntityID,
+			EntityName:    e.EntityName,
+			RequestID:     e.Actor.RequestID,
+			RemoteAddress: e.Actor.RemoteAddress,
+		}
+	}
+
+	return auditLog, nil
+}
+
+// audit records a management action in the audit log together with the
+// actor of Dice, see WithActor. Recording an action doesn't block, and it
+// is a no-op if there is no audit log.
+func (d *Dice) audit(action audit.Action, entityType audit.EntityType, id, name string) {
+	if d.auditLog == nil {
+		return
+	}
+
+	d.auditLog.Record(audit.Entry{
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   id,
+		EntityName: name,
+		Actor:      d.actor,
+	})
+}
+
+// WithActor returns a shallow copy of Dice that records the given actor in
+// all audit log entries. The copy shares all components with d, so it's only
+// meant to be used for the actions of a single API request.
+func (d *Dice) WithActor(actor audit.Actor) controller.Target {
+	c := *d
+	c.actor = actor
+
+	return &c
+}
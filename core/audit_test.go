@@ -0,0 +1,130 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+// TestDice_audit tests the audit log entries recorded by core operations.
+// It creates and removes a node and asserts that both actions have been
+// recorded with the node's identifiers.
+func TestDice_audit(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	file, err := ioutil.TempFile("", "dice-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	if d.auditLog, err = audit.New(file.Name()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	node, err := d.findNode(entity.NodeReference("n1"))
+	if err != nil || node == nil {
+		t.Fatalf("node n1 has not been created: %v", err)
+	}
+
+	if err := d.RemoveNode(entity.NodeReference("n1"), types.NodeRemoveOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Closing the audit log ensures that all queued entries are written.
+	if err := d.auditLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := d.AuditLog(types.AuditLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertions := []audit.Action{audit.CreateAction, audit.RemoveAction}
+
+	if len(entries) != len(assertions) {
+		t.Fatalf("expected %d entries, got %d", len(assertions), len(entries))
+	}
+
+	for i, action := range assertions {
+		e := entries[i]
+
+		if e.Action != string(action) || e.EntityType != string(audit.NodeEntity) || e.EntityID != node.ID || e.EntityName != "n1" {
+			t.Errorf("unexpected entry %v, expected %s of node %s", e, action, node.ID)
+		}
+	}
+}
+
+// TestDice_WithActor tests if the actor passed to Dice.WithActor is recorded
+// for an action, while the original Dice instance records no actor.
+func TestDice_WithActor(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	file, err := ioutil.TempFile("", "dice-audit")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	if d.auditLog, err = audit.New(file.Name()); err != nil {
+		t.Fatal(err)
+	}
+
+	actor := audit.Actor{RequestID: "host/abc-000001", RemoteAddress: "127.0.0.1:50000"}
+
+	if err := d.WithActor(actor).CreateNode("n1", types.NodeCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateNode("n2", types.NodeCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.auditLog.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := d.AuditLog(types.AuditLogOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(entries))
+	}
+
+	if entries[0].RequestID != actor.RequestID || entries[0].RemoteAddress != actor.RemoteAddress {
+		t.Errorf("unexpected entry %v, expected it to contain actor %v", entries[0], actor)
+	}
+
+	if entries[1].RequestID != "" || entries[1].RemoteAddress != "" {
+		t.Errorf("unexpected entry %v, expected it to contain no actor", entries[1])
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"strings"
+)
+
+// redacted replaces the values of secret configuration keys.
+const redacted string = "********"
+
+// secretKeyParts are parts of configuration keys that identify secrets.
+var secretKeyParts = []string{"password", "token", "secret"}
+
+// Config returns the effective configuration of Dice, that is the resolved
+// value of each configuration key along with its source. The values of keys
+// that contain secrets, like redis-password, are redacted if they are set.
+func (d *Dice) Config() ([]types.ConfigEntryOutput, error) {
+	keys := d.config.Keys()
+	entries := make([]types.ConfigEntryOutput, 0, len(keys))
+
+	for _, key := range keys {
+		value := d.config.Get(key)
+
+		if isSecretKey(key) && d.config.GetString(key) != "" {
+			value = redacted
+		}
+
+		entries = append(entries, types.ConfigEntryOutput{
+			Key:    key,
+			Value:  value,
+			Source: string(d.config.Source(key)),
+		})
+	}
+
+	return entries, nil
+}
+
+// isSecretKey indicates whether the given configuration key holds a secret.
+func isSecretKey(key string) bool {
+	for _, part := range secretKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
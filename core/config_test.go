@@ -0,0 +1,181 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/config"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDice_Config checks if environment overrides are reported with their
+// source and if secret values are redacted.
+func TestDice_Config(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	env := map[string]string{
+		"DICE_PROXY_PORT":     "18080",
+		"DICE_REDIS_PASSWORD": "hunter2",
+	}
+
+	for name, value := range env {
+		if err := os.Setenv(name, value); err != nil {
+			t.Fatal(err)
+		}
+		defer os.Unsetenv(name)
+	}
+
+	reader, err := config.NewFile(configName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range config.DiceDefaults {
+		reader.SetDefault(key, value)
+	}
+
+	d.config = reader
+
+	entries, err := d.Config()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	byKey := make(map[string]types.ConfigEntryOutput, len(entries))
+
+	for _, e := range entries {
+		byKey[e.Key] = e
+	}
+
+	expected := map[string]types.ConfigEntryOutput{
+		"proxy-port":     {Key: "proxy-port", Value: "18080", Source: string(config.EnvSource)},
+		"redis-password": {Key: "redis-password", Value: redacted, Source: string(config.EnvSource)},
+		"store-backend":  {Key: "store-backend", Value: "bolt", Source: string(config.DefaultSource)},
+	}
+
+	for key, e := range expected {
+		if byKey[key] != e {
+			t.Errorf("expected %v, got %v", e, byKey[key])
+		}
+	}
+
+	if len(entries) != len(config.DiceDefaults) {
+		t.Errorf("expected %d entries, got %d", len(config.DiceDefaults), len(entries))
+	}
+}
+
+// TestDice_setupConfigCheck checks if a misspelled key in the configuration
+// file triggers a warning, while recognized keys don't.
+func TestDice_setupConfigCheck(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "dice-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := "proxy-port: 9000\nproxy-prot: 9001\n"
+
+	if err := ioutil.WriteFile(filepath.Join(dir, configName+".yml"), []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	reader, err := config.NewFile(configName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range config.DiceDefaults {
+		reader.SetDefault(key, value)
+	}
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.config, d.logger = reader, logger
+
+	if err := d.setupConfigCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "proxy-prot") {
+		t.Errorf("expected a single warning about proxy-prot, got %v", logger.warnings)
+	}
+}
+
+// TestDice_setupConfig_renamedKeys checks if the value of a deprecated key
+// is used for its current name and if the deprecated key triggers a warning
+// instead of being reported as unknown.
+func TestDice_setupConfig_renamedKeys(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	dir, err := ioutil.TempDir("", "dice-config-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	file := "kv-store-file: legacy-store\n"
+
+	if err := ioutil.WriteFile(filepath.Join(dir, configName+".yml"), []byte(file), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if err := d.setupConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if path := d.config.GetString("store-path"); path != "legacy-store" {
+		t.Errorf("expected store path legacy-store, got %s", path)
+	}
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.logger = logger
+
+	if err := d.setupConfigCheck(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "deprecated") {
+		t.Errorf("expected a single deprecation warning, got %v", logger.warnings)
+	}
+}
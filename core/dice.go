@@ -16,27 +16,44 @@
 package core
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/dominikbraun/dice/api"
+	"github.com/dominikbraun/dice/audit"
 	"github.com/dominikbraun/dice/config"
 	"github.com/dominikbraun/dice/controller"
 	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/event"
 	"github.com/dominikbraun/dice/healthcheck"
 	"github.com/dominikbraun/dice/log"
 	"github.com/dominikbraun/dice/proxy"
 	"github.com/dominikbraun/dice/registry"
 	"github.com/dominikbraun/dice/scheduler"
 	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/version"
 	"os"
+	"time"
 )
 
 const (
-	configName string = "dice"
+	configName    string = "dice"
+	boltBackend   string = "bolt"
+	memoryBackend string = "memory"
+	redisBackend  string = "redis"
+)
+
+var (
+	ErrUnsupportedBackend   = errors.New("store backend is not supported")
+	ErrSchedulerUnavailable = errors.New("scheduler could not be created")
 )
 
 // Dice represents the Dice load balancer and wires up all the components.
 //
 // Most importantly, this type consists of:
 // - a key-value store for simply persisting domain entities
+// - an audit log recording all management actions
+// - an event log recording the state changes of instances and nodes
 // - a registry that manages all services and their instances
 // - an API server that exposes a REST API for managing Dice
 // - a proxy server that will receive and balance all requests
@@ -48,12 +65,61 @@ type Dice struct {
 	reloadConfig chan bool
 	logger       log.Logger
 	kvStore      store.EntityStore
+	auditLog     *audit.Log
+	events       *event.Log
+	watcher      store.StoreWatcher
+	origin       string
 	registry     *registry.ServiceRegistry
 	healthCheck  *healthcheck.HealthCheck
 	controller   *controller.Controller
 	interrupt    chan os.Signal
+	levelSignals chan os.Signal
 	apiServer    *api.Server
 	proxy        *proxy.Proxy
+
+	// apiLogfile is the logfile of the API server if it doesn't log into
+	// the Dice logfile. It is closed on shutdown and on a config reload.
+	apiLogfile *os.File
+
+	// proxyLogfile is the access logfile of the proxy. It is replaced on a
+	// config reload and closed on shutdown.
+	proxyLogfile *os.File
+
+	// defaultBalancing is the balancing method for services that haven't
+	// specified one. It falls back to scheduler.DefaultBalancing if unset.
+	defaultBalancing scheduler.BalancingMethod
+
+	// slowStart is the warmup window for attached instances. If it is set,
+	// all schedulers are wrapped with scheduler.SlowStart.
+	slowStart time.Duration
+
+	// zone is the local zone of the proxy. If it is set, instances in that
+	// zone are preferred by all schedulers.
+	zone string
+
+	// strict makes Dice refuse to start if the key-value store contains
+	// conflicting entities, see Validate.
+	strict bool
+
+	// checkInstance checks if a single instance is alive. It is used while
+	// waiting for new instances and defaults to HealthCheck.CheckInstance.
+	checkInstance func(serviceID, instanceID string) (bool, error)
+
+	// logLevel is the log level the logger has been set up with. It will be
+	// restored when receiving restoreSignal.
+	logLevel log.Level
+
+	// sleep pauses between two steps of a long-running operation like
+	// DrainNode. If unset, Dice waits for a timer or a cancelled context.
+	sleep func(time.Duration)
+
+	// now returns the current time for firing scheduled transitions of
+	// services. It defaults to time.Now if unset.
+	now func() time.Time
+
+	// actor is recorded in all audit log entries. It is only set for the
+	// copies of Dice returned by WithActor.
+	actor audit.Actor
 }
 
 // NewDice creates a new Dice instance and sets up all components.
@@ -73,8 +139,13 @@ func (d *Dice) setup() error {
 		d.setupConfig,
 		d.setupReloadConfig,
 		d.setupLogger,
+		d.setupConfigCheck,
 		d.setupKVStore,
+		d.setupWatcher,
+		d.setupAuditLog,
+		d.setupEvents,
 		d.setupRegistry,
+		d.setupBalancing,
 		d.setupHealthCheck,
 		d.setupController,
 		d.setupAPIServer,
@@ -91,60 +162,200 @@ func (d *Dice) setup() error {
 	return nil
 }
 
+// SetStrict enables or disables the strict mode. In strict mode, Dice won't
+// start if the key-value store contains conflicting entities.
+func (d *Dice) SetStrict(strict bool) {
+	d.strict = strict
+}
+
 // Run starts the API and proxy servers. To shut them down gracefully, send
 // an interrupt signal (SIGINT) to the Dice executable. If an error happens
 // while running one of the servers, Dice will be stopped entirely.
+//
+// On a config reload, all components are rebuilt. The proxy keeps running
+// and serving requests unless its listen addresses have changed, see reload.
+//
+// Scheduled transitions of services are fired while Dice is running, see
+// ScheduleService. Periodic health checks run until Dice is shut down, and
+// they're restarted for the new registry on a config reload.
 func (d *Dice) Run() error {
+	d.logger.Infof("starting Dice %s", version.String())
+
+	if err := d.checkConsistency(); err != nil {
+		return err
+	}
+
 	if err := d.initializeRegistry(); err != nil {
 		return err
 	}
 
-	for {
-		errors := make(chan error)
+	d.proxy.SetReady(true)
+
+	if err := d.watch(); err != nil {
+		return err
+	}
 
+	errors := make(chan error)
+
+	runProxy := func(p *proxy.Proxy) {
 		go func() {
-			if err := d.proxy.Run(); err != nil {
+			if err := p.Run(); err != nil {
 				errors <- err
 			}
 		}()
+	}
 
+	runAPIServer := func(s *api.Server) {
 		go func() {
-			if err := d.apiServer.Run(); err != nil {
+			if err := s.Run(); err != nil {
 				errors <- err
 			}
 		}()
+	}
+
+	// The periodic health checks only end when the health checker is
+	// stopped, so there's no error to report.
+	runHealthCheck := func(hc *healthcheck.HealthCheck) {
+		go func() {
+			_ = hc.RunPeriodically(context.Background())
+		}()
+	}
 
+	runProxy(d.proxy)
+	runAPIServer(d.apiServer)
+	runHealthCheck(d.healthCheck)
+
+	schedules := time.NewTicker(scheduleCheckInterval)
+	defer schedules.Stop()
+
+	for {
 		select {
 		case <-d.interrupt:
+			if err := d.healthCheck.Stop(); err != nil {
+				d.logger.Errorf("health check stop error: %v", err)
+			}
 			if err := d.proxy.Shutdown(); err != nil {
 				d.logger.Errorf("Proxy shutdown error: %v", err)
 			}
 			if err := d.apiServer.Shutdown(); err != nil {
 				d.logger.Errorf("API server shutdown error: %v", err)
 			}
+			if err := d.closeAPILogfile(); err != nil {
+				d.logger.Errorf("API logfile close error: %v", err)
+			}
+			if err := d.replaceProxyLogfile(nil); err != nil {
+				d.logger.Errorf("proxy logfile close error: %v", err)
+			}
+			if err := d.auditLog.Close(); err != nil {
+				d.logger.Errorf("audit log close error: %v", err)
+			}
 			return nil
 
 		case reload := <-d.reloadConfig:
 			d.logger.Info("reloading Dice")
 
 			if reload {
-				if err := d.proxy.Shutdown(); err != nil {
-					d.logger.Errorf("proxy shutdown error: %v", err)
-				}
-				if err := d.apiServer.Shutdown(); err != nil {
-					d.logger.Errorf("API server shutdown error: %v", err)
-				}
-				if err := d.setup(); err != nil {
+				runningProxy := d.proxy
+
+				if err := d.reload(); err != nil {
 					return err
 				}
+
+				if d.proxy != runningProxy {
+					runProxy(d.proxy)
+				}
+				runAPIServer(d.apiServer)
+				runHealthCheck(d.healthCheck)
 			}
 
+		case sig := <-d.levelSignals:
+			d.handleLevelSignal(sig)
+
+		case <-schedules.C:
+			d.fireTransitions()
+
 		case err := <-errors:
 			return err
 		}
 	}
 }
 
+// handleLevelSignal changes the log level at runtime: debugSignal raises the
+// log level to debug, restoreSignal restores the configured log level. This
+// allows debugging a running Dice instance without restarting it.
+func (d *Dice) handleLevelSignal(sig os.Signal) {
+	var level log.Level
+
+	switch sig {
+	case debugSignal:
+		level = log.DebugLevel
+	case restoreSignal:
+		level = d.logLevel
+	default:
+		return
+	}
+
+	if current := d.logger.GetLevel(); current != level {
+		d.logger.Infof("changing log level from %s to %s", current, level)
+		d.logger.SetLevel(level)
+	}
+}
+
+// reload rebuilds all components from the configuration and initializes the
+// new service registry. The API server is always restarted and all running
+// health checks are aborted, since they refer to the previous registry.
+//
+// The running proxy is only replaced if its listen addresses have changed.
+// Otherwise, it keeps its listeners and serves requests using the previous
+// registry until the new registry has been initialized. Then, the registry
+// is swapped in place, so that no connections are dropped, see swapRegistry.
+func (d *Dice) reload() error {
+	if err := d.apiServer.Shutdown(); err != nil {
+		d.logger.Errorf("API server shutdown error: %v", err)
+	}
+	if err := d.healthCheck.Stop(); err != nil {
+		d.logger.Errorf("health check stop error: %v", err)
+	}
+
+	previous, previousZone, previousSlowStart := d.registry, d.zone, d.slowStart
+
+	if err := d.setup(); err != nil {
+		return err
+	}
+
+	return d.swapRegistry(previous, d.zone == previousZone && d.slowStart == previousSlowStart)
+}
+
+// swapRegistry initializes the new service registry and hands it over to the
+// proxy. The alive states of all nodes and instances are taken over from the
+// previous registry, since the new health checker hasn't checked them yet.
+// If reuse is set, the schedulers of unchanged services are taken over too.
+func (d *Dice) swapRegistry(previous *registry.ServiceRegistry, reuse bool) error {
+	if err := d.initializeRegistry(); err != nil {
+		return err
+	}
+
+	for id, service := range d.registry.All() {
+		if previousService, ok := previous.Service(id); ok {
+			copyAliveStates(previousService, service)
+		}
+	}
+
+	if reuse {
+		d.reuseSchedulers(previous)
+	}
+
+	d.proxy.SetRegistry(d.registry)
+	d.proxy.SetReady(true)
+
+	return d.watch()
+}
+
+// ProxyLogfile returns the path of the logfile used by the proxy.
+func (d *Dice) ProxyLogfile() string {
+	return d.config.GetString("proxy-logfile")
+}
+
 // initializeServices initializes all services and makes them available for
 // load balancing. This is done by populating the service registry with all
 // services, their deployments and the responsible scheduler.
@@ -153,6 +364,12 @@ func (d *Dice) Run() error {
 // runtime, services and deployments will be registered by core methods like
 // CreateService using the exact same mechanisms.
 //
+// An empty key-value store is valid, for example on a fresh installation.
+// Since the proxy will respond to all requests with 503 in that case, an
+// informative message is logged. Services whose scheduler can't be created,
+// for example because of an unsupported balancing method, are logged and
+// not registered at all.
+//
 // ToDo: Clarify how errors during initialization should be handled.
 func (d *Dice) initializeRegistry() error {
 	services, err := d.kvStore.FindServices(store.AllServicesFilter)
@@ -162,7 +379,14 @@ func (d *Dice) initializeRegistry() error {
 
 	for _, s := range services {
 		registryService, err := d.buildRegistryService(s)
-		if err != nil {
+
+		// A service whose scheduler can't be created isn't routable. This
+		// affects the service only, so it is skipped instead of preventing
+		// Dice from starting.
+		if errors.Is(err, ErrSchedulerUnavailable) {
+			d.logger.Errorf("service %s is unroutable and has not been registered: %v", s.ID, err)
+			continue
+		} else if err != nil {
 			return err
 		}
 
@@ -170,17 +394,66 @@ func (d *Dice) initializeRegistry() error {
 			if err != registry.ErrRouteAlreadyRegistered {
 				return err
 			}
+			d.logger.Warnf("service %s has not been registered: %v", s.ID, err)
 		}
 	}
 
+	if len(services) == 0 {
+		d.logger.Info("no services have been created yet, all requests will be answered with 503")
+	} else if len(d.registry.Routes()) == 0 {
+		d.logger.Info("no service URLs have been registered yet, all requests will be answered with 503")
+	}
+
 	return nil
 }
 
+// reuseSchedulers takes over the schedulers of all services in the previous
+// registry that haven't changed, so that reloading Dice doesn't reset their
+// state and disrupt routing. The reused schedulers are updated with the new
+// deployments. Changed services keep their newly created schedulers.
+func (d *Dice) reuseSchedulers(previous *registry.ServiceRegistry) {
+	for id, service := range d.registry.All() {
+		previousService, ok := previous.Service(id)
+
+		if !ok || !service.CanReuseScheduler(previousService) {
+			continue
+		}
+
+		previousService.Scheduler.UpdateDeployments(service.Deployments)
+		service.Scheduler = previousService.Scheduler
+	}
+}
+
+// copyAliveStates takes over the alive states of the nodes and instances of
+// a previously registered service for a newly built service. These states
+// are only known to the health checker and aren't stored, so all deployments
+// of the new service would be considered dead until the next health check.
+func copyAliveStates(previous, service *registry.Service) {
+	nodesAlive := make(map[string]bool)
+	instancesAlive := make(map[string]bool)
+
+	for _, deployment := range previous.Deployments {
+		nodesAlive[deployment.Node.ID] = deployment.Node.IsAlive
+		instancesAlive[deployment.Instance.ID] = deployment.Instance.IsAlive
+	}
+
+	for _, deployment := range service.Deployments {
+		if isAlive, ok := nodesAlive[deployment.Node.ID]; ok {
+			deployment.Node.IsAlive = isAlive
+		}
+		if isAlive, ok := instancesAlive[deployment.Instance.ID]; ok {
+			deployment.Instance.IsAlive = isAlive
+		}
+	}
+}
+
 // buildRegistryService takes a service entity and creates a registry.Service
 // instance by searching the instances and the nodes they've been deployed to.
 //
 // The created registry.Service includes information about deployed instances
-// of the particular service and provides a scheduler as well.
+// of the particular service and provides a scheduler as well. If any of these
+// can't be built, no service is returned at all. An error that is caused by
+// the scheduler wraps ErrSchedulerUnavailable.
 //
 // See the registry.Service docs for further explanations.
 func (d *Dice) buildRegistryService(service *entity.Service) (*registry.Service, error) {
@@ -192,28 +465,61 @@ func (d *Dice) buildRegistryService(service *entity.Service) (*registry.Service,
 		return i.ServiceID == service.ID
 	})
 	if err != nil {
-		return &registryService, err
+		return nil, err
 	}
 
-	registryService.Deployments = make([]registry.Deployment, len(instances))
+	registryService.Deployments = make([]registry.Deployment, 0, len(instances))
 
-	for i, inst := range instances {
+	for _, inst := range instances {
 		node, err := d.kvStore.FindNode(inst.NodeID)
 		if err != nil {
-			return &registryService, err
+			return nil, err
+		}
+
+		// Instances whose node doesn't exist anymore can't be deployed.
+		// They can be removed using Prune.
+		if node == nil {
+			d.logger.Warnf("instance %s is orphaned: node %s doesn't exist", inst.ID, inst.NodeID)
+			continue
 		}
 
-		registryService.Deployments[i] = registry.Deployment{
+		registryService.Deployments = append(registryService.Deployments, registry.Deployment{
 			Node:     node,
 			Instance: inst,
-		}
+		})
 	}
 
-	serviceScheduler, err := scheduler.New(registryService.Deployments, scheduler.BalancingMethod(service.BalancingMethod))
+	method := scheduler.BalancingMethod(service.BalancingMethod)
+
+	serviceScheduler, err := d.newScheduler(registryService.Deployments, method)
 	if err != nil {
-		return &registryService, err
+		return nil, fmt.Errorf("%w: %v", ErrSchedulerUnavailable, err)
 	}
 
 	registryService.Scheduler = serviceScheduler
 	return &registryService, nil
 }
+
+// newScheduler creates a scheduler for the given deployments that uses the
+// provided balancing method. If a zone has been configured, the scheduler
+// prefers instances in that zone. Standby instances are only selected if
+// no other instance is available in any zone. If a slow start window has
+// been configured, recently attached instances receive less traffic.
+func (d *Dice) newScheduler(deployments []registry.Deployment, method scheduler.BalancingMethod) (registry.Scheduler, error) {
+	standbyAware, err := scheduler.NewStandbyAware(deployments, func(deployments []registry.Deployment) (registry.Scheduler, error) {
+		if d.zone != "" {
+			return scheduler.NewZoneAware(deployments, method, d.zone)
+		}
+
+		return scheduler.New(deployments, method)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if d.slowStart > 0 {
+		return scheduler.NewSlowStart(standbyAware, d.slowStart), nil
+	}
+
+	return standbyAware, nil
+}
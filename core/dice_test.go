@@ -0,0 +1,564 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/api"
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/config"
+	"github.com/dominikbraun/dice/controller"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/healthcheck"
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/proxy"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/scheduler"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestDice_memoryStore tests core operations against the memory backend.
+// It creates a node, a service and an instance, finds them and removes the
+// instance and the node afterwards.
+func TestDice_memoryStore(t *testing.T) {
+	logger := log.NewLogger(ioutil.Discard, log.ErrorLevel)
+
+	d := Dice{
+		logger:   logger,
+		kvStore:  store.NewMemoryStore(),
+		registry: registry.NewServiceRegistry(logger),
+	}
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateInstance("s1", "n1", "n1:8080", types.InstanceCreateOptions{Name: "i1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if node, err := d.findNode("n1"); err != nil || node == nil {
+		t.Errorf("node n1 has not been found: %v", err)
+	}
+
+	if service, err := d.findService("s1"); err != nil || service == nil {
+		t.Errorf("service s1 has not been found: %v", err)
+	}
+
+	instance, err := d.findInstance(entity.InstanceReference("i1"))
+	if err != nil || instance == nil {
+		t.Fatalf("instance i1 has not been found: %v", err)
+	}
+
+	if err := d.RemoveInstance("i1", types.InstanceRemoveOptions{}); err != nil {
+		t.Error(err)
+	}
+
+	if err := d.RemoveNode("n1", types.NodeRemoveOptions{}); err != nil {
+		t.Error(err)
+	}
+
+	if instance, _ := d.kvStore.FindInstance(instance.ID); instance != nil {
+		t.Errorf("instance %s has not been removed", instance.ID)
+	}
+
+	if nodes, _ := d.kvStore.FindNodes(store.AllNodesFilter); len(nodes) != 0 {
+		t.Errorf("got %d nodes, expected 0", len(nodes))
+	}
+}
+
+// TestDice_reuseSchedulers tests Dice.reuseSchedulers as it is used when
+// reloading Dice. It asserts that an unchanged service keeps its scheduler
+// instance, while a service whose balancing method has changed gets a new
+// scheduler.
+func TestDice_reuseSchedulers(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	unchanged, _ := setupReplaceTest(t, d, 2)
+
+	if err := d.CreateService("s2", types.ServiceCreateOptions{URLs: "s2.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := d.findService("s2")
+	if err != nil || changed == nil {
+		t.Fatalf("service s2 has not been found: %v", err)
+	}
+
+	previous := d.registry
+	unchangedScheduler := previous.Services[unchanged.ID].Scheduler
+	changedScheduler := previous.Services[changed.ID].Scheduler
+
+	changed.BalancingMethod = string(scheduler.WeightedRandomBalancing)
+
+	if err := d.kvStore.UpdateService(changed.ID, changed); err != nil {
+		t.Fatal(err)
+	}
+
+	d.registry = registry.NewServiceRegistry(d.logger)
+
+	if err := d.initializeRegistry(); err != nil {
+		t.Fatal(err)
+	}
+
+	d.reuseSchedulers(previous)
+
+	if d.registry.Services[unchanged.ID].Scheduler != unchangedScheduler {
+		t.Error("expected the unchanged service to keep its scheduler")
+	}
+
+	if d.registry.Services[changed.ID].Scheduler == changedScheduler {
+		t.Error("expected the changed service to get a new scheduler")
+	}
+}
+
+// TestDice_setupProxy_reload tests Dice.setupProxy as it is used when
+// reloading Dice. It asserts that the proxy is kept if the proxy ports are
+// unchanged and replaced if they have changed.
+func TestDice_setupProxy_reload(t *testing.T) {
+	logger := log.NewLogger(ioutil.Discard, log.ErrorLevel)
+	environment := config.Environment{"proxy-port": "18080"}
+
+	d := Dice{
+		config:   environment,
+		logger:   logger,
+		registry: registry.NewServiceRegistry(logger),
+	}
+
+	if err := d.setupProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	runningProxy := d.proxy
+
+	if err := d.setupProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.proxy != runningProxy {
+		t.Error("expected the proxy to be kept for unchanged ports")
+	}
+
+	environment["proxy-port"] = "18081"
+
+	if err := d.setupProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.proxy == runningProxy {
+		t.Error("expected the proxy to be replaced for changed ports")
+	}
+}
+
+// TestDice_setupAPIServer_logfile tests if the separate logfile of the API
+// server is closed when setting up the API server again, and if it isn't
+// opened at all once the request log has been disabled.
+func TestDice_setupAPIServer_logfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dice-api-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := log.NewLogger(ioutil.Discard, log.ErrorLevel)
+	environment := config.Environment{
+		"api-server-port":        "",
+		"api-server-logfile":     filepath.Join(dir, "api.log"),
+		"api-server-request-log": true,
+	}
+
+	d := Dice{
+		config:     environment,
+		logger:     logger,
+		controller: controller.New(nil, nil, nil),
+	}
+
+	if err := d.setupAPIServer(); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := d.apiLogfile
+	if previous == nil {
+		t.Fatal("expected the API logfile to be opened")
+	}
+
+	if err := d.setupAPIServer(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := previous.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("got error %v when closing the previous logfile, expected it to be closed", err)
+	}
+
+	environment["api-server-request-log"] = false
+
+	if err := d.setupAPIServer(); err != nil {
+		t.Fatal(err)
+	}
+
+	if d.apiLogfile != nil {
+		t.Error("expected no API logfile without a request log")
+	}
+}
+
+// TestDice_setupProxy_logfile tests if the previous proxy logfile is closed
+// when setting up the proxy again, and if the logfile isn't executable.
+func TestDice_setupProxy_logfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dice-proxy-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	logger := log.NewLogger(ioutil.Discard, log.ErrorLevel)
+	environment := config.Environment{
+		"proxy-port":    "127.0.0.1:0",
+		"proxy-logfile": filepath.Join(dir, "proxy.log"),
+	}
+
+	d := Dice{
+		config:   environment,
+		logger:   logger,
+		registry: registry.NewServiceRegistry(logger),
+	}
+
+	if err := d.setupProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	previous := d.proxyLogfile
+	if previous == nil {
+		t.Fatal("expected the proxy logfile to be opened")
+	}
+
+	info, err := previous.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm()&0111 != 0 {
+		t.Errorf("got file mode %v, expected the logfile not to be executable", info.Mode())
+	}
+
+	if err := d.setupProxy(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := previous.Close(); !errors.Is(err, os.ErrClosed) {
+		t.Errorf("got error %v when closing the previous logfile, expected it to be closed", err)
+	}
+
+	if err := d.replaceProxyLogfile(nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestDice_swapRegistry tests a data-only reload. A request sent right after
+// the new registry has been swapped in has to be proxied to the instance,
+// which has been alive before the reload but hasn't been checked since.
+func TestDice_swapRegistry(t *testing.T) {
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+
+	// The proxy sends requests to instances using the default transport,
+	// which has to trust the certificate of the upstream.
+	transport := http.DefaultTransport.(*http.Transport)
+	tlsConfig := transport.TLSClientConfig
+	transport.TLSClientConfig = upstream.Client().Transport.(*http.Transport).TLSClientConfig
+	defer func() {
+		transport.TLSClientConfig = tlsConfig
+	}()
+
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	address := upstream.Listener.Addr().String()
+
+	if err := d.CreateInstance("s1", "n1", address, types.InstanceCreateOptions{Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, service := range d.registry.All() {
+		for _, deployment := range service.Deployments {
+			deployment.Node.IsAlive = true
+			deployment.Instance.IsAlive = true
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyAddress := listener.Addr().String()
+	_ = listener.Close()
+
+	d.proxy = proxy.New(proxy.Config{Addresses: []string{proxyAddress}}, d.registry)
+	d.proxy.SetReady(true)
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- d.proxy.Run()
+	}()
+
+	// get sends a request for the service to the proxy and returns the
+	// status code of the response.
+	get := func() (int, error) {
+		request, _ := http.NewRequest(http.MethodGet, "http://"+proxyAddress, nil)
+		request.Host = "s1.example.com"
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return 0, err
+		}
+		_ = response.Body.Close()
+
+		return response.StatusCode, nil
+	}
+
+	// The proxy is started asynchronously, so it might not be listening
+	// immediately.
+	for attempt := 0; ; attempt++ {
+		status, err := get()
+		if err == nil && status == http.StatusOK {
+			break
+		} else if attempt == 20 {
+			t.Fatalf("got status %d (%v) before the reload, expected %d", status, err, http.StatusOK)
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	previous := d.registry
+	d.registry = registry.NewServiceRegistry(d.logger)
+
+	if err := d.swapRegistry(previous, true); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, err := get(); err != nil || status != http.StatusOK {
+		t.Errorf("got status %d (%v) after the reload, expected %d", status, err, http.StatusOK)
+	}
+
+	if err := d.proxy.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+// recordingLogger is a log.Logger that records all info and warning
+// messages.
+type recordingLogger struct {
+	log.Logger
+	infos    []string
+	warnings []string
+	errors   []string
+}
+
+func (rl *recordingLogger) Info(args ...interface{}) {
+	rl.infos = append(rl.infos, fmt.Sprint(args...))
+}
+
+func (rl *recordingLogger) Infof(format string, args ...interface{}) {
+	rl.infos = append(rl.infos, fmt.Sprintf(format, args...))
+}
+
+func (rl *recordingLogger) Warnf(format string, args ...interface{}) {
+	rl.warnings = append(rl.warnings, fmt.Sprintf(format, args...))
+}
+
+func (rl *recordingLogger) Errorf(format string, args ...interface{}) {
+	rl.errors = append(rl.errors, fmt.Sprintf(format, args...))
+}
+
+// TestDice_initializeRegistry_emptyStore tests the startup steps of Dice.Run
+// against an empty key-value store. It asserts that Dice starts cleanly and
+// logs that no services have been created yet.
+func TestDice_initializeRegistry_emptyStore(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.logger = logger
+
+	if err := d.checkConsistency(); err != nil {
+		t.Fatalf("expected consistency check to succeed, got %v", err)
+	}
+
+	if err := d.initializeRegistry(); err != nil {
+		t.Fatalf("expected registry initialization to succeed, got %v", err)
+	}
+
+	if len(d.registry.Services) != 0 {
+		t.Errorf("expected no registered services, got %d", len(d.registry.Services))
+	}
+
+	if len(logger.infos) != 1 || !strings.Contains(logger.infos[0], "no services") {
+		t.Errorf("expected an info message about missing services, got %v", logger.infos)
+	}
+}
+
+// TestDice_initializeRegistry_unsupportedBalancing tests the registry setup
+// with a stored service whose balancing method isn't supported, so that its
+// scheduler can't be created. The service mustn't be registered, the reason
+// has to be logged and all other services have to be registered anyway.
+func TestDice_initializeRegistry_unsupportedBalancing(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.logger = logger
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	broken, err := entity.NewService("s2", types.ServiceCreateOptions{URLs: "s2.example.com", Enable: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	broken.BalancingMethod = "unsupported"
+
+	if err := d.kvStore.CreateService(broken); err != nil {
+		t.Fatal(err)
+	}
+
+	d.registry = registry.NewServiceRegistry(logger)
+
+	if err := d.initializeRegistry(); err != nil {
+		t.Fatalf("expected registry initialization to succeed, got %v", err)
+	}
+
+	if _, ok := d.registry.Services[broken.ID]; ok {
+		t.Errorf("expected service s2 not to be registered")
+	}
+
+	if service, ok := d.registry.LookupService("s1.example.com"); !ok || service.Entity.Name != "s1" {
+		t.Errorf("expected service s1 to be registered")
+	}
+
+	if len(logger.errors) != 1 || !strings.Contains(logger.errors[0], broken.ID) {
+		t.Errorf("expected an error message about service s2, got %v", logger.errors)
+	}
+}
+
+// TestDice_Run_healthCheck tests if Dice.Run starts the periodic health
+// checks. The only instance of a service points to an upstream counting
+// the probes, and at least one probe has to arrive before Dice is stopped.
+func TestDice_Run_healthCheck(t *testing.T) {
+	var probes int32
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&probes, 1)
+	}))
+	defer upstream.Close()
+
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetServiceHealthCheck("s1", types.ServiceHealthCheckOptions{Path: "/health"}); err != nil {
+		t.Fatal(err)
+	}
+
+	address := upstream.Listener.Addr().String()
+
+	if err := d.CreateInstance("s1", "n1", address, types.InstanceCreateOptions{Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	dir, err := ioutil.TempDir("", "dice-run-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	if d.auditLog, err = audit.New(filepath.Join(dir, "audit.log")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Run registers all services itself, so it gets an empty registry.
+	d.registry = registry.NewServiceRegistry(d.logger)
+
+	healthCheckConfig := healthcheck.Config{
+		Interval:    time.Millisecond,
+		Timeout:     time.Second,
+		Concurrency: 1,
+	}
+
+	if d.healthCheck, err = healthcheck.New(healthCheckConfig, d.registry); err != nil {
+		t.Fatal(err)
+	}
+
+	d.proxy = proxy.New(proxy.Config{Addresses: []string{"127.0.0.1:0"}}, d.registry)
+	d.apiServer = api.NewServer(api.ServerConfig{Address: "127.0.0.1:0"}, controller.New(nil, nil, nil))
+	d.interrupt = make(chan os.Signal)
+
+	result := make(chan error, 1)
+
+	go func() {
+		result <- d.Run()
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for atomic.LoadInt32(&probes) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("the instance has not been probed by the health checker")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	d.interrupt <- os.Interrupt
+
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+}
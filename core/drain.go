@@ -0,0 +1,113 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"context"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"sort"
+	"time"
+)
+
+// DrainNode cordons an existing node and detaches all of its attached
+// instances one at a time, so that downstream caches and connection pools
+// can adjust gradually instead of losing all instances at once.
+//
+// The instances are detached least-loaded first, where the load of an
+// instance is the number of requests that are currently in flight. Between
+// two detaches, DrainNode pauses for the interval specified in the options.
+// The node itself stays attached and has to be detached separately.
+//
+// If ctx is cancelled during a pause, DrainNode stops and returns the error
+// of ctx. The instances that have been detached so far remain detached.
+func (d *Dice) DrainNode(ctx context.Context, nodeRef entity.NodeReference, options types.NodeDrainOptions) error {
+	node, err := d.findNode(nodeRef)
+
+	if err != nil {
+		return err
+	} else if node == nil {
+		return ErrNodeNotFound
+	}
+
+	if err := d.CordonNode(entity.NodeReference(node.ID)); err != nil {
+		return err
+	}
+
+	instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+		return instance.NodeID == node.ID && instance.IsAttached
+	})
+
+	if err != nil {
+		return err
+	}
+
+	load := make(map[string]int, len(instances))
+
+	for _, instance := range instances {
+		load[instance.ID] = d.instanceLoad(instance.ID)
+	}
+
+	sort.SliceStable(instances, func(i, j int) bool {
+		if load[instances[i].ID] != load[instances[j].ID] {
+			return load[instances[i].ID] < load[instances[j].ID]
+		}
+		return instances[i].URL < instances[j].URL
+	})
+
+	for i, instance := range instances {
+		if i > 0 && options.Interval > 0 {
+			if err := d.pause(ctx, options.Interval); err != nil {
+				return err
+			}
+		}
+
+		if err := d.DetachInstance(entity.InstanceReference(instance.ID)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// instanceLoad returns the number of requests that are currently in flight
+// for the instance with the given ID. Without a proxy, the load is 0.
+func (d *Dice) instanceLoad(instanceID string) int {
+	if d.proxy == nil {
+		return 0
+	}
+
+	return d.proxy.InstanceStats(instanceID).InFlight
+}
+
+// pause blocks for the given duration using the configured sleep function
+// or until ctx is cancelled, in which case the error of ctx is returned.
+func (d *Dice) pause(ctx context.Context, duration time.Duration) error {
+	if d.sleep != nil {
+		d.sleep(duration)
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(duration)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"context"
+	"github.com/dominikbraun/dice/types"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestDice_DrainNode tests Dice.DrainNode for a node with three instances.
+// It asserts that the instances are detached one at a time, that Dice
+// pauses for the configured interval between two detaches and that the
+// node is cordoned afterwards.
+func TestDice_DrainNode(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 3)
+
+	var (
+		pauses    []time.Duration
+		available []int
+	)
+
+	d.sleep = func(duration time.Duration) {
+		pauses = append(pauses, duration)
+		available = append(available, availableInstances(d, service.ID))
+	}
+
+	options := types.NodeDrainOptions{Interval: 2 * time.Second}
+
+	if err := d.DrainNode(context.Background(), "n1", options); err != nil {
+		t.Fatal(err)
+	}
+
+	expectedPauses := []time.Duration{2 * time.Second, 2 * time.Second}
+
+	if !reflect.DeepEqual(pauses, expectedPauses) {
+		t.Errorf("expected pauses %v, got %v", expectedPauses, pauses)
+	}
+
+	// Before each pause, exactly one more instance has to be detached.
+	expectedAvailable := []int{2, 1}
+
+	if !reflect.DeepEqual(available, expectedAvailable) {
+		t.Errorf("expected available instances %v, got %v", expectedAvailable, available)
+	}
+
+	if n := availableInstances(d, service.ID); n != 0 {
+		t.Errorf("expected all instances to be detached, got %d available", n)
+	}
+
+	node, err := d.findNode("n1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !node.Unschedulable {
+		t.Errorf("expected node n1 to be cordoned")
+	}
+}
+
+// TestDice_DrainNode_cancel tests Dice.DrainNode with a context that gets
+// cancelled during the first one-minute pause. It asserts that DrainNode stops without
+// detaching the remaining instances and returns the error of the context.
+func TestDice_DrainNode_cancel(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	options := types.NodeDrainOptions{Interval: time.Minute}
+
+	if err := d.DrainNode(ctx, "n1", options); err != context.Canceled {
+		t.Fatalf("expected error %v, got %v", context.Canceled, err)
+	}
+
+	if n := availableInstances(d, service.ID); n != 2 {
+		t.Errorf("expected 2 available instances, got %d", n)
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/event"
+	"github.com/dominikbraun/dice/types"
+)
+
+// InstanceEvents returns the recent state changes of an instance, like being
+// attached or marked as dead, the latest event coming last.
+func (d *Dice) InstanceEvents(instanceRef entity.InstanceReference) ([]types.EventOutput, error) {
+	instance, err := d.findInstance(instanceRef)
+
+	if err != nil {
+		return nil, err
+	} else if instance == nil {
+		return nil, ErrInstanceNotFound
+	}
+
+	return d.eventList(instance.ID), nil
+}
+
+// NodeEvents returns the recent state changes of a node, the latest event
+// coming last.
+func (d *Dice) NodeEvents(nodeRef entity.NodeReference) ([]types.EventOutput, error) {
+	node, err := d.findNode(nodeRef)
+
+	if err != nil {
+		return nil, err
+	} else if node == nil {
+		return nil, ErrNodeNotFound
+	}
+
+	return d.eventList(node.ID), nil
+}
+
+// eventList converts the events of an entity into their output type.
+func (d *Dice) eventList(entityID string) []types.EventOutput {
+	if d.events == nil {
+		return []types.EventOutput{}
+	}
+
+	events := d.events.Events(entityID)
+	eventList := make([]types.EventOutput, len(events))
+
+	for i, e := range events {
+		eventList[i] = types.EventOutput{
+			Timestamp: e.Timestamp,
+			Type:      string(e.Type),
+		}
+	}
+
+	return eventList
+}
+
+// recordEvent records a state change of an entity in the event log. It is a
+// no-op if there is no event log.
+func (d *Dice) recordEvent(entityID string, eventType event.Type) {
+	if d.events == nil {
+		return
+	}
+
+	d.events.Record(entityID, eventType)
+}
+
+// removeEvents deletes all events of a removed entity from the event log.
+func (d *Dice) removeEvents(entityID string) {
+	if d.events == nil {
+		return
+	}
+
+	d.events.Remove(entityID)
+}
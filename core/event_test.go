@@ -0,0 +1,62 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/event"
+	"github.com/dominikbraun/dice/types"
+	"testing"
+)
+
+// TestDice_InstanceEvents tests if detaching and attaching an instance
+// produces two events in the order of the state changes.
+func TestDice_InstanceEvents(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	setupReplaceTest(t, d, 1)
+
+	d.events = event.NewLog(10)
+
+	if err := d.DetachInstance("n1:8000"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.AttachInstance("n1:8000", types.InstanceAttachOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	events, err := d.InstanceEvents("n1:8000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []event.Type{event.DetachedEvent, event.AttachedEvent}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %v", len(expected), events)
+	}
+
+	for i, e := range events {
+		if e.Type != string(expected[i]) {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], e.Type)
+		}
+	}
+
+	if events[0].Timestamp.After(events[1].Timestamp) {
+		t.Errorf("expected the detach event to be recorded before the attach event")
+	}
+}
@@ -18,16 +18,30 @@ package core
 import (
 	"errors"
 	"fmt"
+	"github.com/dominikbraun/dice/audit"
 	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/event"
 	"github.com/dominikbraun/dice/registry"
 	"github.com/dominikbraun/dice/store"
 	"github.com/dominikbraun/dice/types"
+	"sort"
 	"strings"
+	"time"
+)
+
+const (
+	// nodePlaceholder is replaced with the node name in URL templates, see
+	// CreateInstancesOnNodes.
+	nodePlaceholder string = "{node}"
 )
 
 var (
-	ErrInstanceNotFound      = errors.New("instance could not be found")
-	ErrInstanceAlreadyExists = errors.New("a instance with the given ID, name or URL already exists")
+	ErrInstanceNotFound      = types.NewError(types.NotFoundError, "instance could not be found")
+	ErrInstanceAlreadyExists = types.NewError(types.ConflictError, "a instance with the given ID, name or URL already exists")
+	ErrNodeUnschedulable     = errors.New("node is cordoned and doesn't accept new instances")
+	ErrNodeDetached          = errors.New("node is detached and the instance won't receive traffic, attach the node or use --force")
+	ErrNodeSelectorMissing   = errors.New("no node selector has been specified")
+	ErrURLTemplateInvalid    = errors.New("URL template doesn't contain the {node} placeholder")
 )
 
 // CreateInstance creates a new instance with the provided service ID, node
@@ -48,6 +62,8 @@ func (d *Dice) CreateInstance(serviceRef entity.ServiceReference, nodeRef entity
 		return err
 	} else if node == nil {
 		return ErrNodeNotFound
+	} else if node.Unschedulable {
+		return ErrNodeUnschedulable
 	}
 
 	instance, err := entity.NewInstance(service.ID, node.ID, normalizeURL(url), options)
@@ -55,6 +71,8 @@ func (d *Dice) CreateInstance(serviceRef entity.ServiceReference, nodeRef entity
 		return err
 	}
 
+	instance.IsUpdated = instance.IsUpToDate(service.TargetVersion)
+
 	if ok, message := validateInstance(instance); !ok {
 		return errors.New(message)
 	}
@@ -71,6 +89,9 @@ func (d *Dice) CreateInstance(serviceRef entity.ServiceReference, nodeRef entity
 		return err
 	}
 
+	d.audit(audit.CreateAction, audit.InstanceEntity, instance.ID, instance.Name)
+	d.publish(store.InstanceEntity, instance.ID)
+
 	deployment := registry.Deployment{
 		Node:     node,
 		Instance: instance,
@@ -81,7 +102,7 @@ func (d *Dice) CreateInstance(serviceRef entity.ServiceReference, nodeRef entity
 	}
 
 	if options.Attach {
-		if err := d.AttachInstance(entity.InstanceReference(instance.ID)); err != nil {
+		if err := d.AttachInstance(entity.InstanceReference(instance.ID), types.InstanceAttachOptions{}); err != nil {
 			return fmt.Errorf("instance created but not attached: %s", err.Error())
 		}
 	}
@@ -89,10 +110,107 @@ func (d *Dice) CreateInstance(serviceRef entity.ServiceReference, nodeRef entity
 	return nil
 }
 
+// CloneInstance creates a new instance on the target node that is reachable
+// under newURL and has the same configuration as the source instance: The
+// service, the version, the connection limit and the standby setting are
+// copied, and the clone is attached if the source instance is attached. The
+// name isn't copied, since it has to be unique within the service.
+//
+// Instances don't have a weight of their own, so the clone receives its
+// share of requests according to the weight of the target node.
+func (d *Dice) CloneInstance(srcRef entity.InstanceReference, targetNodeRef entity.NodeReference, newURL string) error {
+	source, err := d.findInstance(srcRef)
+
+	if err != nil {
+		return err
+	} else if source == nil {
+		return ErrInstanceNotFound
+	}
+
+	options := types.InstanceCreateOptions{
+		Version:        source.Version,
+		Attach:         source.IsAttached,
+		MaxConnections: source.MaxConnections,
+		Standby:        source.Standby,
+	}
+
+	return d.CreateInstance(entity.ServiceReference(source.ServiceID), targetNodeRef, newURL, options)
+}
+
+// CreateInstancesOnNodes creates an instance of a service on each node that
+// is selected by nodeSelector, ordered by node name. The instance URL is
+// derived from urlTemplate by replacing the {node} placeholder with the name
+// of the respective node, for example {node}:8080. The same applies to the
+// Name and IDKey options.
+//
+// A failure doesn't abort the creation on the remaining nodes. Instead, the
+// outcome for each node is reported in the returned results. An error will
+// only be returned if the service or the nodes couldn't be found.
+func (d *Dice) CreateInstancesOnNodes(serviceRef entity.ServiceReference, nodeSelector types.NodeSelectOptions, urlTemplate string, options types.InstanceCreateOptions) ([]types.InstanceResultOutput, error) {
+	if nodeSelector.Selector == "" && !nodeSelector.All {
+		return nil, ErrNodeSelectorMissing
+	}
+
+	if !strings.Contains(urlTemplate, nodePlaceholder) {
+		return nil, ErrURLTemplateInvalid
+	}
+
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return nil, err
+	} else if service == nil {
+		return nil, ErrServiceNotFound
+	}
+
+	nodes, err := d.kvStore.FindNodes(func(node *entity.Node) bool {
+		return nodeSelector.All || strings.HasPrefix(node.Name, nodeSelector.Selector)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+
+	results := make([]types.InstanceResultOutput, len(nodes))
+
+	for i, node := range nodes {
+		expand := func(template string) string {
+			return strings.ReplaceAll(template, nodePlaceholder, node.Name)
+		}
+
+		url := expand(urlTemplate)
+
+		nodeOptions := options
+		nodeOptions.Name = expand(options.Name)
+		nodeOptions.IDKey = expand(options.IDKey)
+
+		results[i] = types.InstanceResultOutput{
+			NodeID:   node.ID,
+			NodeName: node.Name,
+			URL:      normalizeURL(url),
+			Success:  true,
+		}
+
+		if err := d.CreateInstance(entity.ServiceReference(service.ID), entity.NodeReference(node.ID), url, nodeOptions); err != nil {
+			results[i].Success = false
+			results[i].Error = err.Error()
+		}
+	}
+
+	return results, nil
+}
+
 // AttachInstance attaches an existing instance to Dice, making it available
 // as a target for load balancing. This function will update the instance
 // data and synchronize the instance with the service registry.
-func (d *Dice) AttachInstance(instanceRef entity.InstanceReference) error {
+//
+// Instances on a detached node don't receive any traffic. Therefore, such
+// an instance can only be attached using the `Force` option.
+func (d *Dice) AttachInstance(instanceRef entity.InstanceReference, options types.InstanceAttachOptions) error {
 	instance, err := d.findInstance(instanceRef)
 
 	if err != nil {
@@ -101,16 +219,35 @@ func (d *Dice) AttachInstance(instanceRef entity.InstanceReference) error {
 		return ErrInstanceNotFound
 	}
 
+	if !options.Force {
+		node, err := d.kvStore.FindNode(instance.NodeID)
+
+		if err != nil {
+			return err
+		} else if node != nil && !node.IsAttached {
+			return ErrNodeDetached
+		}
+	}
+
+	if !instance.IsAttached {
+		instance.AttachedSince = time.Now()
+	}
+
 	instance.IsAttached = true
 
 	if err := d.kvStore.UpdateInstance(instance.ID, instance); err != nil {
 		return err
 	}
 
+	d.audit(audit.AttachAction, audit.InstanceEntity, instance.ID, instance.Name)
+	d.recordEvent(instance.ID, event.AttachedEvent)
+	d.publish(store.InstanceEntity, instance.ID)
+
 	return d.registry.Update(func(s *registry.Service) error {
 		for _, d := range s.Deployments {
 			if d.Instance.ID == instance.ID {
 				d.Instance.IsAttached = true
+				d.Instance.AttachedSince = instance.AttachedSince
 			}
 		}
 		return nil
@@ -135,6 +272,10 @@ func (d *Dice) DetachInstance(instanceRef entity.InstanceReference) error {
 		return err
 	}
 
+	d.audit(audit.DetachAction, audit.InstanceEntity, instance.ID, instance.Name)
+	d.recordEvent(instance.ID, event.DetachedEvent)
+	d.publish(store.InstanceEntity, instance.ID)
+
 	return d.registry.Update(func(s *registry.Service) error {
 		for _, d := range s.Deployments {
 			if d.Instance.ID == instance.ID {
@@ -145,6 +286,102 @@ func (d *Dice) DetachInstance(instanceRef entity.InstanceReference) error {
 	})
 }
 
+// setAttachments attaches or detaches multiple instances at once, where the
+// map holds the desired state for each instance ID. Unlike AttachInstance
+// and DetachInstance, all instances are written to the key-value store in a
+// single transaction and the service registry is only traversed once.
+//
+// Instances that already have the desired state are left untouched. Just
+// like AttachInstance with the `Force` option, the nodes aren't checked.
+func (d *Dice) setAttachments(attachments map[string]bool) error {
+	instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+		isAttached, ok := attachments[instance.ID]
+		return ok && instance.IsAttached != isAttached
+	})
+
+	if err != nil {
+		return err
+	} else if len(instances) == 0 {
+		return nil
+	}
+
+	attachedSince := make(map[string]time.Time, len(instances))
+	now := time.Now()
+
+	for _, instance := range instances {
+		instance.IsAttached = attachments[instance.ID]
+
+		if instance.IsAttached {
+			instance.AttachedSince = now
+		}
+		attachedSince[instance.ID] = instance.AttachedSince
+	}
+
+	if err := d.kvStore.UpdateInstances(instances); err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		action, eventType := audit.DetachAction, event.DetachedEvent
+		if instance.IsAttached {
+			action, eventType = audit.AttachAction, event.AttachedEvent
+		}
+
+		d.audit(action, audit.InstanceEntity, instance.ID, instance.Name)
+		d.recordEvent(instance.ID, eventType)
+		d.publish(store.InstanceEntity, instance.ID)
+	}
+
+	return d.registry.Update(func(s *registry.Service) error {
+		for _, d := range s.Deployments {
+			if isAttached, ok := attachments[d.Instance.ID]; ok {
+				d.Instance.IsAttached = isAttached
+				d.Instance.AttachedSince = attachedSince[d.Instance.ID]
+			}
+		}
+		return nil
+	})
+}
+
+// setUpdated sets the IsUpdated flag of all instances of a service according
+// to the target version of the service. Just like setAttachments, all changed
+// instances are written to the key-value store in a single transaction.
+func (d *Dice) setUpdated(service *entity.Service) error {
+	instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+		return instance.ServiceID == service.ID && instance.IsUpdated != instance.IsUpToDate(service.TargetVersion)
+	})
+
+	if err != nil {
+		return err
+	} else if len(instances) == 0 {
+		return nil
+	}
+
+	updated := make(map[string]bool, len(instances))
+
+	for _, instance := range instances {
+		instance.IsUpdated = !instance.IsUpdated
+		updated[instance.ID] = instance.IsUpdated
+	}
+
+	if err := d.kvStore.UpdateInstances(instances); err != nil {
+		return err
+	}
+
+	for _, instance := range instances {
+		d.publish(store.InstanceEntity, instance.ID)
+	}
+
+	return d.registry.Update(func(s *registry.Service) error {
+		for _, d := range s.Deployments {
+			if isUpdated, ok := updated[d.Instance.ID]; ok {
+				d.Instance.IsUpdated = isUpdated
+			}
+		}
+		return nil
+	})
+}
+
 // RemoveInstance removes an instance entirely. After getting unregistered
 // from the service registry, it won't be available for load balancing any
 // longer. Also, it can't be restored anymore.
@@ -168,10 +405,20 @@ func (d *Dice) RemoveInstance(instanceRef entity.InstanceReference, options type
 		return fmt.Errorf("instance is attached, detach it or use --force")
 	}
 
-	return d.kvStore.DeleteInstance(instance.ID)
+	if err := d.kvStore.DeleteInstance(instance.ID); err != nil {
+		return err
+	}
+
+	d.audit(audit.RemoveAction, audit.InstanceEntity, instance.ID, instance.Name)
+	d.removeEvents(instance.ID)
+	d.publish(store.InstanceEntity, instance.ID)
+
+	return nil
 }
 
 // InstanceInfo returns user-relevant information for an existing instance.
+// It also reports whether the instance is currently serving requests, which
+// is determined using the live state from the service registry.
 func (d *Dice) InstanceInfo(instanceRef entity.InstanceReference) (types.InstanceInfoOutput, error) {
 	instance, err := d.findInstance(instanceRef)
 
@@ -181,6 +428,13 @@ func (d *Dice) InstanceInfo(instanceRef entity.InstanceReference) (types.Instanc
 		return types.InstanceInfoOutput{}, ErrInstanceNotFound
 	}
 
+	deployment, err := d.findDeployment(instance)
+	if err != nil {
+		return types.InstanceInfoOutput{}, err
+	}
+
+	isServing, reason := servingState(deployment)
+
 	instanceInfo := types.InstanceInfoOutput{
 		ID:         instance.ID,
 		Name:       instance.Name,
@@ -188,23 +442,160 @@ func (d *Dice) InstanceInfo(instanceRef entity.InstanceReference) (types.Instanc
 		NodeID:     instance.NodeID,
 		URL:        instance.URL,
 		Version:    instance.Version,
+		IsUpdated:  instance.IsUpdated,
 		IsAttached: instance.IsAttached,
 		IsAlive:    instance.IsAlive,
+		IsStandby:  instance.Standby,
+		IsServing:  isServing,
+		Reason:     reason,
 	}
 
 	return instanceInfo, nil
 }
 
+// findDeployment returns the deployment of an instance from the service
+// registry. If the instance isn't registered, the deployment is built from
+// the key-value store instead. In that case, Node may be `nil`.
+func (d *Dice) findDeployment(instance *entity.Instance) (registry.Deployment, error) {
+	if registryService, ok := d.registry.Service(instance.ServiceID); ok {
+		for _, deployment := range registryService.Deployments {
+			if deployment.Instance.ID == instance.ID {
+				return deployment, nil
+			}
+		}
+	}
+
+	node, err := d.kvStore.FindNode(instance.NodeID)
+	if err != nil {
+		return registry.Deployment{}, err
+	}
+
+	deployment := registry.Deployment{
+		Node:     node,
+		Instance: instance,
+	}
+
+	return deployment, nil
+}
+
+// servingState indicates whether the proxy forwards requests to the instance
+// of a deployment. If it doesn't, the reason is returned as well.
+func servingState(deployment registry.Deployment) (bool, string) {
+	switch {
+	case !deployment.Instance.IsAttached:
+		return false, "instance is detached"
+	case deployment.Node == nil:
+		return false, "node could not be found"
+	case !deployment.Node.IsAttached:
+		return false, "node is detached"
+	case !deployment.Instance.IsAlive:
+		return false, "instance is not alive"
+	}
+
+	return true, ""
+}
+
+// InstanceDescribe returns user-relevant information for an existing instance
+// including the service and node it belongs to. The attach and alive status
+// is taken from the service registry, which reflects the live state.
+func (d *Dice) InstanceDescribe(instanceRef entity.InstanceReference) (types.InstanceDescribeOutput, error) {
+	instance, err := d.findInstance(instanceRef)
+
+	if err != nil {
+		return types.InstanceDescribeOutput{}, err
+	} else if instance == nil {
+		return types.InstanceDescribeOutput{}, ErrInstanceNotFound
+	}
+
+	service, err := d.kvStore.FindService(instance.ServiceID)
+	if err != nil {
+		return types.InstanceDescribeOutput{}, err
+	}
+
+	node, err := d.kvStore.FindNode(instance.NodeID)
+	if err != nil {
+		return types.InstanceDescribeOutput{}, err
+	}
+
+	instanceDescribe := types.InstanceDescribeOutput{
+		ID:         instance.ID,
+		Name:       instance.Name,
+		URL:        instance.URL,
+		Version:    instance.Version,
+		ServiceID:  instance.ServiceID,
+		NodeID:     instance.NodeID,
+		IsAttached: instance.IsAttached,
+		IsAlive:    instance.IsAlive,
+	}
+
+	if service != nil {
+		instanceDescribe.ServiceName = service.Name
+	}
+
+	if node != nil {
+		instanceDescribe.NodeName = node.Name
+		instanceDescribe.NodeZone = node.Zone
+		instanceDescribe.NodeIsAttached = node.IsAttached
+		instanceDescribe.NodeIsAlive = node.IsAlive
+	}
+
+	if registryService, ok := d.registry.Service(instance.ServiceID); ok {
+		for _, deployment := range registryService.Deployments {
+			if deployment.Instance.ID != instance.ID {
+				continue
+			}
+
+			instanceDescribe.IsRegistered = true
+			instanceDescribe.IsAttached = deployment.Instance.IsAttached
+			instanceDescribe.IsAlive = deployment.Instance.IsAlive
+			instanceDescribe.NodeIsAttached = deployment.Node.IsAttached
+			instanceDescribe.NodeIsAlive = deployment.Node.IsAlive
+		}
+	}
+
+	return instanceDescribe, nil
+}
+
+// InstanceStats returns the proxy stats for an existing instance. The stats
+// are cumulative since the proxy has been started, see proxy.InstanceStats.
+func (d *Dice) InstanceStats(instanceRef entity.InstanceReference) (types.InstanceStatsOutput, error) {
+	instance, err := d.findInstance(instanceRef)
+
+	if err != nil {
+		return types.InstanceStatsOutput{}, err
+	} else if instance == nil {
+		return types.InstanceStatsOutput{}, ErrInstanceNotFound
+	}
+
+	stats := d.proxy.InstanceStats(instance.ID)
+
+	instanceStats := types.InstanceStatsOutput{
+		InstanceID:  instance.ID,
+		Requests:    stats.Requests,
+		Errors:      stats.Errors,
+		LastLatency: stats.LastLatency,
+		InFlight:    stats.InFlight,
+	}
+
+	if stats.Requests > 0 {
+		instanceStats.ErrorRate = float64(stats.Errors) / float64(stats.Requests)
+	}
+
+	return instanceStats, nil
+}
+
 // ListInstances returns a list of stored instances. By default, detached
 // instances will be ignored. They only will be returned if the options say
-// to do so.
+// to do so. Using the AliveOnly and DeadOnly options, the instances can be
+// filtered by their alive status as reported by the service registry. The
+// Outdated option only returns instances that aren't up to date.
 func (d *Dice) ListInstances(options types.InstanceListOptions) ([]types.InstanceInfoOutput, error) {
-	filter := store.AllInstancesFilter
+	if options.AliveOnly && options.DeadOnly {
+		return nil, ErrConflictingStatusFilters
+	}
 
-	if !options.All {
-		filter = func(instance *entity.Instance) bool {
-			return instance.IsAttached
-		}
+	filter := func(instance *entity.Instance) bool {
+		return (options.All || instance.IsAttached) && (!options.Outdated || !instance.IsUpdated)
 	}
 
 	instances, err := d.kvStore.FindInstances(filter)
@@ -212,9 +603,19 @@ func (d *Dice) ListInstances(options types.InstanceListOptions) ([]types.Instanc
 		return nil, err
 	}
 
-	serviceList := make([]types.InstanceInfoOutput, len(instances))
+	liveStatus := d.liveInstanceStatus()
+	serviceList := make([]types.InstanceInfoOutput, 0, len(instances))
+
+	for _, inst := range instances {
+		isAlive, ok := liveStatus[inst.ID]
+		if !ok {
+			isAlive = inst.IsAlive
+		}
+
+		if !matchesStatus(isAlive, options.AliveOnly, options.DeadOnly) {
+			continue
+		}
 
-	for i, inst := range instances {
 		info := types.InstanceInfoOutput{
 			ID:         inst.ID,
 			Name:       inst.Name,
@@ -222,10 +623,12 @@ func (d *Dice) ListInstances(options types.InstanceListOptions) ([]types.Instanc
 			NodeID:     inst.NodeID,
 			URL:        inst.URL,
 			Version:    inst.Version,
+			IsUpdated:  inst.IsUpdated,
 			IsAttached: inst.IsAttached,
-			IsAlive:    inst.IsAlive,
+			IsAlive:    isAlive,
+			IsStandby:  inst.Standby,
 		}
-		serviceList[i] = info
+		serviceList = append(serviceList, info)
 	}
 
 	return serviceList, nil
@@ -242,39 +645,44 @@ func (d *Dice) ListInstances(options types.InstanceListOptions) ([]types.Instanc
 // If multiple instances match, only the first one will be returned. If no
 // instances match, `nil` - and no error - will be returned.
 func (d *Dice) findInstance(instanceRef entity.InstanceReference) (*entity.Instance, error) {
-	instancesByID, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+	instanceByID, err := d.firstInstance(func(instance *entity.Instance) bool {
 		return instance.ID == string(instanceRef)
 	})
 
-	if err != nil {
-		return nil, err
-	} else if len(instancesByID) > 0 {
-		return instancesByID[0], nil
+	if err != nil || instanceByID != nil {
+		return instanceByID, err
 	}
 
-	instancesByName, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+	instanceByName, err := d.firstInstance(func(instance *entity.Instance) bool {
 		return instance.Name == string(instanceRef)
 	})
 
-	if err != nil {
-		return nil, err
-	} else if len(instancesByName) > 0 {
-		return instancesByName[0], nil
+	if err != nil || instanceByName != nil {
+		return instanceByName, err
 	}
 
 	instanceURL := normalizeURL(string(instanceRef))
 
-	instancesByURL, err := d.kvStore.FindInstances(func(i *entity.Instance) bool {
+	return d.firstInstance(func(i *entity.Instance) bool {
 		return i.URL == instanceURL
 	})
+}
 
-	if err != nil {
-		return nil, err
-	} else if len(instanceURL) > 0 {
-		return instancesByURL[0], nil
-	}
+// firstInstance returns the first instance in the key-value store that matches
+// the filter. The remaining instances won't be read once a match has been
+// found. If no instance matches, `nil` - and no error - will be returned.
+func (d *Dice) firstInstance(filter store.InstanceFilter) (*entity.Instance, error) {
+	var match *entity.Instance
+
+	err := d.kvStore.ForEachInstance(func(instance *entity.Instance) (bool, error) {
+		if filter(instance) {
+			match = instance
+			return true, nil
+		}
+		return false, nil
+	})
 
-	return nil, nil
+	return match, err
 }
 
 // instanceIsUnique checks if a newly created instance is unique. An instance
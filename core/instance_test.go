@@ -0,0 +1,326 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestDice_InstanceDescribe tests Dice.InstanceDescribe for an instance that
+// is alive according to the service registry only. It asserts that service
+// and node names are resolved and that the live status is reported.
+func TestDice_InstanceDescribe(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 1)
+
+	instanceDescribe, err := d.InstanceDescribe("n1:8000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := types.InstanceDescribeOutput{
+		ID:             instanceDescribe.ID,
+		URL:            "n1:8000",
+		Version:        "v1",
+		ServiceID:      service.ID,
+		ServiceName:    "s1",
+		NodeID:         instanceDescribe.NodeID,
+		NodeName:       "n1",
+		IsRegistered:   true,
+		IsAttached:     true,
+		IsAlive:        true,
+		NodeIsAttached: true,
+	}
+
+	if instanceDescribe != expected {
+		t.Errorf("expected %+v, got %+v", expected, instanceDescribe)
+	}
+
+	if _, err := d.InstanceDescribe("n1:9999"); err != ErrInstanceNotFound {
+		t.Errorf("expected error %v, got %v", ErrInstanceNotFound, err)
+	}
+}
+
+// TestDice_AttachInstance_detachedNode tests Dice.AttachInstance for an
+// instance whose node is detached. It asserts that attaching the instance
+// is refused unless the Force option is used.
+func TestDice_AttachInstance_detachedNode(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	setupReplaceTest(t, d, 1)
+
+	if err := d.DetachInstance("n1:8000"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DetachNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.AttachInstance("n1:8000", types.InstanceAttachOptions{}); err != ErrNodeDetached {
+		t.Errorf("expected error %v, got %v", ErrNodeDetached, err)
+	}
+
+	instance, _ := d.findInstance("n1:8000")
+	if instance.IsAttached {
+		t.Errorf("expected instance to remain detached")
+	}
+
+	if err := d.AttachInstance("n1:8000", types.InstanceAttachOptions{Force: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	instance, _ = d.findInstance("n1:8000")
+	if !instance.IsAttached {
+		t.Errorf("expected instance to be attached")
+	}
+}
+
+// TestDice_InstanceInfo_servingState tests if Dice.InstanceInfo reports the
+// serving state of an instance along with the reason for not serving.
+func TestDice_InstanceInfo_servingState(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	setupReplaceTest(t, d, 1)
+
+	assertServing := func(isServing bool, reason string) {
+		t.Helper()
+
+		instanceInfo, err := d.InstanceInfo("n1:8000")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instanceInfo.IsServing != isServing || instanceInfo.Reason != reason {
+			t.Errorf("expected serving state %v (%q), got %v (%q)", isServing, reason,
+				instanceInfo.IsServing, instanceInfo.Reason)
+		}
+	}
+
+	assertServing(true, "")
+
+	if err := d.DetachNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+	assertServing(false, "node is detached")
+
+	if err := d.DetachInstance("n1:8000"); err != nil {
+		t.Fatal(err)
+	}
+	assertServing(false, "instance is detached")
+
+	if err := d.AttachNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.AttachInstance("n1:8000", types.InstanceAttachOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	assertServing(true, "")
+}
+
+// TestDice_ListInstances_statusFilters tests Dice.ListInstances with the
+// AliveOnly and DeadOnly options for an alive and a dead instance.
+func TestDice_ListInstances_statusFilters(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 2)
+
+	for _, deployment := range d.registry.Services[service.ID].Deployments {
+		deployment.Instance.IsAlive = deployment.Instance.URL == "n1:8000"
+	}
+
+	tests := []struct {
+		options  types.InstanceListOptions
+		expected []string
+	}{
+		{options: types.InstanceListOptions{}, expected: []string{"n1:8000", "n1:8001"}},
+		{options: types.InstanceListOptions{AliveOnly: true}, expected: []string{"n1:8000"}},
+		{options: types.InstanceListOptions{DeadOnly: true}, expected: []string{"n1:8001"}},
+	}
+
+	for _, test := range tests {
+		instanceList, err := d.ListInstances(test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		urls := make([]string, len(instanceList))
+		for i, inst := range instanceList {
+			urls[i] = inst.URL
+		}
+		sort.Strings(urls)
+
+		if !reflect.DeepEqual(urls, test.expected) {
+			t.Errorf("%+v: expected instances %v, got %v", test.options, test.expected, urls)
+		}
+	}
+
+	if _, err := d.ListInstances(types.InstanceListOptions{AliveOnly: true, DeadOnly: true}); err != ErrConflictingStatusFilters {
+		t.Errorf("expected error %v, got %v", ErrConflictingStatusFilters, err)
+	}
+}
+
+// TestDice_CreateInstancesOnNodes tests Dice.CreateInstancesOnNodes with a
+// node selector matching three nodes. It asserts that an instance has been
+// created on each matching node using the templated URL.
+func TestDice_CreateInstancesOnNodes(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	for _, name := range []string{"web-3", "web-1", "db-1", "web-2"} {
+		if err := d.CreateNode(name, types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	selector := types.NodeSelectOptions{Selector: "web-"}
+	options := types.InstanceCreateOptions{Name: "s1-{node}", Version: "v1"}
+
+	results, err := d.CreateInstancesOnNodes("s1", selector, "{node}:8080", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"web-1", "web-2", "web-3"}
+
+	if len(results) != len(expected) {
+		t.Fatalf("expected %d results, got %d", len(expected), len(results))
+	}
+
+	for i, name := range expected {
+		url := name + ":8080"
+
+		if !results[i].Success || results[i].NodeName != name || results[i].URL != url {
+			t.Errorf("unexpected result %v for node %s", results[i], name)
+		}
+
+		instance, err := d.findInstance(entity.InstanceReference(url))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instance == nil {
+			t.Errorf("instance %s has not been created", url)
+			continue
+		}
+
+		if instance.NodeID != results[i].NodeID || instance.Name != "s1-"+name {
+			t.Errorf("unexpected instance %v on node %s", instance, name)
+		}
+	}
+
+	// Creating the instances again fails for each node, but doesn't abort.
+	results, err = d.CreateInstancesOnNodes("s1", selector, "{node}:8080", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range results {
+		if r.Success {
+			t.Errorf("expected creation on node %s to fail", r.NodeName)
+		}
+	}
+}
+
+// TestDice_CreateInstancesOnNodes_invalid tests Dice.CreateInstancesOnNodes
+// with a missing selector and a URL template without placeholder.
+func TestDice_CreateInstancesOnNodes_invalid(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	options := types.InstanceCreateOptions{}
+
+	if _, err := d.CreateInstancesOnNodes("s1", types.NodeSelectOptions{}, "{node}:8080", options); err != ErrNodeSelectorMissing {
+		t.Errorf("expected %v, got %v", ErrNodeSelectorMissing, err)
+	}
+
+	if _, err := d.CreateInstancesOnNodes("s1", types.NodeSelectOptions{All: true}, "web:8080", options); err != ErrURLTemplateInvalid {
+		t.Errorf("expected %v, got %v", ErrURLTemplateInvalid, err)
+	}
+}
+
+// TestDice_CloneInstance tests Dice.CloneInstance for an instance that is
+// cloned to another node. It asserts that the clone shares the configuration
+// of the source instance but has its own ID and runs on the target node, and
+// that cloning to an existing URL is refused.
+func TestDice_CloneInstance(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 0)
+
+	if err := d.CreateNode("n2", types.NodeCreateOptions{Weight: 2, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	options := types.InstanceCreateOptions{Version: "v1", Attach: true, MaxConnections: 10, Standby: true}
+
+	if err := d.CreateInstance("s1", "n1", "n1:8000", options); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CloneInstance("n1:8000", "n2", "n2:8000"); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := d.findInstance("n1:8000")
+	if err != nil || source == nil {
+		t.Fatalf("source instance has not been found: %v", err)
+	}
+
+	clone, err := d.findInstance("n2:8000")
+	if err != nil || clone == nil {
+		t.Fatalf("clone has not been found: %v", err)
+	}
+
+	node, err := d.findNode("n2")
+	if err != nil || node == nil {
+		t.Fatalf("node n2 has not been found: %v", err)
+	}
+
+	if clone.ID == source.ID {
+		t.Errorf("expected clone to have a new ID, got %s", clone.ID)
+	}
+
+	if clone.NodeID != node.ID {
+		t.Errorf("expected clone on node %s, got %s", node.ID, clone.NodeID)
+	}
+
+	if clone.ServiceID != service.ID || clone.Version != source.Version || clone.IsAttached != source.IsAttached ||
+		clone.MaxConnections != source.MaxConnections || clone.Standby != source.Standby {
+		t.Errorf("expected clone to share the configuration of %+v, got %+v", source, clone)
+	}
+
+	if err := d.CloneInstance("n1:8000", "n2", "n2:8000"); err != ErrInstanceAlreadyExists {
+		t.Errorf("expected error %v, got %v", ErrInstanceAlreadyExists, err)
+	}
+
+	if err := d.CloneInstance("n1:9999", "n2", "n2:9000"); err != ErrInstanceNotFound {
+		t.Errorf("expected error %v, got %v", ErrInstanceNotFound, err)
+	}
+}
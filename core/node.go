@@ -18,15 +18,17 @@ package core
 import (
 	"errors"
 	"fmt"
+	"github.com/dominikbraun/dice/audit"
 	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/event"
 	"github.com/dominikbraun/dice/registry"
 	"github.com/dominikbraun/dice/store"
 	"github.com/dominikbraun/dice/types"
 )
 
 var (
-	ErrNodeNotFound      = errors.New("node could not be found")
-	ErrNodeAlreadyExists = errors.New("the given node already exists")
+	ErrNodeNotFound      = types.NewError(types.NotFoundError, "node could not be found")
+	ErrNodeAlreadyExists = types.NewError(types.ConflictError, "the given node already exists")
 )
 
 // CreateNode creates a new node with the provided URL and stores the node
@@ -54,6 +56,9 @@ func (d *Dice) CreateNode(name string, options types.NodeCreateOptions) error {
 		return err
 	}
 
+	d.audit(audit.CreateAction, audit.NodeEntity, node.ID, node.Name)
+	d.publish(store.NodeEntity, node.ID)
+
 	if options.Attach {
 		return d.AttachNode(entity.NodeReference(node.ID))
 	}
@@ -79,6 +84,10 @@ func (d *Dice) AttachNode(nodeRef entity.NodeReference) error {
 		return err
 	}
 
+	d.audit(audit.AttachAction, audit.NodeEntity, node.ID, node.Name)
+	d.recordEvent(node.ID, event.AttachedEvent)
+	d.publish(store.NodeEntity, node.ID)
+
 	return d.registry.Update(func(s *registry.Service) error {
 		for _, d := range s.Deployments {
 			if d.Node.ID == node.ID {
@@ -107,6 +116,10 @@ func (d *Dice) DetachNode(nodeRef entity.NodeReference) error {
 		return err
 	}
 
+	d.audit(audit.DetachAction, audit.NodeEntity, node.ID, node.Name)
+	d.recordEvent(node.ID, event.DetachedEvent)
+	d.publish(store.NodeEntity, node.ID)
+
 	return d.registry.Update(func(s *registry.Service) error {
 		for _, d := range s.Deployments {
 			if d.Node.ID == node.ID {
@@ -117,6 +130,49 @@ func (d *Dice) DetachNode(nodeRef entity.NodeReference) error {
 	})
 }
 
+// CordonNode marks an existing node as unschedulable. No new instances can
+// be created on a cordoned node, but existing instances keep serving. This
+// is useful for draining a node before decommissioning it.
+func (d *Dice) CordonNode(nodeRef entity.NodeReference) error {
+	return d.setNodeUnschedulable(nodeRef, true, audit.CordonAction)
+}
+
+// UncordonNode marks an existing node as schedulable again, allowing new
+// instances to be created on it.
+func (d *Dice) UncordonNode(nodeRef entity.NodeReference) error {
+	return d.setNodeUnschedulable(nodeRef, false, audit.UncordonAction)
+}
+
+// setNodeUnschedulable sets the Unschedulable flag of a node and records
+// the given action in the audit log.
+func (d *Dice) setNodeUnschedulable(nodeRef entity.NodeReference, unschedulable bool, action audit.Action) error {
+	node, err := d.findNode(nodeRef)
+
+	if err != nil {
+		return err
+	} else if node == nil {
+		return ErrNodeNotFound
+	}
+
+	node.Unschedulable = unschedulable
+
+	if err := d.kvStore.UpdateNode(node.ID, node); err != nil {
+		return err
+	}
+
+	d.audit(action, audit.NodeEntity, node.ID, node.Name)
+	d.publish(store.NodeEntity, node.ID)
+
+	return d.registry.Update(func(s *registry.Service) error {
+		for _, d := range s.Deployments {
+			if d.Node.ID == node.ID {
+				d.Node.Unschedulable = unschedulable
+			}
+		}
+		return nil
+	})
+}
+
 // RemoveNode deletes a node entirely, removing it from the key-value store
 // and unregistering it from the service registry.
 //
@@ -139,7 +195,15 @@ func (d *Dice) RemoveNode(nodeRef entity.NodeReference, options types.NodeRemove
 		return fmt.Errorf("node is attached or has attached instances, detach or use --force")
 	}
 
-	return d.kvStore.DeleteNode(node.ID)
+	if err := d.kvStore.DeleteNode(node.ID); err != nil {
+		return err
+	}
+
+	d.audit(audit.RemoveAction, audit.NodeEntity, node.ID, node.Name)
+	d.removeEvents(node.ID)
+	d.publish(store.NodeEntity, node.ID)
+
+	return nil
 }
 
 // NodeInfo returns user-relevant information for an existing node.
@@ -153,19 +217,28 @@ func (d *Dice) NodeInfo(nodeRef entity.NodeReference) (types.NodeInfoOutput, err
 	}
 
 	nodeInfo := types.NodeInfoOutput{
-		ID:         node.ID,
-		Name:       node.Name,
-		IsAttached: node.IsAttached,
-		IsAlive:    node.IsAlive,
+		ID:            node.ID,
+		Name:          node.Name,
+		Zone:          node.Zone,
+		IsAttached:    node.IsAttached,
+		IsAlive:       node.IsAlive,
+		Unschedulable: node.Unschedulable,
 	}
 
 	return nodeInfo, nil
 }
 
 // ListNodes returns a list of stored nodes. By default, detached nodes will
-// be ignored. They only will be returned if the options say to do so. In any
-// case, dead nodes will be returned.
+// be ignored. They only will be returned if the options say to do so. Dead
+// nodes will be returned unless the AliveOnly option is set.
+//
+// The alive status is taken from the service registry if the node has any
+// deployments, since the registry reflects the live state.
 func (d *Dice) ListNodes(options types.NodeListOptions) ([]types.NodeInfoOutput, error) {
+	if options.AliveOnly && options.DeadOnly {
+		return nil, ErrConflictingStatusFilters
+	}
+
 	filter := store.AllNodesFilter
 
 	if !options.All {
@@ -179,16 +252,28 @@ func (d *Dice) ListNodes(options types.NodeListOptions) ([]types.NodeInfoOutput,
 		return nil, err
 	}
 
-	nodeList := make([]types.NodeInfoOutput, len(nodes))
+	liveStatus := d.liveNodeStatus()
+	nodeList := make([]types.NodeInfoOutput, 0, len(nodes))
+
+	for _, n := range nodes {
+		isAlive, ok := liveStatus[n.ID]
+		if !ok {
+			isAlive = n.IsAlive
+		}
+
+		if !matchesStatus(isAlive, options.AliveOnly, options.DeadOnly) {
+			continue
+		}
 
-	for i, n := range nodes {
 		info := types.NodeInfoOutput{
-			ID:         n.ID,
-			Name:       n.Name,
-			IsAttached: n.IsAttached,
-			IsAlive:    n.IsAlive,
+			ID:            n.ID,
+			Name:          n.Name,
+			Zone:          n.Zone,
+			IsAttached:    n.IsAttached,
+			IsAlive:       isAlive,
+			Unschedulable: n.Unschedulable,
 		}
-		nodeList[i] = info
+		nodeList = append(nodeList, info)
 	}
 
 	return nodeList, nil
@@ -200,27 +285,34 @@ func (d *Dice) ListNodes(options types.NodeListOptions) ([]types.NodeInfoOutput,
 // If multiple nodes match, only the first one will be returned. If no nodes
 // match, `nil` - and no error - will be returned.
 func (d *Dice) findNode(nodeRef entity.NodeReference) (*entity.Node, error) {
-	nodesByID, err := d.kvStore.FindNodes(func(node *entity.Node) bool {
+	nodeByID, err := d.firstNode(func(node *entity.Node) bool {
 		return node.ID == string(nodeRef)
 	})
 
-	if err != nil {
-		return nil, err
-	} else if len(nodesByID) > 0 {
-		return nodesByID[0], nil
+	if err != nil || nodeByID != nil {
+		return nodeByID, err
 	}
 
-	nodesByName, err := d.kvStore.FindNodes(func(node *entity.Node) bool {
+	return d.firstNode(func(node *entity.Node) bool {
 		return node.Name == string(nodeRef)
 	})
+}
 
-	if err != nil {
-		return nil, err
-	} else if len(nodesByName) > 0 {
-		return nodesByName[0], nil
-	}
+// firstNode returns the first node in the key-value store that matches
+// the filter. The remaining nodes won't be read once a match has been
+// found. If no node matches, `nil` - and no error - will be returned.
+func (d *Dice) firstNode(filter store.NodeFilter) (*entity.Node, error) {
+	var match *entity.Node
+
+	err := d.kvStore.ForEachNode(func(node *entity.Node) (bool, error) {
+		if filter(node) {
+			match = node
+			return true, nil
+		}
+		return false, nil
+	})
 
-	return nil, nil
+	return match, err
 }
 
 // nodeIsUnique checks if a newly created node is unique. A node is unique
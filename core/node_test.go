@@ -0,0 +1,120 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// TestDice_CordonNode tests Dice.CordonNode for a node with an existing
+// instance. It asserts that no new instances can be created on the node
+// while the existing instance keeps serving, and that uncordoning the node
+// allows new instances again.
+func TestDice_CordonNode(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 1)
+
+	if err := d.CordonNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := d.CreateInstance("s1", "n1", "n1:8100", types.InstanceCreateOptions{Attach: true})
+	if err != ErrNodeUnschedulable {
+		t.Errorf("expected error %v, got %v", ErrNodeUnschedulable, err)
+	}
+
+	registryService := d.registry.Services[service.ID]
+
+	if len(registryService.Deployments) != 1 {
+		t.Fatalf("expected 1 deployment, got %d", len(registryService.Deployments))
+	}
+
+	instance, err := registryService.Scheduler.Next()
+	if err != nil {
+		t.Fatalf("expected the existing instance to keep serving, got %v", err)
+	}
+
+	if instance.URL != "n1:8000" {
+		t.Errorf("expected instance n1:8000, got %s", instance.URL)
+	}
+
+	if err := d.UncordonNode("n1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateInstance("s1", "n1", "n1:8100", types.InstanceCreateOptions{}); err != nil {
+		t.Errorf("expected instance creation on uncordoned node to succeed, got %v", err)
+	}
+}
+
+// TestDice_ListNodes_statusFilters tests Dice.ListNodes with the AliveOnly
+// and DeadOnly options for an alive node, a dead node and a node without
+// any deployments.
+func TestDice_ListNodes_statusFilters(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 1)
+
+	for _, name := range []string{"n2", "n3"} {
+		if err := d.CreateNode(name, types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.CreateInstance("s1", "n2", "n2:8000", types.InstanceCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, deployment := range d.registry.Services[service.ID].Deployments {
+		deployment.Node.IsAlive = deployment.Node.Name == "n1"
+	}
+
+	tests := []struct {
+		options  types.NodeListOptions
+		expected []string
+	}{
+		{options: types.NodeListOptions{}, expected: []string{"n1", "n2", "n3"}},
+		{options: types.NodeListOptions{AliveOnly: true}, expected: []string{"n1"}},
+		{options: types.NodeListOptions{DeadOnly: true}, expected: []string{"n2", "n3"}},
+	}
+
+	for _, test := range tests {
+		nodeList, err := d.ListNodes(test.options)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := make([]string, len(nodeList))
+		for i, n := range nodeList {
+			names[i] = n.Name
+		}
+		sort.Strings(names)
+
+		if !reflect.DeepEqual(names, test.expected) {
+			t.Errorf("%+v: expected nodes %v, got %v", test.options, test.expected, names)
+		}
+	}
+
+	if _, err := d.ListNodes(types.NodeListOptions{AliveOnly: true, DeadOnly: true}); err != ErrConflictingStatusFilters {
+		t.Errorf("expected error %v, got %v", ErrConflictingStatusFilters, err)
+	}
+}
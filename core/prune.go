@@ -0,0 +1,154 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"sort"
+)
+
+// Prune removes orphaned instances and unused services from Dice.
+//
+// An instance is orphaned if its service or its node doesn't exist. This
+// may happen if entities have been deleted from the key-value store manually.
+// A service is unused if it has neither URLs nor instances, which means that
+// it can't serve any requests.
+//
+// Orphaned instances are pruned first, so that services whose instances are
+// all orphaned are pruned as well. If the `DryRun` option is set, the pruned
+// entities are only reported but not removed.
+func (d *Dice) Prune(options types.PruneOptions) (types.PruneOutput, error) {
+	output := types.PruneOutput{
+		Instances: []string{},
+		Services:  []string{},
+		DryRun:    options.DryRun,
+	}
+
+	services, err := d.kvStore.FindServices(store.AllServicesFilter)
+	if err != nil {
+		return output, err
+	}
+
+	nodes, err := d.kvStore.FindNodes(store.AllNodesFilter)
+	if err != nil {
+		return output, err
+	}
+
+	instances, err := d.kvStore.FindInstances(store.AllInstancesFilter)
+	if err != nil {
+		return output, err
+	}
+
+	serviceExists := make(map[string]bool, len(services))
+	nodeExists := make(map[string]bool, len(nodes))
+	instanceCount := make(map[string]int, len(services))
+
+	for _, s := range services {
+		serviceExists[s.ID] = true
+	}
+
+	for _, n := range nodes {
+		nodeExists[n.ID] = true
+	}
+
+	var orphanedInstances []*entity.Instance
+
+	for _, i := range instances {
+		if !serviceExists[i.ServiceID] || !nodeExists[i.NodeID] {
+			orphanedInstances = append(orphanedInstances, i)
+			continue
+		}
+		instanceCount[i.ServiceID]++
+	}
+
+	var unusedServices []*entity.Service
+
+	for _, s := range services {
+		if len(s.URLs) == 0 && instanceCount[s.ID] == 0 {
+			unusedServices = append(unusedServices, s)
+		}
+	}
+
+	sort.Slice(orphanedInstances, func(i, j int) bool {
+		return orphanedInstances[i].ID < orphanedInstances[j].ID
+	})
+
+	sort.Slice(unusedServices, func(i, j int) bool {
+		return unusedServices[i].ID < unusedServices[j].ID
+	})
+
+	for _, i := range orphanedInstances {
+		if !options.DryRun {
+			if err := d.pruneInstance(i); err != nil {
+				return output, err
+			}
+		}
+		output.Instances = append(output.Instances, i.ID)
+	}
+
+	for _, s := range unusedServices {
+		if !options.DryRun {
+			if err := d.pruneService(s); err != nil {
+				return output, err
+			}
+		}
+		output.Services = append(output.Services, s.ID)
+	}
+
+	return output, nil
+}
+
+// pruneInstance removes an instance from the service registry, regardless
+// of whether it is attached, and deletes it from the key-value store.
+func (d *Dice) pruneInstance(instance *entity.Instance) error {
+	filter := func(deployment registry.Deployment) bool {
+		return deployment.Instance.ID == instance.ID
+	}
+
+	_ = d.registry.UnregisterDeployments(filter, true)
+
+	if err := d.kvStore.DeleteInstance(instance.ID); err != nil {
+		return err
+	}
+
+	d.audit(audit.PruneAction, audit.InstanceEntity, instance.ID, instance.Name)
+	d.publish(store.InstanceEntity, instance.ID)
+
+	return nil
+}
+
+// pruneService removes a service from the service registry and deletes it
+// from the key-value store.
+func (d *Dice) pruneService(service *entity.Service) error {
+	if err := d.registry.UnregisterService(service.ID, true); err != nil {
+		if err != registry.ErrUnregisteredService {
+			return err
+		}
+	}
+
+	if err := d.kvStore.DeleteService(service.ID); err != nil {
+		return err
+	}
+
+	d.audit(audit.PruneAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
+	return nil
+}
@@ -0,0 +1,93 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"reflect"
+	"testing"
+)
+
+// TestDice_Prune tests Dice.Prune with instances referencing a nonexistent
+// service or node and a service without URLs and instances. It asserts that
+// a dry run only reports these entities and that they're removed otherwise.
+func TestDice_Prune(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 1)
+	node, _ := d.findNode("n1")
+
+	orphans := []*entity.Instance{
+		{ID: "i1", ServiceID: "missing", NodeID: node.ID, URL: "n1:8001"},
+		{ID: "i2", ServiceID: service.ID, NodeID: "missing", URL: "n2:8000"},
+	}
+
+	for _, i := range orphans {
+		if err := d.kvStore.CreateInstance(i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.kvStore.CreateService(&entity.Service{ID: "s2", Name: "unused"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := types.PruneOutput{
+		Instances: []string{"i1", "i2"},
+		Services:  []string{"s2"},
+		DryRun:    true,
+	}
+
+	output, err := d.Prune(types.PruneOptions{DryRun: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("expected %+v, got %+v", expected, output)
+	}
+
+	if instances, _ := d.kvStore.FindInstances(store.AllInstancesFilter); len(instances) != 3 {
+		t.Errorf("expected dry run to keep all instances, got %d", len(instances))
+	}
+
+	expected.DryRun = false
+
+	output, err = d.Prune(types.PruneOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(output, expected) {
+		t.Errorf("expected %+v, got %+v", expected, output)
+	}
+
+	instances, _ := d.kvStore.FindInstances(store.AllInstancesFilter)
+	if len(instances) != 1 || instances[0].URL != "n1:8000" {
+		t.Errorf("expected only instance n1:8000 to remain, got %+v", instances)
+	}
+
+	if unused, _ := d.kvStore.FindService("s2"); unused != nil {
+		t.Errorf("expected service s2 to be removed")
+	}
+
+	if kept, _ := d.kvStore.FindService(service.ID); kept == nil {
+		t.Errorf("expected service s1 to be kept")
+	}
+}
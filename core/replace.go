@@ -0,0 +1,192 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"sort"
+	"time"
+)
+
+const (
+	// defaultReplaceTimeout is the time a new instance has for becoming
+	// alive if no timeout has been specified.
+	defaultReplaceTimeout = time.Minute
+	// aliveCheckInterval is the interval in which a new instance is checked
+	// while waiting for it to become alive.
+	aliveCheckInterval = 500 * time.Millisecond
+)
+
+var (
+	ErrReplacementURLMissing = errors.New("no replacement URL has been specified for instance")
+	ErrInstanceNotAlive      = errors.New("instance didn't become alive in time")
+)
+
+// RollingReplace replaces all instances of a service that don't have the
+// given version with new instances of that version, one batch at a time.
+//
+// Each new instance is deployed to the node of the instance it replaces and
+// is reachable under the URL specified in the options. After creating and
+// attaching a batch of new instances, Dice waits for them to become alive.
+// Only then the old instances are detached and removed. The batch size is
+// determined by the MaxUnavailable option, which defaults to 1.
+//
+// If a new instance doesn't become alive in time, all new instances of the
+// current batch are removed again and the replacement is aborted. Instances
+// that have been replaced in previous batches will be kept.
+func (d *Dice) RollingReplace(serviceRef entity.ServiceReference, version string, options types.ServiceReplaceOptions) error {
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return err
+	} else if service == nil {
+		return ErrServiceNotFound
+	}
+
+	instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+		return instance.ServiceID == service.ID && instance.Version != version
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].URL < instances[j].URL
+	})
+
+	urls := make(map[string]string, len(options.URLs))
+
+	for oldURL, newURL := range options.URLs {
+		urls[normalizeURL(oldURL)] = newURL
+	}
+
+	for _, instance := range instances {
+		if _, ok := urls[instance.URL]; !ok {
+			return fmt.Errorf("%w: %s", ErrReplacementURLMissing, instance.URL)
+		}
+	}
+
+	batchSize := options.MaxUnavailable
+	if batchSize < 1 {
+		batchSize = 1
+	}
+
+	timeout := options.Timeout
+	if timeout <= 0 {
+		timeout = defaultReplaceTimeout
+	}
+
+	for start := 0; start < len(instances); start += batchSize {
+		end := start + batchSize
+		if end > len(instances) {
+			end = len(instances)
+		}
+
+		if err := d.replaceInstances(service, instances[start:end], version, urls, timeout); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// replaceInstances replaces a single batch of instances as described in the
+// docs for RollingReplace.
+func (d *Dice) replaceInstances(service *entity.Service, instances []*entity.Instance, version string, urls map[string]string, timeout time.Duration) error {
+	replacements := make([]*entity.Instance, 0, len(instances))
+
+	// removeReplacements rolls back the current batch by removing all new
+	// instances that have been created so far.
+	removeReplacements := func() {
+		for _, r := range replacements {
+			_ = d.RemoveInstance(entity.InstanceReference(r.ID), types.InstanceRemoveOptions{Force: true})
+		}
+	}
+
+	for _, instance := range instances {
+		url := urls[instance.URL]
+
+		options := types.InstanceCreateOptions{
+			Version: version,
+			Attach:  true,
+		}
+
+		err := d.CreateInstance(entity.ServiceReference(service.ID), entity.NodeReference(instance.NodeID), url, options)
+
+		// Even if the instance couldn't be attached, it has been created and
+		// needs to be removed in case of an error.
+		created, findErr := d.kvStore.FindInstances(func(i *entity.Instance) bool {
+			return i.ServiceID == service.ID && i.URL == normalizeURL(url)
+		})
+		if findErr == nil && len(created) > 0 {
+			replacements = append(replacements, created[0])
+		}
+
+		if err != nil {
+			removeReplacements()
+			return err
+		}
+	}
+
+	for _, r := range replacements {
+		if err := d.awaitAlive(service.ID, r.ID, timeout); err != nil {
+			removeReplacements()
+			return fmt.Errorf("%w: %s", err, r.URL)
+		}
+	}
+
+	for _, instance := range instances {
+		if err := d.DetachInstance(entity.InstanceReference(instance.ID)); err != nil {
+			return err
+		}
+
+		// The instance has been detached, but the node is still attached.
+		// Therefore, the removal has to be forced.
+		if err := d.RemoveInstance(entity.InstanceReference(instance.ID), types.InstanceRemoveOptions{Force: true}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// awaitAlive checks the instance with the given ID until it is alive. If the
+// instance isn't alive before the timeout expires, ErrInstanceNotAlive will
+// be returned.
+func (d *Dice) awaitAlive(serviceID, instanceID string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for {
+		isAlive, err := d.checkInstance(serviceID, instanceID)
+		if err != nil {
+			return err
+		}
+
+		if isAlive {
+			return nil
+		}
+
+		if time.Now().Add(aliveCheckInterval).After(deadline) {
+			return ErrInstanceNotAlive
+		}
+
+		time.Sleep(aliveCheckInterval)
+	}
+}
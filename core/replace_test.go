@@ -0,0 +1,204 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"testing"
+	"time"
+)
+
+// setupReplaceTest creates a service with the given number of attached and
+// alive instances of version v1. It returns the service and a map of URLs
+// that maps each instance URL to the URL of its replacement.
+func setupReplaceTest(t *testing.T, d *Dice, count int) (*entity.Service, map[string]string) {
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	urls := make(map[string]string)
+
+	for i := 0; i < count; i++ {
+		url := fmt.Sprintf("n1:%d", 8000+i)
+		options := types.InstanceCreateOptions{Version: "v1", Attach: true}
+
+		if err := d.CreateInstance("s1", "n1", url, options); err != nil {
+			t.Fatal(err)
+		}
+		urls[url] = fmt.Sprintf("n1:%d", 9000+i)
+	}
+
+	service, err := d.findService("s1")
+	if err != nil || service == nil {
+		t.Fatalf("service s1 has not been found: %v", err)
+	}
+
+	for _, deployment := range d.registry.Services[service.ID].Deployments {
+		deployment.Instance.IsAlive = true
+	}
+
+	return service, urls
+}
+
+// availableInstances returns the number of attached and alive instances of
+// a service in the service registry.
+func availableInstances(d *Dice, serviceID string) int {
+	available := 0
+
+	for _, deployment := range d.registry.Services[serviceID].Deployments {
+		if deployment.Instance.IsAttached && deployment.Instance.IsAlive {
+			available++
+		}
+	}
+
+	return available
+}
+
+// TestDice_RollingReplace tests Dice.RollingReplace with a stubbed health
+// check that reports each new instance as alive. Whenever an instance is
+// checked, the number of available instances must not be lower than the
+// initial number of instances minus MaxUnavailable. Eventually, only new
+// instances must be left.
+func TestDice_RollingReplace(t *testing.T) {
+	const instanceCount = 5
+	const maxUnavailable = 2
+
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, urls := setupReplaceTest(t, d, instanceCount)
+	minAvailable := instanceCount - maxUnavailable
+	checks := 0
+
+	d.checkInstance = func(serviceID, instanceID string) (bool, error) {
+		checks++
+
+		if available := availableInstances(d, serviceID); available < minAvailable {
+			t.Errorf("%d instances available, expected at least %d", available, minAvailable)
+		}
+
+		for _, deployment := range d.registry.Services[serviceID].Deployments {
+			if deployment.Instance.ID == instanceID {
+				deployment.Instance.IsAlive = true
+			}
+		}
+
+		return true, nil
+	}
+
+	options := types.ServiceReplaceOptions{
+		URLs:           urls,
+		MaxUnavailable: maxUnavailable,
+	}
+
+	if err := d.RollingReplace("s1", "v2", options); err != nil {
+		t.Fatal(err)
+	}
+
+	if checks != instanceCount {
+		t.Errorf("got %d health checks, expected %d", checks, instanceCount)
+	}
+
+	instances, err := d.kvStore.FindInstances(store.AllInstancesFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != instanceCount {
+		t.Errorf("got %d instances, expected %d", len(instances), instanceCount)
+	}
+
+	for _, instance := range instances {
+		if instance.Version != "v2" || !instance.IsAttached {
+			t.Errorf("instance %s has version %s and is attached: %v, expected attached v2", instance.URL, instance.Version, instance.IsAttached)
+		}
+	}
+
+	if available := availableInstances(d, service.ID); available != instanceCount {
+		t.Errorf("%d instances available, expected %d", available, instanceCount)
+	}
+}
+
+// TestDice_RollingReplace_notAlive tests Dice.RollingReplace with a new
+// instance that never becomes alive. The replacement has to be aborted and
+// all old instances have to be kept.
+func TestDice_RollingReplace_notAlive(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, urls := setupReplaceTest(t, d, 3)
+
+	d.checkInstance = func(serviceID, instanceID string) (bool, error) {
+		return false, nil
+	}
+
+	options := types.ServiceReplaceOptions{
+		URLs:    urls,
+		Timeout: time.Millisecond,
+	}
+
+	if err := d.RollingReplace("s1", "v2", options); !errors.Is(err, ErrInstanceNotAlive) {
+		t.Errorf("got error %v, expected %v", err, ErrInstanceNotAlive)
+	}
+
+	instances, err := d.kvStore.FindInstances(store.AllInstancesFilter)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(instances) != 3 {
+		t.Errorf("got %d instances, expected 3", len(instances))
+	}
+
+	for _, instance := range instances {
+		if instance.Version != "v1" {
+			t.Errorf("instance %s has version %s, expected v1", instance.URL, instance.Version)
+		}
+	}
+
+	if available := availableInstances(d, service.ID); available != 3 {
+		t.Errorf("%d instances available, expected 3", available)
+	}
+}
+
+// TestDice_RollingReplace_missingURL tests Dice.RollingReplace without a
+// replacement URL for one of the instances. No instance may be replaced.
+func TestDice_RollingReplace_missingURL(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	_, urls := setupReplaceTest(t, d, 2)
+	delete(urls, "n1:8001")
+
+	d.checkInstance = func(serviceID, instanceID string) (bool, error) {
+		t.Error("no instance should have been checked")
+		return true, nil
+	}
+
+	err := d.RollingReplace("s1", "v2", types.ServiceReplaceOptions{URLs: urls})
+
+	if !errors.Is(err, ErrReplacementURLMissing) {
+		t.Errorf("got error %v, expected %v", err, ErrReplacementURLMissing)
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// routeProbeTimeout is the time an instance has for responding to the
+	// HEAD request sent when testing a route.
+	routeProbeTimeout = 5 * time.Second
+)
+
+var (
+	ErrHostMissing        = errors.New("no host has been specified")
+	ErrInvalidRouteTarget = errors.New("host is not a valid URL")
+	ErrRouteNotFound      = types.NewError(types.NotFoundError, "no service has the given URL")
+)
+
+// ListRoutes returns the routing table of the service registry, that is all
+// registered routes along with the services they're pointing to, ordered by
+// route. The service information reflects the live state of the registry.
+func (d *Dice) ListRoutes() ([]types.RouteInfoOutput, error) {
+	routes := d.registry.Routes()
+	routeList := make([]types.RouteInfoOutput, 0, len(routes))
+
+	for route, serviceID := range routes {
+		routeInfo := types.RouteInfoOutput{
+			Route:     route,
+			ServiceID: serviceID,
+		}
+
+		if service, ok := d.registry.Service(serviceID); ok {
+			routeInfo.ServiceName = service.Entity.Name
+			routeInfo.IsEnabled = service.Entity.IsEnabled
+		}
+
+		routeList = append(routeList, routeInfo)
+	}
+
+	sort.Slice(routeList, func(i, j int) bool {
+		return routeList[i].Route < routeList[j].Route
+	})
+
+	return routeList, nil
+}
+
+// SetRouteWeights splits the requests to a service URL across multiple
+// services by weight. The proxy chooses one of these services for each
+// request before the instance is chosen by the service's scheduler. The
+// service owning the URL only receives requests if it is weighted as well.
+//
+// The weights are stored in the service owning the URL. Passing no weights
+// removes the split, so that the URL points to its own service again.
+func (d *Dice) SetRouteWeights(options types.RouteWeightsOptions) error {
+	owner, err := d.firstService(func(service *entity.Service) bool {
+		for _, u := range service.URLs {
+			if u == options.URL {
+				return true
+			}
+		}
+		return false
+	})
+
+	if err != nil {
+		return err
+	} else if owner == nil {
+		return ErrRouteNotFound
+	}
+
+	weights := make([]entity.RouteWeight, 0, len(options.Weights))
+
+	for serviceRef, weight := range options.Weights {
+		if weight <= 0 {
+			return registry.ErrInvalidRouteWeight
+		}
+
+		service, err := d.findService(entity.ServiceReference(serviceRef))
+
+		if err != nil {
+			return err
+		} else if service == nil {
+			return fmt.Errorf("%w: %s", ErrServiceNotFound, serviceRef)
+		}
+
+		weights = append(weights, entity.RouteWeight{
+			ServiceID: service.ID,
+			Weight:    weight,
+		})
+	}
+
+	sort.Slice(weights, func(i, j int) bool {
+		return weights[i].ServiceID < weights[j].ServiceID
+	})
+
+	if owner.RouteWeights == nil {
+		owner.RouteWeights = make(map[string][]entity.RouteWeight)
+	}
+
+	if len(weights) == 0 {
+		delete(owner.RouteWeights, options.URL)
+	} else {
+		owner.RouteWeights[options.URL] = weights
+	}
+
+	if err := d.kvStore.UpdateService(owner.ID, owner); err != nil {
+		return err
+	}
+
+	if err := d.registry.SetRouteWeights(options.URL, weights); err != nil {
+		return err
+	}
+
+	d.audit(audit.SetWeightsAction, audit.ServiceEntity, owner.ID, owner.Name)
+	d.publish(store.ServiceEntity, owner.ID)
+
+	return d.registry.Update(func(s *registry.Service) error {
+		if s.Entity.ID == owner.ID {
+			s.Entity.RouteWeights = owner.RouteWeights
+		}
+		return nil
+	})
+}
+
+// TestRoute explains how a request to the given host would be routed without
+// actually proxying it: It returns the matched service, all deployments of
+// that service and the instance the scheduler would pick next.
+//
+// For services with sticky sessions, the instance the client is pinned to by
+// an affinity cookie in the provided headers is selected if it's available.
+// The state of the scheduler isn't changed, see registry.Scheduler.Peek. Its
+// internal counters are included as well, see registry.Scheduler.Snapshot.
+//
+// If the Probe option is set, a HEAD request is sent to the selected instance
+// in order to confirm that it is reachable, see proxy.Proxy.Probe.
+func (d *Dice) TestRoute(options types.RouteTestOptions) (types.RouteTestOutput, error) {
+	if options.Host == "" {
+		return types.RouteTestOutput{}, ErrHostMissing
+	}
+
+	target, err := parseRouteTarget(options.Host)
+	if err != nil {
+		return types.RouteTestOutput{}, err
+	}
+
+	routeTest := types.RouteTestOutput{
+		Host:       options.Host,
+		Candidates: make([]types.RouteCandidateOutput, 0),
+	}
+
+	service, ok := d.registry.LookupService(target.Host)
+	if !ok {
+		routeTest.Reason = "no service matches the host"
+		return routeTest, nil
+	}
+
+	routeTest.IsMatched = true
+	routeTest.ServiceID = service.Entity.ID
+	routeTest.ServiceName = service.Entity.Name
+
+	for _, deployment := range service.Deployments {
+		routeTest.Candidates = append(routeTest.Candidates, types.RouteCandidateOutput{
+			InstanceID:         deployment.Instance.ID,
+			InstanceURL:        deployment.Instance.URL,
+			NodeID:             deployment.Node.ID,
+			NodeName:           deployment.Node.Name,
+			IsInstanceAttached: deployment.Instance.IsAttached,
+			IsInstanceAlive:    deployment.Instance.IsAlive,
+			IsNodeAttached:     deployment.Node.IsAttached,
+			IsNodeAlive:        deployment.Node.IsAlive,
+		})
+	}
+
+	switch {
+	case service.Entity.Maintenance.IsEnabled:
+		routeTest.Reason = "service is under maintenance"
+		return routeTest, nil
+	case !service.Entity.IsEnabled:
+		routeTest.Reason = "service is disabled"
+		return routeTest, nil
+	case service.Scheduler == nil:
+		routeTest.Reason = "service has no scheduler"
+		return routeTest, nil
+	}
+
+	routeTest.SchedulerCounters = service.Scheduler.Snapshot().Counters
+	routeTest.Selected, routeTest.Reason = d.selectRouteInstance(service, options.Headers)
+
+	if routeTest.Selected != "" && options.Probe {
+		d.probeRoute(&routeTest, service, target)
+	}
+
+	return routeTest, nil
+}
+
+// selectRouteInstance returns the ID of the instance a request with the given
+// headers would be routed to, along with a reason if that isn't obvious. If
+// no instance would be selected, the ID is empty and the reason explains why.
+func (d *Dice) selectRouteInstance(service *registry.Service, headers map[string]string) (string, string) {
+	if service.Entity.StickySessions {
+		request := &http.Request{Header: make(http.Header)}
+
+		for key, value := range headers {
+			request.Header.Set(key, value)
+		}
+
+		if instanceID, ok := d.proxy.PinnedInstance(request); ok && isAvailable(service, instanceID) {
+			return instanceID, "client is pinned to the instance"
+		}
+	}
+
+	instance, err := service.Scheduler.Peek()
+	if err != nil {
+		return "", err.Error()
+	}
+
+	return instance.ID, ""
+}
+
+// probeRoute sends a HEAD request to the selected instance of a route test
+// and records the outcome in the route test.
+func (d *Dice) probeRoute(routeTest *types.RouteTestOutput, service *registry.Service, target *url.URL) {
+	for _, deployment := range service.Deployments {
+		if deployment.Instance.ID != routeTest.Selected {
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), routeProbeTimeout)
+		defer cancel()
+
+		status, err := d.proxy.Probe(ctx, deployment.Instance.URL, target)
+
+		routeTest.IsProbed = true
+		routeTest.ProbeStatus = status
+
+		if err != nil {
+			routeTest.ProbeError = err.Error()
+		}
+
+		return
+	}
+}
+
+// parseRouteTarget parses the host or URL of a route test. URLs without a
+// scheme are accepted as well, so that example.com/users is a valid target.
+func parseRouteTarget(host string) (*url.URL, error) {
+	if !strings.Contains(host, "://") {
+		host = "http://" + host
+	}
+
+	target, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRouteTarget, err)
+	}
+
+	return target, nil
+}
+
+// isAvailable indicates whether the instance with the given ID is attached
+// and alive and has been deployed to an attached and alive node.
+func isAvailable(service *registry.Service, instanceID string) bool {
+	for _, d := range service.Deployments {
+		if d.Instance.ID == instanceID {
+			return d.Instance.IsAttached && d.Instance.IsAlive && d.Node.IsAttached && d.Node.IsAlive
+		}
+	}
+
+	return false
+}
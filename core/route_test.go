@@ -0,0 +1,275 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/proxy"
+	"github.com/dominikbraun/dice/types"
+	"net"
+	"testing"
+)
+
+// TestDice_ListRoutes tests if routes are listed by Dice.ListRoutes after
+// adding them with Dice.SetServiceURL and disappear after removing them.
+func TestDice_ListRoutes(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "a.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	service, _ := d.findService("s1")
+
+	if err := d.SetServiceURL("s1", "b.example.com", types.ServiceURLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	routeList, err := d.ListRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []types.RouteInfoOutput{
+		{Route: "a.example.com", ServiceID: service.ID, ServiceName: "s1", IsEnabled: true},
+		{Route: "b.example.com", ServiceID: service.ID, ServiceName: "s1", IsEnabled: true},
+	}
+
+	if len(routeList) != len(expected) {
+		t.Fatalf("expected %d routes, got %+v", len(expected), routeList)
+	}
+
+	for i := range expected {
+		if routeList[i] != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], routeList[i])
+		}
+	}
+
+	if err := d.SetServiceURL("s1", "b.example.com", types.ServiceURLOptions{Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	routeList, err = d.ListRoutes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(routeList) != 1 || routeList[0].Route != "a.example.com" {
+		t.Errorf("expected only a.example.com to be routed, got %+v", routeList)
+	}
+}
+
+// TestDice_TestRoute tests Dice.TestRoute for a host matching a service with
+// two alive instances. It asserts that all candidates are reported and that
+// the selected instance is the one the scheduler picks next, without
+// changing the scheduler's state.
+func TestDice_TestRoute(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 2)
+
+	routeTest, err := d.TestRoute(types.RouteTestOptions{Host: "s1.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !routeTest.IsMatched || routeTest.ServiceID != service.ID || routeTest.ServiceName != "s1" {
+		t.Fatalf("expected host to match service s1, got %v", routeTest)
+	}
+
+	if len(routeTest.Candidates) != 2 {
+		t.Errorf("expected 2 candidates, got %d", len(routeTest.Candidates))
+	}
+
+	for _, c := range routeTest.Candidates {
+		if !c.IsInstanceAttached || !c.IsInstanceAlive || !c.IsNodeAttached || c.NodeName != "n1" {
+			t.Errorf("unexpected candidate %v", c)
+		}
+	}
+
+	// Testing the route again has to select the same instance, since the
+	// scheduler state must not be changed.
+	again, err := d.TestRoute(types.RouteTestOptions{Host: "s1.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routeTest.Selected == "" || again.Selected != routeTest.Selected {
+		t.Errorf("expected instance %s to be selected again, got %s", routeTest.Selected, again.Selected)
+	}
+
+	next, err := d.registry.Services[service.ID].Scheduler.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if next.ID != routeTest.Selected {
+		t.Errorf("expected scheduler to pick %s, got %s", routeTest.Selected, next.ID)
+	}
+}
+
+// TestDice_TestRoute_unmatched tests Dice.TestRoute for a host that doesn't
+// match any service.
+func TestDice_TestRoute_unmatched(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	setupReplaceTest(t, d, 1)
+
+	routeTest, err := d.TestRoute(types.RouteTestOptions{Host: "unknown.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routeTest.IsMatched || routeTest.Selected != "" || len(routeTest.Candidates) != 0 {
+		t.Errorf("expected no match, got %v", routeTest)
+	}
+
+	if routeTest.Reason == "" {
+		t.Errorf("expected a reason for the missing match")
+	}
+
+	if _, err := d.TestRoute(types.RouteTestOptions{}); err != ErrHostMissing {
+		t.Errorf("expected %v, got %v", ErrHostMissing, err)
+	}
+}
+
+// TestDice_TestRoute_probe tests Dice.TestRoute with the Probe option for a
+// URL matching a service whose only instance isn't reachable, and for a URL
+// that doesn't match any service. It asserts that only the matched route is
+// probed and that the failed probe is reported.
+func TestDice_TestRoute_probe(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	d.proxy = proxy.New(proxy.Config{}, d.registry)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateInstance("s1", "n1", address, types.InstanceCreateOptions{Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, service := range d.registry.Services {
+		for _, deployment := range service.Deployments {
+			deployment.Instance.IsAlive = true
+		}
+	}
+
+	routeTest, err := d.TestRoute(types.RouteTestOptions{Host: "http://s1.example.com/users", Probe: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !routeTest.IsMatched || routeTest.Selected == "" {
+		t.Fatalf("expected the URL to be routed to an instance, got %v", routeTest)
+	}
+
+	if !routeTest.IsProbed || routeTest.ProbeError == "" || routeTest.ProbeStatus != 0 {
+		t.Errorf("expected a failed probe to be reported, got %v", routeTest)
+	}
+
+	routeTest, err = d.TestRoute(types.RouteTestOptions{Host: "unknown.example.com/users", Probe: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if routeTest.IsMatched || routeTest.IsProbed {
+		t.Errorf("expected an unmatched URL not to be probed, got %v", routeTest)
+	}
+}
+
+// TestDice_SetRouteWeights tests if the requests to a weighted URL are split
+// across both services, if the weights are stored in the owning service and
+// if they're removed along with the URL.
+func TestDice_SetRouteWeights(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	for _, name := range []string{"s1", "s2"} {
+		options := types.ServiceCreateOptions{URLs: name + ".example.com", Enable: true}
+
+		if err := d.CreateService(name, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	options := types.RouteWeightsOptions{
+		URL:     "s1.example.com",
+		Weights: map[string]int{"s1": 1, "s2": 1},
+	}
+
+	if err := d.SetRouteWeights(options); err != nil {
+		t.Fatal(err)
+	}
+
+	counts := make(map[string]int)
+
+	for i := 0; i < 1000; i++ {
+		service, ok := d.registry.LookupService("s1.example.com")
+		if !ok {
+			t.Fatal("expected s1.example.com to be routed to a service")
+		}
+		counts[service.Entity.Name]++
+	}
+
+	if counts["s1"] < 400 || counts["s2"] < 400 {
+		t.Errorf("expected an even split across s1 and s2, got %v", counts)
+	}
+
+	owner, err := d.findService("s1")
+	if err != nil || owner == nil {
+		t.Fatalf("service s1 has not been found: %v", err)
+	}
+
+	if len(owner.RouteWeights["s1.example.com"]) != 2 {
+		t.Errorf("expected 2 stored weights for s1.example.com, got %v", owner.RouteWeights)
+	}
+
+	if err := d.SetServiceURL("s1", "s1.example.com", types.ServiceURLOptions{Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetServiceURL("s1", "s1.example.com", types.ServiceURLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if service, _ := d.registry.LookupService("s1.example.com"); service.Entity.Name != "s1" {
+			t.Fatalf("expected the re-added URL to be routed to s1, got %s", service.Entity.Name)
+		}
+	}
+
+	options.URL = "unknown.example.com"
+
+	if err := d.SetRouteWeights(options); err != ErrRouteNotFound {
+		t.Errorf("expected error %v, got %v", ErrRouteNotFound, err)
+	}
+}
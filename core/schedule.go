@@ -0,0 +1,210 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"github.com/dominikbraun/dice/audit"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"sort"
+	"time"
+)
+
+// scheduleCheckInterval is the interval in which Dice checks for scheduled
+// transitions that are due.
+const scheduleCheckInterval = time.Second
+
+var (
+	ErrInvalidSchedule = errors.New("a schedule requires a transition time and a non-negative interval")
+)
+
+// ScheduleService schedules the enabling and disabling of a service, for
+// example to disable the service during a maintenance window. The schedule
+// is stored along with the service, so that it survives restarts.
+//
+// Once the time of a transition has been reached, it is fired using either
+// EnableService or DisableService, see fireTransitions. The Clear option
+// removes all transitions that have been scheduled before.
+func (d *Dice) ScheduleService(serviceRef entity.ServiceReference, options types.ServiceScheduleOptions) error {
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return err
+	} else if service == nil {
+		return ErrServiceNotFound
+	}
+
+	isEmpty := options.EnableAt.IsZero() && options.DisableAt.IsZero()
+
+	if options.Every < 0 || (isEmpty && !options.Clear) {
+		return ErrInvalidSchedule
+	}
+
+	if options.Clear {
+		service.Schedule = nil
+	}
+
+	if !options.DisableAt.IsZero() {
+		service.Schedule = append(service.Schedule, entity.Transition{
+			Enable: false,
+			At:     options.DisableAt,
+			Every:  options.Every,
+		})
+	}
+
+	if !options.EnableAt.IsZero() {
+		service.Schedule = append(service.Schedule, entity.Transition{
+			Enable: true,
+			At:     options.EnableAt,
+			Every:  options.Every,
+		})
+	}
+
+	sortTransitions(service.Schedule)
+
+	if err := d.kvStore.UpdateService(service.ID, service); err != nil {
+		return err
+	}
+
+	d.audit(audit.ScheduleAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
+	return d.registry.Update(func(s *registry.Service) error {
+		if s.Entity.ID == service.ID {
+			s.Entity.Schedule = service.Schedule
+		}
+		return nil
+	})
+}
+
+// fireTransitions fires the transitions of all services that are due. It
+// is called periodically while Dice is running. Errors are only logged, so
+// that a failing service doesn't prevent the transitions of other services.
+func (d *Dice) fireTransitions() {
+	now := d.currentTime()
+
+	services, err := d.kvStore.FindServices(func(service *entity.Service) bool {
+		for _, t := range service.Schedule {
+			if !t.At.After(now) {
+				return true
+			}
+		}
+		return false
+	})
+
+	if err != nil {
+		d.logger.Errorf("reading scheduled transitions failed: %v", err)
+		return
+	}
+
+	for _, service := range services {
+		if err := d.fireServiceTransitions(service, now); err != nil {
+			d.logger.Errorf("firing scheduled transition of service %s failed: %v", service.Name, err)
+		}
+	}
+}
+
+// fireServiceTransitions fires the transitions of a service that are due.
+// If multiple transitions are due, for example because Dice hasn't been
+// running for a while, only the latest one is fired, since it determines
+// the resulting state of the service.
+//
+// Repeating transitions are moved to their first occurrence after now, all
+// other transitions that have been fired are removed from the schedule.
+func (d *Dice) fireServiceTransitions(service *entity.Service, now time.Time) error {
+	var latest *entity.Transition
+	schedule := make([]entity.Transition, 0, len(service.Schedule))
+
+	for _, t := range service.Schedule {
+		if t.At.After(now) {
+			schedule = append(schedule, t)
+			continue
+		}
+
+		if latest == nil || t.At.After(latest.At) {
+			fired := t
+			latest = &fired
+		}
+
+		if t.Every > 0 {
+			t.At = t.At.Add((now.Sub(t.At)/t.Every + 1) * t.Every)
+			schedule = append(schedule, t)
+		}
+	}
+
+	if latest == nil {
+		return nil
+	}
+
+	serviceRef := entity.ServiceReference(service.ID)
+
+	if latest.Enable {
+		if err := d.EnableService(serviceRef); err != nil {
+			return err
+		}
+		d.logger.Infof("service %s has been enabled as scheduled", service.Name)
+	} else {
+		if err := d.DisableService(serviceRef); err != nil {
+			return err
+		}
+		d.logger.Infof("service %s has been disabled as scheduled", service.Name)
+	}
+
+	// Enabling or disabling the service has updated the stored service, so
+	// it has to be read again before updating its schedule.
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return err
+	} else if service == nil {
+		return nil
+	}
+
+	sortTransitions(schedule)
+	service.Schedule = schedule
+
+	if err := d.kvStore.UpdateService(service.ID, service); err != nil {
+		return err
+	}
+
+	d.publish(store.ServiceEntity, service.ID)
+
+	return d.registry.Update(func(s *registry.Service) error {
+		if s.Entity.ID == service.ID {
+			s.Entity.Schedule = service.Schedule
+		}
+		return nil
+	})
+}
+
+// sortTransitions sorts the transitions by their time.
+func sortTransitions(transitions []entity.Transition) {
+	sort.SliceStable(transitions, func(i, j int) bool {
+		return transitions[i].At.Before(transitions[j].At)
+	})
+}
+
+// currentTime returns the current time using the configured clock.
+func (d *Dice) currentTime() time.Time {
+	if d.now == nil {
+		return time.Now()
+	}
+
+	return d.now()
+}
@@ -0,0 +1,145 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"testing"
+	"time"
+)
+
+// TestDice_fireTransitions tests if a scheduled maintenance window disables
+// and re-enables a service at the scheduled times. The clock is advanced
+// manually, and the transitions must neither fire early nor get lost.
+func TestDice_fireTransitions(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	d.now = func() time.Time { return now }
+
+	options := types.ServiceScheduleOptions{
+		DisableAt: start.Add(2 * time.Hour),
+		EnableAt:  start.Add(4 * time.Hour),
+	}
+
+	if err := d.ScheduleService("s1", options); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		elapsed   time.Duration
+		isEnabled bool
+		remaining int
+	}{
+		{elapsed: 2*time.Hour - time.Second, isEnabled: true, remaining: 2},
+		{elapsed: 2 * time.Hour, isEnabled: false, remaining: 1},
+		{elapsed: 4*time.Hour - time.Second, isEnabled: false, remaining: 1},
+		{elapsed: 4 * time.Hour, isEnabled: true, remaining: 0},
+	}
+
+	for _, test := range tests {
+		now = start.Add(test.elapsed)
+		d.fireTransitions()
+
+		service, err := d.findService("s1")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if service.IsEnabled != test.isEnabled {
+			t.Errorf("after %v: expected enabled %v, got %v", test.elapsed, test.isEnabled, service.IsEnabled)
+		}
+
+		if len(service.Schedule) != test.remaining {
+			t.Errorf("after %v: expected %d transitions left, got %d", test.elapsed, test.remaining, len(service.Schedule))
+		}
+
+		if registered := d.registry.Services[service.ID].Entity.IsEnabled; registered != test.isEnabled {
+			t.Errorf("after %v: expected registered service to be enabled %v, got %v", test.elapsed, test.isEnabled, registered)
+		}
+	}
+}
+
+// TestDice_fireTransitions_repeating tests a transition that repeats every
+// day. After it has been fired, it has to be moved to the next day, even if
+// several occurrences have been missed.
+func TestDice_fireTransitions_repeating(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start.Add(3*24*time.Hour + time.Hour)
+	d.now = func() time.Time { return now }
+
+	options := types.ServiceScheduleOptions{
+		DisableAt: start,
+		Every:     24 * time.Hour,
+	}
+
+	if err := d.ScheduleService("s1", options); err != nil {
+		t.Fatal(err)
+	}
+
+	d.fireTransitions()
+
+	service, err := d.findService("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service.IsEnabled {
+		t.Error("expected service to be disabled")
+	}
+
+	expected := []entity.Transition{{Enable: false, At: start.Add(4 * 24 * time.Hour), Every: 24 * time.Hour}}
+
+	if len(service.Schedule) != 1 || !service.Schedule[0].At.Equal(expected[0].At) {
+		t.Errorf("expected schedule %v, got %v", expected, service.Schedule)
+	}
+}
+
+// TestDice_ScheduleService_invalid tests Dice.ScheduleService with options
+// that don't schedule any transition or have a negative interval.
+func TestDice_ScheduleService_invalid(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := []types.ServiceScheduleOptions{
+		{},
+		{EnableAt: time.Now(), Every: -time.Hour},
+	}
+
+	for _, options := range invalid {
+		if err := d.ScheduleService("s1", options); err != ErrInvalidSchedule {
+			t.Errorf("expected error %v for %+v, got %v", ErrInvalidSchedule, options, err)
+		}
+	}
+}
@@ -17,33 +17,63 @@ package core
 
 import (
 	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/audit"
 	"github.com/dominikbraun/dice/entity"
 	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/scheduler"
 	"github.com/dominikbraun/dice/store"
 	"github.com/dominikbraun/dice/types"
+	"path"
+	"sort"
+	"strings"
 )
 
 var (
-	ErrServiceNotFound      = errors.New("service could not be found")
-	ErrServiceAlreadyExists = errors.New("a service with the given ID or name already exists")
-	ErrServiceURLExists     = errors.New("one or more of the specified URLs already exists")
+	ErrServiceNotFound      = types.NewError(types.NotFoundError, "service could not be found")
+	ErrServiceAlreadyExists = types.NewError(types.ConflictError, "a service with the given ID or name already exists")
+	ErrServiceURLExists     = types.NewError(types.ConflictError, "one or more of the specified URLs already exists")
+	ErrPasswordMissing      = errors.New("a password is required for basic auth")
+	ErrInvalidPathGlob      = errors.New("path glob is malformed")
+	ErrSelectorMissing      = errors.New("no service selector has been specified")
+	ErrInvalidOutliers      = errors.New("outlier detection requires a positive threshold and ejection time")
+	ErrDefaultServiceExists = types.NewError(types.ConflictError, "another service is already the default service")
+	ErrInvalidMirror        = errors.New("mirroring requires a version and a fraction between 0 and 1")
+	ErrInvalidSampleRate    = errors.New("access log sample rate must not be negative")
+	ErrInvalidProbePath     = errors.New("health check path has to start with a slash")
+	ErrInvalidThreshold     = errors.New("health check thresholds must not be negative")
 )
 
 // CreateService creates a new service with the provided name and stores
 // the service in the key-value store. If the `Enable` option is set, the
-// created service will be enabled immediately.
+// created service will be enabled immediately. If no balancing method has
+// been specified, the configured default balancing method will be used.
+//
+// If one of the URLs is already used by another service, ErrServiceURLExists
+// is returned unless the `Force` option is set. In that case, the URL will be
+// removed from the other service and reassigned to the new one. This happens
+// only after the new service has been stored, and if the new service can't
+// be registered, the URLs are given back to their previous services.
 func (d *Dice) CreateService(name string, options types.ServiceCreateOptions) error {
+	if options.Balancing == "" {
+		options.Balancing = string(d.defaultBalancing)
+	}
+
+	if options.Balancing == "" {
+		options.Balancing = string(scheduler.DefaultBalancing)
+	}
+
 	service, err := entity.NewService(name, options)
 	if err != nil {
 		return err
 	}
 
-	ok, err := d.urlsAreValid(service)
+	conflicting, err := d.servicesByURL(service)
 	if err != nil {
 		return err
 	}
 
-	if !ok {
+	if len(conflicting) > 0 && !options.Force {
 		return ErrServiceURLExists
 	}
 
@@ -63,10 +93,17 @@ func (d *Dice) CreateService(name string, options types.ServiceCreateOptions) er
 		return err
 	}
 
+	if err := d.reassignURLs(conflicting, service); err != nil {
+		return d.rollbackCreateService(service, conflicting, err)
+	}
+
 	if err := d.registry.Register(service, d.buildRegistryService); err != nil {
-		return err
+		return d.rollbackCreateService(service, conflicting, err)
 	}
 
+	d.audit(audit.CreateAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
 	if options.Enable {
 		return d.EnableService(entity.ServiceReference(service.ID))
 	}
@@ -77,6 +114,9 @@ func (d *Dice) CreateService(name string, options types.ServiceCreateOptions) er
 // EnableService enables an existing service, making it available as request
 // target. This function will update the service data and synchronize the
 // service with the service registry.
+//
+// A service without any URLs can be enabled, but it won't be reachable until
+// a URL has been set. In that case, a warning is logged.
 func (d *Dice) EnableService(serviceRef entity.ServiceReference) error {
 	service, err := d.findService(serviceRef)
 
@@ -92,6 +132,13 @@ func (d *Dice) EnableService(serviceRef entity.ServiceReference) error {
 		return err
 	}
 
+	d.audit(audit.EnableAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
+	if len(service.URLs) == 0 {
+		d.logger.Warnf("service %s has been enabled but has no URLs, so it isn't reachable", service.Name)
+	}
+
 	return d.registry.Update(func(s *registry.Service) error {
 		if s.Entity.ID == service.ID {
 			s.Entity.IsEnabled = true
@@ -117,6 +164,9 @@ func (d *Dice) DisableService(serviceRef entity.ServiceReference) error {
 		return err
 	}
 
+	d.audit(audit.DisableAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
 	return d.registry.Update(func(s *registry.Service) error {
 		if s.Entity.ID == service.ID {
 			s.Entity.IsEnabled = false
@@ -125,10 +175,70 @@ func (d *Dice) DisableService(serviceRef entity.ServiceReference) error {
 	})
 }
 
+// EnableServices enables all services selected by the given options. See
+// applyToServices for details.
+func (d *Dice) EnableServices(options types.ServiceSelectOptions) ([]types.ServiceResultOutput, error) {
+	return d.applyToServices(options.Selector, d.EnableService)
+}
+
+// DisableServices disables all services selected by the given options. See
+// applyToServices for details.
+func (d *Dice) DisableServices(options types.ServiceSelectOptions) ([]types.ServiceResultOutput, error) {
+	return d.applyToServices(options.Selector, d.DisableService)
+}
+
+// applyToServices applies an operation to all services whose name starts
+// with the given selector, ordered by name. A failure doesn't abort the
+// operation for the remaining services. Instead, each service's outcome is
+// reported in the returned results.
+//
+// An error will only be returned if the services couldn't be selected.
+func (d *Dice) applyToServices(selector string, apply func(entity.ServiceReference) error) ([]types.ServiceResultOutput, error) {
+	if selector == "" {
+		return nil, ErrSelectorMissing
+	}
+
+	services, err := d.kvStore.FindServices(func(service *entity.Service) bool {
+		return strings.HasPrefix(service.Name, selector)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(services, func(i, j int) bool {
+		return services[i].Name < services[j].Name
+	})
+
+	results := make([]types.ServiceResultOutput, len(services))
+
+	for i, service := range services {
+		results[i] = types.ServiceResultOutput{
+			ID:      service.ID,
+			Name:    service.Name,
+			Success: true,
+		}
+
+		if err := apply(entity.ServiceReference(service.ID)); err != nil {
+			results[i].Success = false
+			results[i].Error = err.Error()
+		}
+	}
+
+	return results, nil
+}
+
 // UpdateService updates a service whose instances have already been deployed
-// under specific version tags. That is, all instances whose versions do not
-// match the targetVersion will be detached. Instances that have a matching
-// version will be attached.
+// under specific version tags. That is, all instances of the service whose
+// versions do not match the targetVersion will be detached. Instances that
+// have a matching version will be attached.
+//
+// Instances on detached nodes are attached as well, so that they receive
+// traffic as soon as their node is attached again. All instances are
+// updated at once, see setAttachments.
+//
+// The target version is stored in the service, and all instances are marked
+// as updated or outdated accordingly, see entity.Instance.
 func (d *Dice) UpdateService(serviceRef entity.ServiceReference, targetVersion string) error {
 	service, err := d.findService(serviceRef)
 
@@ -138,35 +248,173 @@ func (d *Dice) UpdateService(serviceRef entity.ServiceReference, targetVersion s
 		return ErrServiceNotFound
 	}
 
-	attachableInstances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
-		return instance.Version == targetVersion
-	})
+	service.TargetVersion = targetVersion
 
-	if err != nil {
+	if err := d.kvStore.UpdateService(service.ID, service); err != nil {
 		return err
 	}
 
-	for _, i := range attachableInstances {
-		// AttachInstance and DetachInstance will search the KV store entry
-		// again in order to create an instance, change it and write it back.
-		// ToDo: Avoid loading instances from the KV store twice.
-		if err := d.AttachInstance(entity.InstanceReference(i.ID)); err != nil {
-			return err
+	if err := d.registry.Update(func(s *registry.Service) error {
+		if s.Entity.ID == service.ID {
+			s.Entity.TargetVersion = targetVersion
 		}
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	detachableInstances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
-		return instance.Version != targetVersion
+	if err := d.setUpdated(service); err != nil {
+		return err
+	}
+
+	instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+		return instance.ServiceID == service.ID
 	})
 
 	if err != nil {
 		return err
 	}
 
-	for _, i := range detachableInstances {
-		if err := d.DetachInstance(entity.InstanceReference(i.ID)); err != nil {
+	attachments := make(map[string]bool, len(instances))
+
+	for _, i := range instances {
+		attachments[i.ID] = i.Version == targetVersion
+	}
+
+	if err := d.setAttachments(attachments); err != nil {
+		return err
+	}
+
+	d.audit(audit.UpdateAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
+	return nil
+}
+
+// PatchService partially updates a service. Only the fields set in the patch
+// are changed, all other fields are left as they are. The changes will be
+// visible for the service registry and the Dice proxy instantly.
+//
+// The service's scheduler is only rebuilt if the balancing method changed,
+// so that the scheduler state is kept for all other changes.
+//
+// The changed URLs are registered before the service is stored. If either
+// fails, the route registry is rolled back, so that the stored service and
+// the registry never disagree.
+func (d *Dice) PatchService(serviceRef entity.ServiceReference, patch types.ServicePatch) error {
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return err
+	} else if service == nil {
+		return ErrServiceNotFound
+	}
+
+	balancingChanged := patch.Balancing != nil && *patch.Balancing != service.BalancingMethod
+
+	if balancingChanged {
+		service.BalancingMethod = *patch.Balancing
+	}
+
+	var addedURLs, removedURLs []string
+
+	if patch.URLs != nil {
+		urls := splitList(*patch.URLs)
+		addedURLs, removedURLs = diffLists(service.URLs, urls)
+
+		ok, err := d.urlsAreValid(&entity.Service{URLs: addedURLs})
+		if err != nil {
 			return err
 		}
+
+		if !ok {
+			return ErrServiceURLExists
+		}
+
+		service.URLs = urls
+	}
+
+	if patch.HealthCheckTimeout != nil {
+		service.HealthCheck.Timeout = *patch.HealthCheckTimeout
+	}
+
+	if ok, message := validateService(service); !ok {
+		return errors.New(message)
+	}
+
+	var serviceScheduler registry.Scheduler
+
+	if balancingChanged {
+		if s, ok := d.registry.Service(service.ID); ok {
+			method := scheduler.BalancingMethod(service.BalancingMethod)
+
+			if serviceScheduler, err = d.newScheduler(s.Deployments, method); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err := d.changeServiceURLs(service.ID, addedURLs, removedURLs, service.RouteWeights); err != nil {
+		return err
+	}
+
+	if err := d.kvStore.UpdateService(service.ID, service); err != nil {
+		if rollbackErr := d.changeServiceURLs(service.ID, removedURLs, addedURLs, service.RouteWeights); rollbackErr != nil {
+			return fmt.Errorf("%w (rolling back the URLs failed: %v)", err, rollbackErr)
+		}
+		return err
+	}
+
+	d.audit(audit.UpdateAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
+	return d.registry.Update(func(s *registry.Service) error {
+		if s.Entity.ID != service.ID {
+			return nil
+		}
+
+		s.Entity.URLs = service.URLs
+		s.Entity.HealthCheck = service.HealthCheck
+
+		if serviceScheduler != nil {
+			s.Entity.BalancingMethod = service.BalancingMethod
+			s.Scheduler = serviceScheduler
+		}
+
+		return nil
+	})
+}
+
+// changeServiceURLs unregisters the removed URLs of a service and registers
+// the added URLs. If that fails, all changes made so far are undone, so that
+// the route registry is left as it has been. The route weights of removed
+// URLs are restored from weights in that case.
+func (d *Dice) changeServiceURLs(serviceID string, added, removed []string, weights map[string][]entity.RouteWeight) error {
+	var unregistered, registered []string
+
+	undo := func(cause error) error {
+		if err := d.changeServiceURLs(serviceID, unregistered, registered, weights); err != nil {
+			return fmt.Errorf("%w (rolling back the URLs failed: %v)", cause, err)
+		}
+		return cause
+	}
+
+	for _, url := range removed {
+		if err := d.registry.UnregisterServiceURL(url); err != nil {
+			return undo(err)
+		}
+		unregistered = append(unregistered, url)
+	}
+
+	for _, url := range added {
+		if err := d.registry.RegisterServiceURL(serviceID, url); err != nil {
+			return undo(err)
+		}
+		registered = append(registered, url)
+
+		if err := d.registry.SetRouteWeights(url, weights[url]); err != nil {
+			return undo(err)
+		}
 	}
 
 	return nil
@@ -182,16 +430,59 @@ func (d *Dice) ServiceInfo(serviceRef entity.ServiceReference) (types.ServiceInf
 		return types.ServiceInfoOutput{}, ErrServiceNotFound
 	}
 
-	serviceInfo := types.ServiceInfoOutput{
+	instanceCounts, err := d.instanceCounts(func(instance *entity.Instance) bool {
+		return instance.ServiceID == service.ID
+	})
+	if err != nil {
+		return types.ServiceInfoOutput{}, err
+	}
+
+	serviceInfo := newServiceInfo(service, instanceCounts[service.ID], d.aliveCounts()[service.ID])
+
+	return serviceInfo, nil
+}
+
+// newServiceInfo creates the user-relevant information for a service, which
+// is returned by ServiceInfo as well as ListServices.
+func newServiceInfo(service *entity.Service, instanceCount, aliveCount int) types.ServiceInfoOutput {
+	return types.ServiceInfoOutput{
 		ID:              service.ID,
 		Name:            service.Name,
 		URLs:            service.URLs,
 		TargetVersion:   service.TargetVersion,
 		BalancingMethod: service.BalancingMethod,
 		IsEnabled:       service.IsEnabled,
+		IsInMaintenance: service.Maintenance.IsEnabled,
+		IsReachable:     len(service.URLs) > 0,
+		InstanceCount:   instanceCount,
+		AliveCount:      aliveCount,
 	}
+}
 
-	return serviceInfo, nil
+// ServiceMetrics returns the proxy metrics for an existing service. The
+// metrics are cumulative since the proxy has been started, see
+// proxy.ServiceMetrics.
+func (d *Dice) ServiceMetrics(serviceRef entity.ServiceReference) (types.ServiceMetricsOutput, error) {
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return types.ServiceMetricsOutput{}, err
+	} else if service == nil {
+		return types.ServiceMetricsOutput{}, ErrServiceNotFound
+	}
+
+	metrics := d.proxy.ServiceMetrics(service.ID)
+
+	serviceMetrics := types.ServiceMetricsOutput{
+		ServiceID:  service.ID,
+		Requests:   metrics.Requests,
+		LatencyP50: metrics.LatencyP50,
+		LatencyP95: metrics.LatencyP95,
+		BytesIn:    metrics.BytesIn,
+		BytesOut:   metrics.BytesOut,
+	}
+
+	return serviceMetrics, nil
 }
 
 // ListServices returns a list of stored services. By default, disabled
@@ -211,18 +502,18 @@ func (d *Dice) ListServices(options types.ServiceListOptions) ([]types.ServiceIn
 		return nil, err
 	}
 
+	// All instances are fetched at once instead of fetching the instances
+	// for each service separately.
+	instanceCounts, err := d.instanceCounts(store.AllInstancesFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	aliveCounts := d.aliveCounts()
 	serviceList := make([]types.ServiceInfoOutput, len(services))
 
 	for i, s := range services {
-		info := types.ServiceInfoOutput{
-			ID:              s.ID,
-			Name:            s.Name,
-			URLs:            s.URLs,
-			TargetVersion:   s.TargetVersion,
-			BalancingMethod: s.BalancingMethod,
-			IsEnabled:       s.IsEnabled,
-		}
-		serviceList[i] = info
+		serviceList[i] = newServiceInfo(s, instanceCounts[s.ID], aliveCounts[s.ID])
 	}
 
 	return serviceList, nil
@@ -230,6 +521,10 @@ func (d *Dice) ListServices(options types.ServiceListOptions) ([]types.ServiceIn
 
 // SetServiceURL sets or removes an URL from a given service. The update
 // will be visible for the service registry and the Dice proxy instantly.
+//
+// If the service registry rejects the change, for example because the URL
+// is already registered for another service, the stored service is rolled
+// back so that the key-value store and the registry stay consistent.
 func (d *Dice) SetServiceURL(serviceRef entity.ServiceReference, url string, options types.ServiceURLOptions) error {
 	service, err := d.findService(serviceRef)
 
@@ -239,10 +534,16 @@ func (d *Dice) SetServiceURL(serviceRef entity.ServiceReference, url string, opt
 		return ErrServiceNotFound
 	}
 
+	previousURLs := append([]string(nil), service.URLs...)
+	previousWeights, isWeighted := service.RouteWeights[url]
+
 	if options.Delete {
 		if err := service.RemoveURL(url); err != nil {
 			return err
 		}
+		// The weights of a removed URL would apply to a URL that is added
+		// later on, so they're removed along with the URL.
+		delete(service.RouteWeights, url)
 	} else {
 		if err := service.AddURL(url); err != nil {
 			return err
@@ -253,29 +554,359 @@ func (d *Dice) SetServiceURL(serviceRef entity.ServiceReference, url string, opt
 		return err
 	}
 
+	var registryErr error
+
 	if options.Delete {
-		if err := d.registry.UnregisterServiceURL(url); err != nil {
-			return err
-		}
+		registryErr = d.registry.UnregisterServiceURL(url)
 	} else {
-		if err := d.registry.RegisterServiceURL(service.ID, url); err != nil {
-			return err
+		registryErr = d.registry.RegisterServiceURL(service.ID, url)
+	}
+
+	if registryErr != nil {
+		service.URLs = previousURLs
+		if isWeighted {
+			service.RouteWeights[url] = previousWeights
 		}
+
+		if err := d.kvStore.UpdateService(service.ID, service); err != nil {
+			return fmt.Errorf("%w (rolling back the service failed: %v)", registryErr, err)
+		}
+
+		return registryErr
 	}
 
+	d.audit(audit.SetURLAction, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
 	return d.registry.Update(func(s *registry.Service) error {
 		if s.Entity.ID == service.ID {
 			s.Entity.URLs = service.URLs
+			s.Entity.RouteWeights = service.RouteWeights
 		}
 		return nil
 	})
 }
 
+// SetDefaultService makes a service the default service by adding the
+// catch-all route to its URLs. The default service receives all requests
+// whose host doesn't match any other route. There can be only one default
+// service, so ErrDefaultServiceExists is returned if another service has
+// the catch-all route already.
+//
+// To unset the default service, its catch-all route has to be removed using
+// SetServiceURL.
+func (d *Dice) SetDefaultService(serviceRef entity.ServiceReference) error {
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return err
+	} else if service == nil {
+		return ErrServiceNotFound
+	}
+
+	if serviceID, ok := d.registry.Routes()[registry.CatchAllRoute]; ok && serviceID != service.ID {
+		return ErrDefaultServiceExists
+	}
+
+	return d.SetServiceURL(entity.ServiceReference(service.ID), registry.CatchAllRoute, types.ServiceURLOptions{})
+}
+
+// SetServiceHealthCheck sets the health check configuration for a service,
+// overriding the global configuration for each option that has been set.
+// The health checker will use the new settings from the next check on.
+func (d *Dice) SetServiceHealthCheck(serviceRef entity.ServiceReference, options types.ServiceHealthCheckOptions) error {
+	if options.Path != "" && !strings.HasPrefix(options.Path, "/") {
+		return ErrInvalidProbePath
+	}
+
+	if options.HealthyThreshold < 0 || options.UnhealthyThreshold < 0 {
+		return ErrInvalidThreshold
+	}
+
+	healthCheck := entity.HealthCheck{
+		Path:               options.Path,
+		Interval:           options.Interval,
+		Timeout:            options.Timeout,
+		HealthyThreshold:   options.HealthyThreshold,
+		UnhealthyThreshold: options.UnhealthyThreshold,
+	}
+
+	return d.updateService(serviceRef, audit.SetHealthCheckAction, func(service *entity.Service) error {
+		service.HealthCheck = healthCheck
+		return nil
+	})
+}
+
+// SetServiceMaintenance turns the maintenance mode of a service on or off.
+// While a service is under maintenance, the proxy responds to all requests
+// with the maintenance message instead of forwarding them to an instance.
+func (d *Dice) SetServiceMaintenance(serviceRef entity.ServiceReference, options types.ServiceMaintenanceOptions) error {
+	maintenance := entity.Maintenance{
+		IsEnabled: options.Enable,
+		Message:   options.Message,
+	}
+
+	return d.updateService(serviceRef, audit.SetMaintenanceAction, func(service *entity.Service) error {
+		service.Maintenance = maintenance
+		return nil
+	})
+}
+
+// SetServiceSticky turns sticky sessions for a service on or off. With
+// sticky sessions, the proxy pins each client to an instance using a cookie
+// and only fails over to another instance if that instance is unavailable.
+func (d *Dice) SetServiceSticky(serviceRef entity.ServiceReference, options types.ServiceStickyOptions) error {
+	return d.updateService(serviceRef, audit.SetStickyAction, func(service *entity.Service) error {
+		service.StickySessions = options.Enable
+		return nil
+	})
+}
+
+// SetServiceCORS sets the CORS configuration of a service. The proxy answers
+// CORS preflight requests directly and adds the CORS headers to all other
+// responses. Setting no origins disables CORS for the service.
+func (d *Dice) SetServiceCORS(serviceRef entity.ServiceReference, options types.ServiceCORSOptions) error {
+	cors := entity.CORS{
+		AllowedOrigins:   splitList(options.Origins),
+		AllowedMethods:   splitList(options.Methods),
+		AllowedHeaders:   splitList(options.Headers),
+		AllowCredentials: options.Credentials,
+	}
+
+	return d.updateService(serviceRef, audit.SetCORSAction, func(service *entity.Service) error {
+		service.CORS = cors
+		return nil
+	})
+}
+
+// SetServiceAuth sets the basic auth credentials of a service. Only a hash
+// of the password will be stored. If credentials have been set, the proxy
+// rejects all requests that don't provide them. Setting no username clears
+// the credentials and disables basic auth for the service.
+func (d *Dice) SetServiceAuth(serviceRef entity.ServiceReference, options types.ServiceAuthOptions) error {
+	var basicAuth entity.BasicAuth
+
+	if options.Username != "" {
+		if options.Password == "" {
+			return ErrPasswordMissing
+		}
+
+		var err error
+
+		if basicAuth, err = entity.NewBasicAuth(options.Username, options.Password); err != nil {
+			return err
+		}
+	}
+
+	return d.updateService(serviceRef, audit.SetAuthAction, func(service *entity.Service) error {
+		service.BasicAuth = basicAuth
+		return nil
+	})
+}
+
+// SetServiceAllowList sets the allow-list of a service. The proxy rejects
+// all requests whose method or path isn't allowed before forwarding them.
+// Setting neither methods nor paths allows all requests again.
+func (d *Dice) SetServiceAllowList(serviceRef entity.ServiceReference, options types.ServiceAllowListOptions) error {
+	allowList := entity.AllowList{
+		Methods: splitList(strings.ToUpper(options.Methods)),
+		Paths:   splitList(options.Paths),
+	}
+
+	for _, p := range allowList.Paths {
+		if _, err := path.Match(p, ""); err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidPathGlob, p)
+		}
+	}
+
+	return d.updateService(serviceRef, audit.SetAllowListAction, func(service *entity.Service) error {
+		service.AllowList = allowList
+		return nil
+	})
+}
+
+// SetServiceOutliers sets the outlier detection settings of a service. The
+// proxy ejects instances that fail too often in a row from load balancing
+// for a while, independently from the active health checks. Setting a
+// threshold of 0 disables outlier detection.
+func (d *Dice) SetServiceOutliers(serviceRef entity.ServiceReference, options types.ServiceOutliersOptions) error {
+	if options.Threshold < 0 || (options.Threshold > 0 && options.EjectionTime <= 0) {
+		return ErrInvalidOutliers
+	}
+
+	outliers := entity.Outliers{
+		Threshold:    options.Threshold,
+		EjectionTime: options.EjectionTime,
+	}
+
+	return d.updateService(serviceRef, audit.SetOutliersAction, func(service *entity.Service) error {
+		service.Outliers = outliers
+		return nil
+	})
+}
+
+// SetServiceMirror sets the traffic mirroring settings of a service. The
+// proxy sends a copy of the configured fraction of requests to an instance
+// with the configured version, see entity.Mirror. Setting a fraction of 0
+// disables mirroring.
+func (d *Dice) SetServiceMirror(serviceRef entity.ServiceReference, options types.ServiceMirrorOptions) error {
+	if options.Fraction < 0 || options.Fraction > 1 || (options.Fraction > 0 && options.Version == "") {
+		return ErrInvalidMirror
+	}
+
+	mirror := entity.Mirror{
+		Version:  options.Version,
+		Fraction: options.Fraction,
+	}
+
+	return d.updateService(serviceRef, audit.SetMirrorAction, func(service *entity.Service) error {
+		service.Mirror = mirror
+		return nil
+	})
+}
+
+// SetServiceAccessLog sets the access log settings of a service. The proxy
+// checks these settings before writing an access line, see entity.AccessLog.
+func (d *Dice) SetServiceAccessLog(serviceRef entity.ServiceReference, options types.ServiceAccessLogOptions) error {
+	if options.SampleRate < 0 {
+		return ErrInvalidSampleRate
+	}
+
+	accessLog := entity.AccessLog{
+		IsDisabled: options.Disable,
+		SampleRate: options.SampleRate,
+	}
+
+	return d.updateService(serviceRef, audit.SetAccessLogAction, func(service *entity.Service) error {
+		service.AccessLog = accessLog
+		return nil
+	})
+}
+
+// updateService applies update to a service and stores the service. The
+// update is recorded with the given audit action and then applied to the
+// registered service as well, so update is called twice and should do no
+// more than assigning the new settings.
+func (d *Dice) updateService(serviceRef entity.ServiceReference, action audit.Action, update func(service *entity.Service) error) error {
+	service, err := d.findService(serviceRef)
+
+	if err != nil {
+		return err
+	} else if service == nil {
+		return ErrServiceNotFound
+	}
+
+	if err := update(service); err != nil {
+		return err
+	}
+
+	if err := d.kvStore.UpdateService(service.ID, service); err != nil {
+		return err
+	}
+
+	d.audit(action, audit.ServiceEntity, service.ID, service.Name)
+	d.publish(store.ServiceEntity, service.ID)
+
+	return d.registry.Update(func(s *registry.Service) error {
+		if s.Entity.ID == service.ID {
+			return update(s.Entity)
+		}
+		return nil
+	})
+}
+
+// splitList splits a comma-separated list and trims all items. Empty items
+// are omitted, so that an empty string results in an empty list.
+func splitList(list string) []string {
+	items := make([]string, 0)
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// diffLists compares two lists and returns the items that have been added
+// to and removed from the previous list.
+func diffLists(previous, current []string) (added, removed []string) {
+	contains := func(list []string, item string) bool {
+		for _, i := range list {
+			if i == item {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, item := range current {
+		if !contains(previous, item) {
+			added = append(added, item)
+		}
+	}
+
+	for _, item := range previous {
+		if !contains(current, item) {
+			removed = append(removed, item)
+		}
+	}
+
+	return added, removed
+}
+
+// instanceCounts returns the number of stored instances for each service,
+// only taking instances that match the provided filter into account.
+func (d *Dice) instanceCounts(filter store.InstanceFilter) (map[string]int, error) {
+	instances, err := d.kvStore.FindInstances(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int)
+
+	for _, i := range instances {
+		counts[i.ServiceID]++
+	}
+
+	return counts, nil
+}
+
+// aliveCounts returns the number of alive instances for each service. The
+// alive states are maintained by the health check in the service registry.
+func (d *Dice) aliveCounts() map[string]int {
+	counts := make(map[string]int)
+
+	for id, s := range d.registry.All() {
+		for _, deployment := range s.Deployments {
+			if deployment.Instance.IsAlive {
+				counts[id]++
+			}
+		}
+	}
+
+	return counts
+}
+
 // urlsAreValid indicates whether a services' URLs are valid and unique
 // so that it can be used safely. This check should be performed before
 // the service entity gets persisted.
 func (d *Dice) urlsAreValid(service *entity.Service) (bool, error) {
-	servicesByURL, err := d.kvStore.FindServices(func(s *entity.Service) bool {
+	servicesByURL, err := d.servicesByURL(service)
+
+	if err != nil {
+		return false, err
+	}
+	isValid := len(servicesByURL) == 0
+
+	return isValid, nil
+}
+
+// servicesByURL returns all services in the key-value store that have at
+// least one URL in common with the given service.
+func (d *Dice) servicesByURL(service *entity.Service) ([]*entity.Service, error) {
+	return d.kvStore.FindServices(func(s *entity.Service) bool {
 		for _, u := range s.URLs {
 			for _, su := range service.URLs {
 				if u == su {
@@ -285,13 +916,102 @@ func (d *Dice) urlsAreValid(service *entity.Service) (bool, error) {
 		}
 		return false
 	})
+}
 
-	if err != nil {
-		return false, err
+// reassignURLs removes all URLs of service from the conflicting services, so
+// that they can be registered for service afterwards. Each reassigned URL is
+// logged. See SetServiceURL for how a URL is removed.
+func (d *Dice) reassignURLs(conflicting []*entity.Service, service *entity.Service) error {
+	for _, c := range conflicting {
+		for _, url := range c.URLs {
+			if !containsURL(service.URLs, url) {
+				continue
+			}
+
+			options := types.ServiceURLOptions{Delete: true}
+
+			if err := d.SetServiceURL(entity.ServiceReference(c.ID), url, options); err != nil {
+				return err
+			}
+
+			d.logger.Infof("URL %s has been reassigned from service %s to service %s", url, c.Name, service.Name)
+		}
 	}
-	isValid := len(servicesByURL) == 0
 
-	return isValid, nil
+	return nil
+}
+
+// rollbackCreateService removes a service that couldn't be created entirely
+// and gives the URLs that have been reassigned to the service back to the
+// conflicting services. The returned error is cause, along with the error
+// that occurred during the rollback if any.
+func (d *Dice) rollbackCreateService(service *entity.Service, conflicting []*entity.Service, cause error) error {
+	if err := d.kvStore.DeleteService(service.ID); err != nil {
+		return fmt.Errorf("%w (rolling back the service failed: %v)", cause, err)
+	}
+
+	// The service's routes might have been registered partially.
+	for _, url := range service.URLs {
+		if err := d.registry.UnregisterServiceURL(url); err != nil && err != registry.ErrUnregisteredRoute {
+			return fmt.Errorf("%w (rolling back the service failed: %v)", cause, err)
+		}
+	}
+
+	if err := d.restoreURLs(conflicting, service); err != nil {
+		return fmt.Errorf("%w (restoring the reassigned URLs failed: %v)", cause, err)
+	}
+
+	return cause
+}
+
+// restoreURLs is the counterpart to reassignURLs. It gives all URLs of the
+// service back to the conflicting services, including their route weights.
+// The conflicting services are expected to be in the state they have been
+// in before reassignURLs has been called.
+func (d *Dice) restoreURLs(conflicting []*entity.Service, service *entity.Service) error {
+	for _, c := range conflicting {
+		if err := d.kvStore.UpdateService(c.ID, c); err != nil {
+			return err
+		}
+
+		for _, url := range c.URLs {
+			if !containsURL(service.URLs, url) {
+				continue
+			}
+
+			if err := d.registry.RegisterServiceURL(c.ID, url); err != nil && err != registry.ErrRouteAlreadyRegistered {
+				return err
+			}
+
+			if err := d.registry.SetRouteWeights(url, c.RouteWeights[url]); err != nil {
+				return err
+			}
+		}
+
+		d.publish(store.ServiceEntity, c.ID)
+
+		if err := d.registry.Update(func(s *registry.Service) error {
+			if s.Entity.ID == c.ID {
+				s.Entity.URLs = c.URLs
+				s.Entity.RouteWeights = c.RouteWeights
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// containsURL indicates whether url is one of urls.
+func containsURL(urls []string, url string) bool {
+	for _, u := range urls {
+		if u == url {
+			return true
+		}
+	}
+	return false
 }
 
 // findService attempts to find a node in the key-value store that matches
@@ -300,27 +1020,34 @@ func (d *Dice) urlsAreValid(service *entity.Service) (bool, error) {
 // If multiple services match, only the first one will be returned. If no
 // services match, `nil` - and no error - will be returned.
 func (d *Dice) findService(serviceRef entity.ServiceReference) (*entity.Service, error) {
-	servicesByID, err := d.kvStore.FindServices(func(service *entity.Service) bool {
+	serviceByID, err := d.firstService(func(service *entity.Service) bool {
 		return service.ID == string(serviceRef)
 	})
 
-	if err != nil {
-		return nil, err
-	} else if len(servicesByID) > 0 {
-		return servicesByID[0], nil
+	if err != nil || serviceByID != nil {
+		return serviceByID, err
 	}
 
-	servicesByName, err := d.kvStore.FindServices(func(service *entity.Service) bool {
+	return d.firstService(func(service *entity.Service) bool {
 		return service.Name == string(serviceRef)
 	})
+}
 
-	if err != nil {
-		return nil, err
-	} else if len(servicesByName) > 0 {
-		return servicesByName[0], nil
-	}
+// firstService returns the first service in the key-value store that matches
+// the filter. The remaining services won't be read once a match has been
+// found. If no service matches, `nil` - and no error - will be returned.
+func (d *Dice) firstService(filter store.ServiceFilter) (*entity.Service, error) {
+	var match *entity.Service
+
+	err := d.kvStore.ForEachService(func(service *entity.Service) (bool, error) {
+		if filter(service) {
+			match = service
+			return true, nil
+		}
+		return false, nil
+	})
 
-	return nil, nil
+	return match, err
 }
 
 // serviceIsUnique checks if a newly created service is unique. A service
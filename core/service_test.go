@@ -0,0 +1,995 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/config"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/scheduler"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// newTestDice creates a Dice instance with a key-value store in a temporary
+// directory and an empty service registry. The returned function removes
+// the temporary directory.
+func newTestDice(t testing.TB) (*Dice, func()) {
+	dir, err := ioutil.TempDir("", "dice-core-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	kvStore, err := store.NewKVStore(filepath.Join(dir, "dice-store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := log.NewLogger(ioutil.Discard, log.ErrorLevel)
+
+	d := Dice{
+		logger:   logger,
+		kvStore:  kvStore,
+		registry: registry.NewServiceRegistry(logger),
+	}
+
+	cleanup := func() {
+		_ = kvStore.Close()
+		_ = os.RemoveAll(dir)
+	}
+
+	return &d, cleanup
+}
+
+// TestDice_CreateService_defaultBalancing tests Dice.CreateService without
+// a balancing method. It asserts that the service is created using the
+// default balancing method.
+func TestDice_CreateService_defaultBalancing(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := d.findService(entity.ServiceReference("s1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service == nil {
+		t.Fatal("service s1 has not been created")
+	}
+
+	if service.BalancingMethod != string(scheduler.DefaultBalancing) {
+		t.Errorf("expected balancing method %s, got %s", scheduler.DefaultBalancing, service.BalancingMethod)
+	}
+}
+
+// TestDice_CreateService_configuredBalancing tests Dice.CreateService with
+// a configured default balancing method. It asserts that a service created
+// without a balancing method uses the configured method.
+func TestDice_CreateService_configuredBalancing(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	d.config = config.Environment{"default-balancing": string(scheduler.WeightedRandomBalancing)}
+
+	if err := d.setupBalancing(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := d.findService(entity.ServiceReference("s1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service == nil {
+		t.Fatal("service s1 has not been created")
+	}
+
+	if service.BalancingMethod != string(scheduler.WeightedRandomBalancing) {
+		t.Errorf("expected balancing method %s, got %s", scheduler.WeightedRandomBalancing, service.BalancingMethod)
+	}
+}
+
+// TestDice_setupBalancing_unsupported tests Dice.setupBalancing with an
+// unknown default balancing method. It asserts that the setup fails.
+func TestDice_setupBalancing_unsupported(t *testing.T) {
+	d := Dice{
+		config: config.Environment{"default-balancing": "bogus"},
+	}
+
+	if err := d.setupBalancing(); !errors.Is(err, scheduler.ErrUnsupportedMethod) {
+		t.Errorf("expected error %v, got %v", scheduler.ErrUnsupportedMethod, err)
+	}
+}
+
+// TestDice_CreateService_unsupportedBalancing tests Dice.CreateService with
+// an unknown balancing method. It asserts that the service is rejected and
+// hasn't been stored.
+func TestDice_CreateService_unsupportedBalancing(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	options := types.ServiceCreateOptions{
+		URLs:      "s1.example.com",
+		Balancing: "bogus",
+	}
+
+	if err := d.CreateService("s1", options); err == nil {
+		t.Error("expected an error for balancing method bogus")
+	}
+
+	service, err := d.findService(entity.ServiceReference("s1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if service != nil {
+		t.Error("service s1 has been stored despite its balancing method")
+	}
+}
+
+// TestDice_CreateService_urlExists tests Dice.CreateService with a URL that
+// is already used by another service. Without the Force option, the service
+// has to be rejected. With the Force option, the URL has to be reassigned.
+func TestDice_CreateService_urlExists(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.logger = logger
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "a.example.com,b.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	options := types.ServiceCreateOptions{URLs: "b.example.com,c.example.com"}
+
+	if err := d.CreateService("s2", options); err != ErrServiceURLExists {
+		t.Fatalf("expected error %v, got %v", ErrServiceURLExists, err)
+	}
+
+	if service, _ := d.findService("s2"); service != nil {
+		t.Fatal("service s2 has been created although its URL exists")
+	}
+
+	options.Force = true
+
+	if err := d.CreateService("s2", options); err != nil {
+		t.Fatal(err)
+	}
+
+	s1, err := d.findService("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s1.URLs, []string{"a.example.com"}) {
+		t.Errorf("expected s1 to keep only a.example.com, got %v", s1.URLs)
+	}
+
+	s2, err := d.findService("s2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	routes := d.registry.Routes()
+
+	for url, serviceID := range map[string]string{"a.example.com": s1.ID, "b.example.com": s2.ID, "c.example.com": s2.ID} {
+		if routes[url] != serviceID {
+			t.Errorf("expected %s to be routed to %s, got %s", url, serviceID, routes[url])
+		}
+	}
+
+	if len(logger.infos) != 1 || !strings.Contains(logger.infos[0], "b.example.com") {
+		t.Errorf("expected the reassignment of b.example.com to be logged, got %v", logger.infos)
+	}
+}
+
+// TestDice_CreateService_urlExistsRollback tests Dice.CreateService with the
+// Force option for a service that can't be registered because one of its
+// URLs is an invalid pattern. It asserts that the reassigned URL is given
+// back to the previous service and that the new service isn't stored.
+func TestDice_CreateService_urlExistsRollback(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "a.example.com,b.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	options := types.ServiceCreateOptions{URLs: "b.example.com,~[", Force: true}
+
+	if err := d.CreateService("s2", options); err == nil {
+		t.Fatal("expected an error for the invalid pattern ~[")
+	}
+
+	if service, _ := d.findService("s2"); service != nil {
+		t.Error("service s2 has been stored although it couldn't be registered")
+	}
+
+	s1, err := d.findService("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(s1.URLs, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("expected s1 to keep all of its URLs, got %v", s1.URLs)
+	}
+
+	routes := d.registry.Routes()
+
+	for _, url := range []string{"a.example.com", "b.example.com"} {
+		if routes[url] != s1.ID {
+			t.Errorf("expected %s to be routed to %s, got %s", url, s1.ID, routes[url])
+		}
+	}
+
+	if _, ok := routes["~["]; ok {
+		t.Error("the route ~[ of service s2 is still registered")
+	}
+}
+
+// TestDice_ListServices_instanceCounts tests the instance counts returned by
+// Dice.ServiceInfo and Dice.ListServices. It creates a service with three
+// instances, two of them being alive, and a service without instances.
+func TestDice_ListServices_instanceCounts(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s2", types.ServiceCreateOptions{URLs: "s2.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, url := range []string{"n1:8080", "n1:8081", "n1:8082"} {
+		if err := d.CreateInstance("s1", "n1", url, types.InstanceCreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Mark all instances except for the one listening on port 8082 as alive,
+	// just like the health check would do.
+	_ = d.registry.Update(func(s *registry.Service) error {
+		for _, deployment := range s.Deployments {
+			deployment.Instance.IsAlive = deployment.Instance.URL != "n1:8082"
+		}
+		return nil
+	})
+
+	serviceInfo, err := d.ServiceInfo("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if serviceInfo.InstanceCount != 3 || serviceInfo.AliveCount != 2 {
+		t.Errorf("expected 3 instances and 2 alive for s1, got %d and %d", serviceInfo.InstanceCount, serviceInfo.AliveCount)
+	}
+
+	serviceList, err := d.ListServices(types.ServiceListOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertions := map[string][2]int{"s1": {3, 2}, "s2": {0, 0}}
+
+	if len(serviceList) != len(assertions) {
+		t.Fatalf("expected %d services, got %d", len(assertions), len(serviceList))
+	}
+
+	for _, s := range serviceList {
+		expected := assertions[s.Name]
+
+		if s.InstanceCount != expected[0] || s.AliveCount != expected[1] {
+			t.Errorf("expected %d instances and %d alive for %s, got %d and %d", expected[0], expected[1], s.Name, s.InstanceCount, s.AliveCount)
+		}
+	}
+}
+
+// TestDice_ListServices_isReachable tests the reachability reported by
+// Dice.ListServices for a service with a URL and a service without URLs.
+func TestDice_ListServices_isReachable(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s2", types.ServiceCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	serviceList, err := d.ListServices(types.ServiceListOptions{All: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"s1": true, "s2": false}
+
+	if len(serviceList) != len(expected) {
+		t.Fatalf("expected %d services, got %d", len(expected), len(serviceList))
+	}
+
+	for _, s := range serviceList {
+		if s.IsReachable != expected[s.Name] {
+			t.Errorf("expected %s to be reachable: %v, got %v", s.Name, expected[s.Name], s.IsReachable)
+		}
+	}
+}
+
+// TestDice_EnableServices tests Dice.EnableServices and Dice.DisableServices
+// with a mix of matching and non-matching services. It asserts that only
+// the matching services are changed and reported.
+func TestDice_EnableServices(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	for _, name := range []string{"api-users", "api-orders", "web"} {
+		if err := d.CreateService(name, types.ServiceCreateOptions{URLs: name + ".example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results, err := d.EnableServices(types.ServiceSelectOptions{Selector: "api-"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 2 || results[0].Name != "api-orders" || results[1].Name != "api-users" {
+		t.Fatalf("expected results for api-orders and api-users, got %+v", results)
+	}
+
+	for _, result := range results {
+		if !result.Success || result.Error != "" {
+			t.Errorf("expected %s to succeed, got %+v", result.Name, result)
+		}
+	}
+
+	expected := map[string]bool{"api-users": true, "api-orders": true, "web": false}
+
+	for name, isEnabled := range expected {
+		service, _ := d.findService(entity.ServiceReference(name))
+		if service.IsEnabled != isEnabled {
+			t.Errorf("expected %s to have IsEnabled=%v", name, isEnabled)
+		}
+	}
+
+	if _, err := d.DisableServices(types.ServiceSelectOptions{Selector: "api-users"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if service, _ := d.findService("api-users"); service.IsEnabled {
+		t.Errorf("expected api-users to be disabled")
+	}
+
+	if _, err := d.EnableServices(types.ServiceSelectOptions{}); err != ErrSelectorMissing {
+		t.Errorf("expected error %v, got %v", ErrSelectorMissing, err)
+	}
+}
+
+// TestDice_applyToServices_failure tests if a failing operation for one
+// service doesn't abort the operation for the remaining services and if
+// the failure is reported in the results.
+func TestDice_applyToServices_failure(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	for _, name := range []string{"api-a", "api-b", "api-c"} {
+		if err := d.CreateService(name, types.ServiceCreateOptions{URLs: name + ".example.com"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	failing, _ := d.findService("api-b")
+	applied := 0
+
+	results, err := d.applyToServices("api-", func(serviceRef entity.ServiceReference) error {
+		applied++
+		if string(serviceRef) == failing.ID {
+			return errors.New("failure")
+		}
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 3 {
+		t.Errorf("expected operation to be applied to 3 services, got %d", applied)
+	}
+
+	for _, result := range results {
+		shouldFail := result.ID == failing.ID
+
+		if result.Success == shouldFail || (result.Error != "") != shouldFail {
+			t.Errorf("unexpected result for %s: %+v", result.Name, result)
+		}
+	}
+}
+
+// TestDice_PatchService_balancing tests Dice.PatchService with a different
+// balancing method. It asserts that the scheduler gets replaced while the
+// URLs and the other settings of the service are left intact.
+func TestDice_PatchService_balancing(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 2)
+	previous := d.registry.Services[service.ID].Scheduler
+
+	balancing := string(scheduler.WeightedRandomBalancing)
+
+	if err := d.PatchService("s1", types.ServicePatch{Balancing: &balancing}); err != nil {
+		t.Fatal(err)
+	}
+
+	patched, err := d.findService("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patched.BalancingMethod != balancing {
+		t.Errorf("expected balancing method %s, got %s", balancing, patched.BalancingMethod)
+	}
+
+	if !reflect.DeepEqual(patched.URLs, []string{"s1.example.com"}) {
+		t.Errorf("expected URLs to be left intact, got %v", patched.URLs)
+	}
+
+	registryService := d.registry.Services[service.ID]
+
+	if registryService.Scheduler == previous {
+		t.Errorf("expected scheduler to be replaced")
+	}
+
+	if registryService.Entity.BalancingMethod != balancing {
+		t.Errorf("expected registry balancing method %s, got %s", balancing, registryService.Entity.BalancingMethod)
+	}
+
+	if !reflect.DeepEqual(registryService.Entity.URLs, []string{"s1.example.com"}) {
+		t.Errorf("expected registry URLs to be left intact, got %v", registryService.Entity.URLs)
+	}
+
+	if routes := d.registry.Routes(); routes["s1.example.com"] != service.ID {
+		t.Errorf("expected route s1.example.com to point to %s, got %v", service.ID, routes)
+	}
+}
+
+// TestDice_PatchService_urls tests Dice.PatchService with new URLs. It
+// asserts that the routes are updated and the scheduler is kept.
+func TestDice_PatchService_urls(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	service, _ := setupReplaceTest(t, d, 1)
+	previous := d.registry.Services[service.ID].Scheduler
+
+	urls := "s1.example.com, api.example.com"
+
+	if err := d.PatchService("s1", types.ServicePatch{URLs: &urls}); err != nil {
+		t.Fatal(err)
+	}
+
+	registryService := d.registry.Services[service.ID]
+
+	if registryService.Scheduler != previous {
+		t.Errorf("expected scheduler to be kept")
+	}
+
+	if !reflect.DeepEqual(registryService.Entity.URLs, []string{"s1.example.com", "api.example.com"}) {
+		t.Errorf("unexpected registry URLs %v", registryService.Entity.URLs)
+	}
+
+	if routes := d.registry.Routes(); routes["api.example.com"] != service.ID {
+		t.Errorf("expected route api.example.com to point to %s, got %v", service.ID, routes)
+	}
+
+	urls = "api.example.com"
+
+	if err := d.PatchService("s1", types.ServicePatch{URLs: &urls}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := d.registry.Routes()["s1.example.com"]; ok {
+		t.Errorf("expected route s1.example.com to be removed")
+	}
+}
+
+// TestDice_PatchService_urlsRollback tests Dice.PatchService with URLs that
+// can't be registered because one of them is an invalid pattern. It asserts
+// that neither the stored service nor the routes have been changed.
+func TestDice_PatchService_urlsRollback(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "a.example.com,b.example.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	urls := "a.example.com,c.example.com,~["
+
+	if err := d.PatchService("s1", types.ServicePatch{URLs: &urls}); err == nil {
+		t.Fatal("expected an error for the invalid pattern ~[")
+	}
+
+	service, err := d.findService("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(service.URLs, []string{"a.example.com", "b.example.com"}) {
+		t.Errorf("expected the stored URLs to be unchanged, got %v", service.URLs)
+	}
+
+	expected := map[string]string{
+		"a.example.com": service.ID,
+		"b.example.com": service.ID,
+	}
+
+	if routes := d.registry.Routes(); !reflect.DeepEqual(routes, expected) {
+		t.Errorf("expected routes %v, got %v", expected, routes)
+	}
+}
+
+// TestDice_SetDefaultService tests Dice.SetDefaultService for two services.
+// It asserts that only one of them can be the default service at a time.
+func TestDice_SetDefaultService(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	for _, name := range []string{"s1", "s2"} {
+		options := types.ServiceCreateOptions{URLs: name + ".example.com", Enable: true}
+
+		if err := d.CreateService(name, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.SetDefaultService("s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetDefaultService("s2"); err != ErrDefaultServiceExists {
+		t.Errorf("expected error %v, got %v", ErrDefaultServiceExists, err)
+	}
+
+	service, ok := d.registry.LookupService("unknown.com")
+	if !ok || service.Entity.Name != "s1" {
+		t.Fatalf("expected unknown.com to be routed to s1")
+	}
+
+	if err := d.SetServiceURL("s1", registry.CatchAllRoute, types.ServiceURLOptions{Delete: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.SetDefaultService("s2"); err != nil {
+		t.Errorf("expected s2 to become the default service, got %v", err)
+	}
+}
+
+// TestDice_SetServiceURL_collision tests Dice.SetServiceURL with an URL that
+// is already registered for another service. It asserts that the error of
+// the registry is returned and the stored URLs of the service are unchanged.
+func TestDice_SetServiceURL_collision(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	for _, name := range []string{"s1", "s2"} {
+		options := types.ServiceCreateOptions{URLs: name + ".example.com", Enable: true}
+
+		if err := d.CreateService(name, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.SetServiceURL("s2", "s1.example.com", types.ServiceURLOptions{}); err != registry.ErrRouteAlreadyRegistered {
+		t.Fatalf("expected error %v, got %v", registry.ErrRouteAlreadyRegistered, err)
+	}
+
+	service, err := d.findService("s2")
+	if err != nil || service == nil {
+		t.Fatalf("service s2 has not been found: %v", err)
+	}
+
+	if len(service.URLs) != 1 || service.URLs[0] != "s2.example.com" {
+		t.Errorf("expected stored URLs [s2.example.com], got %v", service.URLs)
+	}
+
+	if registryService, ok := d.registry.LookupService("s1.example.com"); !ok || registryService.Entity.Name != "s1" {
+		t.Errorf("expected s1.example.com to be routed to s1")
+	}
+}
+
+// TestDice_EnableService_noURLs tests if enabling a service without any URLs
+// logs a warning and if the service is reported as unreachable until a URL
+// has been set.
+func TestDice_EnableService_noURLs(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.logger = logger
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.EnableService("s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.warnings) != 1 || !strings.Contains(logger.warnings[0], "no URLs") {
+		t.Errorf("expected a warning about missing URLs, got %v", logger.warnings)
+	}
+
+	info, err := d.ServiceInfo("s1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.IsReachable {
+		t.Errorf("expected s1 to be unreachable")
+	}
+
+	if err := d.SetServiceURL("s1", "s1.example.com", types.ServiceURLOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if info, _ := d.ServiceInfo("s1"); !info.IsReachable {
+		t.Errorf("expected s1 to be reachable")
+	}
+
+	if err := d.EnableService("s1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logger.warnings) != 1 {
+		t.Errorf("expected no further warnings, got %v", logger.warnings)
+	}
+}
+
+// TestDice_SetServiceHealthCheck tests if health check settings with a path
+// that doesn't start with a slash or a negative threshold are rejected, and
+// if valid settings are stored and applied to the registered service.
+func TestDice_SetServiceHealthCheck(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := map[error]types.ServiceHealthCheckOptions{
+		ErrInvalidProbePath: {Path: "health"},
+		ErrInvalidThreshold: {Path: "/health", UnhealthyThreshold: -1},
+	}
+
+	for expected, options := range invalid {
+		if err := d.SetServiceHealthCheck("s1", options); err != expected {
+			t.Errorf("got error %v, expected %v", err, expected)
+		}
+	}
+
+	options := types.ServiceHealthCheckOptions{Path: "/health", HealthyThreshold: 2, UnhealthyThreshold: 3}
+
+	if err := d.SetServiceHealthCheck("s1", options); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := d.findService("s1")
+	if err != nil || service == nil {
+		t.Fatalf("service s1 has not been found: %v", err)
+	}
+
+	registered, _ := d.registry.Service(service.ID)
+
+	for _, healthCheck := range []entity.HealthCheck{service.HealthCheck, registered.Entity.HealthCheck} {
+		if healthCheck.Path != "/health" || healthCheck.HealthyThreshold != 2 || healthCheck.UnhealthyThreshold != 3 {
+			t.Errorf("got health check settings %+v, expected the given options", healthCheck)
+		}
+	}
+}
+
+// TestDice_SetServiceAccessLog tests if the access log settings are stored
+// and applied to the registered service, and if an unknown service results
+// in ErrServiceNotFound.
+func TestDice_SetServiceAccessLog(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	options := types.ServiceAccessLogOptions{SampleRate: 10}
+
+	if err := d.SetServiceAccessLog("s1", options); err != nil {
+		t.Fatal(err)
+	}
+
+	service, err := d.findService("s1")
+	if err != nil || service == nil {
+		t.Fatalf("service s1 has not been found: %v", err)
+	}
+
+	registered, _ := d.registry.Service(service.ID)
+
+	for _, accessLog := range []entity.AccessLog{service.AccessLog, registered.Entity.AccessLog} {
+		if accessLog.IsDisabled || accessLog.SampleRate != 10 {
+			t.Errorf("got access log settings %+v, expected the given options", accessLog)
+		}
+	}
+
+	if err := d.SetServiceAccessLog("s2", options); err != ErrServiceNotFound {
+		t.Errorf("got error %v, expected %v", err, ErrServiceNotFound)
+	}
+}
+
+// TestDice_UpdateService tests if only the instances of the updated service
+// are attached or detached according to their version.
+func TestDice_UpdateService(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	instances := []struct {
+		service string
+		url     string
+		version string
+	}{
+		{"s1", "n1:8000", "v1"},
+		{"s1", "n1:8001", "v2"},
+		{"s2", "n1:9000", "v1"},
+	}
+
+	for _, name := range []string{"s1", "s2"} {
+		if err := d.CreateService(name, types.ServiceCreateOptions{URLs: name + ".example.com", Enable: true}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, i := range instances {
+		options := types.InstanceCreateOptions{Version: i.version, Attach: i.version == "v1"}
+
+		if err := d.CreateInstance(entity.ServiceReference(i.service), "n1", i.url, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := d.UpdateService("s1", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]bool{"n1:8000": false, "n1:8001": true, "n1:9000": true}
+
+	for url, isAttached := range expected {
+		instance, err := d.findInstance(entity.InstanceReference(url))
+		if err != nil || instance == nil {
+			t.Fatalf("instance %s has not been found: %v", url, err)
+		}
+
+		if instance.IsAttached != isAttached {
+			t.Errorf("instance %s: expected IsAttached=%v in the store", url, isAttached)
+		}
+
+		for _, service := range d.registry.Services {
+			for _, deployment := range service.Deployments {
+				if deployment.Instance.ID == instance.ID && deployment.Instance.IsAttached != isAttached {
+					t.Errorf("instance %s: expected IsAttached=%v in the registry", url, isAttached)
+				}
+			}
+		}
+	}
+}
+
+// TestDice_UpdateService_isUpdated tests if Dice.UpdateService marks the
+// instances of a service as updated or outdated when the target version of
+// the service changes, and if ListInstances filters the outdated instances.
+func TestDice_UpdateService_isUpdated(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	for url, version := range map[string]string{"n1:8000": "v1", "n1:8001": "v2"} {
+		options := types.InstanceCreateOptions{Version: version, Attach: true}
+
+		if err := d.CreateInstance("s1", "n1", url, options); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	assertUpdated := func(targetVersion string, expected map[string]bool) {
+		for url, isUpdated := range expected {
+			instance, err := d.findInstance(entity.InstanceReference(url))
+			if err != nil || instance == nil {
+				t.Fatalf("instance %s has not been found: %v", url, err)
+			}
+
+			if instance.IsUpdated != isUpdated {
+				t.Errorf("target %s, instance %s: expected IsUpdated=%v", targetVersion, url, isUpdated)
+			}
+		}
+	}
+
+	// Without a target version, all instances are up to date.
+	assertUpdated("", map[string]bool{"n1:8000": true, "n1:8001": true})
+
+	if err := d.UpdateService("s1", "v2"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertUpdated("v2", map[string]bool{"n1:8000": false, "n1:8001": true})
+
+	outdated, err := d.ListInstances(types.InstanceListOptions{All: true, Outdated: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(outdated) != 1 || outdated[0].URL != "n1:8000" || outdated[0].IsUpdated {
+		t.Errorf("expected only n1:8000 to be outdated, got %v", outdated)
+	}
+
+	if err := d.UpdateService("s1", "v1"); err != nil {
+		t.Fatal(err)
+	}
+
+	assertUpdated("v1", map[string]bool{"n1:8000": true, "n1:8001": false})
+
+	// New instances are compared to the target version as well.
+	if err := d.CreateInstance("s1", "n1", "n1:8002", types.InstanceCreateOptions{Version: "v2"}); err != nil {
+		t.Fatal(err)
+	}
+
+	assertUpdated("v1", map[string]bool{"n1:8002": false})
+
+	info, err := d.InstanceInfo("n1:8000")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !info.IsUpdated {
+		t.Errorf("expected instance info of n1:8000 to report IsUpdated=true")
+	}
+}
+
+// setupUpdateBenchmark creates the given number of services with the given
+// number of instances each, where every other instance has version v2. It
+// returns the first service.
+func setupUpdateBenchmark(b *testing.B, d *Dice, serviceCount, instanceCount int) *entity.Service {
+	node, _ := entity.NewNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true})
+
+	if err := d.kvStore.CreateNode(node); err != nil {
+		b.Fatal(err)
+	}
+
+	var first *entity.Service
+
+	for s := 0; s < serviceCount; s++ {
+		service, _ := entity.NewService(fmt.Sprintf("s%d", s), types.ServiceCreateOptions{URLs: fmt.Sprintf("s%d.example.com", s), Balancing: string(scheduler.DefaultBalancing)})
+
+		if err := d.kvStore.CreateService(service); err != nil {
+			b.Fatal(err)
+		}
+
+		for i := 0; i < instanceCount; i++ {
+			version := []string{"v1", "v2"}[i%2]
+			url := fmt.Sprintf("n1:%d", 10000+s*instanceCount+i)
+			instance, _ := entity.NewInstance(service.ID, node.ID, url, types.InstanceCreateOptions{Version: version})
+
+			if err := d.kvStore.CreateInstance(instance); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		if first == nil {
+			first = service
+		}
+	}
+
+	if err := d.initializeRegistry(); err != nil {
+		b.Fatal(err)
+	}
+
+	return first
+}
+
+// BenchmarkDice_UpdateService measures UpdateService, which attaches and
+// detaches all instances of a service in a single registry pass.
+func BenchmarkDice_UpdateService(b *testing.B) {
+	d, cleanup := newTestDice(b)
+	defer cleanup()
+
+	service := setupUpdateBenchmark(b, d, 20, 100)
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		if err := d.UpdateService(entity.ServiceReference(service.ID), []string{"v1", "v2"}[n%2]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkDice_UpdateService_sequential measures the same state changes
+// as BenchmarkDice_UpdateService, but applies them one instance at a time
+// using AttachInstance and DetachInstance, which traverse the registry for
+// each instance.
+func BenchmarkDice_UpdateService_sequential(b *testing.B) {
+	d, cleanup := newTestDice(b)
+	defer cleanup()
+
+	service := setupUpdateBenchmark(b, d, 20, 100)
+
+	instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+		return instance.ServiceID == service.ID
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+
+	for n := 0; n < b.N; n++ {
+		targetVersion := []string{"v1", "v2"}[n%2]
+
+		for _, instance := range instances {
+			ref := entity.InstanceReference(instance.ID)
+
+			if instance.Version == targetVersion {
+				err = d.AttachInstance(ref, types.InstanceAttachOptions{Force: true})
+			} else {
+				err = d.DetachInstance(ref)
+			}
+
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
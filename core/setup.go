@@ -18,15 +18,21 @@ package core
 import (
 	"fmt"
 	"github.com/dominikbraun/dice/api"
+	"github.com/dominikbraun/dice/audit"
 	"github.com/dominikbraun/dice/config"
 	"github.com/dominikbraun/dice/controller"
+	"github.com/dominikbraun/dice/event"
 	"github.com/dominikbraun/dice/healthcheck"
 	"github.com/dominikbraun/dice/log"
 	"github.com/dominikbraun/dice/proxy"
 	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/scheduler"
 	"github.com/dominikbraun/dice/store"
+	"io"
+	"io/ioutil"
 	"os"
 	"os/signal"
+	"strings"
 	"time"
 )
 
@@ -44,6 +50,22 @@ func (d *Dice) setupConfig() error {
 		d.config.SetDefault(key, value)
 	}
 
+	config.SetRenamedDefaults(d.config)
+
+	return nil
+}
+
+// setupConfigCheck warns about keys in the configuration file that aren't
+// recognized or deprecated. It has to run after the logger has been set up.
+func (d *Dice) setupConfigCheck() error {
+	for _, key := range config.UnknownKeys(d.config) {
+		d.logger.Warnf("unknown configuration key %s, see `dice config keys`", key)
+	}
+
+	for key, current := range config.RenamedKeys(d.config) {
+		d.logger.Warnf("configuration key %s is deprecated, use %s instead", key, current)
+	}
+
 	return nil
 }
 
@@ -62,30 +84,124 @@ func (d *Dice) setupLogger() error {
 		return err
 	}
 
-	d.logger = log.NewLogger(file, log.DebugLevel)
+	d.logLevel = log.InfoLevel
+	d.logger = log.NewLogger(file, d.logLevel)
 
 	return nil
 }
 
 // setupKVStore opens or, if it doesn't exist, creates the key-value store.
+// The store file will be located at the configured store path.
+//
+// If the memory backend has been configured, the entities are only stored
+// in memory. In this case, the existing store is kept on a config reload.
+// The redis backend allows multiple Dice instances to share their state.
 func (d *Dice) setupKVStore() error {
 	var err error
 
+	backend := d.config.GetString("store-backend")
+
+	if _, isMemory := d.kvStore.(*store.MemoryStore); isMemory && backend == memoryBackend {
+		return nil
+	}
+
 	if d.kvStore != nil {
 		if err := d.kvStore.Close(); err != nil {
 			return err
 		}
 	}
 
-	path := d.config.GetString("kv-store-file")
+	switch backend {
+	case boltBackend:
+		path := d.config.GetString("store-path")
+
+		if d.kvStore, err = store.NewKVStore(path); err != nil {
+			return err
+		}
+	case memoryBackend:
+		d.kvStore = store.NewMemoryStore()
+	case redisBackend:
+		if d.kvStore, err = store.NewRedisStore(d.redisConfig()); err != nil {
+			return err
+		}
+	default:
+		return ErrUnsupportedBackend
+	}
+
+	return nil
+}
+
+// setupWatcher sets up the store watcher for sharing registry changes with
+// other Dice instances. This is only necessary for the redis backend, since
+// the other backends can't be shared.
+func (d *Dice) setupWatcher() error {
+	if d.watcher != nil {
+		if err := d.watcher.Close(); err != nil {
+			return err
+		}
+		d.watcher = nil
+	}
 
-	if d.kvStore, err = store.NewKVStore(path); err != nil {
+	if d.origin == "" {
+		origin, err := newOrigin()
+		if err != nil {
+			return err
+		}
+		d.origin = origin
+	}
+
+	if d.config.GetString("store-backend") == redisBackend {
+		d.watcher = store.NewRedisWatcher(d.redisConfig())
+	}
+
+	return nil
+}
+
+// redisConfig reads the configuration for the redis backend.
+func (d *Dice) redisConfig() store.RedisConfig {
+	return store.RedisConfig{
+		Address:   d.config.GetString("redis-address"),
+		Password:  d.config.GetString("redis-password"),
+		DB:        d.config.GetInt("redis-db"),
+		Namespace: d.config.GetString("redis-namespace"),
+		Timeout:   time.Duration(d.config.GetInt("redis-timeout")) * time.Millisecond,
+	}
+}
+
+// setupAuditLog opens or, if it doesn't exist, creates the audit log file.
+func (d *Dice) setupAuditLog() error {
+	var err error
+
+	if d.auditLog != nil {
+		if err := d.auditLog.Close(); err != nil {
+			return err
+		}
+	}
+
+	path := d.config.GetString("audit-logfile")
+
+	if d.auditLog, err = audit.New(path); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// setupEvents creates the event log keeping the state changes of instances
+// and nodes. On a reload, the existing events are preserved.
+func (d *Dice) setupEvents() error {
+	retention := d.config.GetInt("event-retention")
+
+	if d.events != nil {
+		d.events.SetRetention(retention)
+		return nil
+	}
+
+	d.events = event.NewLog(retention)
+
+	return nil
+}
+
 // setupRegistry initializes the service registry. This is also the point
 // where existing services and instances are acquainted to the registry.
 func (d *Dice) setupRegistry() error {
@@ -93,6 +209,25 @@ func (d *Dice) setupRegistry() error {
 	return nil
 }
 
+// setupBalancing reads and validates the default balancing method, which is
+// used for all services created without a balancing method.
+func (d *Dice) setupBalancing() error {
+	method := scheduler.BalancingMethod(d.config.GetString("default-balancing"))
+
+	if method == "" {
+		method = scheduler.DefaultBalancing
+	}
+
+	if !scheduler.IsSupported(method) {
+		return fmt.Errorf("invalid default-balancing: %w: %s", scheduler.ErrUnsupportedMethod, method)
+	}
+
+	d.defaultBalancing = method
+	d.slowStart = time.Duration(d.config.GetInt("slow-start-window")) * time.Millisecond
+
+	return nil
+}
+
 // setupHealthCheck initializes the default health checker. If no interval
 // or timeout has been configured, Dice's default values will be used.
 func (d *Dice) setupHealthCheck() error {
@@ -100,65 +235,195 @@ func (d *Dice) setupHealthCheck() error {
 
 	interval := d.config.GetInt("healthcheck-interval")
 	timeout := d.config.GetInt("healthcheck-timeout")
+	concurrency := d.config.GetInt("healthcheck-concurrency")
 
 	hcConfig := healthcheck.Config{
-		Interval: time.Duration(interval) * time.Millisecond,
-		Timeout:  time.Duration(timeout) * time.Millisecond,
+		Interval:           time.Duration(interval) * time.Millisecond,
+		Timeout:            time.Duration(timeout) * time.Millisecond,
+		Concurrency:        concurrency,
+		LogWindow:          time.Duration(d.config.GetInt("healthcheck-log-window")) * time.Millisecond,
+		HealthyThreshold:   d.config.GetInt("healthcheck-healthy-count"),
+		UnhealthyThreshold: d.config.GetInt("healthcheck-unhealthy-count"),
 	}
 
-	if d.healthCheck, err = healthcheck.New(hcConfig, &d.registry.Services); err != nil {
+	if d.healthCheck, err = healthcheck.New(hcConfig, d.registry); err != nil {
 		return err
 	}
 
+	d.healthCheck.SetEventLog(d.events)
+	d.healthCheck.SetLogger(d.logger)
+	d.checkInstance = d.healthCheck.CheckInstance
+
 	return nil
 }
 
 // setupController creates a new Controller instance that utilizes Dice
 // itself as a controller target. It will be used by the API server.
 func (d *Dice) setupController() error {
-	d.controller = controller.New(d, d.reloadConfig)
+	d.controller = controller.New(d, d.healthCheck, d.reloadConfig)
 	return nil
 }
 
 // setupAPIServer configures the API server, however it won't be started.
 func (d *Dice) setupAPIServer() error {
-	port := d.config.GetString("api-server-port")
-	address := fmt.Sprintf(":%v", port)
+	var address string
+
+	// An empty port disables the TCP listener, which is useful if the API
+	// should only be accessible through the Unix socket.
+	if port := d.config.GetString("api-server-port"); port != "" {
+		address = fmt.Sprintf(":%v", port)
+	}
 
 	logfile := d.config.GetString("api-server-logfile")
 
 	serverConfig := api.ServerConfig{
-		Address: address,
-		Logfile: logfile,
+		Address:           address,
+		Socket:            d.config.GetString("api-server-socket"),
+		Logfile:           logfile,
+		MaxHeaderBytes:    d.config.GetInt("api-server-max-header-bytes"),
+		Token:             d.config.GetString("api-server-token"),
+		DisableRequestLog: !d.config.GetBool("api-server-request-log"),
 	}
 
 	d.apiServer = api.NewServer(serverConfig, d.controller)
 
+	// The logfile opened by a previous setup isn't used anymore, since the
+	// previous API server has been shut down already.
+	if err := d.closeAPILogfile(); err != nil {
+		return err
+	}
+
+	// The API server logs through the Dice logger, unless another logfile
+	// has been configured for it. In that case, a separate logger with the
+	// current log level writes to that logfile. Without a request log, the
+	// logfile isn't needed at all.
+	apiLogger := d.logger
+
+	if !serverConfig.DisableRequestLog && logfile != "" && logfile != d.config.GetString("dice-logfile") {
+		file, err := os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		d.apiLogfile = file
+		apiLogger = log.NewLogger(file, d.logger.GetLevel())
+	}
+
+	d.apiServer.SetLogger(apiLogger)
+
 	return nil
 }
 
+// closeAPILogfile closes the separate logfile of the API server, if one has
+// been opened by setupAPIServer.
+func (d *Dice) closeAPILogfile() error {
+	if d.apiLogfile == nil {
+		return nil
+	}
+
+	err := d.apiLogfile.Close()
+	d.apiLogfile = nil
+
+	return err
+}
+
 // setupProxy configures the proxy server, which won't be started either.
+// The proxy-port setting may contain multiple comma-separated ports. On a
+// reload, the running proxy will only be replaced if its ports changed.
 func (d *Dice) setupProxy() error {
-	port := d.config.GetString("proxy-port")
-	address := fmt.Sprintf(":%v", port)
+	ports := strings.Split(d.config.GetString("proxy-port"), ",")
+	addresses := make([]string, 0, len(ports))
+
+	// Each port may either be a plain port like 8080 or a full address
+	// including the interface, like 127.0.0.1:8080.
+	for _, port := range ports {
+		port = strings.TrimSpace(port)
+
+		if strings.Contains(port, ":") {
+			addresses = append(addresses, port)
+		} else {
+			addresses = append(addresses, fmt.Sprintf(":%v", port))
+		}
+	}
 
 	logfile := d.config.GetString("proxy-logfile")
 
 	proxyConfig := proxy.Config{
-		Address: address,
-		Logfile: logfile,
+		Addresses:      addresses,
+		Logfile:        logfile,
+		Zone:           d.config.GetString("proxy-zone"),
+		WriteTimeout:   time.Duration(d.config.GetInt("proxy-write-timeout")) * time.Millisecond,
+		RetryAfter:     time.Duration(d.config.GetInt("proxy-retry-after")) * time.Second,
+		MaxHeaderBytes: d.config.GetInt("proxy-max-header-bytes"),
+		DialTimeout:    time.Duration(d.config.GetInt("proxy-dial-timeout")) * time.Millisecond,
+	}
+
+	d.zone = proxyConfig.Zone
+
+	// Access lines are appended to the proxy logfile. Without a logfile,
+	// the proxy doesn't write any access lines.
+	var accessLog io.Writer = ioutil.Discard
+	var file *os.File
+
+	if logfile != "" {
+		var err error
+
+		if file, err = os.OpenFile(logfile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644); err != nil {
+			return err
+		}
+		accessLog = file
+	}
+
+	// A running proxy is kept if its listeners can be reused. Until the new
+	// registry has been initialized, it continues using the old registry.
+	if d.proxy != nil {
+		if d.proxy.Reconfigure(proxyConfig) {
+			d.proxy.SetAccessLog(accessLog)
+			return d.replaceProxyLogfile(file)
+		}
+		if err := d.proxy.Shutdown(); err != nil {
+			d.logger.Errorf("proxy shutdown error: %v", err)
+		}
 	}
 
 	d.proxy = proxy.New(proxyConfig, d.registry)
+	d.proxy.SetLogger(d.logger)
+	d.proxy.SetAccessLog(accessLog)
 
-	return nil
+	return d.replaceProxyLogfile(file)
+}
+
+// replaceProxyLogfile closes the proxy logfile opened by a previous setup
+// and keeps the given file instead. It has to be called once the proxy has
+// stopped writing to the previous logfile, see Proxy.SetAccessLog.
+func (d *Dice) replaceProxyLogfile(file *os.File) error {
+	previous := d.proxyLogfile
+	d.proxyLogfile = file
+
+	if previous == nil {
+		return nil
+	}
+
+	return previous.Close()
 }
 
 // setupInterrupt creates the interrupt channel. It will be notified if a
 // system signal (SIGINT) is sent to the Dice executable.
+//
+// Additionally, the level signals (SIGUSR1 and SIGUSR2) are relayed to the
+// levelSignals channel on platforms supporting them, see handleLevelSignal.
 func (d *Dice) setupInterrupt() error {
 	d.interrupt = make(chan os.Signal)
 	signal.Notify(d.interrupt, os.Interrupt)
 
+	if d.levelSignals != nil {
+		signal.Stop(d.levelSignals)
+	}
+
+	d.levelSignals = make(chan os.Signal, 1)
+
+	if debugSignal != nil && restoreSignal != nil {
+		signal.Notify(d.levelSignals, debugSignal, restoreSignal)
+	}
+
 	return nil
 }
@@ -0,0 +1,31 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// debugSignal raises the log level to debug, see handleLevelSignal.
+	debugSignal os.Signal = syscall.SIGUSR1
+	// restoreSignal restores the original log level.
+	restoreSignal os.Signal = syscall.SIGUSR2
+)
@@ -0,0 +1,75 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+// +build !windows
+
+package core
+
+import (
+	"github.com/dominikbraun/dice/log"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// TestDice_handleLevelSignal tests the log level toggling. It sends SIGUSR1
+// and SIGUSR2 to the test process and asserts that the log level is raised
+// to debug and restored afterwards, and that both changes are logged.
+func TestDice_handleLevelSignal(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	d.logLevel = log.InfoLevel
+	d.logger.SetLevel(d.logLevel)
+
+	logger := &recordingLogger{Logger: d.logger}
+	d.logger = logger
+
+	if err := d.setupInterrupt(); err != nil {
+		t.Fatal(err)
+	}
+
+	send := func(sig syscall.Signal) {
+		if err := syscall.Kill(os.Getpid(), sig); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case received := <-d.levelSignals:
+			d.handleLevelSignal(received)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("signal %v has not been relayed", sig)
+		}
+	}
+
+	send(syscall.SIGUSR1)
+
+	if level := d.logger.GetLevel(); level != log.DebugLevel {
+		t.Errorf("expected level %s after SIGUSR1, got %s", log.DebugLevel, level)
+	}
+
+	send(syscall.SIGUSR2)
+
+	if level := d.logger.GetLevel(); level != log.InfoLevel {
+		t.Errorf("expected level %s after SIGUSR2, got %s", log.InfoLevel, level)
+	}
+
+	if len(logger.infos) != 2 || !strings.Contains(logger.infos[0], "from info to debug") ||
+		!strings.Contains(logger.infos[1], "from debug to info") {
+		t.Errorf("expected both level changes to be logged, got %v", logger.infos)
+	}
+}
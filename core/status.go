@@ -0,0 +1,108 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"errors"
+	"github.com/dominikbraun/dice/types"
+	"sort"
+)
+
+var (
+	ErrConflictingStatusFilters = errors.New("alive and dead filters can't be combined")
+)
+
+// ServiceHealth returns the health status of all services registered in the
+// service registry, sorted by their names.
+//
+// An instance counts as alive if it is able to serve requests, that is, if
+// the instance and its node are both attached and alive. A service whose
+// instances are all alive is healthy. If only some of them are alive, the
+// service is degraded, and if none of them is alive, it is down.
+func (d *Dice) ServiceHealth() ([]types.ServiceHealthOutput, error) {
+	services := d.registry.All()
+	healthList := make([]types.ServiceHealthOutput, 0, len(services))
+
+	for _, service := range services {
+		health := types.ServiceHealthOutput{
+			ID:    service.Entity.ID,
+			Name:  service.Entity.Name,
+			Total: len(service.Deployments),
+		}
+
+		for _, deployment := range service.Deployments {
+			if isAvailable(service, deployment.Instance.ID) {
+				health.Alive++
+			}
+		}
+
+		switch {
+		case health.Alive == 0:
+			health.Status = types.DownStatus
+		case health.Alive < health.Total:
+			health.Status = types.DegradedStatus
+		default:
+			health.Status = types.HealthyStatus
+		}
+
+		healthList = append(healthList, health)
+	}
+
+	sort.Slice(healthList, func(i, j int) bool {
+		return healthList[i].Name < healthList[j].Name
+	})
+
+	return healthList, nil
+}
+
+// liveNodeStatus returns the alive status of all nodes that have deployments
+// in the service registry, mapped against their node IDs. A node is alive if
+// it is alive in any of its deployments.
+func (d *Dice) liveNodeStatus() map[string]bool {
+	status := make(map[string]bool)
+
+	for _, service := range d.registry.All() {
+		for _, deployment := range service.Deployments {
+			status[deployment.Node.ID] = status[deployment.Node.ID] || deployment.Node.IsAlive
+		}
+	}
+
+	return status
+}
+
+// liveInstanceStatus returns the alive status of all instances registered in
+// the service registry, mapped against their instance IDs.
+func (d *Dice) liveInstanceStatus() map[string]bool {
+	status := make(map[string]bool)
+
+	for _, service := range d.registry.All() {
+		for _, deployment := range service.Deployments {
+			status[deployment.Instance.ID] = deployment.Instance.IsAlive
+		}
+	}
+
+	return status
+}
+
+// matchesStatus indicates whether an entity with the given alive status
+// passes the AliveOnly and DeadOnly list options.
+func matchesStatus(isAlive, aliveOnly, deadOnly bool) bool {
+	if aliveOnly && !isAlive {
+		return false
+	}
+
+	return !deadOnly || !isAlive
+}
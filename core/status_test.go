@@ -0,0 +1,94 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"reflect"
+	"testing"
+)
+
+// TestDice_ServiceHealth tests Dice.ServiceHealth for a service whose
+// instances are all alive, a service with a dead instance and a service
+// without any alive instance. It asserts that they are reported as healthy,
+// degraded and down.
+func TestDice_ServiceHealth(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.CreateNode("n1", types.NodeCreateOptions{Weight: 1, Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// alive maps the service names against the alive states of their
+	// instances.
+	alive := map[string][]bool{
+		"s1": {true, true},
+		"s2": {true, false},
+		"s3": {false},
+	}
+
+	for i, name := range []string{"s1", "s2", "s3"} {
+		options := types.ServiceCreateOptions{URLs: name + ".example.com", Enable: true}
+
+		if err := d.CreateService(name, options); err != nil {
+			t.Fatal(err)
+		}
+
+		for j := range alive[name] {
+			url := fmt.Sprintf("n1:%d", 8000+10*i+j)
+
+			if err := d.CreateInstance(entity.ServiceReference(name), "n1", url, types.InstanceCreateOptions{Attach: true}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		service, err := d.findService(entity.ServiceReference(name))
+		if err != nil || service == nil {
+			t.Fatalf("service %s has not been found: %v", name, err)
+		}
+
+		for j, deployment := range d.registry.Services[service.ID].Deployments {
+			deployment.Node.IsAlive = true
+			deployment.Instance.IsAlive = alive[name][j]
+		}
+	}
+
+	healthList, err := d.ServiceHealth()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]types.ServiceHealthOutput{
+		"s1": {Name: "s1", Status: types.HealthyStatus, Alive: 2, Total: 2},
+		"s2": {Name: "s2", Status: types.DegradedStatus, Alive: 1, Total: 2},
+		"s3": {Name: "s3", Status: types.DownStatus, Alive: 0, Total: 1},
+	}
+
+	if len(healthList) != len(expected) {
+		t.Fatalf("expected %d services, got %d", len(expected), len(healthList))
+	}
+
+	for _, health := range healthList {
+		health.ID = ""
+
+		if !reflect.DeepEqual(health, expected[health.Name]) {
+			t.Errorf("expected %v, got %v", expected[health.Name], health)
+		}
+	}
+}
@@ -16,8 +16,25 @@
 package core
 
 import (
+	"errors"
 	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/scheduler"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
 	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	routeConflict    string = "route"
+	serviceConflict  string = "service"
+	nodeConflict     string = "node"
+	instanceConflict string = "instance"
+)
+
+var (
+	ErrConflictingEntities = errors.New("key-value store contains conflicting entities")
 )
 
 // urlSafe specifies a regular expression for a valid URL. It only allows
@@ -53,6 +70,10 @@ func validateService(service *entity.Service) (bool, string) {
 		return false, "Name must only contain _ and - as special characters"
 	}
 
+	if !scheduler.IsSupported(scheduler.BalancingMethod(service.BalancingMethod)) {
+		return false, "Balancing method " + service.BalancingMethod + " is not supported"
+	}
+
 	return true, ""
 }
 
@@ -69,3 +90,130 @@ func validateInstance(instance *entity.Instance) (bool, string) {
 
 	return true, ""
 }
+
+// Validate scans the key-value store for conflicting entities. These are
+// routes claimed by multiple services as well as references - IDs, names
+// and instance URLs - that identify more than one entity of the same kind.
+// Such conflicts can't be created using Dice, but may occur after manual
+// edits or imports of the store.
+//
+// The conflicts are ordered by their kind and value. An error will only be
+// returned if the store couldn't be read.
+func (d *Dice) Validate() ([]types.ConflictOutput, error) {
+	routes := make(referenceIndex)
+	serviceRefs := make(referenceIndex)
+	nodeRefs := make(referenceIndex)
+	instanceRefs := make(referenceIndex)
+
+	services, err := d.kvStore.FindServices(store.AllServicesFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range services {
+		serviceRefs.add(s.ID, s.ID)
+		serviceRefs.add(s.Name, s.ID)
+
+		for _, url := range s.URLs {
+			routes.add(url, s.ID)
+		}
+	}
+
+	nodes, err := d.kvStore.FindNodes(store.AllNodesFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, n := range nodes {
+		nodeRefs.add(n.ID, n.ID)
+		nodeRefs.add(n.Name, n.ID)
+	}
+
+	instances, err := d.kvStore.FindInstances(store.AllInstancesFilter)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, i := range instances {
+		instanceRefs.add(i.ID, i.ID)
+		instanceRefs.add(i.Name, i.ID)
+		instanceRefs.add(i.URL, i.ID)
+	}
+
+	conflicts := routes.conflicts(routeConflict)
+	conflicts = append(conflicts, serviceRefs.conflicts(serviceConflict)...)
+	conflicts = append(conflicts, nodeRefs.conflicts(nodeConflict)...)
+	conflicts = append(conflicts, instanceRefs.conflicts(instanceConflict)...)
+
+	sort.Slice(conflicts, func(i, j int) bool {
+		if conflicts[i].Kind != conflicts[j].Kind {
+			return conflicts[i].Kind < conflicts[j].Kind
+		}
+		return conflicts[i].Value < conflicts[j].Value
+	})
+
+	return conflicts, nil
+}
+
+// checkConsistency validates the key-value store and logs all conflicts.
+// In strict mode, ErrConflictingEntities will be returned if there are any
+// conflicts. Otherwise, Dice continues and only registers one of the
+// conflicting services for each route.
+func (d *Dice) checkConsistency() error {
+	conflicts, err := d.Validate()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range conflicts {
+		d.logger.Errorf("conflict: %s %s is claimed by %s", c.Kind, c.Value, strings.Join(c.IDs, ", "))
+	}
+
+	if len(conflicts) > 0 && d.strict {
+		return ErrConflictingEntities
+	}
+
+	return nil
+}
+
+// referenceIndex maps references like routes, IDs or names to the IDs of
+// all entities using that reference.
+type referenceIndex map[string][]string
+
+// add adds the entity ID to the given reference. Empty references, like
+// unset names, are ignored.
+func (ri referenceIndex) add(reference, id string) {
+	if reference == "" {
+		return
+	}
+
+	for _, existing := range ri[reference] {
+		if existing == id {
+			return
+		}
+	}
+
+	ri[reference] = append(ri[reference], id)
+}
+
+// conflicts returns a conflict of the given kind for each reference that
+// is used by more than one entity.
+func (ri referenceIndex) conflicts(kind string) []types.ConflictOutput {
+	var conflicts []types.ConflictOutput
+
+	for reference, ids := range ri {
+		if len(ids) < 2 {
+			continue
+		}
+
+		sort.Strings(ids)
+
+		conflicts = append(conflicts, types.ConflictOutput{
+			Kind:  kind,
+			Value: reference,
+			IDs:   ids,
+		})
+	}
+
+	return conflicts
+}
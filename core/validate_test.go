@@ -0,0 +1,86 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"reflect"
+	"testing"
+)
+
+// seedConflictingServices writes services directly into the key-value
+// store, bypassing the uniqueness checks of Dice.CreateService. s1 and s2
+// claim the same route and s2 and s3 have the same name.
+func seedConflictingServices(t *testing.T, d *Dice) {
+	services := []*entity.Service{
+		{ID: "s1", Name: "api", URLs: []string{"example.com", "a.example.com"}},
+		{ID: "s2", Name: "web", URLs: []string{"example.com"}},
+		{ID: "s3", Name: "web", URLs: []string{"b.example.com"}},
+	}
+
+	for _, s := range services {
+		if err := d.kvStore.CreateService(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// TestDice_Validate tests if Dice.Validate reports a route claimed by two
+// services as well as two services with the same name.
+func TestDice_Validate(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	seedConflictingServices(t, d)
+
+	conflicts, err := d.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []types.ConflictOutput{
+		{Kind: routeConflict, Value: "example.com", IDs: []string{"s1", "s2"}},
+		{Kind: serviceConflict, Value: "web", IDs: []string{"s2", "s3"}},
+	}
+
+	if !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("expected conflicts %+v, got %+v", expected, conflicts)
+	}
+}
+
+// TestDice_checkConsistency tests if conflicts only prevent Dice from
+// starting in strict mode.
+func TestDice_checkConsistency(t *testing.T) {
+	d, cleanup := newTestDice(t)
+	defer cleanup()
+
+	if err := d.checkConsistency(); err != nil {
+		t.Fatalf("expected empty store to be consistent, got %v", err)
+	}
+
+	seedConflictingServices(t, d)
+
+	if err := d.checkConsistency(); err != nil {
+		t.Errorf("expected conflicts to be tolerated, got %v", err)
+	}
+
+	d.SetStrict(true)
+
+	if err := d.checkConsistency(); err != ErrConflictingEntities {
+		t.Errorf("expected error %v, got %v", ErrConflictingEntities, err)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/store"
+)
+
+// publish notifies other Dice instances sharing the same store about a
+// changed entity. It is a no-op if there is no store watcher.
+func (d *Dice) publish(entityType store.EntityType, id string) {
+	if d.watcher == nil {
+		return
+	}
+
+	change := store.Change{
+		Origin:     d.origin,
+		EntityType: entityType,
+		ID:         id,
+	}
+
+	if err := d.watcher.Publish(change); err != nil {
+		d.logger.Errorf("publishing change of %s %s failed: %v", entityType, id, err)
+	}
+}
+
+// watch subscribes to the store watcher and applies all changes made by
+// other Dice instances to the service registry. The changes are applied in
+// an own goroutine until the watcher is closed.
+func (d *Dice) watch() error {
+	if d.watcher == nil {
+		return nil
+	}
+
+	changes, err := d.watcher.Subscribe()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		for change := range changes {
+			if change.Origin == d.origin {
+				continue
+			}
+
+			if err := d.applyChange(change); err != nil {
+				d.logger.Errorf("applying change of %s %s failed: %v", change.EntityType, change.ID, err)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// applyChange updates the service registry for a changed entity. All
+// services affected by the change will be rebuilt from the store.
+func (d *Dice) applyChange(change store.Change) error {
+	serviceIDs := make(map[string]bool)
+
+	switch change.EntityType {
+	case store.ServiceEntity:
+		serviceIDs[change.ID] = true
+
+	case store.NodeEntity:
+		instances, err := d.kvStore.FindInstances(func(instance *entity.Instance) bool {
+			return instance.NodeID == change.ID
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, i := range instances {
+			serviceIDs[i.ServiceID] = true
+		}
+
+		for id, s := range d.registry.All() {
+			for _, deployment := range s.Deployments {
+				if deployment.Node.ID == change.ID {
+					serviceIDs[id] = true
+				}
+			}
+		}
+
+	case store.InstanceEntity:
+		instance, err := d.kvStore.FindInstance(change.ID)
+		if err != nil {
+			return err
+		}
+
+		if instance != nil {
+			serviceIDs[instance.ServiceID] = true
+		}
+
+		for id, s := range d.registry.All() {
+			for _, deployment := range s.Deployments {
+				if deployment.Instance.ID == change.ID {
+					serviceIDs[id] = true
+				}
+			}
+		}
+	}
+
+	for id := range serviceIDs {
+		if err := d.refreshService(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// refreshService rebuilds a registered service from the store and replaces
+// the registered service. If the service doesn't exist anymore, it will be
+// unregistered.
+//
+// The new service is built completely before it replaces the registered
+// service, so that its routes remain available in the meantime. If it can't
+// be built, the registered service will be kept.
+//
+// The alive states are maintained by the local health check and therefore
+// will be taken over from the replaced service.
+func (d *Dice) refreshService(serviceID string) error {
+	service, err := d.kvStore.FindService(serviceID)
+	if err != nil {
+		return err
+	}
+
+	if service == nil {
+		if err := d.registry.UnregisterService(serviceID, true); err != nil && err != registry.ErrUnregisteredService {
+			return err
+		}
+		return nil
+	}
+
+	registryService, err := d.buildRegistryService(service)
+	if err != nil {
+		return err
+	}
+
+	if s, exists := d.registry.Service(serviceID); exists {
+		copyAliveStates(s, registryService)
+	}
+
+	return d.registry.ReplaceService(registryService)
+}
+
+// newOrigin generates a random identifier for this Dice instance, which is
+// used for recognizing changes published by the instance itself.
+func newOrigin() (string, error) {
+	b := make([]byte, 8)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%x", b), nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package core provides the Dice load balancer and its methods.
+package core
+
+import (
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/store"
+	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"testing"
+	"time"
+)
+
+// TestDice_watch tests the propagation of registry changes between two Dice
+// instances sharing the same store and store watcher. A service, a node and
+// an attached instance are created on the first instance. Afterwards, the
+// service has to be registered with the instance on the second instance.
+func TestDice_watch(t *testing.T) {
+	kvStore := store.NewMemoryStore()
+	watcher := store.NewMemoryWatcher()
+	defer watcher.Close()
+
+	newDice := func(origin string) *Dice {
+		logger := log.NewLogger(ioutil.Discard, log.ErrorLevel)
+
+		d := Dice{
+			logger:   logger,
+			kvStore:  kvStore,
+			registry: registry.NewServiceRegistry(logger),
+			watcher:  watcher,
+			origin:   origin,
+		}
+
+		if err := d.watch(); err != nil {
+			t.Fatal(err)
+		}
+
+		return &d
+	}
+
+	d1 := newDice("d1")
+	d2 := newDice("d2")
+
+	if err := d1.CreateService("s1", types.ServiceCreateOptions{URLs: "s1.example.com", Enable: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d1.CreateNode("n1", types.NodeCreateOptions{Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d1.CreateInstance("s1", "n1", "n1:8080", types.InstanceCreateOptions{Attach: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	// The changes are applied asynchronously, so the second instance might
+	// not see them immediately.
+	for attempt := 0; attempt < 50; attempt++ {
+		service, ok := d2.registry.LookupService("s1.example.com")
+
+		if ok && service.Entity.IsEnabled && len(service.Deployments) == 1 && service.Deployments[0].Instance.IsAttached {
+			return
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	t.Error("changes of the first instance are not visible on the second instance")
+}
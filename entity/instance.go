@@ -17,6 +17,7 @@ package entity
 
 import (
 	"github.com/dominikbraun/dice/types"
+	"strings"
 	"time"
 )
 
@@ -30,40 +31,77 @@ type InstanceReference string
 // Like with nodes, attaching an instance to Dice makes it available for
 // receiving requests. If the instance has been deployed to a node that is
 // currently detached, it won't receive any requests.
+//
+// MaxConnections is the maximum number of requests the proxy forwards to the
+// instance at the same time. 0 means that there is no limit.
+//
+// Standby instances are warm spares: They only receive requests if none of
+// the other instances of the service is available.
+//
+// IsUpdated indicates whether the instance runs the target version of its
+// service, see IsUpToDate. It is updated whenever the target changes.
 type Instance struct {
-	ID            string    `json:"id"`
-	Name          string    `json:"name"`
-	ServiceID     string    `json:"service_id"`
-	NodeID        string    `json:"node_id"`
-	URL           string    `json:"url"`
-	Version       string    `json:"version"`
-	IsAttached    bool      `json:"is_attached"`
-	IsUpdated     bool      `json:"is_updated"`
-	CreatedAt     time.Time `json:"created_at"`
-	AttachedSince time.Time `json:"attached_since"`
-	IsAlive       bool      `json:"is_alive"`
+	ID             string    `json:"id"`
+	Name           string    `json:"name"`
+	ServiceID      string    `json:"service_id"`
+	NodeID         string    `json:"node_id"`
+	URL            string    `json:"url"`
+	Version        string    `json:"version"`
+	IsAttached     bool      `json:"is_attached"`
+	IsUpdated      bool      `json:"is_updated"`
+	CreatedAt      time.Time `json:"created_at"`
+	AttachedSince  time.Time `json:"attached_since"`
+	IsAlive        bool      `json:"is_alive"`
+	MaxConnections int       `json:"max_connections"`
+	Standby        bool      `json:"standby"`
 }
 
 // NewInstance creates a new Instance instance. It doesn't guarantee uniqueness.
 func NewInstance(serviceID, nodeID string, url string, options types.InstanceCreateOptions) (*Instance, error) {
-	uuid, err := generateEntityID()
+	uuid, err := generateEntityID(options.IDKey)
 	if err != nil {
 		return nil, err
 	}
 
 	i := Instance{
-		ID:            uuid,
-		Name:          options.Name,
-		ServiceID:     serviceID,
-		NodeID:        nodeID,
-		URL:           url,
-		Version:       options.Version,
-		IsAttached:    options.Attach,
-		IsUpdated:     false,
-		CreatedAt:     time.Now(),
-		AttachedSince: time.Time{},
-		IsAlive:       false,
+		ID:             uuid,
+		Name:           options.Name,
+		ServiceID:      serviceID,
+		NodeID:         nodeID,
+		URL:            url,
+		Version:        options.Version,
+		IsAttached:     options.Attach,
+		IsUpdated:      false,
+		CreatedAt:      time.Now(),
+		AttachedSince:  time.Time{},
+		IsAlive:        false,
+		MaxConnections: options.MaxConnections,
+		Standby:        options.Standby,
+	}
+
+	if i.IsAttached {
+		i.AttachedSince = i.CreatedAt
 	}
 
 	return &i, nil
 }
+
+// IsUpToDate indicates whether the instance runs the given target version of
+// its service. If no target version has been set, all instances are up to
+// date.
+func (i *Instance) IsUpToDate(targetVersion string) bool {
+	return targetVersion == "" || i.Version == targetVersion
+}
+
+// SplitURL splits the URL of an instance into the address that is dialed
+// for reaching the instance and an optional base path. For example, the URL
+// 10.0.0.1:8080/app has the address 10.0.0.1:8080 and the base path /app.
+func SplitURL(url string) (string, string) {
+	url = strings.TrimPrefix(url, "//")
+
+	if i := strings.Index(url, "/"); i >= 0 {
+		return url[:i], url[i:]
+	}
+
+	return url, ""
+}
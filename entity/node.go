@@ -17,11 +17,15 @@ package entity
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"fmt"
 	"github.com/dominikbraun/dice/types"
 	"time"
 )
 
+// entityIDLength is the number of bytes an entity ID consists of.
+const entityIDLength = 16
+
 // NodeReference is a string that identifies a node, e. g. an ID or name.
 type NodeReference string
 
@@ -31,19 +35,27 @@ type NodeReference string
 // Each node has a weight depicting the node's physical computing power.
 // The heavier a node is, the more requests it receives from Dice. Each
 // node can be attached to Dice, making it available for these requests.
+//
+// Nodes may be assigned to a zone, e. g. a data center or an availability
+// zone. If a local zone is configured, instances in that zone are preferred.
+//
+// An unschedulable (cordoned) node doesn't accept any new instances, while
+// its existing instances keep serving requests.
 type Node struct {
 	ID            string    `json:"id"`
 	Name          string    `json:"name"`
 	Weight        uint8     `json:"weight"`
+	Zone          string    `json:"zone"`
 	IsAttached    bool      `json:"is_attached"`
 	CreatedAt     time.Time `json:"created_at"`
 	AttachedSince time.Time `json:"attached_since"`
 	IsAlive       bool      `json:"is_alive"`
+	Unschedulable bool      `json:"unschedulable"`
 }
 
 // NewNode creates a new Node instance. It doesn't guarantee uniqueness.
 func NewNode(name string, options types.NodeCreateOptions) (*Node, error) {
-	uuid, err := generateEntityID()
+	uuid, err := generateEntityID(options.IDKey)
 	if err != nil {
 		return nil, err
 	}
@@ -52,6 +64,7 @@ func NewNode(name string, options types.NodeCreateOptions) (*Node, error) {
 		ID:            uuid,
 		Name:          name,
 		Weight:        options.Weight,
+		Zone:          options.Zone,
 		IsAttached:    options.Attach,
 		CreatedAt:     time.Now(),
 		AttachedSince: time.Time{},
@@ -61,12 +74,21 @@ func NewNode(name string, options types.NodeCreateOptions) (*Node, error) {
 	return &n, nil
 }
 
-// generateEntityID generates a random, time-based ID. Even though an ID
-// collision is unlikely, you have to check if the ID really is unique.
-func generateEntityID() (string, error) {
-	b := make([]byte, 8)
+// generateEntityID generates a random ID consisting of 16 bytes, encoded as
+// 32 hexadecimal characters. Even though an ID collision is very unlikely,
+// generateEntityID doesn't guarantee uniqueness: Callers have to verify that
+// the ID really is unique, which the core does using its *IsUnique checks.
+//
+// If key is not empty, the ID is derived from the SHA-256 hash of the key
+// instead. The same key always yields the same ID, which makes deployments
+// from the same definition reproducible.
+func generateEntityID(key string) (string, error) {
+	b := make([]byte, entityIDLength)
 
-	if _, err := rand.Read(b); err != nil {
+	if key != "" {
+		hash := sha256.Sum256([]byte(key))
+		copy(b, hash[:])
+	} else if _, err := rand.Read(b); err != nil {
 		return "", err
 	}
 	uuid := fmt.Sprintf("%x", b)
@@ -0,0 +1,112 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package entity provides domain entities and their factory functions.
+package entity
+
+import (
+	"github.com/dominikbraun/dice/types"
+	"regexp"
+	"testing"
+)
+
+// TestGenerateEntityID tests generateEntityID without a key. It asserts
+// that the generated IDs consist of 32 lowercase hexadecimal characters
+// and that subsequently generated IDs differ.
+func TestGenerateEntityID(t *testing.T) {
+	format := regexp.MustCompile(`^[0-9a-f]{32}$`)
+	seen := make(map[string]bool)
+
+	for i := 0; i < 100; i++ {
+		id, err := generateEntityID("")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !format.MatchString(id) {
+			t.Errorf("expected 32 hexadecimal characters, got %s", id)
+		}
+
+		if seen[id] {
+			t.Errorf("expected unique IDs, got %s twice", id)
+		}
+		seen[id] = true
+	}
+}
+
+// TestGenerateEntityID_key tests generateEntityID with a key. It asserts
+// that the same key always yields the same ID and different keys don't.
+func TestGenerateEntityID_key(t *testing.T) {
+	first, err := generateEntityID("my-service/node-1/10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := generateEntityID("my-service/node-1/10.0.0.1:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first != second {
+		t.Errorf("expected equal IDs for equal keys, got %s and %s", first, second)
+	}
+
+	other, err := generateEntityID("my-service/node-2/10.0.0.2:8080")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first == other {
+		t.Errorf("expected different IDs for different keys, got %s twice", first)
+	}
+
+	if len(first) != 2*entityIDLength {
+		t.Errorf("expected ID length %d, got %d", 2*entityIDLength, len(first))
+	}
+}
+
+// TestNewNode_idKey tests NewNode with and without the IDKey option. It
+// asserts that nodes created with the same key have the same ID, while
+// nodes created without a key get random IDs.
+func TestNewNode_idKey(t *testing.T) {
+	options := types.NodeCreateOptions{IDKey: "node-1"}
+
+	first, err := NewNode("node-1", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := NewNode("node-1", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.ID != second.ID {
+		t.Errorf("expected equal IDs for equal keys, got %s and %s", first.ID, second.ID)
+	}
+
+	third, err := NewNode("node-1", types.NodeCreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fourth, err := NewNode("node-1", types.NodeCreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if third.ID == fourth.ID {
+		t.Errorf("expected random IDs without a key, got %s twice", third.ID)
+	}
+}
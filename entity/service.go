@@ -16,9 +16,15 @@
 package entity
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
 	"fmt"
 	"github.com/dominikbraun/dice/types"
+	"path"
 	"strings"
+	"time"
 )
 
 // ServiceReference is a string that identifies a service, e. g. an ID.
@@ -31,26 +37,229 @@ type ServiceReference string
 // Each service is available under multiple URLs like api.example.com and
 // example.com/api. Also, the load balancing algorithm is configurable for
 // each service. If a service is disabled, requests will run into HTTP 503.
+// With sticky sessions, each client is pinned to a single instance for as
+// long as that instance is available.
+//
+// Requests to one of the service's URLs may be split across multiple
+// services by weight. RouteWeights maps a URL to the weighted services that
+// receive the requests to that URL, see RouteWeight.
+//
+// Schedule holds the transitions that will enable or disable the service at
+// a given time, for example to disable it during a maintenance window.
 type Service struct {
-	ID              string   `json:"id"`
-	Name            string   `json:"name"`
-	URLs            []string `json:"urls"`
-	TargetVersion   string   `json:"target_version"`
-	BalancingMethod string   `json:"balancing_method"`
-	IsEnabled       bool     `json:"is_enabled"`
+	ID              string                   `json:"id"`
+	Name            string                   `json:"name"`
+	URLs            []string                 `json:"urls"`
+	TargetVersion   string                   `json:"target_version"`
+	BalancingMethod string                   `json:"balancing_method"`
+	IsEnabled       bool                     `json:"is_enabled"`
+	HealthCheck     HealthCheck              `json:"health_check"`
+	Maintenance     Maintenance              `json:"maintenance"`
+	CORS            CORS                     `json:"cors"`
+	BasicAuth       BasicAuth                `json:"basic_auth"`
+	AllowList       AllowList                `json:"allow_list"`
+	StickySessions  bool                     `json:"sticky_sessions"`
+	Outliers        Outliers                 `json:"outliers"`
+	Mirror          Mirror                   `json:"mirror"`
+	AccessLog       AccessLog                `json:"access_log"`
+	RouteWeights    map[string][]RouteWeight `json:"route_weights"`
+	Schedule        []Transition             `json:"schedule"`
+}
+
+// HealthCheck holds service-specific health check settings. Each setting
+// that is not set falls back to the globally configured value.
+type HealthCheck struct {
+	Path               string        `json:"path"`
+	Interval           time.Duration `json:"interval"`
+	Timeout            time.Duration `json:"timeout"`
+	HealthyThreshold   int           `json:"healthy_threshold"`
+	UnhealthyThreshold int           `json:"unhealthy_threshold"`
+}
+
+// Maintenance indicates whether a service is under maintenance. Requests to
+// a service under maintenance won't be forwarded to any instance. Instead, a
+// 503 response with the maintenance message as body will be returned.
+type Maintenance struct {
+	IsEnabled bool   `json:"is_enabled"`
+	Message   string `json:"message"`
+}
+
+// CORS holds the CORS settings of a service, which are applied by the proxy.
+// CORS is disabled as long as no allowed origins are set. An origin of `*`
+// allows all origins.
+type CORS struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+}
+
+// Outliers holds the settings for the passive outlier detection of the proxy.
+// An instance whose requests fail Threshold times in a row is ejected from
+// load balancing for EjectionTime, multiplied by the number of consecutive
+// ejections. Outlier detection is disabled as long as Threshold is 0.
+type Outliers struct {
+	Threshold    int           `json:"threshold"`
+	EjectionTime time.Duration `json:"ejection_time"`
+}
+
+// Mirror holds the traffic mirroring settings of a service. The proxy sends
+// a copy of the given Fraction of requests to an instance with the given
+// Version and discards its response. Mirroring is disabled as long as the
+// Version is empty or the Fraction is 0.
+type Mirror struct {
+	Version  string  `json:"version"`
+	Fraction float64 `json:"fraction"`
+}
+
+// IsEnabled indicates whether requests are mirrored at all.
+func (m Mirror) IsEnabled() bool {
+	return m.Version != "" && m.Fraction > 0
+}
+
+// AccessLog holds the access log settings of a service. By default, the
+// proxy writes an access line for each request forwarded to an instance. If
+// SampleRate is greater than 1, only 1 in SampleRate requests is logged.
+type AccessLog struct {
+	IsDisabled bool `json:"is_disabled"`
+	SampleRate int  `json:"sample_rate"`
+}
+
+// RouteWeight is the share of requests to a weighted route that the service
+// with the given ID receives. The share is Weight divided by the sum of all
+// weights of the route.
+type RouteWeight struct {
+	ServiceID string `json:"service_id"`
+	Weight    int    `json:"weight"`
+}
+
+// Transition is a scheduled change of the enabled state of a service. Once
+// the time At has been reached, the service is enabled if Enable is set and
+// disabled otherwise. If Every is set, the transition repeats in that
+// interval. Otherwise, it is fired only once.
+type Transition struct {
+	Enable bool          `json:"enable"`
+	At     time.Time     `json:"at"`
+	Every  time.Duration `json:"every"`
+}
+
+// AllowList restricts the requests that are forwarded to the instances of a
+// service. Requests with a method that isn't listed are rejected with 405,
+// requests to a path that doesn't match any of the path globs with 404. An
+// empty list of methods or paths allows all methods or paths, respectively.
+//
+// Path globs use the syntax of path.Match. Additionally, a glob ending with
+// `/**` matches all paths below the preceding path.
+type AllowList struct {
+	Methods []string `json:"methods"`
+	Paths   []string `json:"paths"`
+}
+
+// AllowsMethod indicates whether the given request method is allowed.
+func (a AllowList) AllowsMethod(method string) bool {
+	if len(a.Methods) == 0 {
+		return true
+	}
+
+	for _, m := range a.Methods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// AllowsPath indicates whether the given request path is allowed.
+func (a AllowList) AllowsPath(requestPath string) bool {
+	if len(a.Paths) == 0 {
+		return true
+	}
+
+	for _, p := range a.Paths {
+		if strings.HasSuffix(p, "/**") {
+			prefix := strings.TrimSuffix(p, "/**")
+			if requestPath == prefix || strings.HasPrefix(requestPath, prefix+"/") {
+				return true
+			}
+			continue
+		}
+
+		if matched, _ := path.Match(p, requestPath); matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// BasicAuth holds the credentials required for accessing a service. Only a
+// salted SHA-256 hash of the password is stored, never the password itself.
+// Basic auth is disabled as long as no username is set.
+type BasicAuth struct {
+	Username     string `json:"username"`
+	Salt         string `json:"salt"`
+	PasswordHash string `json:"password_hash"`
+}
+
+// NewBasicAuth creates a new BasicAuth instance for the given credentials,
+// hashing the password with a randomly generated salt.
+func NewBasicAuth(username, password string) (BasicAuth, error) {
+	salt := make([]byte, 16)
+
+	if _, err := rand.Read(salt); err != nil {
+		return BasicAuth{}, err
+	}
+
+	b := BasicAuth{
+		Username:     username,
+		Salt:         hex.EncodeToString(salt),
+		PasswordHash: hashPassword(salt, password),
+	}
+
+	return b, nil
+}
+
+// IsEnabled indicates whether basic auth is enforced for the service.
+func (b BasicAuth) IsEnabled() bool {
+	return b.Username != ""
+}
+
+// Verify checks if the given credentials match the stored credentials. The
+// comparison takes constant time regardless of where the hashes differ.
+func (b BasicAuth) Verify(username, password string) bool {
+	salt, err := hex.DecodeString(b.Salt)
+	if err != nil {
+		return false
+	}
+
+	usernameOK := subtle.ConstantTimeCompare([]byte(username), []byte(b.Username)) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(hashPassword(salt, password)), []byte(b.PasswordHash)) == 1
+
+	return usernameOK && passwordOK
+}
+
+// hashPassword returns the hex-encoded SHA-256 hash of the salted password.
+func hashPassword(salt []byte, password string) string {
+	hash := sha256.Sum256(append(append([]byte{}, salt...), password...))
+	return hex.EncodeToString(hash[:])
 }
 
 // NewService creates a new Service instance. It doesn't guarantee uniqueness.
 func NewService(name string, options types.ServiceCreateOptions) (*Service, error) {
-	uuid, err := generateEntityID()
+	uuid, err := generateEntityID(options.IDKey)
 	if err != nil {
 		return nil, err
 	}
 
-	urls := strings.Split(options.URLs, ",")
+	urls := make([]string, 0)
 
-	for _, u := range urls {
-		u = strings.Trim(u, " ")
+	// Empty URLs are skipped, so that a service created without any URL
+	// doesn't register an empty route.
+	for _, u := range strings.Split(options.URLs, ",") {
+		if u = strings.Trim(u, " "); u != "" {
+			urls = append(urls, u)
+		}
 	}
 
 	s := Service{
@@ -0,0 +1,151 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package event provides an in-memory timeline of state changes of entities.
+package event
+
+import (
+	"sync"
+	"time"
+)
+
+// Type describes a state change of an entity.
+type Type string
+
+const (
+	AttachedEvent Type = "attached"
+	DetachedEvent Type = "detached"
+	DeadEvent     Type = "marked_dead"
+	AliveEvent    Type = "marked_alive"
+)
+
+// Event represents a single state change of an entity.
+type Event struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      Type      `json:"type"`
+}
+
+// Log keeps the most recent events for each entity, keyed by entity ID. In
+// contrast to the audit log, the events aren't persisted. They're meant for
+// debugging flapping instances and nodes at runtime.
+//
+// The events of each entity are stored in a ring buffer that holds up to
+// retention events, so that the oldest event is dropped once the buffer is
+// full. Log is safe for concurrent use.
+type Log struct {
+	retention int
+	timelines map[string]*timeline
+	mutex     sync.Mutex
+}
+
+// timeline is a ring buffer of events. Once the buffer is full, next points
+// to the oldest event, which will be overwritten by the next event.
+type timeline struct {
+	events []Event
+	next   int
+}
+
+// NewLog creates a new Log instance that keeps up to retention events per
+// entity. A retention smaller than 1 is treated as 1.
+func NewLog(retention int) *Log {
+	l := Log{
+		retention: normalizeRetention(retention),
+		timelines: make(map[string]*timeline),
+	}
+
+	return &l
+}
+
+// Record appends an event of the given type to the timeline of an entity.
+// The timestamp is the current time.
+func (l *Log) Record(entityID string, eventType Type) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	t, ok := l.timelines[entityID]
+	if !ok {
+		t = &timeline{events: make([]Event, 0, l.retention)}
+		l.timelines[entityID] = t
+	}
+
+	t.append(Event{Timestamp: time.Now(), Type: eventType}, l.retention)
+}
+
+// Events returns all events of an entity, the latest event coming last.
+func (l *Log) Events(entityID string) []Event {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	t, ok := l.timelines[entityID]
+	if !ok {
+		return []Event{}
+	}
+
+	return t.ordered()
+}
+
+// Remove deletes all events of an entity, for example after the entity has
+// been removed itself.
+func (l *Log) Remove(entityID string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	delete(l.timelines, entityID)
+}
+
+// SetRetention changes the number of events kept per entity. If it has been
+// reduced, the oldest events exceeding the new retention are dropped.
+func (l *Log) SetRetention(retention int) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.retention = normalizeRetention(retention)
+
+	for id, t := range l.timelines {
+		events := t.ordered()
+		if len(events) > l.retention {
+			events = events[len(events)-l.retention:]
+		}
+		l.timelines[id] = &timeline{events: events}
+	}
+}
+
+// append adds an event to the timeline, overwriting the oldest event if the
+// timeline already holds retention events.
+func (t *timeline) append(e Event, retention int) {
+	if len(t.events) < retention {
+		t.events = append(t.events, e)
+		return
+	}
+
+	t.events[t.next] = e
+	t.next = (t.next + 1) % len(t.events)
+}
+
+// ordered returns a copy of all events in the order they've been recorded.
+func (t *timeline) ordered() []Event {
+	events := make([]Event, 0, len(t.events))
+	events = append(events, t.events[t.next:]...)
+	events = append(events, t.events[:t.next]...)
+
+	return events
+}
+
+// normalizeRetention returns the given retention, but at least 1.
+func normalizeRetention(retention int) int {
+	if retention < 1 {
+		return 1
+	}
+	return retention
+}
@@ -0,0 +1,81 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package event provides an in-memory timeline of state changes of entities.
+package event
+
+import (
+	"testing"
+)
+
+// TestLog_Record_retention checks if the oldest events are dropped once the
+// retention has been reached and if the events are returned in order.
+func TestLog_Record_retention(t *testing.T) {
+	l := NewLog(3)
+
+	types := []Type{AttachedEvent, DeadEvent, AliveEvent, DetachedEvent, AttachedEvent}
+
+	for _, eventType := range types {
+		l.Record("i1", eventType)
+	}
+
+	events := l.Events("i1")
+	expected := types[2:]
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+
+	for i, e := range events {
+		if e.Type != expected[i] {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], e.Type)
+		}
+	}
+
+	if events := l.Events("i2"); len(events) != 0 {
+		t.Errorf("expected no events for an unknown entity, got %v", events)
+	}
+}
+
+// TestLog_SetRetention checks if reducing the retention drops the oldest
+// events and if new events are appended correctly afterwards.
+func TestLog_SetRetention(t *testing.T) {
+	l := NewLog(4)
+
+	for _, eventType := range []Type{AttachedEvent, DeadEvent, AliveEvent, DetachedEvent, DeadEvent} {
+		l.Record("i1", eventType)
+	}
+
+	l.SetRetention(2)
+	l.Record("i1", AliveEvent)
+
+	events := l.Events("i1")
+	expected := []Type{DeadEvent, AliveEvent}
+
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d", len(expected), len(events))
+	}
+
+	for i, e := range events {
+		if e.Type != expected[i] {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], e.Type)
+		}
+	}
+
+	l.Remove("i1")
+
+	if events := l.Events("i1"); len(events) != 0 {
+		t.Errorf("expected no events after removing the entity, got %v", events)
+	}
+}
@@ -16,16 +16,29 @@
 package healthcheck
 
 import (
+	"context"
 	"errors"
-	"fmt"
 	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/event"
+	"github.com/dominikbraun/dice/log"
 	"github.com/dominikbraun/dice/registry"
+	"io/ioutil"
 	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
 )
 
+const (
+	// resolution is the interval in which RunPeriodically looks for services
+	// that are due for a health check.
+	resolution = time.Second
+)
+
 var (
 	ErrInvalidDeployments = errors.New("provided deployments are invalid")
+	ErrDeploymentNotFound = errors.New("deployment could not be found")
 )
 
 // Config concludes the user-configurable properties for health checks.
@@ -33,77 +46,414 @@ type Config struct {
 	Interval time.Duration `json:"interval"`
 	// When Timeout expires without response, an instance is considered dead.
 	Timeout time.Duration `json:"timeout"`
+	// Concurrency limits the number of instances that are pinged at once.
+	Concurrency int `json:"concurrency"`
+	// If Path is set, instances are probed with an HTTP request to that path
+	// instead of just establishing a TCP connection.
+	Path string `json:"path"`
+	// Identical state changes logged within LogWindow are collapsed into a
+	// single summary. 0 disables the suppression.
+	LogWindow time.Duration `json:"log_window"`
+	// A dead instance is marked as alive after HealthyThreshold consecutive
+	// successful probes, and an alive instance is marked as dead after
+	// UnhealthyThreshold consecutive failed probes. Values < 1 count as 1.
+	HealthyThreshold   int `json:"healthy_threshold"`
+	UnhealthyThreshold int `json:"unhealthy_threshold"`
+}
+
+// Result is the outcome of a health check for a single instance.
+type Result struct {
+	ServiceID  string
+	NodeID     string
+	InstanceID string
+	IsAlive    bool
 }
 
 // HealthCheck is a simple health checker that can run checks periodically as
 // well as manually. It will ping all instances of a provided service map and
 // mark each instance as dead or alive on each check.
+//
+// Services may override the global configuration with their own settings,
+// see entity.HealthCheck. These settings are read on each check.
+//
+// All checks are bound to the context passed by the caller as well as to the
+// health checker's own context, which gets cancelled by Stop. This way, all
+// in-flight probes are aborted as soon as the health checker is stopped.
+//
+// An instance only changes its alive state once the configured threshold of
+// consecutive probes with the opposite result has been reached. Until then,
+// the probe results are counted in a per-instance streak.
+//
+// If an event log has been set, each instance that is marked as dead or alive
+// after having had the opposite state is recorded in that log. These state
+// changes are logged as well, where repeated identical messages are collapsed
+// into periodic summaries, see logSuppressor.
 type HealthCheck struct {
-	config   Config
-	services *map[string]*registry.Service
-	stop     chan bool
+	config     Config
+	services   *registry.ServiceRegistry
+	ctx        context.Context
+	cancel     context.CancelFunc
+	probe      func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool
+	lastChecks map[string]time.Time
+	streaks    map[string]streak
+	events     *event.Log
+	logs       *logSuppressor
+	mutex      sync.Mutex
 }
 
 // New creates a new HealthCheck instance. It will take all service instances
-// from a service map into account.
-func New(config Config, services *map[string]*registry.Service) (*HealthCheck, error) {
+// from a service registry into account.
+func New(config Config, services *registry.ServiceRegistry) (*HealthCheck, error) {
 	if services == nil {
 		return nil, ErrInvalidDeployments
 	}
 
 	hc := HealthCheck{
-		config:   config,
-		services: services,
-		stop:     make(chan bool),
+		config:     config,
+		services:   services,
+		lastChecks: make(map[string]time.Time),
+		streaks:    make(map[string]streak),
+		logs:       newLogSuppressor(log.NewLogger(ioutil.Discard, log.ErrorLevel), config.LogWindow),
 	}
+	hc.ctx, hc.cancel = context.WithCancel(context.Background())
+	hc.probe = hc.pingInstance
 
 	return &hc, nil
 }
 
+// SetEventLog sets the event log that state changes of instances will be
+// recorded in. Passing `nil` disables recording.
+func (hc *HealthCheck) SetEventLog(events *event.Log) {
+	hc.mutex.Lock()
+	defer hc.mutex.Unlock()
+
+	hc.events = events
+}
+
+// SetLogger sets the logger used for reporting state changes of instances.
+func (hc *HealthCheck) SetLogger(logger log.Logger) {
+	hc.logs.setLogger(logger)
+}
+
 // RunPeriodically runs periodic health checks that will start every time the
 // configured interval expires. This function should run in an own goroutine.
-func (hc *HealthCheck) RunPeriodically() error {
-	intervalTick := time.NewTicker(hc.config.Interval)
+//
+// Since each service may have its own interval, RunPeriodically wakes up at a
+// fixed resolution and only checks the services whose interval has expired.
+// It returns as soon as ctx is cancelled or the health checker is stopped.
+func (hc *HealthCheck) RunPeriodically(ctx context.Context) error {
+	ctx, cancel := hc.bind(ctx)
+	defer cancel()
+
+	intervalTick := time.NewTicker(resolution)
+	defer intervalTick.Stop()
 
-healthcheck:
 	for {
 		select {
 		case <-intervalTick.C:
-			hc.checkServices()
-		case <-hc.stop:
-			break healthcheck
+			hc.checkServices(ctx, false)
+		case <-ctx.Done():
+			return nil
 		}
 	}
-
-	return nil
 }
 
 // RunManually triggers a manual, single health check. This function should be
 // called in an own goroutine as well, since the health check can take a while.
-func (hc *HealthCheck) RunManually() error {
-	hc.checkServices()
-	return nil
+// It returns the check result for each instance of all enabled services.
+//
+// If ctx is cancelled or the health checker is stopped before the check has
+// been finished, all pending probes are aborted and ctx.Err() is returned.
+// In that case, the alive states of the instances remain unchanged.
+func (hc *HealthCheck) RunManually(ctx context.Context) ([]Result, error) {
+	ctx, cancel := hc.bind(ctx)
+	defer cancel()
+
+	results := hc.checkServices(ctx, true)
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return results, nil
+}
+
+// CheckInstance immediately checks a single instance of a given service,
+// regardless of the service's interval. Just like a regular check, it marks
+// the instance as dead or alive once the threshold has been reached and
+// returns the probe result.
+func (hc *HealthCheck) CheckInstance(serviceID, instanceID string) (bool, error) {
+	hc.mutex.Lock()
+
+	service, ok := hc.services.Service(serviceID)
+	if !ok {
+		hc.mutex.Unlock()
+		return false, ErrDeploymentNotFound
+	}
+
+	var deployment registry.Deployment
+	var found bool
+
+	for _, d := range service.Deployments {
+		if d.Instance.ID == instanceID {
+			deployment, found = d, true
+			break
+		}
+	}
+
+	config := hc.serviceConfig(service.Entity)
+	hc.mutex.Unlock()
+
+	if !found {
+		return false, ErrDeploymentNotFound
+	}
+
+	alive := hc.probe(hc.ctx, deployment.Node, deployment.Instance, config)
+	hc.markInstance(deployment.Instance, alive, config)
+
+	return alive, nil
 }
 
 // checkServices loops over all services and their deployments. Each instance
-// will be pinged and marked as dead or alive after the timeout expires.
-func (hc *HealthCheck) checkServices() {
-	for _, s := range *hc.services {
-		if s.Entity.IsEnabled {
-			for _, d := range s.Deployments {
-				d.Instance.IsAlive = hc.pingInstance(d.Node, d.Instance)
-				// ToDo: If all instances are dead, check if the node is alive
+// will be pinged and marked as dead or alive after the timeout expires. Only
+// services whose interval has expired will be checked, unless all is set.
+//
+// The instances are pinged concurrently, so that a single check cycle takes
+// roughly as long as the timeout instead of a multiple of it. The results are
+// written back to the instances only after all pings have been finished.
+//
+// If ctx is cancelled during the check, the results are discarded because
+// aborted pings would mark alive instances as dead. The streaks of instances
+// that are no longer registered are removed.
+func (hc *HealthCheck) checkServices(ctx context.Context, all bool) []Result {
+	targets := make([]target, 0)
+	registered := make(map[string]bool)
+	now := time.Now()
+
+	hc.logs.flush()
+
+	hc.mutex.Lock()
+
+	for _, s := range hc.services.All() {
+		for _, d := range s.Deployments {
+			registered[d.Instance.ID] = true
+		}
+
+		if !s.Entity.IsEnabled {
+			continue
+		}
+
+		config := hc.serviceConfig(s.Entity)
+
+		if !all && now.Sub(hc.lastChecks[s.Entity.ID]) < config.Interval {
+			continue
+		}
+		hc.lastChecks[s.Entity.ID] = now
+
+		for _, d := range s.Deployments {
+			targets = append(targets, target{Deployment: d, config: config})
+		}
+		// ToDo: If all instances are dead, check if the node is alive
+	}
+
+	for instanceID := range hc.streaks {
+		if !registered[instanceID] {
+			delete(hc.streaks, instanceID)
+		}
+	}
+
+	hc.mutex.Unlock()
+
+	alive := hc.pingTargets(ctx, targets)
+
+	if ctx.Err() != nil {
+		return nil
+	}
+
+	results := make([]Result, len(targets))
+
+	for i, t := range targets {
+		hc.markInstance(t.Instance, alive[i], t.config)
+
+		results[i] = Result{
+			ServiceID:  t.Instance.ServiceID,
+			NodeID:     t.Node.ID,
+			InstanceID: t.Instance.ID,
+			IsAlive:    alive[i],
+		}
+	}
+
+	return results
+}
+
+// markInstance adds a probe result to the streak of an instance and marks the
+// instance as dead or alive if the streak has reached the threshold given by
+// config. If the alive state of the instance has changed, the change is logged
+// and recorded as an event.
+func (hc *HealthCheck) markInstance(instance *entity.Instance, alive bool, config Config) {
+	hc.mutex.Lock()
+
+	s := hc.streaks[instance.ID]
+	if s.alive == alive {
+		s.count++
+	} else {
+		s = streak{alive: alive, count: 1}
+	}
+	hc.streaks[instance.ID] = s
+
+	events := hc.events
+	hc.mutex.Unlock()
+
+	threshold := config.UnhealthyThreshold
+	if alive {
+		threshold = config.HealthyThreshold
+	}
+
+	if s.count < threshold {
+		return
+	}
+
+	if instance.IsAlive != alive {
+		eventType := event.DeadEvent
+		if alive {
+			eventType = event.AliveEvent
+		}
+
+		if events != nil {
+			events.Record(instance.ID, eventType)
+		}
+
+		if alive {
+			hc.logs.logf(log.InfoLevel, "instance %s of service %s has been marked as alive", instance.ID, instance.ServiceID)
+		} else {
+			hc.logs.logf(log.WarnLevel, "instance %s of service %s has been marked as dead", instance.ID, instance.ServiceID)
+		}
+	}
+
+	instance.IsAlive = alive
+}
+
+// streak counts the consecutive probes of an instance with the same result.
+type streak struct {
+	alive bool
+	count int
+}
+
+// target is a deployment that is going to be pinged, together with the health
+// check configuration that applies to the deployment's service.
+type target struct {
+	registry.Deployment
+	config Config
+}
+
+// serviceConfig returns the health check configuration for a given service.
+// Any health check setting of the service overrides the global setting.
+func (hc *HealthCheck) serviceConfig(service *entity.Service) Config {
+	config := hc.config
+
+	if service.HealthCheck.Path != "" {
+		config.Path = service.HealthCheck.Path
+	}
+
+	if service.HealthCheck.Interval != 0 {
+		config.Interval = service.HealthCheck.Interval
+	}
+
+	if service.HealthCheck.Timeout != 0 {
+		config.Timeout = service.HealthCheck.Timeout
+	}
+
+	if service.HealthCheck.HealthyThreshold != 0 {
+		config.HealthyThreshold = service.HealthCheck.HealthyThreshold
+	}
+
+	if service.HealthCheck.UnhealthyThreshold != 0 {
+		config.UnhealthyThreshold = service.HealthCheck.UnhealthyThreshold
+	}
+
+	return config
+}
+
+// pingTargets pings the instances of all given targets using a pool of
+// workers. The number of workers is limited by the configured concurrency
+// in order to prevent too many simultaneous connection attempts.
+//
+// The returned slice holds the ping result for each target at the same
+// index, so each worker only writes to the indices it has been given. Once
+// ctx has been cancelled, the remaining targets won't be pinged anymore.
+func (hc *HealthCheck) pingTargets(ctx context.Context, targets []target) []bool {
+	results := make([]bool, len(targets))
+
+	workers := hc.config.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(targets) {
+		workers = len(targets)
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				results[i] = hc.probe(ctx, targets[i].Node, targets[i].Instance, targets[i].config)
 			}
+		}()
+	}
+
+jobs:
+	for i := range targets {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break jobs
 		}
 	}
+
+	close(jobs)
+	wg.Wait()
+
+	return results
 }
 
 // pingInstance reads the address from an instance and attempts to establish a
-// connection to that address. The dialer will use the configured timeout.
-func (hc *HealthCheck) pingInstance(node *entity.Node, instance *entity.Instance) bool {
-	address := fmt.Sprintf("%s:%v", node.Name, instance.URL)
+// connection to that address. The dialer will use the configured timeout and
+// gives up as soon as ctx is cancelled. The address is read from the URL of
+// the instance just like the proxy does, see entity.SplitURL.
+//
+// If a path is configured, an HTTP GET request will be sent to that path and
+// the instance is only considered alive if it responds with a status < 400.
+// Like proxied requests, the path is relative to the base path of the URL.
+func (hc *HealthCheck) pingInstance(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+	address, basePath := entity.SplitURL(instance.URL)
+
+	if config.Path != "" {
+		client := http.Client{Timeout: config.Timeout}
+		path := strings.TrimRight(basePath, "/") + "/" + strings.TrimLeft(config.Path, "/")
+
+		request, err := http.NewRequest(http.MethodGet, "http://"+address+path, nil)
+		if err != nil {
+			return false
+		}
+
+		response, err := client.Do(request.WithContext(ctx))
+		if err != nil {
+			return false
+		}
+
+		_ = response.Body.Close()
+		return response.StatusCode < http.StatusBadRequest
+	}
 
-	conn, err := net.DialTimeout("tcp", address, hc.config.Timeout)
+	dialer := net.Dialer{Timeout: config.Timeout}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	if err != nil {
 		return false
 	}
@@ -112,8 +462,26 @@ func (hc *HealthCheck) pingInstance(node *entity.Node, instance *entity.Instance
 	return true
 }
 
-// Stop gracefully stops an health check. Running checks will not be affected.
+// bind derives a context from ctx that is cancelled as well when the health
+// checker is stopped. The returned cancel function has to be called in order
+// to release the associated resources.
+func (hc *HealthCheck) bind(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		select {
+		case <-hc.ctx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
+
+// Stop stops the health checker. Periodic checks won't be started anymore and
+// all running checks are aborted. Stop may be called multiple times.
 func (hc *HealthCheck) Stop() error {
-	hc.stop <- true
+	hc.cancel()
 	return nil
 }
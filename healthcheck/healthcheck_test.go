@@ -0,0 +1,459 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck provides types and methods for periodic health checks.
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/event"
+	"github.com/dominikbraun/dice/registry"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestRegistry creates a service registry that contains the given
+// services.
+func newTestRegistry(services map[string]*registry.Service) *registry.ServiceRegistry {
+	serviceRegistry := registry.NewServiceRegistry(nil)
+	serviceRegistry.Services = services
+
+	return serviceRegistry
+}
+
+// TestHealthCheck_checkServices tests HealthCheck.checkServices. It sets up
+// a service with many instances that all take the entire timeout to respond.
+// Since the instances are pinged concurrently, the check cycle is expected
+// to take roughly as long as one timeout instead of a multiple of it.
+func TestHealthCheck_checkServices(t *testing.T) {
+	const instanceCount = 50
+	const timeout = 100 * time.Millisecond
+
+	node := &entity.Node{ID: "n1", IsAttached: true, IsAlive: true}
+	service := &registry.Service{
+		Entity: &entity.Service{ID: "s1", IsEnabled: true},
+	}
+
+	for i := 0; i < instanceCount; i++ {
+		instance := &entity.Instance{ID: fmt.Sprintf("i%d", i), IsAttached: true}
+		service.Deployments = append(service.Deployments, registry.Deployment{Node: node, Instance: instance})
+	}
+
+	services := map[string]*registry.Service{"s1": service}
+
+	hc, err := New(Config{Timeout: timeout, Concurrency: instanceCount}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.probe = func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+		time.Sleep(timeout)
+		return true
+	}
+
+	start := time.Now()
+	hc.checkServices(context.Background(), true)
+	elapsed := time.Since(start)
+
+	if elapsed > 3*timeout {
+		t.Errorf("check cycle took %v, expected roughly %v", elapsed, timeout)
+	}
+
+	for _, d := range service.Deployments {
+		if !d.Instance.IsAlive {
+			t.Errorf("instance %s is dead, expected it to be alive", d.Instance.ID)
+		}
+	}
+}
+
+// TestHealthCheck_pingInstance_address tests if HealthCheck.pingInstance
+// probes the address from the instance URL like the proxy does, regardless
+// of the node name. The probe path has to be relative to the base path.
+func TestHealthCheck_pingInstance_address(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/app/ready" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer upstream.Close()
+
+	hc, err := New(Config{}, newTestRegistry(map[string]*registry.Service{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	node := &entity.Node{ID: "n1", Name: "n1"}
+
+	tests := []struct {
+		url      string
+		path     string
+		expected bool
+	}{
+		{url: upstream.Listener.Addr().String(), path: "", expected: true},
+		{url: "//" + upstream.Listener.Addr().String() + "/app", path: "", expected: true},
+		{url: upstream.Listener.Addr().String() + "/app", path: "/ready", expected: true},
+		{url: upstream.Listener.Addr().String() + "/app/", path: "ready", expected: true},
+		{url: upstream.Listener.Addr().String(), path: "/ready", expected: false},
+	}
+
+	for _, test := range tests {
+		instance := &entity.Instance{ID: "i1", URL: test.url}
+		config := Config{Timeout: time.Second, Path: test.path}
+
+		if alive := hc.pingInstance(context.Background(), node, instance, config); alive != test.expected {
+			t.Errorf("URL %s with path %q: got alive %v, expected %v", test.url, test.path, alive, test.expected)
+		}
+	}
+}
+
+// TestHealthCheck_serviceConfig tests the usage of service-specific health
+// check settings. Two services use different probe paths on the same stub
+// upstream, which only responds successfully to one of these paths.
+func TestHealthCheck_serviceConfig(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ready" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer upstream.Close()
+
+	address := upstream.Listener.Addr().String()
+
+	node := &entity.Node{ID: "n1", Name: "127.0.0.1", IsAttached: true}
+	instance1 := &entity.Instance{ID: "i1", ServiceID: "s1", URL: address, IsAttached: true}
+	instance2 := &entity.Instance{ID: "i2", ServiceID: "s2", URL: address, IsAttached: true}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity:      &entity.Service{ID: "s1", IsEnabled: true, HealthCheck: entity.HealthCheck{Path: "/ready"}},
+			Deployments: []registry.Deployment{{Node: node, Instance: instance1}},
+		},
+		"s2": {
+			Entity:      &entity.Service{ID: "s2", IsEnabled: true, HealthCheck: entity.HealthCheck{Path: "/health"}},
+			Deployments: []registry.Deployment{{Node: node, Instance: instance2}},
+		},
+	}
+
+	hc, err := New(Config{Timeout: time.Second, Concurrency: 2}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := hc.RunManually(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !instance1.IsAlive {
+		t.Errorf("instance %s is dead, expected it to be alive", instance1.ID)
+	}
+
+	if instance2.IsAlive {
+		t.Errorf("instance %s is alive, expected it to be dead", instance2.ID)
+	}
+}
+
+// TestHealthCheck_CheckInstance tests HealthCheck.CheckInstance. Only the
+// requested instance may be probed and marked as alive, and an unknown
+// instance has to result in ErrDeploymentNotFound.
+func TestHealthCheck_CheckInstance(t *testing.T) {
+	node := &entity.Node{ID: "n1", IsAttached: true, IsAlive: true}
+	instance1 := &entity.Instance{ID: "i1", IsAttached: true}
+	instance2 := &entity.Instance{ID: "i2", IsAttached: true}
+
+	service := &registry.Service{
+		Entity: &entity.Service{ID: "s1", IsEnabled: true},
+		Deployments: []registry.Deployment{
+			{Node: node, Instance: instance1},
+			{Node: node, Instance: instance2},
+		},
+	}
+
+	services := map[string]*registry.Service{"s1": service}
+
+	hc, err := New(Config{}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.probe = func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+		return true
+	}
+
+	if alive, err := hc.CheckInstance("s1", "i2"); err != nil || !alive {
+		t.Errorf("got %v and error %v, expected instance i2 to be alive", alive, err)
+	}
+
+	if instance1.IsAlive || !instance2.IsAlive {
+		t.Errorf("expected only instance i2 to be marked as alive")
+	}
+
+	if _, err := hc.CheckInstance("s1", "i3"); err != ErrDeploymentNotFound {
+		t.Errorf("got error %v, expected %v", err, ErrDeploymentNotFound)
+	}
+}
+
+// TestHealthCheck_CheckInstance_events tests if an instance that changes its
+// alive state is recorded in the event log, while an unchanged state isn't.
+func TestHealthCheck_CheckInstance_events(t *testing.T) {
+	node := &entity.Node{ID: "n1", IsAttached: true, IsAlive: true}
+	instance := &entity.Instance{ID: "i1", IsAttached: true}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity:      &entity.Service{ID: "s1", IsEnabled: true},
+			Deployments: []registry.Deployment{{Node: node, Instance: instance}},
+		},
+	}
+
+	hc, err := New(Config{}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := event.NewLog(10)
+	hc.SetEventLog(events)
+
+	states := []bool{true, true, false}
+
+	for _, alive := range states {
+		hc.probe = func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+			return alive
+		}
+		if _, err := hc.CheckInstance("s1", "i1"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	recorded := events.Events("i1")
+	expected := []event.Type{event.AliveEvent, event.DeadEvent}
+
+	if len(recorded) != len(expected) {
+		t.Fatalf("expected %d events, got %v", len(expected), recorded)
+	}
+
+	for i, e := range recorded {
+		if e.Type != expected[i] {
+			t.Errorf("expected event %d to be %s, got %s", i, expected[i], e.Type)
+		}
+	}
+}
+
+// TestHealthCheck_CheckInstance_threshold tests the healthy and unhealthy
+// thresholds of a service. The instance may only change its alive state
+// after the respective number of consecutive probes with the same result.
+func TestHealthCheck_CheckInstance_threshold(t *testing.T) {
+	node := &entity.Node{ID: "n1", IsAttached: true, IsAlive: true}
+	instance := &entity.Instance{ID: "i1", IsAttached: true}
+
+	healthCheck := entity.HealthCheck{HealthyThreshold: 2, UnhealthyThreshold: 3}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity:      &entity.Service{ID: "s1", IsEnabled: true, HealthCheck: healthCheck},
+			Deployments: []registry.Deployment{{Node: node, Instance: instance}},
+		},
+	}
+
+	hc, err := New(Config{HealthyThreshold: 1, UnhealthyThreshold: 1}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		probe   bool
+		isAlive bool
+	}{
+		{true, false},
+		{true, true},
+		{false, true},
+		{false, true},
+		{true, true},
+		{false, true},
+		{false, true},
+		{false, false},
+	}
+
+	for i, step := range steps {
+		probe := step.probe
+		hc.probe = func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+			return probe
+		}
+
+		if _, err := hc.CheckInstance("s1", "i1"); err != nil {
+			t.Fatal(err)
+		}
+
+		if instance.IsAlive != step.isAlive {
+			t.Errorf("step %d: got alive state %v, expected %v", i, instance.IsAlive, step.isAlive)
+		}
+	}
+}
+
+// TestHealthCheck_checkServices_pruneStreaks tests that the streak of an
+// instance is removed by the next check once the instance has been removed
+// from the registry, while the streaks of registered instances are kept.
+func TestHealthCheck_checkServices_pruneStreaks(t *testing.T) {
+	node := &entity.Node{ID: "n1", IsAttached: true, IsAlive: true}
+	kept := &entity.Instance{ID: "i1", IsAttached: true}
+	removed := &entity.Instance{ID: "i2", IsAttached: true}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity: &entity.Service{ID: "s1", IsEnabled: true},
+			Deployments: []registry.Deployment{
+				{Node: node, Instance: kept},
+				{Node: node, Instance: removed},
+			},
+		},
+	}
+
+	serviceRegistry := newTestRegistry(services)
+
+	hc, err := New(Config{HealthyThreshold: 3, UnhealthyThreshold: 3}, serviceRegistry)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.probe = func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+		return true
+	}
+
+	hc.checkServices(context.Background(), true)
+
+	if len(hc.streaks) != 2 {
+		t.Fatalf("expected 2 streaks, got %d", len(hc.streaks))
+	}
+
+	if err := serviceRegistry.Update(func(s *registry.Service) error {
+		s.Deployments = s.Deployments[:1]
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	hc.checkServices(context.Background(), true)
+
+	if _, ok := hc.streaks[removed.ID]; ok {
+		t.Errorf("expected streak of instance %s to be removed", removed.ID)
+	}
+
+	if s := hc.streaks[kept.ID]; s.count != 2 {
+		t.Errorf("expected streak of instance %s to count 2 probes, got %d", kept.ID, s.count)
+	}
+}
+
+// TestHealthCheck_RunManually_cancel tests that cancelling the context of a
+// manual health check aborts all pending probes. The stub upstream doesn't
+// respond before the probe timeout, so the check has to return as soon as
+// the context is cancelled and must leave the alive state untouched.
+func TestHealthCheck_RunManually_cancel(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer upstream.Close()
+
+	address := upstream.Listener.Addr().String()
+
+	node := &entity.Node{ID: "n1", Name: "127.0.0.1", IsAttached: true}
+	instance := &entity.Instance{ID: "i1", ServiceID: "s1", URL: address, IsAttached: true, IsAlive: true}
+
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity:      &entity.Service{ID: "s1", IsEnabled: true, HealthCheck: entity.HealthCheck{Path: "/health"}},
+			Deployments: []registry.Deployment{{Node: node, Instance: instance}},
+		},
+	}
+
+	hc, err := New(Config{Timeout: time.Minute, Concurrency: 1}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err = hc.RunManually(ctx)
+	elapsed := time.Since(start)
+
+	if err != context.DeadlineExceeded {
+		t.Errorf("got error %v, expected %v", err, context.DeadlineExceeded)
+	}
+
+	if elapsed > 5*time.Second {
+		t.Errorf("check took %v, expected it to be aborted after the context deadline", elapsed)
+	}
+
+	if !instance.IsAlive {
+		t.Errorf("instance %s has been marked as dead by an aborted check", instance.ID)
+	}
+}
+
+// TestHealthCheck_Stop tests that stopping the health checker returns from
+// RunPeriodically and aborts manual checks that are still running.
+func TestHealthCheck_Stop(t *testing.T) {
+	node := &entity.Node{ID: "n1", IsAttached: true}
+	services := map[string]*registry.Service{
+		"s1": {
+			Entity:      &entity.Service{ID: "s1", IsEnabled: true},
+			Deployments: []registry.Deployment{{Node: node, Instance: &entity.Instance{ID: "i1"}}},
+		},
+	}
+
+	hc, err := New(Config{Concurrency: 1}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hc.probe = func(ctx context.Context, node *entity.Node, instance *entity.Instance, config Config) bool {
+		<-ctx.Done()
+		return false
+	}
+
+	periodic := make(chan error, 1)
+	manual := make(chan error, 1)
+
+	go func() { periodic <- hc.RunPeriodically(context.Background()) }()
+	go func() {
+		_, err := hc.RunManually(context.Background())
+		manual <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+
+	if err := hc.Stop(); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, ch := range map[string]chan error{"RunPeriodically": periodic, "RunManually": manual} {
+		select {
+		case err := <-ch:
+			if name == "RunManually" && err != context.Canceled {
+				t.Errorf("%s: got error %v, expected %v", name, err, context.Canceled)
+			}
+		case <-time.After(5 * time.Second):
+			t.Errorf("%s didn't return after stopping the health checker", name)
+		}
+	}
+}
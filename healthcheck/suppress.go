@@ -0,0 +1,133 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck provides types and methods for periodic health checks.
+package healthcheck
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/log"
+	"sync"
+	"time"
+)
+
+// logSuppressor writes log messages while collapsing repeated identical
+// messages, so that a flapping or permanently dead instance doesn't flood
+// the log.
+//
+// The first occurrence of a message is logged immediately. Any further
+// occurrences within the window are only counted. Once the window expired,
+// a summary including the number of suppressed occurrences is logged, see
+// flush. A window of 0 disables the suppression.
+type logSuppressor struct {
+	logger   log.Logger
+	window   time.Duration
+	now      func() time.Time
+	messages map[string]*suppressedMessage
+	mutex    sync.Mutex
+}
+
+// suppressedMessage is a message that has been logged at since. Count is
+// the number of occurrences that have been suppressed after that.
+type suppressedMessage struct {
+	level log.Level
+	since time.Time
+	count int
+}
+
+// newLogSuppressor creates a new logSuppressor that writes to logger.
+func newLogSuppressor(logger log.Logger, window time.Duration) *logSuppressor {
+	s := logSuppressor{
+		logger:   logger,
+		window:   window,
+		now:      time.Now,
+		messages: make(map[string]*suppressedMessage),
+	}
+
+	return &s
+}
+
+// setLogger replaces the logger that messages are written to.
+func (s *logSuppressor) setLogger(logger log.Logger) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.logger = logger
+}
+
+// logf formats and logs a message with the given level, unless the same
+// message has been logged within the window.
+func (s *logSuppressor) logf(level log.Level, format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.window <= 0 {
+		s.write(level, message)
+		return
+	}
+
+	now := s.now()
+
+	if m, ok := s.messages[message]; ok {
+		if now.Sub(m.since) < s.window {
+			m.count++
+			return
+		}
+		s.summarize(message, m)
+		delete(s.messages, message)
+	}
+
+	s.write(level, message)
+	s.messages[message] = &suppressedMessage{level: level, since: now}
+}
+
+// flush logs a summary for each message whose window has expired and whose
+// occurrences have been suppressed. These messages are forgotten, so that
+// their next occurrence will be logged immediately again.
+func (s *logSuppressor) flush() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := s.now()
+
+	for message, m := range s.messages {
+		if now.Sub(m.since) >= s.window {
+			s.summarize(message, m)
+			delete(s.messages, message)
+		}
+	}
+}
+
+// summarize logs the number of suppressed occurrences of a message, if any.
+func (s *logSuppressor) summarize(message string, m *suppressedMessage) {
+	if m.count > 0 {
+		s.write(m.level, fmt.Sprintf("%s (repeated %d times in the last %v)", message, m.count, s.window))
+	}
+}
+
+// write logs a message with the given level.
+func (s *logSuppressor) write(level log.Level, message string) {
+	switch level {
+	case log.DebugLevel:
+		s.logger.Debug(message)
+	case log.InfoLevel:
+		s.logger.Info(message)
+	case log.WarnLevel:
+		s.logger.Warn(message)
+	default:
+		s.logger.Error(message)
+	}
+}
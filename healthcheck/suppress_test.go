@@ -0,0 +1,96 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package healthcheck provides types and methods for periodic health checks.
+package healthcheck
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/registry"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+// recordingLogger is a log.Logger that records all info and warning messages.
+type recordingLogger struct {
+	log.Logger
+	infos    []string
+	warnings []string
+}
+
+func (rl *recordingLogger) Info(args ...interface{}) {
+	rl.infos = append(rl.infos, fmt.Sprint(args...))
+}
+
+func (rl *recordingLogger) Warn(args ...interface{}) {
+	rl.warnings = append(rl.warnings, fmt.Sprint(args...))
+}
+
+// TestHealthCheck_markInstance_suppressLogs tests the log suppression for a
+// flapping instance. Only the first state change of each kind may be logged
+// within the window, and a summary of the suppressed messages has to be
+// logged once the window has expired.
+func TestHealthCheck_markInstance_suppressLogs(t *testing.T) {
+	services := make(map[string]*registry.Service)
+
+	hc, err := New(Config{LogWindow: time.Minute}, newTestRegistry(services))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordingLogger{Logger: log.NewLogger(ioutil.Discard, log.ErrorLevel)}
+	hc.SetLogger(logger)
+
+	now := time.Now()
+	hc.logs.now = func() time.Time { return now }
+
+	instance := &entity.Instance{ID: "i1", ServiceID: "s1", IsAlive: true}
+
+	for i := 0; i < 5; i++ {
+		hc.markInstance(instance, false, hc.config)
+		hc.markInstance(instance, true, hc.config)
+	}
+
+	if len(logger.warnings) != 1 || len(logger.infos) != 1 {
+		t.Fatalf("got %d warnings and %d infos, expected 1 each", len(logger.warnings), len(logger.infos))
+	}
+
+	now = now.Add(30 * time.Second)
+	hc.logs.flush()
+
+	if len(logger.warnings) != 1 {
+		t.Fatalf("got %d warnings before the window expired, expected 1", len(logger.warnings))
+	}
+
+	now = now.Add(30 * time.Second)
+	hc.logs.flush()
+
+	if len(logger.warnings) != 2 || !strings.Contains(logger.warnings[1], "repeated 4 times") {
+		t.Fatalf("got warnings %v, expected a summary of 4 suppressed messages", logger.warnings)
+	}
+
+	if len(logger.infos) != 2 || !strings.Contains(logger.infos[1], "repeated 4 times") {
+		t.Fatalf("got infos %v, expected a summary of 4 suppressed messages", logger.infos)
+	}
+
+	hc.markInstance(instance, false, hc.config)
+
+	if len(logger.warnings) != 3 || logger.warnings[2] != logger.warnings[0] {
+		t.Errorf("got warnings %v, expected the original message to be logged again", logger.warnings)
+	}
+}
@@ -28,6 +28,26 @@ const (
 	ErrorLevel Level = 3
 )
 
+// String returns the name of the level.
+func (l Level) String() string {
+	return l.logrusLevel().String()
+}
+
+// logrusLevel converts the level into the corresponding logrus level, whose
+// numeric values are in reverse order.
+func (l Level) logrusLevel() logrus.Level {
+	switch l {
+	case DebugLevel:
+		return logrus.DebugLevel
+	case InfoLevel:
+		return logrus.InfoLevel
+	case WarnLevel:
+		return logrus.WarnLevel
+	default:
+		return logrus.ErrorLevel
+	}
+}
+
 type Logger interface {
 	Debug(args ...interface{})
 	Debugf(format string, args ...interface{})
@@ -37,12 +57,41 @@ type Logger interface {
 	Warnf(format string, args ...interface{})
 	Error(args ...interface{})
 	Errorf(format string, args ...interface{})
+	// SetLevel changes the level of the logger at runtime. It is safe to
+	// call SetLevel while other goroutines are logging.
+	SetLevel(level Level)
+	// GetLevel returns the current level of the logger.
+	GetLevel() Level
+}
+
+// logger is a Logger backed by logrus.
+type logger struct {
+	*logrus.Logger
 }
 
 func NewLogger(output io.Writer, level Level) Logger {
 	l := logrus.New()
 	l.SetOutput(output)
-	l.SetLevel(logrus.Level(level))
+	l.SetLevel(level.logrusLevel())
+
+	return &logger{Logger: l}
+}
+
+// SetLevel implements Logger.SetLevel.
+func (l *logger) SetLevel(level Level) {
+	l.Logger.SetLevel(level.logrusLevel())
+}
 
-	return l
+// GetLevel implements Logger.GetLevel.
+func (l *logger) GetLevel() Level {
+	switch l.Logger.GetLevel() {
+	case logrus.DebugLevel, logrus.TraceLevel:
+		return DebugLevel
+	case logrus.InfoLevel:
+		return InfoLevel
+	case logrus.WarnLevel:
+		return WarnLevel
+	default:
+		return ErrorLevel
+	}
 }
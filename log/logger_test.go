@@ -0,0 +1,47 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// TestLogger_SetLevel tests Logger.SetLevel. It asserts that debug messages
+// are suppressed at the info level and written after raising the level to
+// debug at runtime.
+func TestLogger_SetLevel(t *testing.T) {
+	var output bytes.Buffer
+
+	logger := NewLogger(&output, InfoLevel)
+	logger.Debug("first debug message")
+
+	if output.Len() != 0 {
+		t.Fatalf("expected no output at info level, got %q", output.String())
+	}
+
+	logger.SetLevel(DebugLevel)
+
+	if level := logger.GetLevel(); level != DebugLevel {
+		t.Fatalf("expected level %s, got %s", DebugLevel, level)
+	}
+
+	logger.Debug("second debug message")
+
+	if !strings.Contains(output.String(), "second debug message") {
+		t.Errorf("expected debug message to be written, got %q", output.String())
+	}
+}
@@ -0,0 +1,134 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// followInterval is the interval in which Follow checks for new lines.
+const followInterval = 250 * time.Millisecond
+
+// Tail returns the last n lines of the file at the given path. If n is not
+// greater than 0, all lines will be returned.
+func Tail(path string, n int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(file)
+
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+
+		if n > 0 && len(lines) > n {
+			lines = lines[1:]
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return lines, nil
+}
+
+// Follow reads all lines that are appended to the file at the given path
+// and passes them to handle, starting at the current end of the file. It
+// blocks until the context is cancelled or handle returns an error.
+//
+// If the file gets rotated, meaning that it has been replaced or truncated,
+// Follow continues reading at the beginning of the new file.
+func Follow(ctx context.Context, path string, handle func(line string) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	offset, err := file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(file)
+	ticker := time.NewTicker(followInterval)
+	defer ticker.Stop()
+
+	// pending holds an incomplete line that has been read at the end of the
+	// file. It will be completed as soon as the rest of the line is written.
+	var pending string
+
+	for {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+
+		if err == nil {
+			if err := handle(strings.TrimSuffix(pending+line, "\n")); err != nil {
+				return err
+			}
+			pending = ""
+			continue
+		}
+
+		if err != io.EOF {
+			return err
+		}
+
+		pending += line
+
+		if isRotated(file, path, offset) {
+			rotated, err := os.Open(path)
+			if err == nil {
+				_ = file.Close()
+				file, offset, pending = rotated, 0, ""
+				reader.Reset(file)
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// isRotated checks if the file at the given path has been replaced by a new
+// file or if it has been truncated below the current read offset.
+func isRotated(file *os.File, path string, offset int64) bool {
+	current, err := file.Stat()
+	if err != nil {
+		return false
+	}
+
+	latest, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+
+	return !os.SameFile(current, latest) || latest.Size() < offset
+}
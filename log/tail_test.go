@@ -0,0 +1,46 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package log
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestTail tests Tail. It writes five lines into a logfile and asserts that
+// only the last three lines are returned when asking for three lines.
+func TestTail(t *testing.T) {
+	file, err := ioutil.TempFile("", "dice-tail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString("l1\nl2\nl3\nl4\nl5\n"); err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	lines, err := Tail(file.Name(), 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if result := strings.Join(lines, ","); result != "l3,l4,l5" {
+		t.Errorf("expected lines l3,l4,l5, got %s", result)
+	}
+}
@@ -0,0 +1,78 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/registry"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// accessLogger writes an access line for each request that has been
+// forwarded to an instance. Before writing a line, it checks the access log
+// settings of the service, see entity.AccessLog. Sampled requests are picked
+// randomly, so that 1 in N requests is logged on average.
+type accessLogger struct {
+	mutex  sync.Mutex
+	output io.Writer
+	random func(n int) int
+}
+
+// newAccessLogger creates a new accessLogger instance that discards all
+// lines until an output has been set.
+func newAccessLogger() *accessLogger {
+	a := accessLogger{
+		output: ioutil.Discard,
+		random: rand.Intn,
+	}
+
+	return &a
+}
+
+// setOutput replaces the writer that access lines are written to.
+func (a *accessLogger) setOutput(output io.Writer) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.output = output
+}
+
+// log writes an access line for the given request and its response status
+// unless the access log settings of the service prevent it.
+func (a *accessLogger) log(r *http.Request, service *registry.Service, status int, written int64, start time.Time) {
+	settings := service.Entity.AccessLog
+
+	if settings.IsDisabled {
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if settings.SampleRate > 1 && a.random(settings.SampleRate) != 0 {
+		return
+	}
+
+	_, _ = fmt.Fprintf(a.output, "%s %s [%s] \"%s %s %s\" %d %d %s\n",
+		r.RemoteAddr, service.Entity.Name, start.Format(time.RFC3339), r.Method, r.URL.RequestURI(),
+		r.Proto, status, written, time.Since(start))
+}
@@ -0,0 +1,79 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"net/http"
+	"strings"
+)
+
+// applyCORS applies the CORS configuration of a service to a request. If
+// the request is a preflight request from an allowed origin, it is answered
+// directly and applyCORS returns true. In this case, the request must not
+// be forwarded to an instance. For all other requests from an allowed
+// origin, the CORS headers are added to the response.
+//
+// Requests without an Origin header or from an origin that isn't allowed
+// remain untouched, leaving it to the browser to block the response.
+func applyCORS(w http.ResponseWriter, r *http.Request, cors entity.CORS) bool {
+	origin := r.Header.Get("Origin")
+
+	if origin == "" || !isOriginAllowed(cors, origin) {
+		return false
+	}
+
+	header := w.Header()
+
+	// The origin is always echoed instead of responding with `*`, since
+	// browsers reject `*` for requests with credentials.
+	header.Set("Access-Control-Allow-Origin", origin)
+	header.Add("Vary", "Origin")
+
+	if cors.AllowCredentials {
+		header.Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	isPreflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+
+	if !isPreflight {
+		return false
+	}
+
+	if len(cors.AllowedMethods) > 0 {
+		header.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+	}
+
+	if len(cors.AllowedHeaders) > 0 {
+		header.Set("Access-Control-Allow-Headers", strings.Join(cors.AllowedHeaders, ", "))
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// isOriginAllowed indicates whether the given origin is allowed by the CORS
+// configuration. A configured origin of `*` allows all origins.
+func isOriginAllowed(cors entity.CORS, origin string) bool {
+	for _, allowed := range cors.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,77 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"errors"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"net/http"
+)
+
+var (
+	ErrNoInstanceAvailable = errors.New("all instances have reached their connection limit or have been ejected")
+)
+
+// acquireInstance returns the instance a request should be forwarded to and
+// counts the request as in-flight for that instance. The caller has to call
+// stats.release once the request has been completed.
+//
+// If the instance returned by nextInstance has reached its connection limit
+// or has been ejected by the outlier detection, the next instance determined
+// by the scheduler is tried instead. Since the scheduler may return an
+// instance multiple times depending on node weights, it is asked at most
+// once per weight unit of all deployments. If none of those instances is
+// available, ErrNoInstanceAvailable will be returned.
+func (p *Proxy) acquireInstance(w http.ResponseWriter, r *http.Request, service *registry.Service) (*entity.Instance, error) {
+	instance, err := p.nextInstance(w, r, service)
+	if err != nil {
+		return nil, err
+	}
+
+	attempts := schedulingAttempts(service.Deployments)
+
+	for i := 0; ; i++ {
+		if !p.outliers.isEjected(instance.ID) && p.stats.acquire(instance.ID, instance.MaxConnections) {
+			return instance, nil
+		}
+
+		if i >= attempts {
+			return nil, ErrNoInstanceAvailable
+		}
+
+		if instance, err = service.Scheduler.Next(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// schedulingAttempts returns the number of times the scheduler may have to
+// be asked until it has returned each available instance at least once.
+func schedulingAttempts(deployments []registry.Deployment) int {
+	attempts := 0
+
+	for _, d := range deployments {
+		if d.Node != nil && d.Node.Weight > 1 {
+			attempts += int(d.Node.Weight)
+		} else {
+			attempts++
+		}
+	}
+
+	return attempts
+}
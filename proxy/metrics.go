@@ -0,0 +1,251 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ServiceMetrics holds aggregated metrics for all requests that have been
+// proxied to the instances of a single service. The latency of a request is
+// the time until the entire response has been sent to the client.
+//
+// The percentiles are estimated on the fly without storing the individual
+// latencies, see quantileEstimator. Just like InstanceStats, the metrics are
+// cumulative since the proxy has been started.
+type ServiceMetrics struct {
+	Requests   uint64        `json:"requests"`
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP95 time.Duration `json:"latency_p95"`
+	BytesIn    uint64        `json:"bytes_in"`
+	BytesOut   uint64        `json:"bytes_out"`
+}
+
+// serviceMetrics accumulates the ServiceMetrics for a single service.
+type serviceMetrics struct {
+	requests uint64
+	p50      *quantileEstimator
+	p95      *quantileEstimator
+	bytesIn  uint64
+	bytesOut uint64
+}
+
+// metricsRecorder accumulates ServiceMetrics for all services. It is safe
+// for concurrent use.
+type metricsRecorder struct {
+	services map[string]*serviceMetrics
+	mutex    sync.RWMutex
+}
+
+// newMetricsRecorder creates a new, empty metricsRecorder instance.
+func newMetricsRecorder() *metricsRecorder {
+	mr := metricsRecorder{
+		services: make(map[string]*serviceMetrics),
+	}
+
+	return &mr
+}
+
+// record adds a proxied request with the given latency and the number of
+// bytes received from the client and sent to the client to the metrics of
+// the service with the given ID.
+func (mr *metricsRecorder) record(serviceID string, latency time.Duration, bytesIn, bytesOut int64) {
+	mr.mutex.Lock()
+	defer mr.mutex.Unlock()
+
+	metrics, ok := mr.services[serviceID]
+	if !ok {
+		metrics = &serviceMetrics{
+			p50: newQuantileEstimator(0.5),
+			p95: newQuantileEstimator(0.95),
+		}
+		mr.services[serviceID] = metrics
+	}
+
+	metrics.requests++
+	metrics.p50.add(float64(latency))
+	metrics.p95.add(float64(latency))
+	metrics.bytesIn += uint64(bytesIn)
+	metrics.bytesOut += uint64(bytesOut)
+}
+
+// get returns the metrics of the service with the given ID. If no request
+// has been proxied to that service yet, empty metrics are returned.
+func (mr *metricsRecorder) get(serviceID string) ServiceMetrics {
+	mr.mutex.RLock()
+	defer mr.mutex.RUnlock()
+
+	metrics, ok := mr.services[serviceID]
+	if !ok {
+		return ServiceMetrics{}
+	}
+
+	return ServiceMetrics{
+		Requests:   metrics.requests,
+		LatencyP50: time.Duration(metrics.p50.value()),
+		LatencyP95: time.Duration(metrics.p95.value()),
+		BytesIn:    metrics.bytesIn,
+		BytesOut:   metrics.bytesOut,
+	}
+}
+
+// quantileEstimator estimates a single quantile of a stream of values using
+// the P² algorithm by Jain and Chlamtac. Instead of storing all values, it
+// maintains five markers whose heights approximate the minimum, the p/2-,
+// p- and (1+p)/2-quantiles and the maximum. The markers are adjusted using
+// a piecewise-parabolic interpolation each time a value is added.
+type quantileEstimator struct {
+	p     float64
+	count int
+	// heights are the marker heights. Until five values have been added,
+	// they hold the values themselves.
+	heights [5]float64
+	// positions are the actual marker positions, desired are the desired
+	// marker positions and increments are the increments of the desired
+	// positions for each added value.
+	positions  [5]float64
+	desired    [5]float64
+	increments [5]float64
+}
+
+// newQuantileEstimator creates a new quantileEstimator for the p-quantile,
+// where p has to be between 0 and 1.
+func newQuantileEstimator(p float64) *quantileEstimator {
+	qe := quantileEstimator{
+		p:          p,
+		positions:  [5]float64{1, 2, 3, 4, 5},
+		desired:    [5]float64{1, 1 + 2*p, 1 + 4*p, 3 + 2*p, 5},
+		increments: [5]float64{0, p / 2, p, (1 + p) / 2, 1},
+	}
+
+	return &qe
+}
+
+// add adds a value to the stream and adjusts the markers.
+func (qe *quantileEstimator) add(x float64) {
+	if qe.count < len(qe.heights) {
+		qe.heights[qe.count] = x
+		qe.count++
+
+		if qe.count == len(qe.heights) {
+			sort.Float64s(qe.heights[:])
+		}
+		return
+	}
+
+	qe.count++
+
+	// k is the index of the cell that contains x. The minimum and maximum
+	// markers are moved if x is outside of the current range.
+	var k int
+
+	switch {
+	case x < qe.heights[0]:
+		qe.heights[0] = x
+		k = 0
+	case x >= qe.heights[4]:
+		qe.heights[4] = x
+		k = 3
+	default:
+		for k = 0; k < 3; k++ {
+			if x < qe.heights[k+1] {
+				break
+			}
+		}
+	}
+
+	for i := k + 1; i < len(qe.positions); i++ {
+		qe.positions[i]++
+	}
+
+	for i := range qe.desired {
+		qe.desired[i] += qe.increments[i]
+	}
+
+	for i := 1; i <= 3; i++ {
+		d := qe.desired[i] - qe.positions[i]
+
+		if (d >= 1 && qe.positions[i+1]-qe.positions[i] > 1) || (d <= -1 && qe.positions[i-1]-qe.positions[i] < -1) {
+			s := 1.0
+			if d < 0 {
+				s = -1.0
+			}
+
+			height := qe.parabolic(i, s)
+
+			if qe.heights[i-1] >= height || height >= qe.heights[i+1] {
+				height = qe.linear(i, s)
+			}
+
+			qe.heights[i] = height
+			qe.positions[i] += s
+		}
+	}
+}
+
+// parabolic computes the new height of the marker at index i when moving it
+// by s using the piecewise-parabolic formula.
+func (qe *quantileEstimator) parabolic(i int, s float64) float64 {
+	h, n := qe.heights, qe.positions
+
+	return h[i] + s/(n[i+1]-n[i-1])*
+		((n[i]-n[i-1]+s)*(h[i+1]-h[i])/(n[i+1]-n[i])+
+			(n[i+1]-n[i]-s)*(h[i]-h[i-1])/(n[i]-n[i-1]))
+}
+
+// linear computes the new height of the marker at index i when moving it by
+// s using linear interpolation. It is used if the parabolic formula would
+// violate the order of the markers.
+func (qe *quantileEstimator) linear(i int, s float64) float64 {
+	j := i + int(s)
+	return qe.heights[i] + s*(qe.heights[j]-qe.heights[i])/(qe.positions[j]-qe.positions[i])
+}
+
+// value returns the current estimate of the quantile. As long as there are
+// less than five values, the quantile is determined from the values exactly.
+func (qe *quantileEstimator) value() float64 {
+	if qe.count == 0 {
+		return 0
+	}
+
+	if qe.count < len(qe.heights) {
+		values := make([]float64, qe.count)
+		copy(values, qe.heights[:qe.count])
+		sort.Float64s(values)
+
+		return values[int(qe.p*float64(qe.count-1)+0.5)]
+	}
+
+	return qe.heights[2]
+}
+
+// countingReader is an io.ReadCloser that counts the bytes read from the
+// underlying reader.
+type countingReader struct {
+	io.ReadCloser
+	count int64
+}
+
+// Read implements io.Reader.Read.
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.ReadCloser.Read(p)
+	cr.count += int64(n)
+	return n, err
+}
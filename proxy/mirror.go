@@ -0,0 +1,174 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// maxMirrorBodySize is the maximum size of a request body that will be
+	// buffered for mirroring. Requests with larger bodies aren't mirrored.
+	maxMirrorBodySize = 1 << 20
+	// maxConcurrentMirrors limits the number of mirrored requests that may
+	// be in flight at once. If the limit is reached, requests aren't
+	// mirrored until a mirrored request has been finished.
+	maxConcurrentMirrors = 256
+	// mirrorTimeout is the time a mirror instance has for responding.
+	mirrorTimeout = 30 * time.Second
+)
+
+// mirrorer implements traffic mirroring: It sends a copy of a fraction of
+// the requests for a service to an instance with the mirror version of the
+// service, see entity.Mirror. Mirrored requests are sent asynchronously and
+// their responses are discarded, so that they don't affect the client.
+//
+// Mirror instances don't have to be attached. Detached instances only get
+// mirrored requests, since they aren't selected by the scheduler. Among all
+// alive mirror instances, the instances are selected in turn.
+type mirrorer struct {
+	slots  chan struct{}
+	random func() float64
+	next   uint32
+}
+
+// newMirrorer creates a new mirrorer instance.
+func newMirrorer() *mirrorer {
+	m := mirrorer{
+		slots:  make(chan struct{}, maxConcurrentMirrors),
+		random: rand.Float64,
+	}
+
+	return &m
+}
+
+// mirror decides whether a request is mirrored according to the mirroring
+// settings of the service. If so, the request is sent to a mirror instance
+// other than the primary instance in its own goroutine.
+//
+// In order to send the request body twice, it is buffered and the body of
+// r is replaced with the buffer. Requests whose body exceeds the maximum
+// size for mirroring are forwarded to the primary instance only.
+func (m *mirrorer) mirror(transport http.RoundTripper, r *http.Request, service *registry.Service, primary *entity.Instance) {
+	settings := service.Entity.Mirror
+
+	if !settings.IsEnabled() || m.random() >= settings.Fraction {
+		return
+	}
+
+	target := m.selectInstance(service.Deployments, settings.Version, primary.ID)
+	if target == nil {
+		return
+	}
+
+	select {
+	case m.slots <- struct{}{}:
+	default:
+		return
+	}
+
+	body, ok := bufferBody(r)
+	if !ok {
+		<-m.slots
+		return
+	}
+
+	request, err := http.NewRequest(r.Method, backendURL(target.URL, r.URL), bytes.NewReader(body))
+	if err != nil {
+		<-m.slots
+		return
+	}
+
+	request.ContentLength = int64(len(body))
+	request.Host = r.Host
+	request.Header = r.Header.Clone()
+	removeHopHeaders(request.Header)
+
+	go func() {
+		defer func() { <-m.slots }()
+
+		ctx, cancel := context.WithTimeout(context.Background(), mirrorTimeout)
+		defer cancel()
+
+		response, err := transport.RoundTrip(request.WithContext(ctx))
+		if err != nil {
+			return
+		}
+
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		_ = response.Body.Close()
+	}()
+}
+
+// selectInstance returns the next alive instance with the given version
+// that isn't the excluded instance. If there is no such instance, nil will
+// be returned.
+func (m *mirrorer) selectInstance(deployments []registry.Deployment, version, excludedID string) *entity.Instance {
+	candidates := make([]*entity.Instance, 0)
+
+	for _, d := range deployments {
+		if d.Instance.Version == version && d.Instance.IsAlive && d.Instance.ID != excludedID {
+			candidates = append(candidates, d.Instance)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	next := atomic.AddUint32(&m.next, 1)
+	return candidates[int(next)%len(candidates)]
+}
+
+// bufferBody reads the entire body of r into memory and replaces the body
+// with the buffer, so that it can be read again. If the body is too large,
+// false will be returned and r still provides the entire body.
+func bufferBody(r *http.Request) ([]byte, bool) {
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, true
+	}
+
+	if r.ContentLength > maxMirrorBodySize {
+		return nil, false
+	}
+
+	original := r.Body
+	body, err := ioutil.ReadAll(io.LimitReader(original, maxMirrorBodySize+1))
+
+	if err != nil || len(body) > maxMirrorBodySize {
+		r.Body = readCloser{Reader: io.MultiReader(bytes.NewReader(body), original), Closer: original}
+		return nil, false
+	}
+
+	r.Body = readCloser{Reader: bytes.NewReader(body), Closer: original}
+	return body, true
+}
+
+// readCloser combines a reader with the closer of the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
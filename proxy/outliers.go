@@ -0,0 +1,116 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"sync"
+	"time"
+)
+
+const (
+	// maxEjectionFactor limits the factor the base ejection time of an
+	// instance is multiplied with for consecutive ejections.
+	maxEjectionFactor = 10
+)
+
+// outlierState holds the outlier detection state of a single instance.
+type outlierState struct {
+	failures     int
+	ejections    int
+	ejectedUntil time.Time
+}
+
+// outlierDetector implements passive health checking: It tracks consecutive
+// failed requests for each instance and ejects instances that exceed the
+// threshold of their service, see entity.Outliers. An ejected instance is
+// skipped by the proxy until its ejection time has passed, regardless of
+// the active health checks.
+//
+// Each consecutive ejection increases the ejection time by the base ejection
+// time, up to maxEjectionFactor times the base ejection time. A successful
+// request after the instance has been reintroduced resets this factor. The
+// outlierDetector is safe for concurrent use.
+type outlierDetector struct {
+	instances map[string]*outlierState
+	mutex     sync.Mutex
+	now       func() time.Time
+}
+
+// newOutlierDetector creates a new outlierDetector without any ejections.
+func newOutlierDetector() *outlierDetector {
+	od := outlierDetector{
+		instances: make(map[string]*outlierState),
+		now:       time.Now,
+	}
+
+	return &od
+}
+
+// record records the outcome of a request proxied to the instance with the
+// given ID and ejects the instance if it has failed too often in a row. If
+// outlier detection is disabled for the service, nothing is recorded.
+func (od *outlierDetector) record(instanceID string, failed bool, outliers entity.Outliers) {
+	if outliers.Threshold <= 0 {
+		return
+	}
+
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+
+	state, ok := od.instances[instanceID]
+	if !ok {
+		state = &outlierState{}
+		od.instances[instanceID] = state
+	}
+
+	now := od.now()
+
+	if !failed {
+		state.failures = 0
+		// Requests that have been started before the ejection may still
+		// succeed, so the factor is only reset after the ejection.
+		if !now.Before(state.ejectedUntil) {
+			state.ejections = 0
+		}
+		return
+	}
+
+	state.failures++
+
+	if state.failures < outliers.Threshold {
+		return
+	}
+
+	if state.ejections < maxEjectionFactor {
+		state.ejections++
+	}
+
+	state.failures = 0
+	state.ejectedUntil = now.Add(outliers.EjectionTime * time.Duration(state.ejections))
+}
+
+// isEjected indicates whether the instance with the given ID is currently
+// ejected from load balancing.
+func (od *outlierDetector) isEjected(instanceID string) bool {
+	od.mutex.Lock()
+	defer od.mutex.Unlock()
+
+	state, ok := od.instances[instanceID]
+
+	return ok && od.now().Before(state.ejectedUntil)
+}
@@ -18,64 +18,324 @@ package proxy
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/log"
 	"github.com/dominikbraun/dice/registry"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // Config concludes properties that are configurable by the user.
+//
+// The proxy listens on all Addresses. For compatibility, Address will be
+// used as an additional listen address if it is set. Zone is the zone the
+// proxy is running in, see scheduler.ZoneAware.
+//
+// WriteTimeout is the time a client has for accepting each chunk of the
+// response. If it is exceeded, the response is aborted so that a slow client
+// can't hold the upstream connection indefinitely. 0 disables the timeout.
+//
+// RetryAfter is sent in the Retry-After header if a service has no available
+// instance, so that clients back off before retrying. It is rounded up to
+// full seconds. 0 omits the header.
+//
+// Requests whose headers exceed MaxHeaderBytes are rejected with 431. If it
+// is 0, http.DefaultMaxHeaderBytes is used.
+//
+// DialTimeout is the time for establishing the TCP connection to an instance
+// and for the TLS handshake, each. It doesn't limit the time the instance may
+// take for responding, so that dead instances fail fast while slow responses
+// are still possible. If it is 0, the timeouts of http.DefaultTransport apply.
 type Config struct {
-	Address string `json:"address"`
-	Logfile string `json:"logfile"`
+	Address        string        `json:"address"`
+	Addresses      []string      `json:"addresses"`
+	Logfile        string        `json:"logfile"`
+	Zone           string        `json:"zone"`
+	WriteTimeout   time.Duration `json:"write_timeout"`
+	RetryAfter     time.Duration `json:"retry_after"`
+	MaxHeaderBytes int           `json:"max_header_bytes"`
+	DialTimeout    time.Duration `json:"dial_timeout"`
 }
 
+// connContextKey is the context key for the client connection of a request.
+type connContextKey struct{}
+
 // Proxy is a reverse proxy that accepts incoming requests for all services,
 // looks up the responsible service in the registry and proxies the request
 // for to an instance of that service.
 //
-// Proxy only uses read-only access on ServiceRegistry.
+// Proxy only uses read-only access on ServiceRegistry. Until the registry has
+// been initialized and the proxy has been marked as ready, all requests will
+// be answered with 503. The registry may be replaced at runtime, so that the
+// proxy keeps serving while Dice is reloaded.
 type Proxy struct {
-	config    Config
-	registry  *registry.ServiceRegistry
-	server    *http.Server
-	transport http.RoundTripper
+	config        Config
+	registry      *registry.ServiceRegistry
+	registryMutex sync.RWMutex
+	servers       []*http.Server
+	transport     http.RoundTripper
+	stats         *statsRecorder
+	metrics       *metricsRecorder
+	outliers      *outlierDetector
+	mirrors       *mirrorer
+	accessLog     *accessLogger
+	affinity      *affinityMap
+	logger        log.Logger
+	writeTimeout  int64
+	retryAfter    int64
+	ready         int32
 }
 
-// New creates a new Proxy instance and sets up a ready-to-go HTTP server.
+// New creates a new Proxy instance and sets up a ready-to-go HTTP server for
+// each configured listen address.
 func New(config Config, registry *registry.ServiceRegistry) *Proxy {
 	p := Proxy{
 		config:    config,
 		registry:  registry,
-		transport: http.DefaultTransport,
+		transport: newTransport(config.DialTimeout),
+		stats:     newStatsRecorder(),
+		metrics:   newMetricsRecorder(),
+		outliers:  newOutlierDetector(),
+		mirrors:   newMirrorer(),
+		accessLog: newAccessLogger(),
+		affinity:  newAffinityMap(),
+		logger:    log.NewLogger(ioutil.Discard, log.ErrorLevel),
 	}
 
-	p.server = &http.Server{
-		Addr:    p.config.Address,
-		Handler: p.handleRequest(),
+	p.setWriteTimeout(config.WriteTimeout)
+	p.setRetryAfter(config.RetryAfter)
+
+	handler := p.handleRequest()
+
+	for _, address := range p.config.addresses() {
+		p.servers = append(p.servers, &http.Server{
+			Addr:           address,
+			Handler:        handler,
+			ConnContext:    withConn,
+			MaxHeaderBytes: config.MaxHeaderBytes,
+		})
 	}
 
 	return &p
 }
 
-// Run starts the proxy, accepting incoming requests on the configured port.
+// newTransport creates the transport used for sending requests to instances.
+// If dialTimeout is greater than 0, it limits the time for establishing the
+// connection and for the TLS handshake, see Config.DialTimeout.
+func newTransport(dialTimeout time.Duration) http.RoundTripper {
+	if dialTimeout <= 0 {
+		return http.DefaultTransport
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	dialer := net.Dialer{
+		Timeout:   dialTimeout,
+		KeepAlive: 30 * time.Second,
+	}
+
+	transport.DialContext = dialer.DialContext
+	transport.TLSHandshakeTimeout = dialTimeout
+
+	return transport
+}
+
+// Run starts the proxy, accepting incoming requests on all configured
+// addresses. Run blocks until all servers have been stopped. If a server
+// fails, all other servers will be shut down as well and the errors are
+// combined into a single error.
 func (p *Proxy) Run() error {
-	err := p.server.ListenAndServe()
+	errs := make(chan error, len(p.servers))
+
+	for _, server := range p.servers {
+		go func(server *http.Server) {
+			err := server.ListenAndServe()
 
-	if err != nil && err != http.ErrServerClosed {
-		return err
+			if err != nil && err != http.ErrServerClosed {
+				errs <- fmt.Errorf("%s: %v", server.Addr, err)
+				_ = p.Shutdown()
+				return
+			}
+
+			errs <- nil
+		}(server)
 	}
 
-	return nil
+	return collectErrors(errs, len(p.servers))
 }
 
-// Shutdown attempts a graceful shutdown of the proxy server. It will wait
+// Shutdown attempts a graceful shutdown of all proxy servers. It will wait
 // for all open connections to finish and stops the proxy subsequently.
 func (p *Proxy) Shutdown() error {
-	err := p.server.Shutdown(context.Background())
-	_ = p.server.Close()
+	errs := make(chan error, len(p.servers))
+
+	for _, server := range p.servers {
+		err := server.Shutdown(context.Background())
+		_ = server.Close()
+
+		errs <- err
+	}
 
-	return err
+	return collectErrors(errs, len(p.servers))
+}
+
+// SetReady marks the proxy as ready or not ready for handling requests. It
+// should be set as soon as the service registry has been initialized.
+func (p *Proxy) SetReady(ready bool) {
+	var value int32
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&p.ready, value)
+}
+
+// IsReady indicates whether the proxy is ready for handling requests.
+func (p *Proxy) IsReady() bool {
+	return atomic.LoadInt32(&p.ready) == 1
+}
+
+// Reconfigure applies a new configuration to the running proxy. This is only
+// possible if the listen addresses, the maximum header size and the dial
+// timeout haven't changed, since the servers and the transport are kept. In
+// this case, Reconfigure returns true. Otherwise, the configuration isn't
+// applied and the proxy has to be replaced by a new one.
+func (p *Proxy) Reconfigure(config Config) bool {
+	current, next := p.config.addresses(), config.addresses()
+
+	if len(current) != len(next) || p.config.MaxHeaderBytes != config.MaxHeaderBytes ||
+		p.config.DialTimeout != config.DialTimeout {
+		return false
+	}
+
+	listening := make(map[string]bool, len(current))
+
+	for _, address := range current {
+		listening[address] = true
+	}
+
+	for _, address := range next {
+		if !listening[address] {
+			return false
+		}
+	}
+
+	p.config = config
+	p.setWriteTimeout(config.WriteTimeout)
+	p.setRetryAfter(config.RetryAfter)
+
+	return true
+}
+
+// withConn stores the client connection in the request context, so that
+// the write deadline can be set while streaming the response.
+func withConn(ctx context.Context, conn net.Conn) context.Context {
+	return context.WithValue(ctx, connContextKey{}, conn)
+}
+
+// SetLogger sets the logger used for reporting aborted responses.
+func (p *Proxy) SetLogger(logger log.Logger) {
+	p.logger = logger
+}
+
+// SetAccessLog sets the writer that access lines are written to. Until it
+// has been set, all access lines are discarded.
+func (p *Proxy) SetAccessLog(output io.Writer) {
+	p.accessLog.setOutput(output)
+}
+
+// setWriteTimeout stores the write timeout, which may be changed by
+// Reconfigure while requests are being processed.
+func (p *Proxy) setWriteTimeout(timeout time.Duration) {
+	atomic.StoreInt64(&p.writeTimeout, int64(timeout))
+}
+
+// getWriteTimeout returns the current write timeout, see Config.
+func (p *Proxy) getWriteTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.writeTimeout))
+}
+
+// setRetryAfter stores the Retry-After duration, which may be changed by
+// Reconfigure while requests are being processed.
+func (p *Proxy) setRetryAfter(retryAfter time.Duration) {
+	atomic.StoreInt64(&p.retryAfter, int64(retryAfter))
+}
+
+// getRetryAfter returns the current Retry-After duration, see Config.
+func (p *Proxy) getRetryAfter() time.Duration {
+	return time.Duration(atomic.LoadInt64(&p.retryAfter))
+}
+
+// SetRegistry replaces the service registry used for looking up services.
+// Requests that are already being processed aren't affected.
+func (p *Proxy) SetRegistry(registry *registry.ServiceRegistry) {
+	p.registryMutex.Lock()
+	defer p.registryMutex.Unlock()
+
+	p.registry = registry
+}
+
+// serviceRegistry returns the service registry currently used by the proxy.
+func (p *Proxy) serviceRegistry() *registry.ServiceRegistry {
+	p.registryMutex.RLock()
+	defer p.registryMutex.RUnlock()
+
+	return p.registry
+}
+
+// InstanceStats returns the stats of all requests that have been proxied
+// to the instance with the given ID. See InstanceStats for details.
+func (p *Proxy) InstanceStats(instanceID string) InstanceStats {
+	return p.stats.get(instanceID)
+}
+
+// ServiceMetrics returns the metrics of all requests that have been proxied
+// to the service with the given ID. See ServiceMetrics for details.
+func (p *Proxy) ServiceMetrics(serviceID string) ServiceMetrics {
+	return p.metrics.get(serviceID)
+}
+
+// addresses returns all configured listen addresses without duplicates.
+func (c Config) addresses() []string {
+	addresses := make([]string, 0, len(c.Addresses)+1)
+	seen := make(map[string]bool)
+
+	for _, address := range append(c.Addresses, c.Address) {
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		addresses = append(addresses, address)
+	}
+
+	return addresses
+}
+
+// collectErrors receives n errors from a channel and combines all errors
+// that are not nil into a single error. Returns nil if there are none.
+func collectErrors(errs <-chan error, n int) error {
+	messages := make([]string, 0)
+
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			messages = append(messages, err.Error())
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil
+	}
+
+	return errors.New(strings.Join(messages, "; "))
 }
 
 // handleRequest processes an incoming request. After looking up the desired
@@ -84,38 +344,122 @@ func (p *Proxy) Shutdown() error {
 // instance, forward the request to it and send the response back to the client.
 func (p *Proxy) handleRequest() http.Handler {
 	handler := func(w http.ResponseWriter, r *http.Request) {
-		service, ok := p.registry.LookupService(r.Host)
+		if !p.IsReady() {
+			p.displayError(w, r, http.StatusServiceUnavailable, "Service Unavailable")
+			return
+		}
+
+		service, ok := p.serviceRegistry().LookupService(r.Host)
+
+		// Services under maintenance won't be scheduled at all. If there is
+		// no custom maintenance message, a default error page is displayed.
+		if ok && service.Entity.Maintenance.IsEnabled {
+			p.displayMaintenance(w, r, service.Entity.Maintenance.Message)
+			return
+		}
 
 		// The following cases cause Dice to return error 503:
 		// - service is not registered/not found in the registry
 		// - service is not enabled
-		// - service scheduler is nil -> ToDo: Can that really happen?
-		if !ok || !service.Entity.IsEnabled || service.Scheduler == nil {
+		if !ok || !service.Entity.IsEnabled {
+			p.displayError(w, r, http.StatusServiceUnavailable, "Service Unavailable")
+			return
+		}
+
+		// The registry rejects services without a scheduler, so this only
+		// happens if a scheduler has been removed from a registered service.
+		if service.Scheduler == nil {
+			p.logger.Errorf("service %s has no scheduler and can't be routed", service.Entity.ID)
 			p.displayError(w, r, http.StatusServiceUnavailable, "Service Unavailable")
 			return
 		}
 
-		instance, err := service.Scheduler.Next()
+		// Preflight requests are answered by the proxy itself, so they don't
+		// have to be supported by the instances.
+		if applyCORS(w, r, service.Entity.CORS) {
+			return
+		}
+
+		// Disallowed requests are rejected before checking any credentials
+		// so that they never reach an instance.
+		if allowList := service.Entity.AllowList; !allowList.AllowsPath(r.URL.Path) {
+			p.displayError(w, r, http.StatusNotFound, "Not Found")
+			return
+		} else if !allowList.AllowsMethod(r.Method) {
+			w.Header().Set("Allow", strings.Join(allowList.Methods, ", "))
+			p.displayError(w, r, http.StatusMethodNotAllowed, "Method Not Allowed")
+			return
+		}
+
+		// Since browsers don't send credentials with preflight requests, the
+		// credentials are checked afterwards.
+		if auth := service.Entity.BasicAuth; auth.IsEnabled() {
+			username, password, ok := r.BasicAuth()
+
+			if !ok || !auth.Verify(username, password) {
+				w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s", charset="UTF-8"`, service.Entity.Name))
+				p.displayError(w, r, http.StatusUnauthorized, "Unauthorized")
+				return
+			}
+		}
+
+		// If no instance is available, the client is asked to back off. This
+		// doesn't apply to hosts without a service, since retrying won't help.
+		instance, err := p.acquireInstance(w, r, service)
 		if err != nil {
+			if retryAfter := p.getRetryAfter(); retryAfter > 0 {
+				seconds := int64((retryAfter + time.Second - 1) / time.Second)
+				w.Header().Set("Retry-After", strconv.FormatInt(seconds, 10))
+			}
 			p.displayError(w, r, http.StatusServiceUnavailable, "Service Unavailable")
 			return
 		}
+		defer p.stats.release(instance.ID)
+
+		// The request body is wrapped so that the bytes received from the
+		// client can be recorded in the service metrics.
+		body := &countingReader{ReadCloser: r.Body}
+		if r.Body != nil {
+			r.Body = body
+		}
+
+		// A copy of the request may be sent to a mirror instance. This is
+		// done asynchronously, so that the client isn't slowed down.
+		p.mirrors.mirror(p.transport, r, service, instance)
 
+		start := time.Now()
 		response, err := p.dialBackend(r, instance.URL)
+
+		failed := err != nil || response.StatusCode >= http.StatusInternalServerError
+		p.stats.record(instance.ID, time.Since(start), failed)
+		p.outliers.record(instance.ID, failed, service.Entity.Outliers)
+
 		if err != nil {
+			p.accessLog.log(r, service, http.StatusInternalServerError, 0, start)
 			p.displayError(w, r, http.StatusInternalServerError, err.Error())
+			return
 		}
 
-		if err := p.streamResponse(w, response); err != nil {
-			p.displayError(w, r, http.StatusInternalServerError, err.Error())
-		}
+		// Once the response is being streamed, the status code has been sent
+		// and an error can't be displayed anymore. Errors have been logged by
+		// streamResponse, so there's nothing left to do.
+		written, _ := p.streamResponse(w, r, response)
+		p.metrics.record(service.Entity.ID, time.Since(start), body.count, written)
+		p.accessLog.log(r, service, response.StatusCode, written, start)
 	}
 
 	return http.HandlerFunc(handler)
 }
 
+// dialBackend forwards the request to the instance with the given URL and
+// returns the instance's response. See backendURL for how the URL of the
+// backend request is built.
+//
+// Hop-by-hop headers only apply to the client connection and are removed,
+// see removeHopHeaders. Connections to the instance are managed by the
+// proxy's transport, regardless of whether the client uses keep-alive.
 func (p *Proxy) dialBackend(src *http.Request, targetURL string) (*http.Response, error) {
-	backendRequest, err := http.NewRequest(src.Method, "https://"+targetURL, src.Body)
+	backendRequest, err := http.NewRequest(src.Method, backendURL(targetURL, src.URL), src.Body)
 	if err != nil {
 		return nil, err
 	}
@@ -128,6 +472,14 @@ func (p *Proxy) dialBackend(src *http.Request, targetURL string) (*http.Response
 		backendRequest.Header[key] = val
 	}
 
+	removeHopHeaders(backendRequest.Header)
+
+	// gRPC clients signal that they accept trailers, which the instance may
+	// rely on. This is the only value of the TE header that is forwarded.
+	if acceptsTrailers(src.Header) {
+		backendRequest.Header.Set("Te", "trailers")
+	}
+
 	response, err := p.transport.RoundTrip(backendRequest)
 	if err != nil {
 		return nil, err
@@ -136,19 +488,169 @@ func (p *Proxy) dialBackend(src *http.Request, targetURL string) (*http.Response
 	return response, nil
 }
 
-func (p *Proxy) streamResponse(w http.ResponseWriter, response *http.Response) error {
+// Probe sends a HEAD request for target to the instance with the given URL
+// the same way a proxied request would be sent, and returns the status code
+// of the instance's response. The request is aborted if ctx is cancelled.
+func (p *Proxy) Probe(ctx context.Context, instanceURL string, target *url.URL) (int, error) {
+	request, err := http.NewRequest(http.MethodHead, backendURL(instanceURL, target), nil)
+	if err != nil {
+		return 0, err
+	}
+
+	request.Host = target.Host
+
+	response, err := p.transport.RoundTrip(request.WithContext(ctx))
+	if err != nil {
+		return 0, err
+	}
+
+	_ = response.Body.Close()
+
+	return response.StatusCode, nil
+}
+
+// hopHeaders are the hop-by-hop headers as defined in RFC 7230, section 6.1,
+// as well as some non-standard hop-by-hop headers that are still in use.
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopHeaders removes all hop-by-hop headers from header, including
+// the headers listed in the Connection header. These headers must not be
+// forwarded by a proxy.
+func removeHopHeaders(header http.Header) {
+	for _, value := range header["Connection"] {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				header.Del(name)
+			}
+		}
+	}
+
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+// acceptsTrailers indicates whether the TE header contains `trailers`.
+func acceptsTrailers(header http.Header) bool {
+	for _, value := range header["Te"] {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "trailers") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// backendURL builds the URL that a request is forwarded to. The URL of an
+// instance may contain a path like 10.0.0.1:8080/app, which serves as base
+// path for all requests: A request for /users is forwarded to /app/users.
+// The query string of the request is preserved.
+func backendURL(targetURL string, src *url.URL) string {
+	host, basePath := entity.SplitURL(targetURL)
+
+	backend := "https://" + host + joinPaths(basePath, src.EscapedPath())
+
+	if src.RawQuery != "" {
+		backend += "?" + src.RawQuery
+	}
+
+	return backend
+}
+
+// joinPaths joins a base path and a request path using exactly one slash,
+// regardless of whether the base path ends with a slash or not.
+func joinPaths(basePath, path string) string {
+	if basePath == "" {
+		return path
+	}
+
+	return strings.TrimRight(basePath, "/") + "/" + strings.TrimLeft(path, "/")
+}
+
+// streamResponse copies the status code, the headers and the body of the
+// response to the client. Each chunk will be flushed immediately if the
+// ResponseWriter supports it, so that streaming responses like server-sent
+// events aren't delayed. Trailers are announced in the Trailer header and
+// copied once the entire body has been read, as required by gRPC-Web.
+//
+// If a write timeout has been configured, the client has to accept each
+// chunk before the timeout expires. Otherwise, the response is aborted and
+// the upstream connection is closed.
+//
+// A failed write usually means that the client has gone away, which is an
+// expected condition and only logged as a warning. Failing to read from the
+// upstream instance is logged as an error. In both cases, the response is
+// aborted and the error is returned.
+//
+// The returned value is the number of body bytes sent to the client, even
+// if an error occurred.
+func (p *Proxy) streamResponse(w http.ResponseWriter, r *http.Request, response *http.Response) (int64, error) {
+	defer response.Body.Close()
+
+	flusher, canFlush := w.(http.Flusher)
 	buf := make([]byte, 8192)
+	var written int64
+
+	conn, _ := r.Context().Value(connContextKey{}).(net.Conn)
+	timeout := p.getWriteTimeout()
+
+	// The deadline has to be reset, since the connection may be re-used
+	// for subsequent requests.
+	if conn != nil && timeout > 0 {
+		defer func() {
+			_ = conn.SetWriteDeadline(time.Time{})
+		}()
+	}
+
+	header := w.Header()
+	removeHopHeaders(response.Header)
+
+	for key, values := range response.Header {
+		for _, value := range values {
+			header.Add(key, value)
+		}
+	}
+
+	for key := range response.Trailer {
+		header.Add("Trailer", key)
+	}
+
+	w.WriteHeader(response.StatusCode)
 
 	for {
 		length, err := response.Body.Read(buf)
 		if err != nil && err != io.EOF {
-			return err
+			p.logger.Errorf("aborting response to %s, reading from the instance failed: %v", r.RemoteAddr, err)
+			return written, err
 		}
 
 		if length > 0 {
-			_, writeErr := w.Write(buf[:length])
+			if conn != nil && timeout > 0 {
+				_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+			}
+
+			n, writeErr := w.Write(buf[:length])
+			written += int64(n)
+
 			if writeErr != nil {
-				return err
+				p.logger.Warnf("aborting response to %s, the client is gone: %v", r.RemoteAddr, writeErr)
+				return written, writeErr
+			}
+
+			if canFlush {
+				flusher.Flush()
 			}
 		}
 
@@ -157,7 +659,13 @@ func (p *Proxy) streamResponse(w http.ResponseWriter, response *http.Response) e
 		}
 	}
 
-	return nil
+	for key, values := range response.Trailer {
+		for _, value := range values {
+			w.Header().Add(http.TrailerPrefix+key, value)
+		}
+	}
+
+	return written, nil
 }
 
 // displayError returns an error response to the client by setting the provided
@@ -176,3 +684,17 @@ func (p *Proxy) displayError(w http.ResponseWriter, r *http.Request, status int,
 	w.WriteHeader(status)
 	_, _ = w.Write([]byte(body))
 }
+
+// displayMaintenance returns a 503 response to the client, displaying the
+// provided maintenance message. If the message is empty, a default error
+// page will be displayed instead.
+func (p *Proxy) displayMaintenance(w http.ResponseWriter, r *http.Request, message string) {
+	if message == "" {
+		p.displayError(w, r, http.StatusServiceUnavailable, "Service Under Maintenance")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusServiceUnavailable)
+	_, _ = w.Write([]byte(message))
+}
@@ -0,0 +1,1941 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/log"
+	"github.com/dominikbraun/dice/registry"
+	"github.com/dominikbraun/dice/scheduler"
+	"io"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// testScheduler is a registry.Scheduler that always returns the same
+// instance and counts how often it has been asked for an instance.
+type testScheduler struct {
+	instance *entity.Instance
+	calls    int
+}
+
+func (ts *testScheduler) Next() (*entity.Instance, error) {
+	ts.calls++
+	return ts.instance, nil
+}
+
+func (ts *testScheduler) Peek() (*entity.Instance, error) {
+	return ts.instance, nil
+}
+
+func (ts *testScheduler) UpdateDeployments(deployments []registry.Deployment) {}
+
+func (ts *testScheduler) Snapshot() registry.SchedulerSnapshot {
+	return registry.SchedulerSnapshot{}
+}
+
+// testTransport is a http.RoundTripper that counts the requests sent to
+// an upstream instance without establishing any connection. If status is
+// set, an empty response with that status code is returned.
+type testTransport struct {
+	status int
+	calls  int
+}
+
+func (tt *testTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	tt.calls++
+
+	if tt.status == 0 {
+		return nil, errors.New("upstream must not be called")
+	}
+
+	response := &http.Response{
+		StatusCode: tt.status,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+
+	return response, nil
+}
+
+// TestProxy_handleRequest_maintenance tests Proxy.handleRequest for a
+// service under maintenance. It asserts that the maintenance message is
+// returned with status 503 and that neither the scheduler nor the upstream
+// instance is called.
+func TestProxy_handleRequest_maintenance(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		Maintenance: entity.Maintenance{
+			IsEnabled: true,
+			Message:   "Back soon",
+		},
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	transport := &testTransport{}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	recorder := httptest.NewRecorder()
+
+	p.handleRequest().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	if body := recorder.Body.String(); body != "Back soon" {
+		t.Errorf("expected maintenance message as body, got %s", body)
+	}
+
+	if scheduler.calls != 0 || transport.calls != 0 {
+		t.Errorf("expected no upstream call, got %d scheduler and %d transport calls", scheduler.calls, transport.calls)
+	}
+}
+
+// TestProxy_handleRequest_readiness tests Proxy.handleRequest before and
+// after the proxy has been marked as ready. While the registry is still
+// being initialized, requests have to be answered with 503 without calling
+// the upstream instance. Afterwards, they have to be forwarded.
+func TestProxy_handleRequest_readiness(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	transport := &testTransport{status: http.StatusOK}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+
+	recorder := httptest.NewRecorder()
+	p.handleRequest().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d during initialization, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	if transport.calls != 0 {
+		t.Errorf("expected no upstream call during initialization, got %d", transport.calls)
+	}
+
+	p.SetReady(true)
+
+	recorder = httptest.NewRecorder()
+	p.handleRequest().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if recorder.Code != http.StatusOK {
+		t.Errorf("expected status %d after initialization, got %d", http.StatusOK, recorder.Code)
+	}
+
+	if transport.calls != 1 {
+		t.Errorf("expected 1 upstream call after initialization, got %d", transport.calls)
+	}
+}
+
+// TestProxy_handleRequest_corsPreflight tests Proxy.handleRequest for a
+// CORS preflight request from an allowed origin. It asserts that the proxy
+// answers the request itself with the configured CORS headers.
+func TestProxy_handleRequest_corsPreflight(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		CORS: entity.CORS{
+			AllowedOrigins:   []string{"https://app.example.com"},
+			AllowedMethods:   []string{"GET", "PUT"},
+			AllowedHeaders:   []string{"Content-Type"},
+			AllowCredentials: true,
+		},
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	transport := &testTransport{}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	request := httptest.NewRequest(http.MethodOptions, "http://example.com/", nil)
+	request.Header.Set("Origin", "https://app.example.com")
+	request.Header.Set("Access-Control-Request-Method", "PUT")
+	recorder := httptest.NewRecorder()
+
+	p.handleRequest().ServeHTTP(recorder, request)
+
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Methods":     "GET, PUT",
+		"Access-Control-Allow-Headers":     "Content-Type",
+		"Access-Control-Allow-Credentials": "true",
+	}
+
+	for key, value := range expected {
+		if actual := recorder.Header().Get(key); actual != value {
+			t.Errorf("expected %s to be %s, got %s", key, value, actual)
+		}
+	}
+
+	if scheduler.calls != 0 || transport.calls != 0 {
+		t.Errorf("expected no upstream call, got %d scheduler and %d transport calls", scheduler.calls, transport.calls)
+	}
+}
+
+// TestProxy_handleRequest_corsHeaders tests Proxy.handleRequest for regular
+// requests to a service with CORS enabled. It asserts that the CORS headers
+// are added for allowed origins only and that the request is forwarded.
+func TestProxy_handleRequest_corsHeaders(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		CORS: entity.CORS{
+			AllowedOrigins: []string{"https://app.example.com"},
+		},
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	transport := &testTransport{status: http.StatusOK}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	testCases := map[string]string{
+		"https://app.example.com":   "https://app.example.com",
+		"https://other.example.com": "",
+	}
+
+	for origin, expected := range testCases {
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		request.Header.Set("Origin", origin)
+		recorder := httptest.NewRecorder()
+
+		p.handleRequest().ServeHTTP(recorder, request)
+
+		if actual := recorder.Header().Get("Access-Control-Allow-Origin"); actual != expected {
+			t.Errorf("expected allowed origin %q for %s, got %q", expected, origin, actual)
+		}
+
+		if recorder.Header().Get("Access-Control-Allow-Credentials") != "" {
+			t.Errorf("expected no credentials header for %s", origin)
+		}
+	}
+
+	if transport.calls != len(testCases) {
+		t.Errorf("expected %d upstream calls, got %d", len(testCases), transport.calls)
+	}
+}
+
+// TestProxy_handleRequest_basicAuth tests Proxy.handleRequest for a service
+// requiring basic auth. It asserts that requests without credentials or with
+// a wrong password are challenged and that only requests with the correct
+// credentials are forwarded to the instance.
+func TestProxy_handleRequest_basicAuth(t *testing.T) {
+	auth, err := entity.NewBasicAuth("dice", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := &entity.Service{
+		ID:        "s1",
+		Name:      "example",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		BasicAuth: auth,
+	}
+
+	testCases := []struct {
+		name     string
+		username string
+		password string
+		status   int
+		calls    int
+	}{
+		{name: "missing credentials", status: http.StatusUnauthorized},
+		{name: "wrong password", username: "dice", password: "wrong", status: http.StatusUnauthorized},
+		{name: "correct password", username: "dice", password: "secret", status: http.StatusOK, calls: 1},
+	}
+
+	for _, tc := range testCases {
+		scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+		transport := &testTransport{status: http.StatusOK}
+
+		serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+		if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+			t.Fatal(err)
+		}
+
+		p := New(Config{}, serviceRegistry)
+		p.transport = transport
+		p.SetReady(true)
+
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		if tc.username != "" {
+			request.SetBasicAuth(tc.username, tc.password)
+		}
+		recorder := httptest.NewRecorder()
+
+		p.handleRequest().ServeHTTP(recorder, request)
+
+		if recorder.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, recorder.Code)
+		}
+
+		challenge := recorder.Header().Get("WWW-Authenticate")
+
+		if tc.status == http.StatusUnauthorized && !strings.HasPrefix(challenge, `Basic realm="example"`) {
+			t.Errorf("%s: expected basic auth challenge, got %q", tc.name, challenge)
+		}
+
+		if tc.status == http.StatusOK && challenge != "" {
+			t.Errorf("%s: expected no challenge, got %q", tc.name, challenge)
+		}
+
+		if transport.calls != tc.calls {
+			t.Errorf("%s: expected %d upstream calls, got %d", tc.name, tc.calls, transport.calls)
+		}
+	}
+}
+
+// TestProxy_handleRequest_allowList tests Proxy.handleRequest for a service
+// with an allow-list. It asserts that allowed requests are forwarded, while
+// requests with a blocked method or path are rejected with 405 and 404.
+func TestProxy_handleRequest_allowList(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		AllowList: entity.AllowList{
+			Methods: []string{"GET", "POST"},
+			Paths:   []string{"/api/**", "/health"},
+		},
+	}
+
+	testCases := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		calls  int
+	}{
+		{name: "allowed GET", method: http.MethodGet, path: "/api/users", status: http.StatusOK, calls: 1},
+		{name: "blocked DELETE", method: http.MethodDelete, path: "/api/users", status: http.StatusMethodNotAllowed},
+		{name: "path outside allow-list", method: http.MethodGet, path: "/admin", status: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+		transport := &testTransport{status: http.StatusOK}
+
+		serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+		if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+			t.Fatal(err)
+		}
+
+		p := New(Config{}, serviceRegistry)
+		p.transport = transport
+		p.SetReady(true)
+
+		recorder := httptest.NewRecorder()
+		p.handleRequest().ServeHTTP(recorder, httptest.NewRequest(tc.method, "http://example.com"+tc.path, nil))
+
+		if recorder.Code != tc.status {
+			t.Errorf("%s: expected status %d, got %d", tc.name, tc.status, recorder.Code)
+		}
+
+		if tc.status == http.StatusMethodNotAllowed && recorder.Header().Get("Allow") != "GET, POST" {
+			t.Errorf("%s: expected Allow header, got %q", tc.name, recorder.Header().Get("Allow"))
+		}
+
+		if transport.calls != tc.calls {
+			t.Errorf("%s: expected %d upstream calls, got %d", tc.name, tc.calls, transport.calls)
+		}
+	}
+}
+
+// TestProxy_handleRequest_stickyFailover tests Proxy.handleRequest for a
+// service with sticky sessions. It asserts that a client stays pinned to its
+// instance and is pinned to a healthy instance with a new affinity cookie
+// once the pinned instance has died.
+func TestProxy_handleRequest_stickyFailover(t *testing.T) {
+	service := &entity.Service{
+		ID:             "s1",
+		URLs:           []string{"example.com"},
+		IsEnabled:      true,
+		StickySessions: true,
+	}
+
+	instances := map[string]*entity.Instance{
+		"i1": {ID: "i1", URL: "localhost:8081", IsAttached: true, IsAlive: true},
+		"i2": {ID: "i2", URL: "localhost:8082", IsAttached: true, IsAlive: true},
+	}
+
+	deployments := []registry.Deployment{
+		{Node: &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true}, Instance: instances["i1"]},
+		{Node: &entity.Node{ID: "n2", Weight: 1, IsAttached: true, IsAlive: true}, Instance: instances["i2"]},
+	}
+
+	wrr, err := scheduler.New(deployments, scheduler.WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+	registryService := &registry.Service{Entity: service, Deployments: deployments, Scheduler: wrr}
+
+	if err := serviceRegistry.RegisterService(registryService, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = &testTransport{status: http.StatusOK}
+	p.SetReady(true)
+
+	// request sends a request with the given affinity token and returns the
+	// affinity cookie set by the proxy, if any.
+	request := func(token string) *http.Cookie {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		if token != "" {
+			r.AddCookie(&http.Cookie{Name: affinityCookie, Value: token})
+		}
+		recorder := httptest.NewRecorder()
+
+		p.handleRequest().ServeHTTP(recorder, r)
+
+		for _, cookie := range recorder.Result().Cookies() {
+			if cookie.Name == affinityCookie {
+				return cookie
+			}
+		}
+		return nil
+	}
+
+	cookie := request("")
+	if cookie == nil {
+		t.Fatal("expected an affinity cookie for the first request")
+	}
+
+	pinned, ok := p.affinity.get(cookie.Value)
+	if !ok {
+		t.Fatalf("token %s has not been pinned", cookie.Value)
+	}
+
+	for i := 0; i < 3; i++ {
+		if c := request(cookie.Value); c != nil {
+			t.Errorf("expected the client to stay pinned, got new token %s", c.Value)
+		}
+	}
+
+	instances[pinned].IsAlive = false
+
+	failover := request(cookie.Value)
+	if failover == nil {
+		t.Fatal("expected a new affinity cookie after the pinned instance died")
+	}
+
+	repinned, ok := p.affinity.get(failover.Value)
+	if !ok || repinned == pinned {
+		t.Errorf("expected the client to be pinned to a healthy instance, got %s", repinned)
+	}
+
+	if _, ok := p.affinity.get(cookie.Value); ok {
+		t.Errorf("expected the old token %s to be removed", cookie.Value)
+	}
+}
+
+// TestProxy_Run tests Proxy.Run with two listen addresses. It asserts that
+// both addresses serve requests and that Run returns without an error after
+// the proxy has been shut down.
+func TestProxy_Run(t *testing.T) {
+	addresses := []string{freeAddress(t), freeAddress(t)}
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	p := New(Config{Addresses: addresses}, serviceRegistry)
+	done := make(chan error)
+
+	go func() {
+		done <- p.Run()
+	}()
+
+	client := http.Client{Timeout: time.Second}
+
+	for _, address := range addresses {
+		var response *http.Response
+		var err error
+
+		// The servers are started asynchronously, so they might not be
+		// listening immediately.
+		for attempt := 0; attempt < 20; attempt++ {
+			if response, err = client.Get("http://" + address); err == nil {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		if err != nil {
+			t.Errorf("address %s is not served: %v", address, err)
+			continue
+		}
+
+		_ = response.Body.Close()
+
+		if response.StatusCode != http.StatusServiceUnavailable {
+			t.Errorf("expected status %d from %s, got %d", http.StatusServiceUnavailable, address, response.StatusCode)
+		}
+	}
+
+	if err := p.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+// TestProxy_Reconfigure tests a data-only reload of a running proxy. While
+// a request is in flight, the proxy is reconfigured with the same listen
+// address and a new registry. It asserts that the in-flight request succeeds,
+// that subsequent requests use the new registry and that a changed listen
+// address can't be applied in place.
+func TestProxy_Reconfigure(t *testing.T) {
+	received := make(chan struct{})
+	release := make(chan struct{})
+
+	oldUpstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-release
+		_, _ = w.Write([]byte("old"))
+	}))
+	defer oldUpstream.Close()
+
+	newUpstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("new"))
+	}))
+	defer newUpstream.Close()
+
+	// newRegistry creates a registry routing example.com to the upstream.
+	newRegistry := func(upstream *httptest.Server) *registry.ServiceRegistry {
+		service := &entity.Service{ID: "s1", URLs: []string{"example.com"}, IsEnabled: true}
+		instance := &entity.Instance{ID: "i1", URL: strings.TrimPrefix(upstream.URL, "https://")}
+
+		serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+		if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: &testScheduler{instance: instance}}, false); err != nil {
+			t.Fatal(err)
+		}
+		return serviceRegistry
+	}
+
+	address := freeAddress(t)
+	config := Config{Addresses: []string{address}}
+
+	p := New(config, newRegistry(oldUpstream))
+	p.transport = &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	p.SetReady(true)
+
+	done := make(chan error)
+
+	go func() {
+		done <- p.Run()
+	}()
+
+	// get sends a request for example.com to the proxy and returns the body.
+	get := func() (string, error) {
+		request, _ := http.NewRequest(http.MethodGet, "http://"+address, nil)
+		request.Host = "example.com"
+
+		response, err := http.DefaultClient.Do(request)
+		if err != nil {
+			return "", err
+		}
+		defer response.Body.Close()
+
+		body, err := ioutil.ReadAll(response.Body)
+		return string(body), err
+	}
+
+	inFlight := make(chan string)
+
+	go func() {
+		// The server is started asynchronously, so it might not be listening
+		// immediately.
+		for attempt := 0; attempt < 20; attempt++ {
+			if body, err := get(); err == nil {
+				inFlight <- body
+				return
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+		inFlight <- ""
+	}()
+
+	select {
+	case <-received:
+	case <-time.After(2 * time.Second):
+		t.Fatal("request hasn't reached the upstream")
+	}
+
+	if !p.Reconfigure(config) {
+		t.Fatal("expected the unchanged configuration to be applied in place")
+	}
+	p.SetRegistry(newRegistry(newUpstream))
+
+	close(release)
+
+	if body := <-inFlight; body != "old" {
+		t.Errorf("expected in-flight request to succeed with body old, got %q", body)
+	}
+
+	if body, err := get(); err != nil || body != "new" {
+		t.Errorf("expected body new after the reload, got %q (%v)", body, err)
+	}
+
+	if p.Reconfigure(Config{Addresses: []string{freeAddress(t)}}) {
+		t.Error("expected a changed listen address not to be applied in place")
+	}
+
+	if err := p.Shutdown(); err != nil {
+		t.Error(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Error(err)
+	}
+}
+
+// freeAddress returns a local TCP address that is currently not in use.
+func freeAddress(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	address := listener.Addr().String()
+	_ = listener.Close()
+
+	return address
+}
+
+// TestProxy_handleRequest_stats tests if the instance stats are updated for
+// each proxied request. Unreachable instances and 5xx responses have to be
+// counted as errors.
+func TestProxy_handleRequest_stats(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	transport := &testTransport{}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	if stats := p.InstanceStats("i1"); stats.Requests != 0 || stats.Errors != 0 {
+		t.Fatalf("expected empty stats, got %v", stats)
+	}
+
+	// A status of 0 makes the transport fail, see testTransport.
+	statuses := []int{http.StatusOK, http.StatusNotFound, http.StatusBadGateway, 0}
+
+	for _, status := range statuses {
+		transport.status = status
+
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	stats := p.InstanceStats("i1")
+
+	if stats.Requests != 4 {
+		t.Errorf("expected 4 requests, got %d", stats.Requests)
+	}
+
+	if stats.Errors != 2 {
+		t.Errorf("expected 2 errors, got %d", stats.Errors)
+	}
+
+	if other := p.InstanceStats("i2"); other.Requests != 0 {
+		t.Errorf("expected no requests for another instance, got %d", other.Requests)
+	}
+}
+
+// TestProxy_handleRequest_connectionLimit tests Proxy.handleRequest for an
+// instance that has reached its connection limit. It asserts that requests
+// are shed to the other instance, that 503 is returned once all instances
+// are at their limit and that completed requests are no longer in flight.
+func TestProxy_handleRequest_connectionLimit(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	deployments := []registry.Deployment{
+		{
+			Node:     &entity.Node{ID: "n1", Weight: 2, IsAttached: true, IsAlive: true},
+			Instance: &entity.Instance{ID: "i1", URL: "localhost:8081", IsAttached: true, IsAlive: true, MaxConnections: 1},
+		},
+		{
+			Node:     &entity.Node{ID: "n2", Weight: 1, IsAttached: true, IsAlive: true},
+			Instance: &entity.Instance{ID: "i2", URL: "localhost:8082", IsAttached: true, IsAlive: true, MaxConnections: 2},
+		},
+	}
+
+	wrr, err := scheduler.New(deployments, scheduler.WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+	registryService := &registry.Service{Entity: service, Deployments: deployments, Scheduler: wrr}
+
+	if err := serviceRegistry.RegisterService(registryService, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = &testTransport{status: http.StatusOK}
+	p.SetReady(true)
+
+	request := func() int {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		recorder := httptest.NewRecorder()
+
+		p.handleRequest().ServeHTTP(recorder, r)
+		return recorder.Code
+	}
+
+	// i1 is busy with a long-running request, so that it is at its limit.
+	if !p.stats.acquire("i1", 1) {
+		t.Fatal("expected i1 to accept a request")
+	}
+
+	for i := 0; i < 4; i++ {
+		if code := request(); code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+		}
+	}
+
+	if requests := p.InstanceStats("i1").Requests; requests != 0 {
+		t.Errorf("expected requests to be shed from i1, got %d requests", requests)
+	}
+
+	if requests := p.InstanceStats("i2").Requests; requests != 4 {
+		t.Errorf("expected 4 requests for i2, got %d", requests)
+	}
+
+	if inFlight := p.InstanceStats("i2").InFlight; inFlight != 0 {
+		t.Errorf("expected no requests in flight for i2, got %d", inFlight)
+	}
+
+	// Once i2 is at its limit as well, no instance is available.
+	p.stats.acquire("i2", 2)
+	p.stats.acquire("i2", 2)
+
+	if code := request(); code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, code)
+	}
+
+	p.stats.release("i1")
+
+	if code := request(); code != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, code)
+	}
+
+	if requests := p.InstanceStats("i1").Requests; requests != 1 {
+		t.Errorf("expected 1 request for i1 after it has been released, got %d", requests)
+	}
+}
+
+// TestProxy_handleRequest_outliers tests Proxy.handleRequest for a service
+// with outlier detection. It asserts that a failing instance is ejected after
+// the threshold has been reached and that it is reintroduced after the
+// ejection time has passed.
+func TestProxy_handleRequest_outliers(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		Outliers:  entity.Outliers{Threshold: 2, EjectionTime: time.Minute},
+	}
+
+	deployments := []registry.Deployment{
+		{
+			Node:     &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true},
+			Instance: &entity.Instance{ID: "i1", URL: "localhost:8081", IsAttached: true, IsAlive: true},
+		},
+		{
+			Node:     &entity.Node{ID: "n2", Weight: 1, IsAttached: true, IsAlive: true},
+			Instance: &entity.Instance{ID: "i2", URL: "localhost:8082", IsAttached: true, IsAlive: true},
+		},
+	}
+
+	wrr, err := scheduler.New(deployments, scheduler.WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+	registryService := &registry.Service{Entity: service, Deployments: deployments, Scheduler: wrr}
+
+	if err := serviceRegistry.RegisterService(registryService, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// i1 fails all requests with 502, i2 responds successfully.
+	transport := &hostTransport{statuses: map[string]int{
+		"localhost:8081": http.StatusBadGateway,
+		"localhost:8082": http.StatusOK,
+	}}
+
+	now := time.Now()
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.outliers.now = func() time.Time { return now }
+	p.SetReady(true)
+
+	request := func() {
+		r := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		p.handleRequest().ServeHTTP(httptest.NewRecorder(), r)
+	}
+
+	// Round robin alternates between both instances, so that i1 has failed
+	// twice after 4 requests and gets ejected.
+	for i := 0; i < 4; i++ {
+		request()
+	}
+
+	if !p.outliers.isEjected("i1") {
+		t.Fatal("expected i1 to be ejected after 2 consecutive failures")
+	}
+
+	transport.hosts = nil
+
+	for i := 0; i < 4; i++ {
+		request()
+	}
+
+	for _, host := range transport.hosts {
+		if host == "localhost:8081" {
+			t.Fatalf("expected no requests to ejected instance i1, got %v", transport.hosts)
+		}
+	}
+
+	// After the ejection time, i1 is reintroduced. Another 2 failures eject
+	// it again, this time for twice the base ejection time.
+	now = now.Add(time.Minute)
+
+	if p.outliers.isEjected("i1") {
+		t.Fatal("expected i1 to be reintroduced after the ejection time")
+	}
+
+	transport.hosts = nil
+
+	for i := 0; i < 4; i++ {
+		request()
+	}
+
+	if len(transport.hosts) != 4 || transport.hosts[0] == transport.hosts[1] {
+		t.Errorf("expected requests to both instances, got %v", transport.hosts)
+	}
+
+	now = now.Add(time.Minute)
+
+	if !p.outliers.isEjected("i1") {
+		t.Errorf("expected i1 to be ejected for twice the ejection time")
+	}
+}
+
+// hostTransport is a http.RoundTripper that responds with the status code
+// configured for the requested host and records the requested hosts.
+type hostTransport struct {
+	statuses map[string]int
+	hosts    []string
+}
+
+func (ht *hostTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ht.hosts = append(ht.hosts, r.URL.Host)
+
+	response := &http.Response{
+		StatusCode: ht.statuses[r.URL.Host],
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+
+	return response, nil
+}
+
+// TestProxy_streamResponse tests if chunks of a streaming upstream response
+// arrive at the client incrementally and if trailers are copied. The slow
+// upstream only sends its second chunk after the client has received the
+// first one, which can only happen if the proxy flushes each chunk.
+func TestProxy_streamResponse(t *testing.T) {
+	release := make(chan struct{})
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "X-Checksum")
+
+		_, _ = w.Write([]byte("first\n"))
+		w.(http.Flusher).Flush()
+
+		<-release
+
+		_, _ = w.Write([]byte("second\n"))
+		w.Header().Set("X-Checksum", "abc")
+	}))
+	defer upstream.Close()
+
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	upstreamURL := strings.TrimPrefix(upstream.URL, "https://")
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: upstreamURL}}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = upstream.Client().Transport
+	p.SetReady(true)
+
+	server := httptest.NewServer(p.handleRequest())
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	request.Host = "example.com"
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		close(release)
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	lines := make(chan string)
+
+	go func() {
+		buf := make([]byte, 64)
+		for {
+			n, err := response.Body.Read(buf)
+			if n > 0 {
+				lines <- string(buf[:n])
+			}
+			if err != nil {
+				close(lines)
+				return
+			}
+		}
+	}()
+
+	select {
+	case chunk := <-lines:
+		if chunk != "first\n" {
+			t.Errorf("got chunk %q, expected %q", chunk, "first\n")
+		}
+	case <-time.After(2 * time.Second):
+		close(release)
+		t.Fatal("first chunk hasn't arrived before the upstream finished")
+	}
+
+	close(release)
+
+	var rest string
+	for chunk := range lines {
+		rest += chunk
+	}
+
+	if rest != "second\n" {
+		t.Errorf("got remaining body %q, expected %q", rest, "second\n")
+	}
+
+	if checksum := response.Trailer.Get("X-Checksum"); checksum != "abc" {
+		t.Errorf("got trailer %q, expected %q", checksum, "abc")
+	}
+}
+
+// TestProxy_streamResponse_writeTimeout tests if the response is aborted if
+// the client doesn't accept it within the write timeout. The upstream sends
+// an endless response, which the client never reads. As soon as the proxy
+// aborts the response, the upstream connection gets closed.
+func TestProxy_streamResponse_writeTimeout(t *testing.T) {
+	stopped := make(chan struct{})
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer close(stopped)
+
+		chunk := make([]byte, 32*1024)
+
+		for {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer upstream.Close()
+
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	upstreamURL := strings.TrimPrefix(upstream.URL, "https://")
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: upstreamURL}}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{WriteTimeout: 100 * time.Millisecond}, serviceRegistry)
+	p.transport = upstream.Client().Transport
+	p.SetReady(true)
+
+	server := httptest.NewUnstartedServer(p.handleRequest())
+	server.Config.ConnContext = withConn
+	server.Start()
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_ = conn.(*net.TCPConn).SetReadBuffer(4096)
+
+	if _, err := conn.Write([]byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-stopped:
+	case <-time.After(10 * time.Second):
+		t.Fatal("upstream connection hasn't been closed after the write timeout")
+	}
+}
+
+// recordingLogger is a log.Logger that records all warning and error
+// messages.
+type recordingLogger struct {
+	log.Logger
+	warnings []string
+	errors   []string
+}
+
+func (rl *recordingLogger) Warnf(format string, args ...interface{}) {
+	rl.warnings = append(rl.warnings, fmt.Sprintf(format, args...))
+}
+
+func (rl *recordingLogger) Errorf(format string, args ...interface{}) {
+	rl.errors = append(rl.errors, fmt.Sprintf(format, args...))
+}
+
+// disconnectingWriter is a http.ResponseWriter that fails all writes after
+// the first one with a broken pipe, just like a client that has gone away
+// in the middle of a response. It counts the calls to WriteHeader.
+type disconnectingWriter struct {
+	*httptest.ResponseRecorder
+	writes       int
+	headerWrites int
+}
+
+func (dw *disconnectingWriter) WriteHeader(status int) {
+	dw.headerWrites++
+	dw.ResponseRecorder.WriteHeader(status)
+}
+
+func (dw *disconnectingWriter) Write(b []byte) (int, error) {
+	dw.writes++
+	if dw.writes > 1 {
+		return 0, syscall.EPIPE
+	}
+	return dw.ResponseRecorder.Write(b)
+}
+
+// TestProxy_streamResponse_clientGone tests if a client disconnecting in the
+// middle of a response is handled gracefully: The response must be aborted
+// without attempting to send an error status, and the disconnect has to be
+// logged as a warning only.
+func TestProxy_streamResponse_clientGone(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	logger := &recordingLogger{Logger: log.NewLogger(ioutil.Discard, log.ErrorLevel)}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = &echoTransport{}
+	p.SetLogger(logger)
+	p.SetReady(true)
+
+	// The echoed body is larger than the buffer used for streaming, so that
+	// it takes multiple writes to send it.
+	body := strings.Repeat("x", 16*1024)
+	request := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(body))
+	w := &disconnectingWriter{ResponseRecorder: httptest.NewRecorder()}
+
+	p.handleRequest().ServeHTTP(w, request)
+
+	if w.headerWrites != 1 || w.Code != http.StatusOK {
+		t.Errorf("got %d status writes with status %d, expected 1 with status %d", w.headerWrites, w.Code, http.StatusOK)
+	}
+
+	if w.writes != 2 {
+		t.Errorf("got %d body writes, expected the response to be aborted after 2", w.writes)
+	}
+
+	if len(logger.warnings) != 1 || len(logger.errors) != 0 {
+		t.Errorf("got warnings %v and errors %v, expected a single warning", logger.warnings, logger.errors)
+	}
+}
+
+// TestQuantileEstimator feeds the latencies from 1ms to 1000ms in random
+// order into quantile estimators and asserts that the estimated median and
+// 95th percentile deviate from the exact values by less than 2%.
+func TestQuantileEstimator(t *testing.T) {
+	p50, p95 := newQuantileEstimator(0.5), newQuantileEstimator(0.95)
+
+	for _, i := range rand.New(rand.NewSource(1)).Perm(1000) {
+		latency := float64(time.Duration(i+1) * time.Millisecond)
+		p50.add(latency)
+		p95.add(latency)
+	}
+
+	tests := []struct {
+		estimator *quantileEstimator
+		expected  time.Duration
+	}{
+		{estimator: p50, expected: 500 * time.Millisecond},
+		{estimator: p95, expected: 950 * time.Millisecond},
+	}
+
+	for _, test := range tests {
+		estimate := time.Duration(test.estimator.value())
+		deviation := math.Abs(float64(estimate-test.expected)) / float64(test.expected)
+
+		if deviation >= 0.02 {
+			t.Errorf("expected p%v to be about %v, got %v", test.estimator.p*100, test.expected, estimate)
+		}
+	}
+}
+
+// TestQuantileEstimator_fewValues tests if the quantiles of less than five
+// values are determined exactly.
+func TestQuantileEstimator_fewValues(t *testing.T) {
+	qe := newQuantileEstimator(0.5)
+
+	if value := qe.value(); value != 0 {
+		t.Errorf("expected 0 without any values, got %v", value)
+	}
+
+	for _, x := range []float64{30, 10, 20} {
+		qe.add(x)
+	}
+
+	if value := qe.value(); value != 20 {
+		t.Errorf("expected median 20, got %v", value)
+	}
+}
+
+// echoTransport is a http.RoundTripper that responds with the request body.
+type echoTransport struct{}
+
+func (et *echoTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader(string(body) + string(body))),
+	}
+
+	return response, nil
+}
+
+// TestProxy_handleRequest_metrics tests if the bytes received from and sent
+// to the client are recorded in the service metrics.
+func TestProxy_handleRequest_metrics(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = &echoTransport{}
+	p.SetReady(true)
+
+	for _, body := range []string{"hello", "dice"} {
+		request := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader(body))
+		p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	metrics := p.ServiceMetrics("s1")
+
+	if metrics.Requests != 2 {
+		t.Errorf("expected 2 requests, got %d", metrics.Requests)
+	}
+
+	if metrics.BytesIn != 9 {
+		t.Errorf("expected 9 bytes in, got %d", metrics.BytesIn)
+	}
+
+	if metrics.BytesOut != 18 {
+		t.Errorf("expected 18 bytes out, got %d", metrics.BytesOut)
+	}
+
+	if metrics.LatencyP50 <= 0 || metrics.LatencyP95 < metrics.LatencyP50 {
+		t.Errorf("expected positive, ordered latencies, got p50 %v and p95 %v", metrics.LatencyP50, metrics.LatencyP95)
+	}
+}
+
+// TestProxy_handleRequest_retryAfter tests Proxy.handleRequest for a service
+// whose instances are all dead and for a host without a service. It asserts
+// that the Retry-After header is only sent for the service, since retrying
+// won't help for an unknown host.
+func TestProxy_handleRequest_retryAfter(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	deployments := []registry.Deployment{
+		{
+			Node:     &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true},
+			Instance: &entity.Instance{ID: "i1", URL: "localhost:8080", IsAttached: true, IsAlive: false},
+		},
+	}
+
+	wrr, err := scheduler.New(deployments, scheduler.WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+	registryService := &registry.Service{Entity: service, Deployments: deployments, Scheduler: wrr}
+
+	if err := serviceRegistry.RegisterService(registryService, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{RetryAfter: 1500 * time.Millisecond}, serviceRegistry)
+	p.transport = &testTransport{}
+	p.SetReady(true)
+
+	recorder := httptest.NewRecorder()
+	p.handleRequest().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+	if recorder.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d", http.StatusServiceUnavailable, recorder.Code)
+	}
+
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "2" {
+		t.Errorf("expected Retry-After 2, got %q", retryAfter)
+	}
+
+	recorder = httptest.NewRecorder()
+	p.handleRequest().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://unknown.com/", nil))
+
+	if retryAfter := recorder.Header().Get("Retry-After"); retryAfter != "" {
+		t.Errorf("expected no Retry-After header for an unknown host, got %q", retryAfter)
+	}
+}
+
+// TestBackendURL tests backendURL with instance URLs with and without base
+// paths, making sure that no double slashes are produced.
+func TestBackendURL(t *testing.T) {
+	tests := []struct {
+		target   string
+		request  string
+		expected string
+	}{
+		{"10.0.0.1:8080", "/users", "https://10.0.0.1:8080/users"},
+		{"10.0.0.1:8080/app", "/users", "https://10.0.0.1:8080/app/users"},
+		{"10.0.0.1:8080/app/", "/users", "https://10.0.0.1:8080/app/users"},
+		{"10.0.0.1:8080/app", "/", "https://10.0.0.1:8080/app/"},
+		{"//10.0.0.1:8080/app", "/users?page=2", "https://10.0.0.1:8080/app/users?page=2"},
+		{"10.0.0.1:8080/app", "/a%2Fb", "https://10.0.0.1:8080/app/a%2Fb"},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest(http.MethodGet, "http://example.com"+test.request, nil)
+
+		if actual := backendURL(test.target, request.URL); actual != test.expected {
+			t.Errorf("%s with %s: expected %s, got %s", test.target, test.request, test.expected, actual)
+		}
+	}
+}
+
+// urlTransport is a http.RoundTripper that records the requested URLs.
+type urlTransport struct {
+	urls []string
+}
+
+func (ut *urlTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ut.urls = append(ut.urls, r.URL.String())
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+
+	return response, nil
+}
+
+// TestProxy_handleRequest_basePath tests if a request to a service whose
+// instance URL contains a base path is forwarded to the combined path.
+func TestProxy_handleRequest_basePath(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "10.0.0.1:8080/app/"}}
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &urlTransport{}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	request := httptest.NewRequest(http.MethodGet, "http://example.com/users/1?fields=name", nil)
+	p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+
+	expected := "https://10.0.0.1:8080/app/users/1?fields=name"
+
+	if len(transport.urls) != 1 || transport.urls[0] != expected {
+		t.Errorf("expected request to %s, got %v", expected, transport.urls)
+	}
+}
+
+// mirrorTransport is a http.RoundTripper that counts the requests for each
+// host and records the request bodies. Requests to the slow host are only
+// answered after the delay has expired.
+type mirrorTransport struct {
+	slowHost string
+	delay    time.Duration
+	mutex    sync.Mutex
+	counts   map[string]int
+	bodies   map[string][]string
+}
+
+func (mt *mirrorTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.URL.Host == mt.slowHost {
+		time.Sleep(mt.delay)
+	}
+
+	mt.mutex.Lock()
+	mt.counts[r.URL.Host]++
+	mt.bodies[r.URL.Host] = append(mt.bodies[r.URL.Host], string(body))
+	mt.mutex.Unlock()
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+
+	return response, nil
+}
+
+// newMirrorTestProxy creates a proxy for a service with a primary instance
+// with version v1 and a detached mirror instance with version v2. Requests
+// are mirrored with the given fraction.
+func newMirrorTestProxy(t *testing.T, fraction float64, transport http.RoundTripper) *Proxy {
+	primary := &entity.Instance{ID: "i1", URL: "primary:8080", Version: "v1", IsAttached: true, IsAlive: true}
+	mirror := &entity.Instance{ID: "i2", URL: "mirror:8080", Version: "v2", IsAlive: true}
+
+	service := &registry.Service{
+		Entity: &entity.Service{
+			ID:        "s1",
+			URLs:      []string{"example.com"},
+			IsEnabled: true,
+			Mirror:    entity.Mirror{Version: "v2", Fraction: fraction},
+		},
+		Deployments: []registry.Deployment{
+			{Node: &entity.Node{ID: "n1"}, Instance: primary},
+			{Node: &entity.Node{ID: "n1"}, Instance: mirror},
+		},
+		Scheduler: &testScheduler{instance: primary},
+	}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(service, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	return p
+}
+
+// awaitMirrors waits until all mirrored requests of the proxy have been
+// finished.
+func awaitMirrors(t *testing.T, p *Proxy) {
+	deadline := time.Now().Add(5 * time.Second)
+
+	for len(p.mirrors.slots) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("mirrored requests haven't been finished in time")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestProxy_handleRequest_mirrorRate tests if the mirror instance receives
+// roughly the configured fraction of all requests while the primary instance
+// receives every request.
+func TestProxy_handleRequest_mirrorRate(t *testing.T) {
+	const requests = 2000
+	const fraction = 0.25
+
+	transport := &mirrorTransport{counts: make(map[string]int), bodies: make(map[string][]string)}
+
+	p := newMirrorTestProxy(t, fraction, transport)
+	p.mirrors.slots = make(chan struct{}, requests)
+
+	for i := 0; i < requests; i++ {
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	awaitMirrors(t, p)
+
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	if transport.counts["primary:8080"] != requests {
+		t.Errorf("expected %d requests to the primary instance, got %d", requests, transport.counts["primary:8080"])
+	}
+
+	rate := float64(transport.counts["mirror:8080"]) / requests
+
+	if math.Abs(rate-fraction) > 0.05 {
+		t.Errorf("expected roughly %v of the requests to be mirrored, got %v", fraction, rate)
+	}
+}
+
+// TestProxy_handleRequest_mirrorLatency tests if a slow mirror instance
+// doesn't delay the response to the client and if both instances receive
+// the entire request body.
+func TestProxy_handleRequest_mirrorLatency(t *testing.T) {
+	const delay = 500 * time.Millisecond
+
+	transport := &mirrorTransport{
+		slowHost: "mirror:8080",
+		delay:    delay,
+		counts:   make(map[string]int),
+		bodies:   make(map[string][]string),
+	}
+
+	p := newMirrorTestProxy(t, 1, transport)
+
+	start := time.Now()
+	request := httptest.NewRequest(http.MethodPost, "http://example.com/", strings.NewReader("payload"))
+	p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+
+	if elapsed := time.Since(start); elapsed >= delay/2 {
+		t.Errorf("expected the client not to wait for the mirror, took %v", elapsed)
+	}
+
+	awaitMirrors(t, p)
+
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+
+	for _, host := range []string{"primary:8080", "mirror:8080"} {
+		if bodies := transport.bodies[host]; len(bodies) != 1 || bodies[0] != "payload" {
+			t.Errorf("expected %s to receive the request body once, got %v", host, bodies)
+		}
+	}
+}
+
+// headerTransport is a http.RoundTripper that records the request headers.
+type headerTransport struct {
+	header http.Header
+}
+
+func (ht *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	ht.header = r.Header
+
+	response := &http.Response{
+		StatusCode: http.StatusOK,
+		Header:     make(http.Header),
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}
+
+	return response, nil
+}
+
+// TestProxy_handleRequest_hopHeaders tests if hop-by-hop headers, including
+// those listed in the Connection header, aren't forwarded to the instance
+// while all end-to-end headers are.
+func TestProxy_handleRequest_hopHeaders(t *testing.T) {
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "localhost:8080"}}
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	transport := &headerTransport{}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = transport
+	p.SetReady(true)
+
+	request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+	request.Header.Set("Connection", "close, X-Session-Hop")
+	request.Header.Set("Keep-Alive", "timeout=5")
+	request.Header.Set("Proxy-Connection", "keep-alive")
+	request.Header.Set("Upgrade", "h2c")
+	request.Header.Set("X-Session-Hop", "1")
+	request.Header.Set("X-Request-ID", "42")
+
+	p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+
+	for _, name := range []string{"Connection", "Keep-Alive", "Proxy-Connection", "Upgrade", "X-Session-Hop"} {
+		if value := transport.header.Get(name); value != "" {
+			t.Errorf("expected header %s not to be forwarded, got %s", name, value)
+		}
+	}
+
+	if value := transport.header.Get("X-Request-ID"); value != "42" {
+		t.Errorf("expected header X-Request-ID to be forwarded, got %q", value)
+	}
+
+	if request.Header.Get("X-Session-Hop") != "1" {
+		t.Errorf("expected the client request headers to remain unchanged")
+	}
+}
+
+// newAccessLogTestProxy creates a proxy for a service with the given access
+// log settings whose access lines are written to output.
+func newAccessLogTestProxy(t *testing.T, settings entity.AccessLog, output io.Writer) *Proxy {
+	service := &entity.Service{
+		ID:        "s1",
+		Name:      "service-1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+		AccessLog: settings,
+	}
+
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: "10.0.0.1:8080"}}
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = &echoTransport{}
+	p.SetAccessLog(output)
+	p.SetReady(true)
+
+	return p
+}
+
+// TestProxy_handleRequest_accessLog tests if an access line is written for
+// each request to a service with the default access log settings, and if no
+// line is written for a service whose access log has been disabled.
+func TestProxy_handleRequest_accessLog(t *testing.T) {
+	var enabled, disabled bytes.Buffer
+
+	p := newAccessLogTestProxy(t, entity.AccessLog{}, &enabled)
+	q := newAccessLogTestProxy(t, entity.AccessLog{IsDisabled: true}, &disabled)
+
+	for i := 0; i < 3; i++ {
+		p.handleRequest().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/users?page=2", nil))
+		q.handleRequest().ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "http://example.com/users?page=2", nil))
+	}
+
+	lines := strings.Split(strings.TrimSpace(enabled.String()), "\n")
+
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 access lines, got %d: %q", len(lines), enabled.String())
+	}
+
+	if !strings.Contains(lines[0], `service-1`) || !strings.Contains(lines[0], `"GET /users?page=2 HTTP/1.1" 200`) {
+		t.Errorf("unexpected access line %q", lines[0])
+	}
+
+	if disabled.Len() != 0 {
+		t.Errorf("expected no access lines for a disabled access log, got %q", disabled.String())
+	}
+}
+
+// TestProxy_handleRequest_accessLogSampling tests if roughly 1 in 10 requests
+// is logged for a service with a sample rate of 10.
+func TestProxy_handleRequest_accessLogSampling(t *testing.T) {
+	const requests = 5000
+	const sampleRate = 10
+
+	var output bytes.Buffer
+
+	p := newAccessLogTestProxy(t, entity.AccessLog{SampleRate: sampleRate}, &output)
+
+	for i := 0; i < requests; i++ {
+		request := httptest.NewRequest(http.MethodGet, "http://example.com/", nil)
+		p.handleRequest().ServeHTTP(httptest.NewRecorder(), request)
+	}
+
+	rate := float64(strings.Count(output.String(), "\n")) / requests
+
+	if math.Abs(rate-1.0/sampleRate) > 0.02 {
+		t.Errorf("expected roughly %v of the requests to be logged, got %v", 1.0/sampleRate, rate)
+	}
+}
+
+// TestProxy_handleRequest_grpcWeb tests the proxy with a gRPC-Web upstream
+// that responds with gRPC-specific headers and sends the gRPC status in its
+// trailers. It asserts that the client receives the status code, headers
+// and trailers of the upstream, and that `TE: trailers` is forwarded.
+func TestProxy_handleRequest_grpcWeb(t *testing.T) {
+	var te string
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		te = r.Header.Get("Te")
+
+		w.Header().Set("Content-Type", "application/grpc-web+proto")
+		w.Header().Set("Grpc-Accept-Encoding", "gzip")
+		w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+		w.WriteHeader(http.StatusOK)
+
+		_, _ = w.Write([]byte{0, 0, 0, 0, 2, 8, 1})
+
+		w.Header().Set("Grpc-Status", "5")
+		w.Header().Set("Grpc-Message", "not found")
+	}))
+	defer upstream.Close()
+
+	service := &entity.Service{
+		ID:        "s1",
+		URLs:      []string{"example.com"},
+		IsEnabled: true,
+	}
+
+	upstreamURL := strings.TrimPrefix(upstream.URL, "https://")
+	scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: upstreamURL}}
+
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+		t.Fatal(err)
+	}
+
+	p := New(Config{}, serviceRegistry)
+	p.transport = upstream.Client().Transport
+	p.SetReady(true)
+
+	server := httptest.NewServer(p.handleRequest())
+	defer server.Close()
+
+	request, _ := http.NewRequest(http.MethodPost, server.URL+"/helloworld.Greeter/SayHello", strings.NewReader("\x00\x00\x00\x00\x00"))
+	request.Host = "example.com"
+	request.Header.Set("Content-Type", "application/grpc-web+proto")
+	request.Header.Set("Te", "trailers")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if te != "trailers" {
+		t.Errorf("expected TE header %q to be forwarded, got %q", "trailers", te)
+	}
+
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("expected status %d, got %d", http.StatusOK, response.StatusCode)
+	}
+
+	if contentType := response.Header.Get("Content-Type"); contentType != "application/grpc-web+proto" {
+		t.Errorf("expected content type %q, got %q", "application/grpc-web+proto", contentType)
+	}
+
+	if encoding := response.Header.Get("Grpc-Accept-Encoding"); encoding != "gzip" {
+		t.Errorf("expected grpc-accept-encoding %q, got %q", "gzip", encoding)
+	}
+
+	if len(body) != 7 {
+		t.Errorf("expected a body of 7 bytes, got %d", len(body))
+	}
+
+	if status := response.Trailer.Get("Grpc-Status"); status != "5" {
+		t.Errorf("expected trailer grpc-status %q, got %q", "5", status)
+	}
+
+	if message := response.Trailer.Get("Grpc-Message"); message != "not found" {
+		t.Errorf("expected trailer grpc-message %q, got %q", "not found", message)
+	}
+}
+
+// TestProxy_Run_maxHeaderBytes tests if requests whose headers exceed the
+// configured maximum header size are rejected with 431, while requests with
+// smaller headers are processed as usual.
+func TestProxy_Run_maxHeaderBytes(t *testing.T) {
+	address := freeAddress(t)
+	serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	p := New(Config{Address: address, MaxHeaderBytes: 1024}, serviceRegistry)
+	done := make(chan error)
+
+	go func() {
+		done <- p.Run()
+	}()
+
+	defer func() {
+		_ = p.Shutdown()
+		<-done
+	}()
+
+	client := http.Client{Timeout: time.Second}
+
+	send := func(headerSize int) *http.Response {
+		request, _ := http.NewRequest(http.MethodGet, "http://"+address, nil)
+		request.Header.Set("X-Padding", strings.Repeat("a", headerSize))
+
+		var response *http.Response
+		var err error
+
+		// The server is started asynchronously, so it might not be
+		// listening immediately.
+		for attempt := 0; attempt < 20; attempt++ {
+			if response, err = client.Do(request); err == nil {
+				break
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = response.Body.Close()
+
+		return response
+	}
+
+	// net/http grants some additional bytes on top of the maximum header
+	// size, so the oversized header has to exceed the limit considerably.
+	if response := send(16 * 1024); response.StatusCode != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("expected status %d for oversized headers, got %d", http.StatusRequestHeaderFieldsTooLarge, response.StatusCode)
+	}
+
+	if response := send(100); response.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d for small headers, got %d", http.StatusServiceUnavailable, response.StatusCode)
+	}
+}
+
+// TestProxy_Probe tests if Proxy.Probe sends a HEAD request for the target
+// path and host to the instance and returns the status code.
+func TestProxy_Probe(t *testing.T) {
+	var method, path, host string
+
+	upstream := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, host = r.Method, r.URL.Path, r.Host
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer upstream.Close()
+
+	p := New(Config{}, registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel)))
+	p.transport = upstream.Client().Transport
+
+	target, _ := url.Parse("http://example.com/users")
+	instanceURL := strings.TrimPrefix(upstream.URL, "https://") + "/app"
+
+	status, err := p.Probe(context.Background(), instanceURL, target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, status)
+	}
+
+	if method != http.MethodHead || path != "/app/users" || host != "example.com" {
+		t.Errorf("expected HEAD /app/users for example.com, got %s %s for %s", method, path, host)
+	}
+}
+
+// TestProxy_handleRequest_dialTimeout tests if requests to an unroutable
+// instance and to an instance that never completes the TLS handshake fail
+// as soon as the dial timeout has expired.
+func TestProxy_handleRequest_dialTimeout(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+
+	// The connections are accepted, but the TLS handshake never happens.
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	const dialTimeout = 200 * time.Millisecond
+
+	for _, instanceURL := range []string{"10.255.255.1:81", listener.Addr().String()} {
+		service := &entity.Service{ID: "s1", URLs: []string{"example.com"}, IsEnabled: true}
+		scheduler := &testScheduler{instance: &entity.Instance{ID: "i1", URL: instanceURL}}
+		serviceRegistry := registry.NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+		if err := serviceRegistry.RegisterService(&registry.Service{Entity: service, Scheduler: scheduler}, false); err != nil {
+			t.Fatal(err)
+		}
+
+		p := New(Config{DialTimeout: dialTimeout}, serviceRegistry)
+		p.SetReady(true)
+
+		recorder := httptest.NewRecorder()
+		start := time.Now()
+
+		p.handleRequest().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "http://example.com/", nil))
+
+		if elapsed := time.Since(start); elapsed > 5*dialTimeout {
+			t.Errorf("%s: expected the request to fail after %v, took %v", instanceURL, dialTimeout, elapsed)
+		}
+
+		if recorder.Code != http.StatusInternalServerError {
+			t.Errorf("%s: expected status %d, got %d", instanceURL, http.StatusInternalServerError, recorder.Code)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"sync"
+	"time"
+)
+
+// InstanceStats holds lightweight counters for requests that have been
+// proxied to a single instance. A request counts as error if the instance
+// couldn't be reached or responded with a 5xx status code.
+//
+// All counters are cumulative since the proxy has been started. They are
+// never reset while the proxy is running, but a restart or configuration
+// reload of Dice starts them over. The only exception is InFlight, which is
+// the number of requests currently being proxied to the instance.
+type InstanceStats struct {
+	Requests    uint64        `json:"requests"`
+	Errors      uint64        `json:"errors"`
+	LastLatency time.Duration `json:"last_latency"`
+	InFlight    int           `json:"in_flight"`
+}
+
+// statsRecorder accumulates InstanceStats for all instances. It is safe for
+// concurrent use.
+type statsRecorder struct {
+	instances map[string]*InstanceStats
+	mutex     sync.RWMutex
+}
+
+// newStatsRecorder creates a new, empty statsRecorder instance.
+func newStatsRecorder() *statsRecorder {
+	sr := statsRecorder{
+		instances: make(map[string]*InstanceStats),
+	}
+
+	return &sr
+}
+
+// record adds a proxied request with the given latency to the stats of the
+// instance with the given ID.
+func (sr *statsRecorder) record(instanceID string, latency time.Duration, failed bool) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	stats, ok := sr.instances[instanceID]
+	if !ok {
+		stats = &InstanceStats{}
+		sr.instances[instanceID] = stats
+	}
+
+	stats.Requests++
+	stats.LastLatency = latency
+
+	if failed {
+		stats.Errors++
+	}
+}
+
+// acquire increments the number of in-flight requests of the instance with
+// the given ID, unless it has already reached the given limit. A limit of 0
+// means that the number of in-flight requests is unlimited. The returned
+// value indicates whether the request may be proxied to the instance.
+//
+// Each successful call has to be followed by a call to release.
+func (sr *statsRecorder) acquire(instanceID string, limit int) bool {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	stats, ok := sr.instances[instanceID]
+	if !ok {
+		stats = &InstanceStats{}
+		sr.instances[instanceID] = stats
+	}
+
+	if limit > 0 && stats.InFlight >= limit {
+		return false
+	}
+
+	stats.InFlight++
+	return true
+}
+
+// release decrements the number of in-flight requests of the instance with
+// the given ID after a request has been completed.
+func (sr *statsRecorder) release(instanceID string) {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if stats, ok := sr.instances[instanceID]; ok && stats.InFlight > 0 {
+		stats.InFlight--
+	}
+}
+
+// get returns a copy of the stats of the instance with the given ID. If no
+// request has been proxied to that instance yet, empty stats are returned.
+func (sr *statsRecorder) get(instanceID string) InstanceStats {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	if stats, ok := sr.instances[instanceID]; ok {
+		return *stats
+	}
+
+	return InstanceStats{}
+}
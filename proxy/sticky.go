@@ -0,0 +1,157 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package proxy provides a reverse proxy. Its job is to accept incoming
+// requests, find a service instance and forward the request to it.
+package proxy
+
+import (
+	"crypto/rand"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"net/http"
+	"sync"
+)
+
+const (
+	// affinityCookie is the name of the cookie holding the affinity token
+	// of a client for services with sticky sessions.
+	affinityCookie = "dice_affinity"
+	// maxAffinityTokens is the maximum number of affinity tokens that will
+	// be stored. If it is exceeded, all clients will be pinned again.
+	maxAffinityTokens = 100000
+)
+
+// affinityMap maps affinity tokens to the ID of the instance the client has
+// been pinned to. Tokens are random and don't reveal any instance data. The
+// map is safe for concurrent use.
+type affinityMap struct {
+	tokens map[string]string
+	mutex  sync.Mutex
+}
+
+// newAffinityMap creates a new, empty affinityMap instance.
+func newAffinityMap() *affinityMap {
+	am := affinityMap{
+		tokens: make(map[string]string),
+	}
+
+	return &am
+}
+
+// get returns the ID of the instance the token has been pinned to.
+func (am *affinityMap) get(token string) (string, bool) {
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	instanceID, ok := am.tokens[token]
+	return instanceID, ok
+}
+
+// pin creates a new token that is pinned to the given instance. If a token
+// to be replaced is provided, that token will be removed.
+func (am *affinityMap) pin(instanceID, replaced string) (string, error) {
+	b := make([]byte, 16)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	token := fmt.Sprintf("%x", b)
+
+	am.mutex.Lock()
+	defer am.mutex.Unlock()
+
+	delete(am.tokens, replaced)
+
+	if len(am.tokens) >= maxAffinityTokens {
+		am.tokens = make(map[string]string)
+	}
+
+	am.tokens[token] = instanceID
+
+	return token, nil
+}
+
+// nextInstance returns the instance a request should be forwarded to. For
+// services without sticky sessions, this is the next instance determined by
+// the scheduler.
+//
+// For services with sticky sessions, the instance the client is pinned to
+// will be returned as long as it is available. Otherwise, the client will
+// fail over to the next instance determined by the scheduler and is pinned
+// to that instance by setting a new affinity cookie.
+func (p *Proxy) nextInstance(w http.ResponseWriter, r *http.Request, service *registry.Service) (*entity.Instance, error) {
+	if !service.Entity.StickySessions {
+		return service.Scheduler.Next()
+	}
+
+	var token string
+
+	if cookie, err := r.Cookie(affinityCookie); err == nil {
+		if instanceID, ok := p.affinity.get(cookie.Value); ok {
+			if instance := availableInstance(service, instanceID); instance != nil {
+				return instance, nil
+			}
+			token = cookie.Value
+		}
+	}
+
+	instance, err := service.Scheduler.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	if token, err = p.affinity.pin(instance.ID, token); err != nil {
+		return nil, err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     affinityCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+	})
+
+	return instance, nil
+}
+
+// PinnedInstance returns the ID of the instance the client sending r has been
+// pinned to using an affinity cookie. It doesn't check whether the instance
+// is still available.
+func (p *Proxy) PinnedInstance(r *http.Request) (string, bool) {
+	cookie, err := r.Cookie(affinityCookie)
+	if err != nil {
+		return "", false
+	}
+
+	return p.affinity.get(cookie.Value)
+}
+
+// availableInstance returns the instance of the service with the given ID
+// if it is attached and alive and has been deployed to an attached and alive
+// node. Otherwise, `nil` will be returned.
+func availableInstance(service *registry.Service, instanceID string) *entity.Instance {
+	for _, d := range service.Deployments {
+		if d.Instance.ID != instanceID {
+			continue
+		}
+
+		if d.Instance.IsAttached && d.Instance.IsAlive && d.Node.IsAttached && d.Node.IsAlive {
+			return d.Instance
+		}
+	}
+
+	return nil
+}
@@ -21,69 +21,277 @@ package registry
 
 import (
 	"errors"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"math/rand"
+	"regexp"
+	"strings"
 )
 
+const (
+	// patternPrefix marks a route as a regular expression that the host is
+	// matched against, for example ~^(api|www)\.example\.com$.
+	patternPrefix string = "~"
+	// wildcardPrefix marks a route as a wildcard route that matches all
+	// subdomains of a host, for example *.example.com.
+	wildcardPrefix string = "*."
+	// CatchAllRoute is the route of the default service, which receives
+	// all requests whose host doesn't match any other route.
+	CatchAllRoute string = "*"
+)
+
+// defaultPorts are the ports that are stripped from hosts and routes, since
+// example.com:80 and example.com:443 are equivalent to example.com.
+var defaultPorts = []string{":80", ":443"}
+
 // ServiceRoute is a host with an optional route that serves as a HTTP
 // request target. It is an unique identifier for services.
 //
-// Currently, a service route simply is a host like example.com.
+// Currently, a service route simply is a host like example.com. A route may
+// also be a wildcard like *.example.com, matching all subdomains, or a regex
+// pattern prefixed with ~ like ~^(api|www)\.example\.com$. The special
+// route * is the catch-all route, see CatchAllRoute.
+//
+// Since hosts are case-insensitive, all routes except for regex routes are
+// lowercased, and default ports are stripped, see normalizeRoute. Regex
+// routes are matched against the normalized host. Paths, once supported,
+// remain case-sensitive.
 // ToDo: Implemented service routes in URL-form like example.com/api.
 type ServiceRoute string
 
 var (
 	ErrUnregisteredRoute      = errors.New("route is not registered")
 	ErrRouteAlreadyRegistered = errors.New("route is already registered")
+	ErrInvalidRoutePattern    = errors.New("route pattern is not a valid regular expression")
+	ErrInvalidRouteWeight     = errors.New("route weights have to be greater than 0")
 )
 
-// routeRegistry is the global registry for service routes. It manages a
+// routePattern is a regex route that has been compiled at registration.
+type routePattern struct {
+	route     ServiceRoute
+	regexp    *regexp.Regexp
+	serviceID string
+}
+
+// RouteRegistry is the global registry for service routes. It manages a
 // simple mapping between a service route and a corresponding service ID.
-type RouteRegistry map[ServiceRoute]string
+//
+// Regex routes are stored separately, in the order of their registration,
+// because they can't be looked up directly.
+//
+// A route may optionally be weighted, meaning that the requests to that
+// route are split across multiple services by weight, see SetWeights.
+type RouteRegistry struct {
+	routes   map[ServiceRoute]string
+	patterns []routePattern
+	weights  map[ServiceRoute][]entity.RouteWeight
+	random   func(n int) int
+}
 
 // NewRouteRegistry creates a new, ready to go routeRegistry instance.
-func NewRouteRegistry() RouteRegistry {
-	rr := make(map[ServiceRoute]string)
-	return rr
+func NewRouteRegistry() *RouteRegistry {
+	rr := RouteRegistry{
+		routes:   make(map[ServiceRoute]string),
+		patterns: make([]routePattern, 0),
+		weights:  make(map[ServiceRoute][]entity.RouteWeight),
+		random:   rand.Intn,
+	}
+	return &rr
 }
 
 // RegisterRoute registers a new route and maps it against a service ID.
 // Returns an error if it already exists, unless force is set to `true`.
-func (rr RouteRegistry) RegisterRoute(route string, serviceID string, force bool) error {
-	if _, exists := rr[ServiceRoute(route)]; exists {
+// Regex routes are compiled once when they're registered.
+func (rr *RouteRegistry) RegisterRoute(route string, serviceID string, force bool) error {
+	route = normalizeRoute(route)
+
+	if _, exists := rr.routes[ServiceRoute(route)]; exists {
 		if !force {
 			return ErrRouteAlreadyRegistered
 		}
 	}
 
-	rr[ServiceRoute(route)] = serviceID
+	if strings.HasPrefix(route, patternPrefix) {
+		compiled, err := regexp.Compile(strings.TrimPrefix(route, patternPrefix))
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidRoutePattern, err)
+		}
+
+		rr.removePattern(ServiceRoute(route))
+		rr.patterns = append(rr.patterns, routePattern{
+			route:     ServiceRoute(route),
+			regexp:    compiled,
+			serviceID: serviceID,
+		})
+	}
+
+	rr.routes[ServiceRoute(route)] = serviceID
 
 	return nil
 }
 
 // UnregisterRoute removes a route from the registry. Returns an error if
 // the route doesn't exist.
-func (rr RouteRegistry) UnregisterRoute(route string) error {
-	if _, exists := rr[ServiceRoute(route)]; !exists {
+func (rr *RouteRegistry) UnregisterRoute(route string) error {
+	route = normalizeRoute(route)
+
+	if _, exists := rr.routes[ServiceRoute(route)]; !exists {
+		return ErrUnregisteredRoute
+	}
+	delete(rr.routes, ServiceRoute(route))
+	delete(rr.weights, ServiceRoute(route))
+	rr.removePattern(ServiceRoute(route))
+
+	return nil
+}
+
+// SetWeights splits the requests to a registered route across the given
+// services by weight. Passing no weights removes the split, so that the
+// route points to its own service again. Returns an error if the route
+// doesn't exist or if any weight isn't greater than 0.
+func (rr *RouteRegistry) SetWeights(route string, weights []entity.RouteWeight) error {
+	route = normalizeRoute(route)
+
+	if _, exists := rr.routes[ServiceRoute(route)]; !exists {
 		return ErrUnregisteredRoute
 	}
-	delete(rr, ServiceRoute(route))
+
+	if len(weights) == 0 {
+		delete(rr.weights, ServiceRoute(route))
+		return nil
+	}
+
+	for _, w := range weights {
+		if w.Weight <= 0 {
+			return ErrInvalidRouteWeight
+		}
+	}
+
+	rr.weights[ServiceRoute(route)] = append([]entity.RouteWeight(nil), weights...)
 
 	return nil
 }
 
+// removePattern removes the regex route with the given route, if any.
+func (rr *RouteRegistry) removePattern(route ServiceRoute) {
+	for i, p := range rr.patterns {
+		if p.route == route {
+			rr.patterns = append(rr.patterns[:i], rr.patterns[i+1:]...)
+			return
+		}
+	}
+}
+
 // LookupServiceID looks up a service ID that is associated with the given
 // route. The second return value indicates whether the service ID could
 // be found or not.
-func (rr RouteRegistry) LookupServiceID(route string) (string, bool) {
-	if serviceID, exists := rr[ServiceRoute(route)]; exists {
-		return serviceID, true
+//
+// An exact match takes precedence over wildcard routes, where the most
+// specific wildcard wins. Regex routes are evaluated next, in the order in
+// which they have been registered. If no route matches, the catch-all route
+// is used if it has been registered. The route is normalized before it is
+// looked up, so that EXAMPLE.com:80 matches example.com.
+//
+// If the matching route is weighted, one of its services is chosen randomly
+// for each lookup, where the probability is proportional to its weight.
+func (rr *RouteRegistry) LookupServiceID(route string) (string, bool) {
+	matched, serviceID, exists := rr.match(normalizeRoute(route))
+	if !exists {
+		return "", false
+	}
+
+	if weights, ok := rr.weights[matched]; ok {
+		return rr.pick(weights), true
+	}
+
+	return serviceID, true
+}
+
+// match finds the registered route matching the given normalized route as
+// described in LookupServiceID. It returns that route along with the ID of
+// the service the route is registered for.
+func (rr *RouteRegistry) match(route string) (ServiceRoute, string, bool) {
+	if serviceID, exists := rr.routes[ServiceRoute(route)]; exists {
+		return ServiceRoute(route), serviceID, true
+	}
+
+	for host := route; strings.Contains(host, "."); {
+		host = host[strings.Index(host, ".")+1:]
+		wildcard := ServiceRoute(wildcardPrefix + host)
+
+		if serviceID, exists := rr.routes[wildcard]; exists {
+			return wildcard, serviceID, true
+		}
+	}
+
+	for _, p := range rr.patterns {
+		if p.regexp.MatchString(route) {
+			return p.route, p.serviceID, true
+		}
+	}
+
+	if serviceID, exists := rr.routes[ServiceRoute(CatchAllRoute)]; exists {
+		return ServiceRoute(CatchAllRoute), serviceID, true
+	}
+
+	return "", "", false
+}
+
+// pick randomly chooses one of the weighted services, where the probability
+// of each service is proportional to its weight.
+func (rr *RouteRegistry) pick(weights []entity.RouteWeight) string {
+	total := 0
+
+	for _, w := range weights {
+		total += w.Weight
+	}
+
+	n := rr.random(total)
+
+	for _, w := range weights {
+		if n < w.Weight {
+			return w.ServiceID
+		}
+		n -= w.Weight
+	}
+
+	return weights[len(weights)-1].ServiceID
+}
+
+// Routes returns a copy of all registered routes, mapped against the IDs
+// of their services.
+func (rr *RouteRegistry) Routes() map[string]string {
+	routes := make(map[string]string, len(rr.routes))
+
+	for route, serviceID := range rr.routes {
+		routes[string(route)] = serviceID
 	}
 
-	return "", false
+	return routes
 }
 
 // IsRegistered checks and returns if a given route is registered. Note
 // that there's a difference between `example.com` and `example.com/`.
-func (rr RouteRegistry) IsRegistered(route string) bool {
-	_, exists := rr[ServiceRoute(route)]
+func (rr *RouteRegistry) IsRegistered(route string) bool {
+	_, exists := rr.routes[ServiceRoute(normalizeRoute(route))]
 	return exists
 }
+
+// normalizeRoute lowercases the given route and strips a default port from
+// it. Regex routes are returned unchanged, because lowercasing them would
+// change their meaning.
+func normalizeRoute(route string) string {
+	if strings.HasPrefix(route, patternPrefix) {
+		return route
+	}
+
+	route = strings.ToLower(route)
+
+	for _, port := range defaultPorts {
+		if strings.HasSuffix(route, port) {
+			return strings.TrimSuffix(route, port)
+		}
+	}
+
+	return route
+}
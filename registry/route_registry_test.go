@@ -0,0 +1,317 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry provides the service registry and the route registry.
+//
+// While the core package as well as the store package represent the data
+// statically and storage-oriented, the registries provide a representation
+// required at runtime: In-memory, dynamic and quickly accessible.
+package registry
+
+import (
+	"errors"
+	"github.com/dominikbraun/dice/entity"
+	"math"
+	"testing"
+)
+
+// TestRouteRegistry_LookupServiceID_pattern checks if a regex route matches
+// multiple hosts and if exact and wildcard routes take precedence over it.
+func TestRouteRegistry_LookupServiceID_pattern(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	routes := map[string]string{
+		`~^(api|www)\.example\.com$`: "s1",
+		"*.example.com":              "s2",
+		"api.example.com":            "s3",
+	}
+
+	for route, serviceID := range routes {
+		if err := rr.RegisterRoute(route, serviceID, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Without the wildcard and exact routes, the regex route matches both
+	// api.example.com and www.example.com.
+	patternOnly := NewRouteRegistry()
+
+	if err := patternOnly.RegisterRoute(`~^(api|www)\.example\.com$`, "s1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"api.example.com", "www.example.com"} {
+		if serviceID, ok := patternOnly.LookupServiceID(host); !ok || serviceID != "s1" {
+			t.Errorf("expected %s to match s1, got %s", host, serviceID)
+		}
+	}
+
+	if _, ok := patternOnly.LookupServiceID("shop.example.com"); ok {
+		t.Errorf("expected shop.example.com not to match")
+	}
+
+	expected := map[string]string{
+		"api.example.com":      "s3",
+		"www.example.com":      "s2",
+		"shop.example.com":     "s2",
+		"a.shop.example.com":   "s2",
+		"www.example.com.evil": "",
+	}
+
+	for host, serviceID := range expected {
+		id, ok := rr.LookupServiceID(host)
+
+		if ok != (serviceID != "") || id != serviceID {
+			t.Errorf("expected %s to match %q, got %q", host, serviceID, id)
+		}
+	}
+}
+
+// TestRouteRegistry_LookupServiceID_patternOrder checks if regex routes are
+// evaluated after a more specific wildcard and that unregistering a regex
+// route removes its compiled pattern.
+func TestRouteRegistry_LookupServiceID_patternOrder(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	if err := rr.RegisterRoute(`~\.example\.com$`, "s1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := rr.RegisterRoute("*.shop.example.com", "s2", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if serviceID, _ := rr.LookupServiceID("eu.shop.example.com"); serviceID != "s2" {
+		t.Errorf("expected wildcard route to match, got %q", serviceID)
+	}
+
+	if serviceID, _ := rr.LookupServiceID("www.example.com"); serviceID != "s1" {
+		t.Errorf("expected regex route to match, got %q", serviceID)
+	}
+
+	if err := rr.UnregisterRoute(`~\.example\.com$`); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := rr.LookupServiceID("www.example.com"); ok {
+		t.Errorf("expected unregistered regex route not to match")
+	}
+}
+
+// TestRouteRegistry_RegisterRoute_invalidPattern checks if an invalid regex
+// route is rejected at registration.
+func TestRouteRegistry_RegisterRoute_invalidPattern(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	if err := rr.RegisterRoute("~^(api", "s1", false); !errors.Is(err, ErrInvalidRoutePattern) {
+		t.Errorf("expected %v, got %v", ErrInvalidRoutePattern, err)
+	}
+
+	if rr.IsRegistered("~^(api") {
+		t.Errorf("expected invalid route not to be registered")
+	}
+}
+
+// TestRouteRegistry_LookupServiceID_mixedCase checks if hosts are matched
+// case-insensitively, regardless of the case of the registered route.
+func TestRouteRegistry_LookupServiceID_mixedCase(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	routes := map[string]string{
+		"Example.com":   "s1",
+		"*.Example.org": "s2",
+	}
+
+	for route, serviceID := range routes {
+		if err := rr.RegisterRoute(route, serviceID, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hosts := map[string]string{
+		"example.com":     "s1",
+		"EXAMPLE.COM":     "s1",
+		"eXaMpLe.CoM":     "s1",
+		"api.example.org": "s2",
+		"API.Example.ORG": "s2",
+	}
+
+	for host, expected := range hosts {
+		if serviceID, ok := rr.LookupServiceID(host); !ok || serviceID != expected {
+			t.Errorf("expected %s to match %s, got %s", host, expected, serviceID)
+		}
+	}
+
+	if !rr.IsRegistered("example.COM") {
+		t.Errorf("expected example.COM to be registered")
+	}
+}
+
+// TestRouteRegistry_LookupServiceID_defaultPort checks if hosts with an
+// explicit default port match the registered route, while other ports
+// don't.
+func TestRouteRegistry_LookupServiceID_defaultPort(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	if err := rr.RegisterRoute("example.com", "s1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, host := range []string{"example.com:80", "example.com:443", "Example.com:80"} {
+		if serviceID, ok := rr.LookupServiceID(host); !ok || serviceID != "s1" {
+			t.Errorf("expected %s to match s1, got %s", host, serviceID)
+		}
+	}
+
+	if _, ok := rr.LookupServiceID("example.com:8080"); ok {
+		t.Errorf("expected example.com:8080 not to match")
+	}
+
+	// Routes are normalized at registration as well.
+	if err := rr.RegisterRoute("example.com:443", "s2", false); err != ErrRouteAlreadyRegistered {
+		t.Errorf("expected error %v, got %v", ErrRouteAlreadyRegistered, err)
+	}
+}
+
+// TestRouteRegistry_LookupServiceID_catchAll checks if hosts that don't
+// match any route fall back to the catch-all route, while all other routes
+// take precedence over it.
+func TestRouteRegistry_LookupServiceID_catchAll(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	routes := map[string]string{
+		CatchAllRoute:     "s1",
+		"example.com":     "s2",
+		"*.example.org":   "s3",
+		`~^api\.example$`: "s4",
+	}
+
+	for route, serviceID := range routes {
+		if err := rr.RegisterRoute(route, serviceID, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hosts := map[string]string{
+		"example.com":     "s2",
+		"www.example.org": "s3",
+		"api.example":     "s4",
+		"unknown.com":     "s1",
+		"localhost":       "s1",
+	}
+
+	for host, expected := range hosts {
+		if serviceID, ok := rr.LookupServiceID(host); !ok || serviceID != expected {
+			t.Errorf("expected %s to match %s, got %s", host, expected, serviceID)
+		}
+	}
+
+	if err := rr.RegisterRoute(CatchAllRoute, "s5", false); err != ErrRouteAlreadyRegistered {
+		t.Errorf("expected error %v for a second catch-all route, got %v", ErrRouteAlreadyRegistered, err)
+	}
+}
+
+// TestRouteRegistry_UnregisterRoute_catchAll checks if hosts without a
+// matching route don't match anymore once the catch-all route has been
+// unregistered.
+func TestRouteRegistry_UnregisterRoute_catchAll(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	if err := rr.RegisterRoute(CatchAllRoute, "s1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := rr.LookupServiceID("unknown.com"); !ok {
+		t.Fatalf("expected unknown.com to match the catch-all route")
+	}
+
+	if err := rr.UnregisterRoute(CatchAllRoute); err != nil {
+		t.Fatal(err)
+	}
+
+	if serviceID, ok := rr.LookupServiceID("unknown.com"); ok {
+		t.Errorf("expected unknown.com not to match, got %s", serviceID)
+	}
+}
+
+// TestRouteRegistry_LookupServiceID_weighted checks if the requests to a
+// weighted route are split across its services approximately by weight and
+// if removing the weights restores the original route.
+func TestRouteRegistry_LookupServiceID_weighted(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	if err := rr.RegisterRoute("*.example.com", "s1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	weights := []entity.RouteWeight{
+		{ServiceID: "s1", Weight: 3},
+		{ServiceID: "s2", Weight: 1},
+	}
+
+	if err := rr.SetWeights("*.example.com", weights); err != nil {
+		t.Fatal(err)
+	}
+
+	const lookups = 10000
+	counts := make(map[string]int)
+
+	for i := 0; i < lookups; i++ {
+		serviceID, ok := rr.LookupServiceID("www.example.com")
+		if !ok {
+			t.Fatal("expected www.example.com to match the weighted route")
+		}
+		counts[serviceID]++
+	}
+
+	if share := float64(counts["s2"]) / lookups; math.Abs(share-0.25) > 0.03 {
+		t.Errorf("expected s2 to receive about 25%% of the requests, got %.1f%%", share*100)
+	}
+
+	if counts["s1"]+counts["s2"] != lookups {
+		t.Errorf("expected all requests to be routed to s1 or s2, got %v", counts)
+	}
+
+	if err := rr.SetWeights("*.example.com", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 100; i++ {
+		if serviceID, _ := rr.LookupServiceID("www.example.com"); serviceID != "s1" {
+			t.Fatalf("expected the unweighted route to point to s1, got %s", serviceID)
+		}
+	}
+}
+
+// TestRouteRegistry_SetWeights_invalid checks if weights for unregistered
+// routes and weights that aren't greater than 0 are rejected.
+func TestRouteRegistry_SetWeights_invalid(t *testing.T) {
+	rr := NewRouteRegistry()
+
+	weights := []entity.RouteWeight{{ServiceID: "s1", Weight: 1}}
+
+	if err := rr.SetWeights("example.com", weights); err != ErrUnregisteredRoute {
+		t.Errorf("expected error %v, got %v", ErrUnregisteredRoute, err)
+	}
+
+	if err := rr.RegisterRoute("example.com", "s1", false); err != nil {
+		t.Fatal(err)
+	}
+
+	weights = append(weights, entity.RouteWeight{ServiceID: "s2", Weight: 0})
+
+	if err := rr.SetWeights("example.com", weights); err != ErrInvalidRouteWeight {
+		t.Errorf("expected error %v, got %v", ErrInvalidRouteWeight, err)
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"errors"
 	"github.com/dominikbraun/dice/entity"
 	"github.com/dominikbraun/dice/log"
+	"sync"
 )
 
 type (
@@ -37,6 +38,7 @@ var (
 	ErrServiceNotRemovable      = errors.New("service has attached instances on an attached node")
 	ErrUnregisteredDeployment   = errors.New("deployment is not registered")
 	ErrDeploymentNotRemovable   = errors.New("deployed instance is attached on an attached node")
+	ErrSchedulerMissing         = errors.New("service has no scheduler")
 )
 
 // ServiceRegistry is the global registry for all services known to Dice.
@@ -49,10 +51,22 @@ var (
 //
 // ServiceRegistry also offers methods for updating existing service data
 // and for registering new services or service deployments at runtime.
+//
+// The registry is accessed concurrently by the proxy, the API and the store
+// watcher, so all methods are safe for concurrent use. The Services map must
+// only be accessed directly before the registry is shared, for example when
+// initializing it. Afterwards, use Service, All or Update.
+//
+// Registered services are copied on write: Update, RegisterDeployment and
+// UnregisterDeployments replace a service with a modified copy, so that the
+// entity and the deployments of a service returned by LookupService, Service
+// or All never change. Only the nodes and instances of the deployments are
+// shared between the copies, and their states are changed in place.
 type ServiceRegistry struct {
 	Services      map[string]*Service
-	routeRegistry RouteRegistry
+	routeRegistry *RouteRegistry
 	logger        log.Logger
+	mutex         sync.RWMutex
 }
 
 // NewServiceRegistry creates a new ServiceRegistry instance that writes
@@ -82,9 +96,51 @@ func (sr *ServiceRegistry) Register(entity *entity.Service, build func(*entity.S
 
 // RegisterService registers a new service. Returns an error if the service
 // is already registered, unless force is set to `true`.
+//
+// A service without a scheduler can't be routed to any instance, so such a
+// service is never registered and ErrSchedulerMissing is returned instead.
 func (sr *ServiceRegistry) RegisterService(service *Service, force bool) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	return sr.registerService(service, force)
+}
+
+// ReplaceService registers a service and replaces the registered service
+// with the same ID in a single step, so that the service's routes remain
+// available while it is being replaced. Routes of the replaced service that
+// the new service doesn't have anymore will be unregistered.
+func (sr *ServiceRegistry) ReplaceService(service *Service) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	if service.Scheduler == nil {
+		return ErrSchedulerMissing
+	}
+
+	if previous, exists := sr.Services[service.Entity.ID]; exists {
+		for _, r := range previous.Entity.URLs {
+			if containsRoute(service.Entity.URLs, r) {
+				continue
+			}
+			if err := sr.routeRegistry.UnregisterRoute(r); err != nil && err != ErrUnregisteredRoute {
+				return err
+			}
+		}
+	}
+
+	return sr.registerService(service, true)
+}
+
+// registerService registers a service as described in RegisterService. The
+// caller has to hold the registry's lock.
+func (sr *ServiceRegistry) registerService(service *Service, force bool) error {
 	serviceID := service.Entity.ID
 
+	if service.Scheduler == nil {
+		return ErrSchedulerMissing
+	}
+
 	if _, exists := sr.Services[serviceID]; exists {
 		if !force {
 			return ErrServiceAlreadyRegistered
@@ -95,6 +151,9 @@ func (sr *ServiceRegistry) RegisterService(service *Service, force bool) error {
 		if err := sr.routeRegistry.RegisterRoute(r, serviceID, force); err != nil {
 			return err
 		}
+		if err := sr.routeRegistry.SetWeights(r, service.Entity.RouteWeights[r]); err != nil {
+			return err
+		}
 	}
 
 	sr.Services[serviceID] = service
@@ -105,6 +164,9 @@ func (sr *ServiceRegistry) RegisterService(service *Service, force bool) error {
 // an error if the service has attached instances on attached nodes, unless
 // force is set to `true`.
 func (sr *ServiceRegistry) UnregisterService(serviceID string, force bool) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
 	if _, exists := sr.Services[serviceID]; !exists {
 		return ErrUnregisteredService
 	}
@@ -130,6 +192,9 @@ func (sr *ServiceRegistry) UnregisterService(serviceID string, force bool) error
 // LookupService looks up the service available under a given route. The
 // second return value indicates whether the service could be found or not.
 func (sr *ServiceRegistry) LookupService(host string) (*Service, bool) {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
 	serviceID, exists := sr.routeRegistry.LookupServiceID(host)
 	if !exists {
 		return &Service{}, false
@@ -143,17 +208,53 @@ func (sr *ServiceRegistry) LookupService(host string) (*Service, bool) {
 	return &Service{}, false
 }
 
+// Service returns the registered service with the given ID. The second
+// return value indicates whether the service is registered.
+func (sr *ServiceRegistry) Service(serviceID string) (*Service, bool) {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	service, exists := sr.Services[serviceID]
+	return service, exists
+}
+
+// All returns all registered services mapped against their IDs. The map is
+// a copy and can be modified safely, while the services are shared with the
+// registry and may only be modified using Update.
+func (sr *ServiceRegistry) All() map[string]*Service {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	services := make(map[string]*Service, len(sr.Services))
+
+	for id, service := range sr.Services {
+		services[id] = service
+	}
+
+	return services
+}
+
 // Update is the public API for accessing the registry services and applying
 // an update function on each of them. This function may be used to update the
 // service entity itself or some node or instance information.
 //
 // Update should be the only way for other components to gain write-access to
-// the registry's internal services.
+// the registry's internal services. The update function is applied to a copy
+// of each service, which replaces the service once the function succeeded.
+// Since the registry is locked while the update function runs, the function
+// must not call any registry methods.
 func (sr *ServiceRegistry) Update(updateFunc func(service *Service) error) error {
-	for _, s := range sr.Services {
-		if err := updateFunc(s); err != nil {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	for id, s := range sr.Services {
+		updated := s.clone()
+
+		if err := updateFunc(updated); err != nil {
 			return err
 		}
+
+		sr.Services[id] = updated
 	}
 
 	return nil
@@ -162,28 +263,56 @@ func (sr *ServiceRegistry) Update(updateFunc func(service *Service) error) error
 // RegisterServiceURL registers a new public URL for a service. Returns an
 // error of the given URL already exists for this or another service.
 func (sr *ServiceRegistry) RegisterServiceURL(serviceID, url string) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
 	return sr.routeRegistry.RegisterRoute(url, serviceID, false)
 }
 
 // UnregisterServiceURL removes a public URL from the registry. Unregistering
 // an URL will cause Dice to return an error for requests related to that URL.
 func (sr *ServiceRegistry) UnregisterServiceURL(url string) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
 	return sr.routeRegistry.UnregisterRoute(url)
 }
 
+// SetRouteWeights splits the requests to a registered URL across multiple
+// services by weight. Passing no weights removes the split again.
+func (sr *ServiceRegistry) SetRouteWeights(url string, weights []entity.RouteWeight) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
+	return sr.routeRegistry.SetWeights(url, weights)
+}
+
+// Routes returns all registered routes mapped against the IDs of their
+// services. The returned map is a copy and can be modified safely.
+func (sr *ServiceRegistry) Routes() map[string]string {
+	sr.mutex.RLock()
+	defer sr.mutex.RUnlock()
+
+	return sr.routeRegistry.Routes()
+}
+
 // RegisterDeployment registers new service deployment. Returns an error
 // if the stored service in the `Instance` field is not registered yet.
 func (sr *ServiceRegistry) RegisterDeployment(deployment Deployment) error {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
 	serviceID := deployment.Instance.ServiceID
 
 	if _, exists := sr.Services[serviceID]; !exists {
 		return ErrUnregisteredService
 	}
 
-	service := sr.Services[serviceID]
+	service := sr.Services[serviceID].clone()
 	service.Deployments = append(service.Deployments, deployment)
 
 	service.Scheduler.UpdateDeployments(service.Deployments)
+	sr.Services[serviceID] = service
 
 	return nil
 }
@@ -205,6 +334,9 @@ func (sr *ServiceRegistry) RegisterDeployment(deployment Deployment) error {
 // However, it would be more safe to check UnregisterDeployments' return value
 // and inform the user if some deployments could not be removed safely.
 func (sr *ServiceRegistry) UnregisterDeployments(filter func(deployment Deployment) bool, force bool) bool {
+	sr.mutex.Lock()
+	defer sr.mutex.Unlock()
+
 	if !force {
 		for _, s := range sr.Services {
 			for _, d := range s.Deployments {
@@ -215,14 +347,24 @@ func (sr *ServiceRegistry) UnregisterDeployments(filter func(deployment Deployme
 		}
 	}
 
-	for _, s := range sr.Services {
-		for i, d := range s.Deployments {
-			if filter(d) {
-				s.Deployments[i] = s.Deployments[len(s.Deployments)-1]
-				s.Deployments = s.Deployments[:len(s.Deployments)-1]
+	for id, s := range sr.Services {
+		deployments := make([]Deployment, 0, len(s.Deployments))
+
+		for _, d := range s.Deployments {
+			if !filter(d) {
+				deployments = append(deployments, d)
 			}
 		}
-		s.Scheduler.UpdateDeployments(s.Deployments)
+
+		if len(deployments) == len(s.Deployments) {
+			continue
+		}
+
+		updated := s.clone()
+		updated.Deployments = deployments
+
+		updated.Scheduler.UpdateDeployments(updated.Deployments)
+		sr.Services[id] = updated
 	}
 
 	return true
@@ -244,3 +386,16 @@ func (sr *ServiceRegistry) indexOfDeployment(serviceID string, deployment Deploy
 
 	return -1, nil
 }
+
+// containsRoute checks if the given routes contain a route.
+func containsRoute(routes []string, route string) bool {
+	route = normalizeRoute(route)
+
+	for _, r := range routes {
+		if normalizeRoute(r) == route {
+			return true
+		}
+	}
+
+	return false
+}
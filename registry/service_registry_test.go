@@ -0,0 +1,156 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package registry provides the service registry and the route registry.
+//
+// While the core package as well as the store package represent the data
+// statically and storage-oriented, the registries provide a representation
+// required at runtime: In-memory, dynamic and quickly accessible.
+package registry
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/log"
+	"io/ioutil"
+	"testing"
+)
+
+// testScheduler is a scheduler that doesn't schedule any instances.
+type testScheduler struct{}
+
+func (ts testScheduler) Next() (*entity.Instance, error)  { return nil, nil }
+func (ts testScheduler) Peek() (*entity.Instance, error)  { return nil, nil }
+func (ts testScheduler) UpdateDeployments(_ []Deployment) {}
+func (ts testScheduler) Snapshot() SchedulerSnapshot      { return SchedulerSnapshot{} }
+
+// TestServiceRegistry_ReplaceService tests ServiceRegistry.ReplaceService
+// for a service whose URLs have changed. It asserts that the kept URL still
+// points to the service, the new URL has been registered and the removed
+// URL has been unregistered.
+func TestServiceRegistry_ReplaceService(t *testing.T) {
+	sr := NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	previous := &Service{
+		Entity:    &entity.Service{ID: "s1", URLs: []string{"a.example.com", "b.example.com"}},
+		Scheduler: testScheduler{},
+	}
+
+	if err := sr.RegisterService(previous, false); err != nil {
+		t.Fatal(err)
+	}
+
+	service := &Service{
+		Entity:    &entity.Service{ID: "s1", URLs: []string{"b.example.com", "c.example.com"}},
+		Scheduler: testScheduler{},
+	}
+
+	if err := sr.ReplaceService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	if s, ok := sr.Service("s1"); !ok || s != service {
+		t.Errorf("expected service s1 to be replaced, got %v", s)
+	}
+
+	expected := map[string]string{
+		"b.example.com": "s1",
+		"c.example.com": "s1",
+	}
+
+	routes := sr.Routes()
+
+	if len(routes) != len(expected) {
+		t.Fatalf("expected routes %v, got %v", expected, routes)
+	}
+
+	for route, serviceID := range expected {
+		if routes[route] != serviceID {
+			t.Errorf("expected route %s for service %s, got %v", route, serviceID, routes)
+		}
+	}
+
+	if err := sr.ReplaceService(&Service{Entity: &entity.Service{ID: "s1"}}); err != ErrSchedulerMissing {
+		t.Errorf("expected error %v, got %v", ErrSchedulerMissing, err)
+	}
+
+	if s, _ := sr.Service("s1"); s != service {
+		t.Errorf("expected service s1 to be kept, got %v", s)
+	}
+}
+
+// TestServiceRegistry_copyOnWrite tests that Update, RegisterDeployment and
+// UnregisterDeployments don't modify a service obtained from the registry
+// before, while the registry returns the updated service afterwards.
+func TestServiceRegistry_copyOnWrite(t *testing.T) {
+	sr := NewServiceRegistry(log.NewLogger(ioutil.Discard, log.ErrorLevel))
+
+	service := &Service{
+		Entity:    &entity.Service{ID: "s1", Name: "service", URLs: []string{"example.com"}},
+		Scheduler: testScheduler{},
+	}
+
+	if err := sr.RegisterService(service, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := sr.Update(func(s *Service) error {
+		s.Entity.Name = "renamed"
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if service.Entity.Name != "service" {
+		t.Errorf("expected previous service name service, got %s", service.Entity.Name)
+	}
+
+	if s, _ := sr.Service("s1"); s.Entity.Name != "renamed" {
+		t.Errorf("expected service name renamed, got %s", s.Entity.Name)
+	}
+
+	before, _ := sr.Service("s1")
+
+	deployment := Deployment{
+		Node:     &entity.Node{ID: "n1"},
+		Instance: &entity.Instance{ID: "i1", ServiceID: "s1", NodeID: "n1"},
+	}
+
+	if err := sr.RegisterDeployment(deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(before.Deployments) != 0 {
+		t.Errorf("expected previous service to have no deployments, got %d", len(before.Deployments))
+	}
+
+	registered, _ := sr.Service("s1")
+
+	if len(registered.Deployments) != 1 {
+		t.Fatalf("expected 1 deployment, got %d", len(registered.Deployments))
+	}
+
+	if ok := sr.UnregisterDeployments(func(d Deployment) bool {
+		return d.Instance.ID == "i1"
+	}, true); !ok {
+		t.Fatal("expected deployment to be unregistered")
+	}
+
+	if len(registered.Deployments) != 1 {
+		t.Errorf("expected previous service to keep 1 deployment, got %d", len(registered.Deployments))
+	}
+
+	if s, _ := sr.Service("s1"); len(s.Deployments) != 0 {
+		t.Errorf("expected no deployments, got %d", len(s.Deployments))
+	}
+}
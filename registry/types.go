@@ -23,9 +23,30 @@ import "github.com/dominikbraun/dice/entity"
 
 // Scheduler represents a load balancing algorithm that manages multiple
 // deployments of a service and returns the next instance using `Next`.
+//
+// Peek returns the instance that Next would return without changing the
+// state of the scheduler. It is used for debugging routing decisions, just
+// like Snapshot, which must not change the state of the scheduler either.
+//
+// Implementations have to be safe for concurrent use: The proxy calls Next
+// for concurrent requests, while UpdateDeployments may be called at any
+// time. A call to Next must never see partially replaced deployments.
 type Scheduler interface {
 	Next() (*entity.Instance, error)
+	Peek() (*entity.Instance, error)
 	UpdateDeployments(deployments []Deployment)
+	Snapshot() SchedulerSnapshot
+}
+
+// SchedulerSnapshot is a read-only copy of the state of a Scheduler at the
+// time the snapshot has been taken.
+//
+// Counters holds the internal counters of the scheduler, for example the
+// current index and weight counter of a Weighted Round Robin scheduler. The
+// counters of wrapped schedulers are prefixed with their role and a dot.
+type SchedulerSnapshot struct {
+	Deployments []Deployment
+	Counters    map[string]int
 }
 
 // Service is the service representation used by the registries. Compared
@@ -41,6 +62,53 @@ type Service struct {
 	Scheduler   Scheduler
 }
 
+// clone returns a copy of the service with its own entity and deployments,
+// so that the copy can be modified without affecting any readers of the
+// service. The nodes and instances of the deployments are still shared.
+func (s *Service) clone() *Service {
+	c := *s
+
+	if s.Entity != nil {
+		e := *s.Entity
+		c.Entity = &e
+	}
+
+	c.Deployments = append([]Deployment(nil), s.Deployments...)
+
+	return &c
+}
+
+// CanReuseScheduler indicates whether the scheduler of a previous version of
+// the service can be used for the service, so that its state is preserved.
+// This is the case if the balancing method and the set of deployments haven't
+// changed. The order of the deployments doesn't matter.
+func (s *Service) CanReuseScheduler(previous *Service) bool {
+	if previous.Scheduler == nil || s.Entity.BalancingMethod != previous.Entity.BalancingMethod {
+		return false
+	}
+
+	if len(s.Deployments) != len(previous.Deployments) {
+		return false
+	}
+
+	for _, d := range s.Deployments {
+		found := false
+
+		for _, p := range previous.Deployments {
+			if d.equals(p) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Deployment represents a physical service deployment, simply consisting
 // of an instance and the node it has been deployed to. This association
 // is used by the scheduler for load balancing.
@@ -17,6 +17,7 @@ package scheduler
 
 import (
 	"errors"
+	"fmt"
 	"github.com/dominikbraun/dice/registry"
 )
 
@@ -28,19 +29,68 @@ const (
 	RandomBalancing             BalancingMethod = "random"
 	RoundRobinBalancing         BalancingMethod = "round_robin"
 	WeightedRoundRobinBalancing BalancingMethod = "weighted_round_robin"
+	WeightedRandomBalancing     BalancingMethod = "weighted_random"
 )
 
 var (
 	ErrUnsupportedMethod = errors.New("balancing method is not supported")
 )
 
+// DefaultBalancing is the balancing method used for services that haven't
+// specified a balancing method explicitly.
+const DefaultBalancing = WeightedRoundRobinBalancing
+
 // New creates a new Scheduler instance depending on the provided balancing
 // method. The particular instance has read-only access to the deployments.
+//
+// For unknown balancing methods, an error wrapping ErrUnsupportedMethod will
+// be returned.
 func New(deployments []registry.Deployment, method BalancingMethod) (registry.Scheduler, error) {
 	switch method {
 	case WeightedRoundRobinBalancing:
 		return newWeightedRoundRobin(deployments), nil
+	case WeightedRandomBalancing:
+		return newWeightedRandom(deployments), nil
 	default:
-		return nil, ErrUnsupportedMethod
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedMethod, method)
+	}
+}
+
+// IsSupported indicates whether a scheduler for the given balancing method
+// can be created using New.
+func IsSupported(method BalancingMethod) bool {
+	_, err := New(nil, method)
+	return err == nil
+}
+
+// copyDeployments returns a copy of the deployments, so that a snapshot
+// isn't affected by replacing the deployments of a scheduler.
+func copyDeployments(deployments []registry.Deployment) []registry.Deployment {
+	deployments = append([]registry.Deployment(nil), deployments...)
+	if deployments == nil {
+		deployments = make([]registry.Deployment, 0)
 	}
+
+	return deployments
+}
+
+// mergeSnapshots merges the snapshots of wrapped schedulers into a single
+// snapshot. The deployments are appended in the given order of roles, and
+// each counter is prefixed with the role of its scheduler.
+func mergeSnapshots(snapshots map[string]registry.SchedulerSnapshot, roles ...string) registry.SchedulerSnapshot {
+	merged := registry.SchedulerSnapshot{
+		Deployments: make([]registry.Deployment, 0),
+		Counters:    make(map[string]int),
+	}
+
+	for _, role := range roles {
+		snapshot := snapshots[role]
+		merged.Deployments = append(merged.Deployments, snapshot.Deployments...)
+
+		for name, value := range snapshot.Counters {
+			merged.Counters[role+"."+name] = value
+		}
+	}
+
+	return merged
 }
@@ -0,0 +1,96 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"sync"
+	"testing"
+)
+
+// TestScheduler_UpdateDeployments_concurrent interleaves calls of Next and
+// UpdateDeployments for all schedulers. Both deployment sets contain alive
+// instances only, so Next must always return an instance of one of them.
+// Run it using the -race flag to detect unsynchronized access.
+func TestScheduler_UpdateDeployments_concurrent(t *testing.T) {
+	newDeployments := func(prefix string, count int) []registry.Deployment {
+		deployments := make([]registry.Deployment, count)
+
+		for i := range deployments {
+			deployments[i] = registry.Deployment{
+				Node:     &entity.Node{ID: prefix + "-node", Weight: 2, IsAttached: true, IsAlive: true, Zone: "z1"},
+				Instance: &entity.Instance{ID: fmt.Sprintf("%s%d", prefix, i), IsAttached: true, IsAlive: true},
+			}
+		}
+
+		return deployments
+	}
+
+	deploymentSets := [][]registry.Deployment{
+		newDeployments("a", 3),
+		newDeployments("b", 5),
+	}
+
+	schedulers := map[string]func() (registry.Scheduler, error){
+		"weighted_round_robin": func() (registry.Scheduler, error) {
+			return New(deploymentSets[0], WeightedRoundRobinBalancing)
+		},
+		"weighted_random": func() (registry.Scheduler, error) {
+			return New(deploymentSets[0], WeightedRandomBalancing)
+		},
+		"zone_aware": func() (registry.Scheduler, error) {
+			return NewZoneAware(deploymentSets[0], WeightedRoundRobinBalancing, "z1")
+		},
+	}
+
+	for name, newScheduler := range schedulers {
+		s, err := newScheduler()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var wg sync.WaitGroup
+		errs := make(chan error, 4)
+
+		for g := 0; g < 4; g++ {
+			wg.Add(1)
+
+			go func() {
+				defer wg.Done()
+
+				for i := 0; i < 500; i++ {
+					if _, err := s.Next(); err != nil {
+						errs <- err
+						return
+					}
+				}
+			}()
+		}
+
+		for i := 0; i < 200; i++ {
+			s.UpdateDeployments(deploymentSets[i%2])
+		}
+
+		wg.Wait()
+		close(errs)
+
+		for err := range errs {
+			t.Errorf("%s: expected Next to succeed during updates, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"math/rand"
+	"time"
+)
+
+const (
+	// minSlowStartFactor is the fraction of its weight an instance has
+	// right after it has been attached.
+	minSlowStartFactor = 0.1
+	// maxSlowStartAttempts is the number of instances SlowStart asks for
+	// before accepting an instance regardless of its warmup.
+	maxSlowStartAttempts = 8
+)
+
+// SlowStart is a scheduler that ramps up the traffic for instances that have
+// been attached recently, so that they can warm up their caches before
+// receiving their full share of requests.
+//
+// During the warmup window after AttachedSince, the effective weight of an
+// instance grows linearly from a small fraction to its configured weight.
+// SlowStart wraps another scheduler and accepts a warming instance returned
+// by it only with a probability equal to that fraction. Otherwise, the
+// wrapped scheduler is asked for another instance.
+type SlowStart struct {
+	scheduler registry.Scheduler
+	window    time.Duration
+	now       func() time.Time
+	random    func() float64
+}
+
+// NewSlowStart creates a new SlowStart scheduler wrapping scheduler with
+// the given warmup window.
+func NewSlowStart(scheduler registry.Scheduler, window time.Duration) *SlowStart {
+	ss := SlowStart{
+		scheduler: scheduler,
+		window:    window,
+		now:       time.Now,
+		random:    rand.Float64,
+	}
+
+	return &ss
+}
+
+// Next implements registry.Scheduler.Next. If all attempts to find an
+// instance that is accepted fail, the last instance will be returned.
+func (ss *SlowStart) Next() (*entity.Instance, error) {
+	var instance *entity.Instance
+	var err error
+
+	for attempt := 0; attempt < maxSlowStartAttempts; attempt++ {
+		instance, err = ss.scheduler.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if ss.random() < ss.factor(instance) {
+			return instance, nil
+		}
+	}
+
+	return instance, nil
+}
+
+// Peek implements registry.Scheduler.Peek. It doesn't take the warmup into
+// account, since it must not change the state of the wrapped scheduler.
+func (ss *SlowStart) Peek() (*entity.Instance, error) {
+	return ss.scheduler.Peek()
+}
+
+// UpdateDeployments implements registry.Scheduler.UpdateDeployments.
+func (ss *SlowStart) UpdateDeployments(deployments []registry.Deployment) {
+	ss.scheduler.UpdateDeployments(deployments)
+}
+
+// Snapshot implements registry.Scheduler.Snapshot by returning the snapshot
+// of the wrapped scheduler.
+func (ss *SlowStart) Snapshot() registry.SchedulerSnapshot {
+	return ss.scheduler.Snapshot()
+}
+
+// factor returns the fraction of its weight the instance currently has. It
+// is 1 for instances that have completed their warmup.
+func (ss *SlowStart) factor(instance *entity.Instance) float64 {
+	if instance.AttachedSince.IsZero() {
+		return 1
+	}
+
+	elapsed := ss.now().Sub(instance.AttachedSince)
+
+	if elapsed >= ss.window {
+		return 1
+	} else if elapsed <= 0 {
+		return minSlowStartFactor
+	}
+
+	return minSlowStartFactor + (1-minSlowStartFactor)*float64(elapsed)/float64(ss.window)
+}
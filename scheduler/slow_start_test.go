@@ -0,0 +1,81 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"math"
+	"testing"
+	"time"
+)
+
+// TestSlowStart_Next tests SlowStart.Next with two instances on nodes of the
+// same weight, one of which is halfway through its warmup window. It asserts
+// that the warming instance is selected with its reduced effective weight,
+// and that both instances are selected equally often after the warmup.
+func TestSlowStart_Next(t *testing.T) {
+	const requests = 20000
+
+	now := time.Now()
+	window := time.Minute
+
+	warm := &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}
+	warming := &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true, AttachedSince: now.Add(-window / 2)}
+
+	deployments := []registry.Deployment{
+		{Node: &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true}, Instance: warm},
+		{Node: &entity.Node{ID: "n2", Weight: 1, IsAttached: true, IsAlive: true}, Instance: warming},
+	}
+
+	wrapped, err := New(deployments, WeightedRandomBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ss := NewSlowStart(wrapped, window)
+	ss.now = func() time.Time { return now }
+
+	share := func() float64 {
+		var selected int
+
+		for i := 0; i < requests; i++ {
+			instance, err := ss.Next()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if instance.ID == warming.ID {
+				selected++
+			}
+		}
+
+		return float64(selected) / requests
+	}
+
+	// Halfway through the warmup, the effective weight is 0.55.
+	factor := minSlowStartFactor + (1-minSlowStartFactor)*0.5
+	expected := factor / (1 + factor)
+
+	if actual := share(); math.Abs(actual-expected) > 0.03 {
+		t.Errorf("expected the warming instance to receive %.2f of the requests, got %.2f", expected, actual)
+	}
+
+	now = now.Add(window)
+
+	if actual := share(); math.Abs(actual-0.5) > 0.03 {
+		t.Errorf("expected the warm instance to receive 0.50 of the requests, got %.2f", actual)
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"sync"
+)
+
+// StandbyAware is a scheduler that keeps standby instances idle as long as
+// a primary instance - an instance that isn't a standby instance - can be
+// selected. Only if none of the primary instances is available, it fails
+// over to the standby instances.
+//
+// Like ZoneAware, StandbyAware wraps two schedulers: One of them manages the
+// primary deployments and the other one manages the standby deployments.
+// Both are created using the same constructor, so they may be ZoneAware
+// schedulers themselves.
+type StandbyAware struct {
+	mutex   sync.RWMutex
+	primary registry.Scheduler
+	standby registry.Scheduler
+}
+
+// NewStandbyAware creates a new StandbyAware scheduler. The wrapped
+// schedulers are created using newScheduler, and any error returned by it
+// is returned as well.
+func NewStandbyAware(deployments []registry.Deployment, newScheduler func([]registry.Deployment) (registry.Scheduler, error)) (*StandbyAware, error) {
+	primary, standby := splitByStandby(deployments)
+
+	primaryScheduler, err := newScheduler(primary)
+	if err != nil {
+		return nil, err
+	}
+
+	standbyScheduler, err := newScheduler(standby)
+	if err != nil {
+		return nil, err
+	}
+
+	sa := StandbyAware{
+		primary: primaryScheduler,
+		standby: standbyScheduler,
+	}
+
+	return &sa, nil
+}
+
+// Next implements registry.Scheduler.Next. It asks the scheduler for the
+// primary instances first and fails over to the standby instances.
+func (sa *StandbyAware) Next() (*entity.Instance, error) {
+	sa.mutex.RLock()
+	defer sa.mutex.RUnlock()
+
+	if instance, err := sa.primary.Next(); err == nil {
+		return instance, nil
+	}
+
+	return sa.standby.Next()
+}
+
+// Peek implements registry.Scheduler.Peek, preferring the primary instances
+// just like Next does.
+func (sa *StandbyAware) Peek() (*entity.Instance, error) {
+	sa.mutex.RLock()
+	defer sa.mutex.RUnlock()
+
+	if instance, err := sa.primary.Peek(); err == nil {
+		return instance, nil
+	}
+
+	return sa.standby.Peek()
+}
+
+// UpdateDeployments implements registry.Scheduler.UpdateDeployments.
+func (sa *StandbyAware) UpdateDeployments(deployments []registry.Deployment) {
+	primary, standby := splitByStandby(deployments)
+
+	sa.mutex.Lock()
+	defer sa.mutex.Unlock()
+
+	sa.primary.UpdateDeployments(primary)
+	sa.standby.UpdateDeployments(standby)
+}
+
+// Snapshot implements registry.Scheduler.Snapshot. It combines the snapshots
+// of the schedulers for the primary and the standby instances.
+func (sa *StandbyAware) Snapshot() registry.SchedulerSnapshot {
+	sa.mutex.RLock()
+	defer sa.mutex.RUnlock()
+
+	return mergeSnapshots(map[string]registry.SchedulerSnapshot{
+		"primary": sa.primary.Snapshot(),
+		"standby": sa.standby.Snapshot(),
+	}, "primary", "standby")
+}
+
+// splitByStandby splits the deployments into primary and standby ones.
+func splitByStandby(deployments []registry.Deployment) ([]registry.Deployment, []registry.Deployment) {
+	primary := make([]registry.Deployment, 0)
+	standby := make([]registry.Deployment, 0)
+
+	for _, d := range deployments {
+		if d.Instance.Standby {
+			standby = append(standby, d)
+		} else {
+			primary = append(primary, d)
+		}
+	}
+
+	return primary, standby
+}
@@ -0,0 +1,87 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"testing"
+)
+
+// TestStandbyAware_Next tests StandbyAware.Next with two primary instances
+// and a standby instance. As long as a primary instance is alive, the
+// standby instance must stay idle. Once the primaries are dead, the standby
+// instance has to be selected, and once a primary is alive again, the
+// standby instance has to become idle again.
+func TestStandbyAware_Next(t *testing.T) {
+	node := &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true}
+
+	primary1 := &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}
+	primary2 := &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true}
+	standby := &entity.Instance{ID: "i3", IsAttached: true, IsAlive: true, Standby: true}
+
+	deployments := []registry.Deployment{
+		{Node: node, Instance: standby},
+		{Node: node, Instance: primary1},
+		{Node: node, Instance: primary2},
+	}
+
+	sa, err := NewStandbyAware(deployments, func(deployments []registry.Deployment) (registry.Scheduler, error) {
+		return New(deployments, WeightedRoundRobinBalancing)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	primary1.IsAlive = false
+
+	for i := 0; i < 10; i++ {
+		instance, err := sa.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instance.ID != primary2.ID {
+			t.Fatalf("expected primary instance %s, got %s", primary2.ID, instance.ID)
+		}
+	}
+
+	primary2.IsAlive = false
+
+	for i := 0; i < 3; i++ {
+		instance, err := sa.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instance.ID != standby.ID {
+			t.Errorf("expected failover to standby instance %s, got %s", standby.ID, instance.ID)
+		}
+	}
+
+	primary1.IsAlive = true
+
+	if instance, err := sa.Next(); err != nil || instance.ID != primary1.ID {
+		t.Errorf("expected recovered primary instance %s, got %v (%v)", primary1.ID, instance, err)
+	}
+
+	primary1.IsAlive = false
+	standby.IsAlive = false
+
+	if _, err := sa.Next(); err != ErrNoInstanceFound {
+		t.Errorf("expected error %v, got %v", ErrNoInstanceFound, err)
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"math/rand"
+	"sort"
+	"sync"
+)
+
+// WeightedRandom is a scheduler that randomly picks a deployment, where the
+// probability of a deployment being picked is proportional to its weight.
+//
+// Instances don't have a weight of their own, so the weight of a deployment
+// is just the weight of the node it has been deployed to rather than a
+// product of node and instance weight. A node of weight 2 will therefore be
+// selected twice as often as a node of weight 1 on average.
+//
+// Instances that are either detached or considered dead won't be selected,
+// just as instances that are deployed to a detached or dead node.
+//
+// WeightedRandom is safe for concurrent use. The deployments and their
+// weights are always replaced together.
+type WeightedRandom struct {
+	mutex       sync.RWMutex
+	deployments []registry.Deployment
+	// cumulativeWeights holds the sum of all deployment weights up to and
+	// including the deployment at the same index.
+	cumulativeWeights []int64
+}
+
+// newWeightedRandom creates a new WeightedRandom instance.
+func newWeightedRandom(deployments []registry.Deployment) *WeightedRandom {
+	wr := WeightedRandom{}
+	wr.UpdateDeployments(deployments)
+
+	return &wr
+}
+
+// Next implements registry.Scheduler.Next. It draws a random number within
+// the total weight and searches the deployment whose cumulative weight range
+// contains that number.
+//
+// If the drawn deployment isn't available, Next falls back to a draw among
+// the available deployments only. This requires the weights to be computed
+// again, but keeps the selection proportional to the weights.
+func (wr *WeightedRandom) Next() (*entity.Instance, error) {
+	wr.mutex.RLock()
+	defer wr.mutex.RUnlock()
+
+	if d, ok := draw(wr.deployments, wr.cumulativeWeights); ok && isAvailable(d) {
+		return d.Instance, nil
+	}
+
+	available := make([]registry.Deployment, 0, len(wr.deployments))
+
+	for _, d := range wr.deployments {
+		if isAvailable(d) {
+			available = append(available, d)
+		}
+	}
+
+	if d, ok := draw(available, cumulativeWeights(available)); ok {
+		return d.Instance, nil
+	}
+
+	return nil, ErrNoInstanceFound
+}
+
+// Peek implements registry.Scheduler.Peek. Since WeightedRandom doesn't have
+// any state, Peek draws an instance just like Next does. Therefore, the next
+// call to Next may return another instance.
+func (wr *WeightedRandom) Peek() (*entity.Instance, error) {
+	return wr.Next()
+}
+
+// UpdateDeployments implements registry.Scheduler.UpdateDeployments. It will
+// compute the cumulative weight table for the new deployments.
+func (wr *WeightedRandom) UpdateDeployments(deployments []registry.Deployment) {
+	weights := cumulativeWeights(deployments)
+
+	wr.mutex.Lock()
+	defer wr.mutex.Unlock()
+
+	wr.deployments = deployments
+	wr.cumulativeWeights = weights
+}
+
+// Snapshot implements registry.Scheduler.Snapshot. The only counter is the
+// total weight of all deployments.
+func (wr *WeightedRandom) Snapshot() registry.SchedulerSnapshot {
+	wr.mutex.RLock()
+	defer wr.mutex.RUnlock()
+
+	var total int64
+
+	if len(wr.cumulativeWeights) > 0 {
+		total = wr.cumulativeWeights[len(wr.cumulativeWeights)-1]
+	}
+
+	return registry.SchedulerSnapshot{
+		Deployments: copyDeployments(wr.deployments),
+		Counters: map[string]int{
+			"total_weight": int(total),
+		},
+	}
+}
+
+// cumulativeWeights builds the cumulative weight table for the deployments.
+func cumulativeWeights(deployments []registry.Deployment) []int64 {
+	weights := make([]int64, len(deployments))
+	var total int64
+
+	for i, d := range deployments {
+		total += int64(d.Node.Weight)
+		weights[i] = total
+	}
+
+	return weights
+}
+
+// draw randomly picks a deployment using the cumulative weight table. Returns
+// false if there is no deployment with a weight greater than zero.
+func draw(deployments []registry.Deployment, weights []int64) (registry.Deployment, bool) {
+	if len(weights) == 0 || weights[len(weights)-1] == 0 {
+		return registry.Deployment{}, false
+	}
+
+	n := rand.Int63n(weights[len(weights)-1])
+
+	index := sort.Search(len(weights), func(i int) bool {
+		return weights[i] > n
+	})
+
+	return deployments[index], true
+}
+
+// isAvailable checks if a deployment may receive requests.
+func isAvailable(d registry.Deployment) bool {
+	return d.Instance.IsAttached && d.Instance.IsAlive && d.Node.IsAttached && d.Node.IsAlive
+}
@@ -0,0 +1,73 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"math"
+	"testing"
+)
+
+// TestWeightedRandom_Next tests WeightedRandom.Next. It sets up 4 instances
+// deployed to nodes of different weights, one of them being dead. After many
+// draws, the share of each instance has to approximate the share of its node
+// weight within a small tolerance, and the dead instance mustn't be selected.
+func TestWeightedRandom_Next(t *testing.T) {
+	node1 := &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true}
+	node2 := &entity.Node{ID: "n2", Weight: 2, IsAttached: true, IsAlive: true}
+	node3 := &entity.Node{ID: "n3", Weight: 3, IsAttached: true, IsAlive: true}
+
+	instance1 := &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}
+	instance2 := &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true}
+	instance3 := &entity.Instance{ID: "i3", IsAttached: true, IsAlive: true}
+	instance4 := &entity.Instance{ID: "i4", IsAttached: true, IsAlive: false}
+
+	deployments := []registry.Deployment{
+		{Node: node1, Instance: instance1},
+		{Node: node2, Instance: instance2},
+		{Node: node3, Instance: instance3},
+		{Node: node3, Instance: instance4},
+	}
+
+	wr, err := New(deployments, WeightedRandomBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const draws = 60000
+	const tolerance = 0.02
+
+	counts := make(map[string]int)
+
+	for i := 0; i < draws; i++ {
+		instance, err := wr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		counts[instance.ID]++
+	}
+
+	assertions := map[string]float64{"i1": 1.0 / 6, "i2": 2.0 / 6, "i3": 3.0 / 6, "i4": 0}
+
+	for id, expected := range assertions {
+		share := float64(counts[id]) / draws
+
+		if math.Abs(share-expected) > tolerance {
+			t.Errorf("instance %s has a share of %.3f, expected %.3f", id, share, expected)
+		}
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"errors"
 	"github.com/dominikbraun/dice/entity"
 	"github.com/dominikbraun/dice/registry"
+	"sync"
 )
 
 var (
@@ -36,7 +37,15 @@ var (
 //
 // Instances that are either detached or considered dead won't be selected,
 // just as instances that are deployed to a detached or dead node.
+//
+// Instances deployed to a node of weight 0 will never be selected. This is
+// a valid way to park a node without detaching it. If all deployments are
+// deployed to such nodes, ErrNoInstanceFound will be returned.
+//
+// WeightedRoundRobin is safe for concurrent use. Replacing the deployments
+// doesn't affect a selection that is in progress.
 type WeightedRoundRobin struct {
+	mutex         sync.Mutex
 	deployments   []registry.Deployment
 	currentIndex  int
 	currentWeight uint8
@@ -55,45 +64,57 @@ func newWeightedRoundRobin(deployments []registry.Deployment) *WeightedRoundRobi
 
 // Next implements registry.Scheduler.Next. It is an implementation of the
 // Weighted Round Robin algorithm, respecting the rules described above.
+//
+// Next examines each deployment at most once, starting at the current index.
+// Only the deployment at the current index may be examined twice: first with
+// the current weight counter, and after all other deployments have been
+// examined, with a reset weight counter. If no deployment can be selected,
+// ErrNoInstanceFound is returned.
 func (wrr *WeightedRoundRobin) Next() (*entity.Instance, error) {
-	if len(wrr.deployments) == 0 {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
+	return wrr.next(&wrr.currentIndex, &wrr.currentWeight)
+}
+
+// Peek implements registry.Scheduler.Peek. It runs the same algorithm as
+// Next on a copy of the current index and weight counter.
+func (wrr *WeightedRoundRobin) Peek() (*entity.Instance, error) {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
+	currentIndex, currentWeight := wrr.currentIndex, wrr.currentWeight
+	return wrr.next(&currentIndex, &currentWeight)
+}
+
+// next selects the next instance as described for Next, starting at the
+// given index and weight counter. Both are updated during the selection.
+// The caller has to hold the mutex.
+func (wrr *WeightedRoundRobin) next(currentIndex *int, currentWeight *uint8) (*entity.Instance, error) {
+	count := len(wrr.deployments)
+
+	if count == 0 {
 		return nil, ErrNoInstanceFound
 	}
 
-	attempts := 0
-
-lookup:
-	for attempts <= len(wrr.deployments) {
+	for examined := 0; examined <= count; examined++ {
 		// index specifies the deployment that will be selected based on the
 		// request count and available deployments.
-		index := wrr.currentIndex % len(wrr.deployments)
-		d := (wrr.deployments)[index]
-
-		// Start a new lookup if the instance isn't attached or alive.
-		if !d.Instance.IsAttached || !d.Instance.IsAlive {
-			wrr.currentIndex++
-			wrr.currentWeight = uint8(0)
-			attempts++
-			continue lookup
-		}
+		index := *currentIndex % count
+		d := wrr.deployments[index]
 
 		// If the deployment node's weight is higher than the weight counter,
-		// there's still some capacity and we can pick that deployment.
-		if d.Node.Weight > wrr.currentWeight {
-			wrr.currentWeight++
+		// there's still some capacity and we can pick that deployment. This
+		// never applies to instances that aren't attached or alive and to
+		// nodes that are parked with a weight of 0.
+		if d.Instance.IsAttached && d.Instance.IsAlive && d.Node.Weight > *currentWeight {
+			*currentWeight++
 			return d.Instance, nil
 		}
 
-		// Otherwise, if the node's maximum weight has been reached, we move
-		// on to the next index and start a new lookup.
-		if d.Node.Weight == wrr.currentWeight {
-			wrr.currentIndex++
-			wrr.currentWeight = uint8(0)
-			attempts++
-			continue lookup
-		}
-
-		attempts++
+		// Otherwise, we move on to the next index and reset the weight counter.
+		*currentIndex = (index + 1) % count
+		*currentWeight = uint8(0)
 	}
 
 	return nil, ErrNoInstanceFound
@@ -101,5 +122,23 @@ lookup:
 
 // UpdateDeployments implements registry.Scheduler.UpdateDeployments.
 func (wrr *WeightedRoundRobin) UpdateDeployments(deployments []registry.Deployment) {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
 	wrr.deployments = deployments
 }
+
+// Snapshot implements registry.Scheduler.Snapshot. The counters are the
+// current index and the current weight counter.
+func (wrr *WeightedRoundRobin) Snapshot() registry.SchedulerSnapshot {
+	wrr.mutex.Lock()
+	defer wrr.mutex.Unlock()
+
+	return registry.SchedulerSnapshot{
+		Deployments: copyDeployments(wrr.deployments),
+		Counters: map[string]int{
+			"index":  wrr.currentIndex,
+			"weight": int(wrr.currentWeight),
+		},
+	}
+}
@@ -63,3 +63,216 @@ func TestWeightedRoundRobin_Next(t *testing.T) {
 		}
 	}
 }
+
+// TestWeightedRoundRobin_Next_zeroWeight tests WeightedRoundRobin.Next with
+// a mix of parked nodes with weight 0 and regular nodes. Instances deployed
+// to parked nodes must never be selected.
+func TestWeightedRoundRobin_Next_zeroWeight(t *testing.T) {
+	node1 := &entity.Node{ID: "n1", Weight: 0, IsAttached: true, IsAlive: true}
+	node2 := &entity.Node{ID: "n2", Weight: 2, IsAttached: true, IsAlive: true}
+	node3 := &entity.Node{ID: "n3", Weight: 0, IsAttached: true, IsAlive: true}
+	node4 := &entity.Node{ID: "n4", Weight: 1, IsAttached: true, IsAlive: true}
+
+	deployments := []registry.Deployment{
+		{Node: node1, Instance: &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}},
+		{Node: node2, Instance: &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true}},
+		{Node: node3, Instance: &entity.Instance{ID: "i3", IsAttached: true, IsAlive: true}},
+		{Node: node4, Instance: &entity.Instance{ID: "i4", IsAttached: true, IsAlive: true}},
+	}
+
+	wrr, err := New(deployments, WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertions := []string{"i2", "i2", "i4", "i2", "i2", "i4"}
+
+	for run := 0; run < len(assertions); run++ {
+		instance, err := wrr.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instance.ID != assertions[run] {
+			t.Errorf("selected instance %s, expected %s", instance.ID, assertions[run])
+		}
+	}
+}
+
+// TestWeightedRoundRobin_Next_allZeroWeights tests WeightedRoundRobin.Next
+// with all nodes having a weight of 0. Next must return ErrNoInstanceFound
+// on each call instead of selecting an instance.
+func TestWeightedRoundRobin_Next_allZeroWeights(t *testing.T) {
+	node1 := &entity.Node{ID: "n1", Weight: 0, IsAttached: true, IsAlive: true}
+	node2 := &entity.Node{ID: "n2", Weight: 0, IsAttached: true, IsAlive: true}
+
+	deployments := []registry.Deployment{
+		{Node: node1, Instance: &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}},
+		{Node: node2, Instance: &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true}},
+	}
+
+	wrr, err := New(deployments, WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 3; run++ {
+		if instance, err := wrr.Next(); err != ErrNoInstanceFound {
+			t.Errorf("got %v and error %v, expected %v", instance, err, ErrNoInstanceFound)
+		}
+	}
+}
+
+// TestWeightedRoundRobin_Next_edgeCases tests WeightedRoundRobin.Next with
+// a single deployment, with dead instances only and with a mix of dead and
+// alive instances. Each test case asserts a sequence of selected instances,
+// where an empty ID means that ErrNoInstanceFound is expected.
+func TestWeightedRoundRobin_Next_edgeCases(t *testing.T) {
+	light := &entity.Node{ID: "n1", Weight: 1, IsAttached: true, IsAlive: true}
+	heavy := &entity.Node{ID: "n2", Weight: 3, IsAttached: true, IsAlive: true}
+
+	alive := func(id string) *entity.Instance {
+		return &entity.Instance{ID: id, IsAttached: true, IsAlive: true}
+	}
+	dead := func(id string) *entity.Instance {
+		return &entity.Instance{ID: id, IsAttached: true, IsAlive: false}
+	}
+
+	tests := []struct {
+		name        string
+		deployments []registry.Deployment
+		assertions  []string
+	}{
+		{
+			name:        "single deployment of weight 1",
+			deployments: []registry.Deployment{{Node: light, Instance: alive("i1")}},
+			assertions:  []string{"i1", "i1", "i1"},
+		},
+		{
+			name:        "single deployment of weight 3",
+			deployments: []registry.Deployment{{Node: heavy, Instance: alive("i1")}},
+			assertions:  []string{"i1", "i1", "i1", "i1", "i1"},
+		},
+		{
+			name:        "single dead deployment",
+			deployments: []registry.Deployment{{Node: heavy, Instance: dead("i1")}},
+			assertions:  []string{"", ""},
+		},
+		{
+			name: "all dead",
+			deployments: []registry.Deployment{
+				{Node: light, Instance: dead("i1")},
+				{Node: heavy, Instance: dead("i2")},
+				{Node: light, Instance: dead("i3")},
+			},
+			assertions: []string{"", "", ""},
+		},
+		{
+			name: "mixed",
+			deployments: []registry.Deployment{
+				{Node: light, Instance: dead("i1")},
+				{Node: heavy, Instance: alive("i2")},
+				{Node: light, Instance: dead("i3")},
+				{Node: light, Instance: alive("i4")},
+			},
+			assertions: []string{"i2", "i2", "i2", "i4", "i2", "i2", "i2", "i4"},
+		},
+	}
+
+	for _, test := range tests {
+		wrr, err := New(test.deployments, WeightedRoundRobinBalancing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for run, assertedID := range test.assertions {
+			instance, err := wrr.Next()
+
+			if assertedID == "" {
+				if err != ErrNoInstanceFound {
+					t.Errorf("%s, run %d: got error %v, expected %v", test.name, run, err, ErrNoInstanceFound)
+				}
+				continue
+			}
+
+			if err != nil {
+				t.Errorf("%s, run %d: unexpected error %v", test.name, run, err)
+			} else if instance.ID != assertedID {
+				t.Errorf("%s, run %d: selected instance %s, expected %s", test.name, run, instance.ID, assertedID)
+			}
+		}
+	}
+}
+
+// TestWeightedRoundRobin_Peek tests WeightedRoundRobin.Peek. Each call of
+// Peek has to return the instance that the following call of Next returns.
+func TestWeightedRoundRobin_Peek(t *testing.T) {
+	node1 := &entity.Node{ID: "n1", Weight: 2, IsAttached: true, IsAlive: true}
+	node2 := &entity.Node{ID: "n2", Weight: 1, IsAttached: true, IsAlive: true}
+
+	deployments := []registry.Deployment{
+		{Node: node1, Instance: &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}},
+		{Node: node2, Instance: &entity.Instance{ID: "i2", IsAttached: true, IsAlive: false}},
+		{Node: node2, Instance: &entity.Instance{ID: "i3", IsAttached: true, IsAlive: true}},
+	}
+
+	wrr, err := New(deployments, WeightedRoundRobinBalancing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for run := 0; run < 6; run++ {
+		peeked, _ := wrr.Peek()
+		again, _ := wrr.Peek()
+		next, _ := wrr.Next()
+
+		if peeked.ID != again.ID || peeked.ID != next.ID {
+			t.Errorf("run %d: peeked %s and %s, but Next selected %s", run, peeked.ID, again.ID, next.ID)
+		}
+	}
+}
+
+// TestWeightedRoundRobin_Snapshot tests WeightedRoundRobin.Snapshot. The
+// snapshot has to reflect the configured deployments and the counters after
+// two calls to Next, and taking it must not change the next selection.
+func TestWeightedRoundRobin_Snapshot(t *testing.T) {
+	node1 := &entity.Node{ID: "n1", Weight: 2, IsAttached: true, IsAlive: true}
+	node2 := &entity.Node{ID: "n2", Weight: 1, IsAttached: true, IsAlive: true}
+
+	deployments := []registry.Deployment{
+		{Node: node1, Instance: &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}},
+		{Node: node2, Instance: &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true}},
+	}
+
+	wrr := newWeightedRoundRobin(deployments)
+
+	for i := 0; i < 2; i++ {
+		_, _ = wrr.Next()
+	}
+
+	snapshot := wrr.Snapshot()
+
+	if len(snapshot.Deployments) != len(deployments) {
+		t.Fatalf("got %d deployments, expected %d", len(snapshot.Deployments), len(deployments))
+	}
+
+	for i, d := range snapshot.Deployments {
+		if d.Instance.ID != deployments[i].Instance.ID {
+			t.Errorf("got instance %s at index %d, expected %s", d.Instance.ID, i, deployments[i].Instance.ID)
+		}
+	}
+
+	if snapshot.Counters["index"] != 0 || snapshot.Counters["weight"] != 2 {
+		t.Errorf("got counters %v, expected index 0 and weight 2", snapshot.Counters)
+	}
+
+	wrr.UpdateDeployments(deployments[:1])
+
+	if len(snapshot.Deployments) != len(deployments) {
+		t.Errorf("snapshot has been changed by updating the deployments")
+	}
+
+	if instance, _ := wrr.Next(); instance.ID != "i1" {
+		t.Errorf("selected instance %s after the snapshot, expected i1", instance.ID)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"sync"
+)
+
+// ZoneAware is a scheduler that prefers instances deployed to nodes in the
+// local zone, which is the zone the proxy is running in. Only if none of
+// these instances is available, an instance from another zone is selected.
+//
+// ZoneAware wraps two schedulers of the same balancing method: One of them
+// manages the deployments in the local zone and the other one manages all
+// remaining deployments. Both schedulers are updated at once, so that Next
+// never sees the new local deployments along with the old remaining ones.
+type ZoneAware struct {
+	mutex    sync.RWMutex
+	zone     string
+	local    registry.Scheduler
+	fallback registry.Scheduler
+}
+
+// NewZoneAware creates a new ZoneAware scheduler for the given local zone.
+// The wrapped schedulers are created using New with the provided balancing
+// method, so the same errors as for New may be returned.
+func NewZoneAware(deployments []registry.Deployment, method BalancingMethod, zone string) (*ZoneAware, error) {
+	local, remote := splitByZone(deployments, zone)
+
+	localScheduler, err := New(local, method)
+	if err != nil {
+		return nil, err
+	}
+
+	fallbackScheduler, err := New(remote, method)
+	if err != nil {
+		return nil, err
+	}
+
+	za := ZoneAware{
+		zone:     zone,
+		local:    localScheduler,
+		fallback: fallbackScheduler,
+	}
+
+	return &za, nil
+}
+
+// Next implements registry.Scheduler.Next. It asks the scheduler for the
+// local zone first and falls back to the other zones if it fails.
+func (za *ZoneAware) Next() (*entity.Instance, error) {
+	za.mutex.RLock()
+	defer za.mutex.RUnlock()
+
+	if instance, err := za.local.Next(); err == nil {
+		return instance, nil
+	}
+
+	return za.fallback.Next()
+}
+
+// Peek implements registry.Scheduler.Peek, preferring the local zone just
+// like Next does.
+func (za *ZoneAware) Peek() (*entity.Instance, error) {
+	za.mutex.RLock()
+	defer za.mutex.RUnlock()
+
+	if instance, err := za.local.Peek(); err == nil {
+		return instance, nil
+	}
+
+	return za.fallback.Peek()
+}
+
+// UpdateDeployments implements registry.Scheduler.UpdateDeployments.
+func (za *ZoneAware) UpdateDeployments(deployments []registry.Deployment) {
+	local, remote := splitByZone(deployments, za.zone)
+
+	za.mutex.Lock()
+	defer za.mutex.Unlock()
+
+	za.local.UpdateDeployments(local)
+	za.fallback.UpdateDeployments(remote)
+}
+
+// Snapshot implements registry.Scheduler.Snapshot. It combines the snapshots
+// of the schedulers for the local zone and the other zones.
+func (za *ZoneAware) Snapshot() registry.SchedulerSnapshot {
+	za.mutex.RLock()
+	defer za.mutex.RUnlock()
+
+	return mergeSnapshots(map[string]registry.SchedulerSnapshot{
+		"local":    za.local.Snapshot(),
+		"fallback": za.fallback.Snapshot(),
+	}, "local", "fallback")
+}
+
+// splitByZone splits the deployments into those deployed to a node in the
+// given zone and all others.
+func splitByZone(deployments []registry.Deployment, zone string) ([]registry.Deployment, []registry.Deployment) {
+	local := make([]registry.Deployment, 0)
+	remote := make([]registry.Deployment, 0)
+
+	for _, d := range deployments {
+		if d.Node.Zone == zone {
+			local = append(local, d)
+		} else {
+			remote = append(remote, d)
+		}
+	}
+
+	return local, remote
+}
@@ -0,0 +1,76 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scheduler provides scheduler implementations for load balancing.
+package scheduler
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/registry"
+	"testing"
+)
+
+// TestZoneAware_Next tests ZoneAware.Next with instances in a local and a
+// remote zone. As long as the local instances are alive, only they may be
+// selected. Once they're dead, the remote instance has to be selected.
+func TestZoneAware_Next(t *testing.T) {
+	localNode := &entity.Node{ID: "n1", Weight: 1, Zone: "eu-1", IsAttached: true, IsAlive: true}
+	remoteNode := &entity.Node{ID: "n2", Weight: 1, Zone: "us-1", IsAttached: true, IsAlive: true}
+
+	local1 := &entity.Instance{ID: "i1", IsAttached: true, IsAlive: true}
+	local2 := &entity.Instance{ID: "i2", IsAttached: true, IsAlive: true}
+	remote := &entity.Instance{ID: "i3", IsAttached: true, IsAlive: true}
+
+	deployments := []registry.Deployment{
+		{Node: remoteNode, Instance: remote},
+		{Node: localNode, Instance: local1},
+		{Node: localNode, Instance: local2},
+	}
+
+	za, err := NewZoneAware(deployments, WeightedRoundRobinBalancing, "eu-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 10; i++ {
+		instance, err := za.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instance.ID == remote.ID {
+			t.Fatalf("expected a local instance, got remote instance %s", instance.ID)
+		}
+	}
+
+	local1.IsAlive = false
+	local2.IsAlive = false
+
+	for i := 0; i < 3; i++ {
+		instance, err := za.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if instance.ID != remote.ID {
+			t.Errorf("expected fallback to remote instance %s, got %s", remote.ID, instance.ID)
+		}
+	}
+
+	remote.IsAlive = false
+
+	if _, err := za.Next(); err != ErrNoInstanceFound {
+		t.Errorf("expected error %v, got %v", ErrNoInstanceFound, err)
+	}
+}
@@ -17,8 +17,11 @@ package store
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/boltdb/bolt"
 	"github.com/dominikbraun/dice/entity"
+	"os"
+	"path/filepath"
 )
 
 type Bucket []byte
@@ -30,16 +33,23 @@ var (
 	instanceBucket       Bucket = []byte("instances")
 	ErrBucketNotFound    error  = errors.New("bucket could not be found")
 	ErrMarshallingFailed error  = errors.New("marshalling of entity failed")
+	ErrPathNotWritable   error  = errors.New("store path is not writable")
 )
 
 type KVStore struct {
 	internal *bolt.DB
 }
 
+// NewKVStore opens the store file at the given path or creates it if it
+// doesn't exist yet. Missing parent directories will be created as well.
 func NewKVStore(path string) (*KVStore, error) {
 	var kv KVStore
 	var err error
 
+	if err = ensureWritable(path); err != nil {
+		return nil, fmt.Errorf("%w: %s (%v)", ErrPathNotWritable, path, err)
+	}
+
 	if kv.internal, err = bolt.Open(path, 0600, nil); err != nil {
 		return nil, err
 	}
@@ -61,26 +71,35 @@ func (kv *KVStore) CreateNode(node *entity.Node) error {
 }
 
 func (kv *KVStore) FindNodes(filter NodeFilter) ([]*entity.Node, error) {
-	values, err := kv.getAll(nodeBucket)
-	if len(values) == 0 || err != nil {
+	var nodes []*entity.Node
+
+	err := kv.ForEachNode(func(node *entity.Node) (bool, error) {
+		if filter(node) {
+			nodes = append(nodes, node)
+		}
+		return false, nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	nodes := make([]*entity.Node, 0)
+	return nodes, nil
+}
 
-	for _, v := range values {
+// ForEachNode invokes visit for each stored node in the order of their IDs.
+// The nodes are read using a cursor, so that the iteration can be stopped
+// without reading the remaining nodes.
+func (kv *KVStore) ForEachNode(visit NodeVisitor) error {
+	return kv.forEach(nodeBucket, func(value []byte) (bool, error) {
 		var node entity.Node
 
-		if err = json.Unmarshal(v, &node); err != nil {
-			return nil, ErrMarshallingFailed
-		}
-
-		if filter(&node) {
-			nodes = append(nodes, &node)
+		if err := json.Unmarshal(value, &node); err != nil {
+			return true, ErrMarshallingFailed
 		}
-	}
 
-	return nodes, nil
+		return visit(&node)
+	})
 }
 
 func (kv *KVStore) FindNode(id string) (*entity.Node, error) {
@@ -116,26 +135,35 @@ func (kv *KVStore) CreateService(service *entity.Service) error {
 }
 
 func (kv *KVStore) FindServices(filter ServiceFilter) ([]*entity.Service, error) {
-	values, err := kv.getAll(serviceBucket)
-	if len(values) == 0 || err != nil {
+	var services []*entity.Service
+
+	err := kv.ForEachService(func(service *entity.Service) (bool, error) {
+		if filter(service) {
+			services = append(services, service)
+		}
+		return false, nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	services := make([]*entity.Service, 0)
+	return services, nil
+}
 
-	for _, v := range values {
+// ForEachService invokes visit for each stored service in the order of their IDs.
+// The services are read using a cursor, so that the iteration can be stopped
+// without reading the remaining services.
+func (kv *KVStore) ForEachService(visit ServiceVisitor) error {
+	return kv.forEach(serviceBucket, func(value []byte) (bool, error) {
 		var service entity.Service
 
-		if err = json.Unmarshal(v, &service); err != nil {
-			return nil, ErrMarshallingFailed
+		if err := json.Unmarshal(value, &service); err != nil {
+			return true, ErrMarshallingFailed
 		}
 
-		if filter(&service) {
-			services = append(services, &service)
-		}
-	}
-
-	return services, nil
+		return visit(&service)
+	})
 }
 
 func (kv *KVStore) FindService(id string) (*entity.Service, error) {
@@ -171,26 +199,35 @@ func (kv *KVStore) CreateInstance(instance *entity.Instance) error {
 }
 
 func (kv *KVStore) FindInstances(filter InstanceFilter) ([]*entity.Instance, error) {
-	values, err := kv.getAll(instanceBucket)
-	if len(values) == 0 || err != nil {
+	var instances []*entity.Instance
+
+	err := kv.ForEachInstance(func(instance *entity.Instance) (bool, error) {
+		if filter(instance) {
+			instances = append(instances, instance)
+		}
+		return false, nil
+	})
+
+	if err != nil {
 		return nil, err
 	}
 
-	instances := make([]*entity.Instance, 0)
+	return instances, nil
+}
 
-	for _, v := range values {
+// ForEachInstance invokes visit for each stored instance in the order of their IDs.
+// The instances are read using a cursor, so that the iteration can be stopped
+// without reading the remaining instances.
+func (kv *KVStore) ForEachInstance(visit InstanceVisitor) error {
+	return kv.forEach(instanceBucket, func(value []byte) (bool, error) {
 		var instance entity.Instance
 
-		if err = json.Unmarshal(v, &instance); err != nil {
-			return nil, ErrMarshallingFailed
+		if err := json.Unmarshal(value, &instance); err != nil {
+			return true, ErrMarshallingFailed
 		}
 
-		if filter(&instance) {
-			instances = append(instances, &instance)
-		}
-	}
-
-	return instances, nil
+		return visit(&instance)
+	})
 }
 
 func (kv *KVStore) FindInstance(id string) (*entity.Instance, error) {
@@ -212,6 +249,22 @@ func (kv *KVStore) UpdateInstance(id string, source *entity.Instance) error {
 	return kv.CreateInstance(source)
 }
 
+// UpdateInstances updates all given instances in a single transaction. If
+// an instance can't be updated, none of the instances will be updated.
+func (kv *KVStore) UpdateInstances(sources []*entity.Instance) error {
+	values := make(map[string][]byte, len(sources))
+
+	for _, instance := range sources {
+		value, err := json.Marshal(instance)
+		if err != nil {
+			return ErrMarshallingFailed
+		}
+		values[instance.ID] = value
+	}
+
+	return kv.setAll(instanceBucket, values)
+}
+
 func (kv *KVStore) DeleteInstance(id string) error {
 	return kv.delete(instanceBucket, id)
 }
@@ -258,6 +311,26 @@ func (kv *KVStore) set(bucket Bucket, key string, value []byte) error {
 	return kv.internal.Update(fn)
 }
 
+// setAll stores all given values in a single transaction.
+func (kv *KVStore) setAll(bucket Bucket, values map[string][]byte) error {
+	fn := func(tx *bolt.Tx) error {
+		b := tx.Bucket(diceBucket).Bucket(bucket)
+		if b == nil {
+			return ErrBucketNotFound
+		}
+
+		for key, value := range values {
+			if err := b.Put([]byte(key), value); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	return kv.internal.Update(fn)
+}
+
 func (kv *KVStore) get(bucket Bucket, key string) ([]byte, error) {
 	var result []byte
 
@@ -282,28 +355,26 @@ func (kv *KVStore) get(bucket Bucket, key string) ([]byte, error) {
 	return result, nil
 }
 
-func (kv *KVStore) getAll(bucket Bucket) ([][]byte, error) {
-	var result [][]byte
-
-	fn := func(tx *bolt.Tx) error {
+// forEach iterates over all values of a bucket using a cursor and invokes
+// fn for each value until fn returns true or an error. The value is only
+// valid until fn returns.
+func (kv *KVStore) forEach(bucket Bucket, fn func(value []byte) (bool, error)) error {
+	return kv.internal.View(func(tx *bolt.Tx) error {
 		b := tx.Bucket(diceBucket).Bucket(bucket)
 		if b == nil {
 			return ErrBucketNotFound
 		}
 
-		_ = b.ForEach(func(k, v []byte) error {
-			result = append(result, v)
-			return nil
-		})
-
-		return nil
-	}
+		cursor := b.Cursor()
 
-	if err := kv.internal.View(fn); err != nil {
-		return nil, err
-	}
+		for k, v := cursor.First(); k != nil; k, v = cursor.Next() {
+			if stop, err := fn(v); stop || err != nil {
+				return err
+			}
+		}
 
-	return result, nil
+		return nil
+	})
 }
 
 func (kv *KVStore) delete(bucket Bucket, key string) error {
@@ -318,3 +389,18 @@ func (kv *KVStore) delete(bucket Bucket, key string) error {
 
 	return kv.internal.Update(fn)
 }
+
+// ensureWritable creates all missing parent directories of the given path
+// and checks if the file at that path can be opened for writing.
+func ensureWritable(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	return file.Close()
+}
@@ -15,8 +15,13 @@
 package store
 
 import (
+	"errors"
 	"github.com/dominikbraun/dice/entity"
 	"github.com/dominikbraun/dice/types"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -155,3 +160,229 @@ func TestKVStore_DeleteNode(t *testing.T) {
 		t.Errorf("got node %v, expected nil", deletedNode.ID)
 	}
 }
+
+func TestKVStore_FindServices_subset(t *testing.T) {
+	store, cleanup := newTempKVStore(t)
+	defer cleanup()
+
+	services, err := store.FindServices(AllServicesFilter)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(services) != 0 {
+		t.Errorf("%v services found in empty bucket, %v expected", len(services), 0)
+	}
+
+	for _, name := range []string{"api", "web", "api-v2"} {
+		service, _ := entity.NewService(name, types.ServiceCreateOptions{})
+
+		if err := store.CreateService(service); err != nil {
+			t.Error(err)
+		}
+	}
+
+	services, err = store.FindServices(func(service *entity.Service) bool {
+		return service.Name != "web"
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(services) != 2 {
+		t.Errorf("%v services found, %v expected", len(services), 2)
+	}
+
+	for _, s := range services {
+		if s == nil {
+			t.Errorf("found nil service in %v", services)
+		}
+	}
+}
+
+func TestKVStore_FindInstances_subset(t *testing.T) {
+	store, cleanup := newTempKVStore(t)
+	defer cleanup()
+
+	instances, err := store.FindInstances(AllInstancesFilter)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(instances) != 0 {
+		t.Errorf("%v instances found in empty bucket, %v expected", len(instances), 0)
+	}
+
+	for _, url := range []string{"n1:8000", "n1:8001", "n2:8000"} {
+		instance, _ := entity.NewInstance("s1", url[:2], url, types.InstanceCreateOptions{})
+
+		if err := store.CreateInstance(instance); err != nil {
+			t.Error(err)
+		}
+	}
+
+	instances, err = store.FindInstances(func(instance *entity.Instance) bool {
+		return instance.NodeID == "n1"
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(instances) != 2 {
+		t.Errorf("%v instances found, %v expected", len(instances), 2)
+	}
+
+	for _, i := range instances {
+		if i == nil {
+			t.Errorf("found nil instance in %v", instances)
+		}
+	}
+}
+
+// newTempKVStore creates a KVStore in a temporary directory. The returned
+// function closes the store and removes the directory.
+// TestKVStore_ForEachService tests if the iteration stops as soon as the
+// visitor returns true, if an error returned by the visitor is passed on
+// and if FindServices, which is based on ForEachService, filters correctly.
+func TestKVStore_ForEachService(t *testing.T) {
+	store, cleanup := newTempKVStore(t)
+	defer cleanup()
+
+	for _, name := range []string{"api", "web", "api-v2", "docs"} {
+		service, _ := entity.NewService(name, types.ServiceCreateOptions{})
+
+		if err := store.CreateService(service); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	visited := 0
+
+	err := store.ForEachService(func(service *entity.Service) (bool, error) {
+		visited++
+		return visited == 2, nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if visited != 2 {
+		t.Errorf("%v services visited, %v expected", visited, 2)
+	}
+
+	errVisit := errors.New("visit failed")
+	visited = 0
+
+	err = store.ForEachService(func(service *entity.Service) (bool, error) {
+		visited++
+		return false, errVisit
+	})
+	if err != errVisit || visited != 1 {
+		t.Errorf("got error %v after %v services, expected %v after 1", err, visited, errVisit)
+	}
+
+	services, err := store.FindServices(func(service *entity.Service) bool {
+		return strings.HasPrefix(service.Name, "api")
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(services) != 2 {
+		t.Errorf("%v services found, %v expected", len(services), 2)
+	}
+
+	for _, s := range services {
+		if !strings.HasPrefix(s.Name, "api") {
+			t.Errorf("found service %s that doesn't match the filter", s.Name)
+		}
+	}
+}
+
+func newTempKVStore(t *testing.T) (*KVStore, func()) {
+	dir, err := ioutil.TempDir("", "dice-store")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store, err := NewKVStore(filepath.Join(dir, "dice-store"))
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+
+	return store, func() {
+		_ = store.Close()
+		_ = os.RemoveAll(dir)
+	}
+}
+
+func TestNewKVStore_path(t *testing.T) {
+	dir, err := ioutil.TempDir("", "dice-store-path")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "data", "dice-store")
+
+	store, err := NewKVStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("store file has not been created at %s: %v", path, err)
+	}
+}
+
+func TestNewKVStore_notWritable(t *testing.T) {
+	file, err := ioutil.TempFile("", "dice-store-file")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+	defer os.Remove(file.Name())
+
+	// A regular file can't be used as parent directory of the store file.
+	path := filepath.Join(file.Name(), "dice-store")
+
+	if _, err := NewKVStore(path); !errors.Is(err, ErrPathNotWritable) {
+		t.Errorf("expected %v, got %v", ErrPathNotWritable, err)
+	}
+}
+
+// TestKVStore_UpdateInstances tests if all instances are updated at once.
+func TestKVStore_UpdateInstances(t *testing.T) {
+	store, cleanup := newTempKVStore(t)
+	defer cleanup()
+
+	instances := make([]*entity.Instance, 0)
+
+	for _, url := range []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"} {
+		instance, _ := entity.NewInstance("s1", "n1", url, types.InstanceCreateOptions{})
+
+		if err := store.CreateInstance(instance); err != nil {
+			t.Fatal(err)
+		}
+
+		instance.IsAttached = true
+		instances = append(instances, instance)
+	}
+
+	if err := store.UpdateInstances(instances[:2]); err != nil {
+		t.Fatal(err)
+	}
+
+	for i, instance := range instances {
+		stored, err := store.FindInstance(instance.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if expected := i < 2; stored == nil || stored.IsAttached != expected {
+			t.Errorf("got instance %v, expected IsAttached=%v", stored, expected)
+		}
+	}
+}
@@ -0,0 +1,335 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/entity"
+	"sort"
+	"sync"
+)
+
+// MemoryStore is an EntityStore that keeps all entities in memory. It can
+// be used for tests and for running Dice without touching the disk. All
+// entities will be lost when the process exits.
+//
+// Just like KVStore, MemoryStore stores the entities in serialized form so
+// that changes to a returned entity don't affect the stored entity.
+type MemoryStore struct {
+	buckets map[string]map[string][]byte
+	mutex   sync.RWMutex
+}
+
+// NewMemoryStore creates a new, empty MemoryStore instance.
+func NewMemoryStore() *MemoryStore {
+	ms := MemoryStore{
+		buckets: map[string]map[string][]byte{
+			string(nodeBucket):     make(map[string][]byte),
+			string(serviceBucket):  make(map[string][]byte),
+			string(instanceBucket): make(map[string][]byte),
+		},
+	}
+
+	return &ms
+}
+
+func (ms *MemoryStore) CreateNode(node *entity.Node) error {
+	return ms.set(nodeBucket, node.ID, node)
+}
+
+func (ms *MemoryStore) FindNodes(filter NodeFilter) ([]*entity.Node, error) {
+	values := ms.getAll(nodeBucket)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	nodes := make([]*entity.Node, 0)
+
+	for _, v := range values {
+		var node entity.Node
+
+		if err := json.Unmarshal(v, &node); err != nil {
+			return nil, ErrMarshallingFailed
+		}
+
+		if filter(&node) {
+			nodes = append(nodes, &node)
+		}
+	}
+
+	return nodes, nil
+}
+
+// ForEachNode invokes visit for each stored node in the order of their IDs.
+func (ms *MemoryStore) ForEachNode(visit NodeVisitor) error {
+	values := ms.getAll(nodeBucket)
+
+	for _, v := range values {
+		var node entity.Node
+
+		if err := json.Unmarshal(v, &node); err != nil {
+			return ErrMarshallingFailed
+		}
+
+		if stop, err := visit(&node); stop || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) FindNode(id string) (*entity.Node, error) {
+	value := ms.get(nodeBucket, id)
+	if value == nil {
+		return nil, nil
+	}
+
+	var node entity.Node
+
+	if err := json.Unmarshal(value, &node); err != nil {
+		return nil, ErrMarshallingFailed
+	}
+
+	return &node, nil
+}
+
+func (ms *MemoryStore) UpdateNode(id string, source *entity.Node) error {
+	return ms.CreateNode(source)
+}
+
+func (ms *MemoryStore) DeleteNode(id string) error {
+	ms.delete(nodeBucket, id)
+	return nil
+}
+
+func (ms *MemoryStore) CreateService(service *entity.Service) error {
+	return ms.set(serviceBucket, service.ID, service)
+}
+
+func (ms *MemoryStore) FindServices(filter ServiceFilter) ([]*entity.Service, error) {
+	values := ms.getAll(serviceBucket)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	services := make([]*entity.Service, 0)
+
+	for _, v := range values {
+		var service entity.Service
+
+		if err := json.Unmarshal(v, &service); err != nil {
+			return nil, ErrMarshallingFailed
+		}
+
+		if filter(&service) {
+			services = append(services, &service)
+		}
+	}
+
+	return services, nil
+}
+
+// ForEachService invokes visit for each stored service in the order of their IDs.
+func (ms *MemoryStore) ForEachService(visit ServiceVisitor) error {
+	values := ms.getAll(serviceBucket)
+
+	for _, v := range values {
+		var service entity.Service
+
+		if err := json.Unmarshal(v, &service); err != nil {
+			return ErrMarshallingFailed
+		}
+
+		if stop, err := visit(&service); stop || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) FindService(id string) (*entity.Service, error) {
+	value := ms.get(serviceBucket, id)
+	if value == nil {
+		return nil, nil
+	}
+
+	var service entity.Service
+
+	if err := json.Unmarshal(value, &service); err != nil {
+		return nil, ErrMarshallingFailed
+	}
+
+	return &service, nil
+}
+
+func (ms *MemoryStore) UpdateService(id string, source *entity.Service) error {
+	return ms.CreateService(source)
+}
+
+func (ms *MemoryStore) DeleteService(id string) error {
+	ms.delete(serviceBucket, id)
+	return nil
+}
+
+func (ms *MemoryStore) CreateInstance(instance *entity.Instance) error {
+	return ms.set(instanceBucket, instance.ID, instance)
+}
+
+func (ms *MemoryStore) FindInstances(filter InstanceFilter) ([]*entity.Instance, error) {
+	values := ms.getAll(instanceBucket)
+	if len(values) == 0 {
+		return nil, nil
+	}
+
+	instances := make([]*entity.Instance, 0)
+
+	for _, v := range values {
+		var instance entity.Instance
+
+		if err := json.Unmarshal(v, &instance); err != nil {
+			return nil, ErrMarshallingFailed
+		}
+
+		if filter(&instance) {
+			instances = append(instances, &instance)
+		}
+	}
+
+	return instances, nil
+}
+
+// ForEachInstance invokes visit for each stored instance in the order of their IDs.
+func (ms *MemoryStore) ForEachInstance(visit InstanceVisitor) error {
+	values := ms.getAll(instanceBucket)
+
+	for _, v := range values {
+		var instance entity.Instance
+
+		if err := json.Unmarshal(v, &instance); err != nil {
+			return ErrMarshallingFailed
+		}
+
+		if stop, err := visit(&instance); stop || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) FindInstance(id string) (*entity.Instance, error) {
+	value := ms.get(instanceBucket, id)
+	if value == nil {
+		return nil, nil
+	}
+
+	var instance entity.Instance
+
+	if err := json.Unmarshal(value, &instance); err != nil {
+		return nil, ErrMarshallingFailed
+	}
+
+	return &instance, nil
+}
+
+func (ms *MemoryStore) UpdateInstance(id string, source *entity.Instance) error {
+	return ms.CreateInstance(source)
+}
+
+// UpdateInstances updates all given instances at once. If an instance can't
+// be serialized, none of the instances will be updated.
+func (ms *MemoryStore) UpdateInstances(sources []*entity.Instance) error {
+	values := make(map[string][]byte, len(sources))
+
+	for _, instance := range sources {
+		value, err := json.Marshal(instance)
+		if err != nil {
+			return ErrMarshallingFailed
+		}
+		values[instance.ID] = value
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	for key, value := range values {
+		ms.buckets[string(instanceBucket)][key] = value
+	}
+
+	return nil
+}
+
+func (ms *MemoryStore) DeleteInstance(id string) error {
+	ms.delete(instanceBucket, id)
+	return nil
+}
+
+// Close implements EntityStore.Close. Since there is nothing to release,
+// the stored entities remain available after closing the store.
+func (ms *MemoryStore) Close() error {
+	return nil
+}
+
+func (ms *MemoryStore) set(bucket Bucket, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return ErrMarshallingFailed
+	}
+
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	ms.buckets[string(bucket)][key] = value
+	return nil
+}
+
+func (ms *MemoryStore) get(bucket Bucket, key string) []byte {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	return ms.buckets[string(bucket)][key]
+}
+
+// getAll returns all values of a bucket, ordered by their keys just like
+// the values returned by KVStore.
+func (ms *MemoryStore) getAll(bucket Bucket) [][]byte {
+	ms.mutex.RLock()
+	defer ms.mutex.RUnlock()
+
+	b := ms.buckets[string(bucket)]
+	keys := make([]string, 0, len(b))
+
+	for k := range b {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	values := make([][]byte, len(keys))
+
+	for i, k := range keys {
+		values[i] = b[k]
+	}
+
+	return values
+}
+
+func (ms *MemoryStore) delete(bucket Bucket, key string) {
+	ms.mutex.Lock()
+	defer ms.mutex.Unlock()
+
+	delete(ms.buckets[string(bucket)], key)
+}
@@ -0,0 +1,99 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"testing"
+)
+
+func TestMemoryStore_Node(t *testing.T) {
+	memoryStore := NewMemoryStore()
+
+	node, _ := entity.NewNode("172.21.21.1", types.NodeCreateOptions{Weight: 1})
+
+	if err := memoryStore.CreateNode(node); err != nil {
+		t.Error(err)
+	}
+
+	// Changes to the created entity mustn't affect the stored entity.
+	node.Weight = 2
+
+	storedNode, err := memoryStore.FindNode(node.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if storedNode == nil || storedNode.Weight != 1 {
+		t.Errorf("got node %v, expected weight 1", storedNode)
+	}
+
+	if err := memoryStore.UpdateNode(node.ID, node); err != nil {
+		t.Error(err)
+	}
+
+	nodes, err := memoryStore.FindNodes(func(n *entity.Node) bool {
+		return n.Weight == 2
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(nodes) != 1 || nodes[0].ID != node.ID {
+		t.Errorf("got %d nodes, expected node %s", len(nodes), node.ID)
+	}
+
+	if err := memoryStore.DeleteNode(node.ID); err != nil {
+		t.Error(err)
+	}
+
+	deletedNode, err := memoryStore.FindNode(node.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if deletedNode != nil {
+		t.Errorf("got node %v, expected nil", deletedNode.ID)
+	}
+}
+
+// TestMemoryStore_ForEachInstance tests if the iteration stops as soon as
+// the visitor returns true.
+func TestMemoryStore_ForEachInstance(t *testing.T) {
+	memoryStore := NewMemoryStore()
+
+	for _, url := range []string{"10.0.0.1:8080", "10.0.0.2:8080", "10.0.0.3:8080"} {
+		instance, _ := entity.NewInstance("s1", "n1", url, types.InstanceCreateOptions{})
+
+		if err := memoryStore.CreateInstance(instance); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	visited := 0
+
+	err := memoryStore.ForEachInstance(func(instance *entity.Instance) (bool, error) {
+		visited++
+		return true, nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if visited != 1 {
+		t.Errorf("%v instances visited, %v expected", visited, 1)
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var (
+	ErrInvalidReply = errors.New("invalid reply from redis server")
+)
+
+// redisError is an error reply sent by the Redis server.
+type redisError string
+
+func (re redisError) Error() string {
+	return string(re)
+}
+
+// redisClient is a minimal Redis client speaking the RESP protocol over a
+// single connection. Commands are serialized using a mutex.
+//
+// If the connection gets lost, the client re-establishes the connection and
+// retries the command once. Therefore, only idempotent commands should be
+// sent using the client.
+type redisClient struct {
+	config RedisConfig
+	conn   net.Conn
+	reader *bufio.Reader
+	mutex  sync.Mutex
+}
+
+// do sends a command to the Redis server and returns its reply. The reply
+// is either nil, a string, an int64, a []byte or a []interface{}.
+func (rc *redisClient) do(args ...string) (interface{}, error) {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	reply, err := rc.send(args)
+
+	// Error replies are no connection errors, so only retry if the command
+	// failed for any other reason.
+	if _, isRedisError := err.(redisError); err != nil && !isRedisError {
+		rc.disconnect()
+		reply, err = rc.send(args)
+	}
+
+	return reply, err
+}
+
+// close closes the connection to the Redis server.
+func (rc *redisClient) close() error {
+	rc.mutex.Lock()
+	defer rc.mutex.Unlock()
+
+	if rc.conn == nil {
+		return nil
+	}
+
+	err := rc.conn.Close()
+	rc.conn = nil
+
+	return err
+}
+
+// send writes the command to the connection and reads the reply. It will
+// establish a new connection if there is none.
+func (rc *redisClient) send(args []string) (interface{}, error) {
+	if rc.conn == nil {
+		if err := rc.connect(); err != nil {
+			return nil, err
+		}
+	}
+
+	if rc.config.Timeout > 0 {
+		_ = rc.conn.SetDeadline(time.Now().Add(rc.config.Timeout))
+	}
+
+	if err := rc.write(args); err != nil {
+		return nil, err
+	}
+
+	return rc.read()
+}
+
+// connect establishes a new connection and authenticates if a password
+// has been configured. Afterwards, the configured database is selected.
+func (rc *redisClient) connect() error {
+	conn, err := net.DialTimeout("tcp", rc.config.Address, rc.config.Timeout)
+	if err != nil {
+		return err
+	}
+
+	rc.conn = conn
+	rc.reader = bufio.NewReader(conn)
+
+	if rc.config.Password != "" {
+		if _, err := rc.send([]string{"AUTH", rc.config.Password}); err != nil {
+			rc.disconnect()
+			return err
+		}
+	}
+
+	if rc.config.DB != 0 {
+		if _, err := rc.send([]string{"SELECT", strconv.Itoa(rc.config.DB)}); err != nil {
+			rc.disconnect()
+			return err
+		}
+	}
+
+	return nil
+}
+
+// disconnect closes the current connection without returning an error.
+func (rc *redisClient) disconnect() {
+	if rc.conn != nil {
+		_ = rc.conn.Close()
+		rc.conn = nil
+	}
+}
+
+// write writes a command as RESP array of bulk strings.
+func (rc *redisClient) write(args []string) error {
+	buf := []byte(fmt.Sprintf("*%d\r\n", len(args)))
+
+	for _, arg := range args {
+		buf = append(buf, fmt.Sprintf("$%d\r\n%s\r\n", len(arg), arg)...)
+	}
+
+	_, err := rc.conn.Write(buf)
+	return err
+}
+
+// read reads a single RESP reply from the connection.
+func (rc *redisClient) read() (interface{}, error) {
+	line, err := rc.reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, ErrInvalidReply
+	}
+
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, redisError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, ErrInvalidReply
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		value := make([]byte, length+2)
+
+		if _, err := io.ReadFull(rc.reader, value); err != nil {
+			return nil, err
+		}
+
+		return value[:length], nil
+	case '*':
+		length, err := strconv.Atoi(payload)
+		if err != nil {
+			return nil, ErrInvalidReply
+		}
+		if length < 0 {
+			return nil, nil
+		}
+
+		values := make([]interface{}, length)
+
+		for i := range values {
+			if values[i], err = rc.read(); err != nil {
+				return nil, err
+			}
+		}
+
+		return values, nil
+	default:
+		return nil, ErrInvalidReply
+	}
+}
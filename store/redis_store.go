@@ -0,0 +1,406 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"github.com/dominikbraun/dice/entity"
+	"sort"
+	"strings"
+	"time"
+)
+
+// scanCount is the number of keys that Redis should return per SCAN call.
+const scanCount = "100"
+
+// RedisConfig concludes the connection properties for a Redis server.
+type RedisConfig struct {
+	Address   string        `json:"address"`
+	Password  string        `json:"password"`
+	DB        int           `json:"db"`
+	Namespace string        `json:"namespace"`
+	Timeout   time.Duration `json:"timeout"`
+}
+
+// RedisStore is an EntityStore that stores all entities in a Redis server.
+// Multiple Dice instances can share their state by using the same server.
+//
+// Each entity is stored as JSON under a namespaced key in the form of
+// `<namespace>:<bucket>:<id>`. Filters are applied on the client side.
+type RedisStore struct {
+	namespace string
+	client    *redisClient
+}
+
+// NewRedisStore creates a new RedisStore instance and checks if the Redis
+// server is reachable. Lost connections will be re-established later on.
+func NewRedisStore(config RedisConfig) (*RedisStore, error) {
+	rs := RedisStore{
+		namespace: config.Namespace,
+		client:    &redisClient{config: config},
+	}
+
+	if _, err := rs.client.do("PING"); err != nil {
+		return nil, err
+	}
+
+	return &rs, nil
+}
+
+func (rs *RedisStore) CreateNode(node *entity.Node) error {
+	return rs.set(nodeBucket, node.ID, node)
+}
+
+func (rs *RedisStore) FindNodes(filter NodeFilter) ([]*entity.Node, error) {
+	values, err := rs.getAll(nodeBucket)
+	if len(values) == 0 || err != nil {
+		return nil, err
+	}
+
+	nodes := make([]*entity.Node, 0)
+
+	for _, v := range values {
+		var node entity.Node
+
+		if err = json.Unmarshal(v, &node); err != nil {
+			return nil, ErrMarshallingFailed
+		}
+
+		if filter(&node) {
+			nodes = append(nodes, &node)
+		}
+	}
+
+	return nodes, nil
+}
+
+// ForEachNode invokes visit for each stored node in the order of their IDs.
+// All nodes are read at once, see getAll.
+func (rs *RedisStore) ForEachNode(visit NodeVisitor) error {
+	values, err := rs.getAll(nodeBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		var node entity.Node
+
+		if err := json.Unmarshal(v, &node); err != nil {
+			return ErrMarshallingFailed
+		}
+
+		if stop, err := visit(&node); stop || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rs *RedisStore) FindNode(id string) (*entity.Node, error) {
+	value, err := rs.get(nodeBucket, id)
+	if value == nil || err != nil {
+		return nil, err
+	}
+
+	var node entity.Node
+
+	if err = json.Unmarshal(value, &node); err != nil {
+		return nil, ErrMarshallingFailed
+	}
+
+	return &node, nil
+}
+
+func (rs *RedisStore) UpdateNode(id string, source *entity.Node) error {
+	return rs.CreateNode(source)
+}
+
+func (rs *RedisStore) DeleteNode(id string) error {
+	return rs.delete(nodeBucket, id)
+}
+
+func (rs *RedisStore) CreateService(service *entity.Service) error {
+	return rs.set(serviceBucket, service.ID, service)
+}
+
+func (rs *RedisStore) FindServices(filter ServiceFilter) ([]*entity.Service, error) {
+	values, err := rs.getAll(serviceBucket)
+	if len(values) == 0 || err != nil {
+		return nil, err
+	}
+
+	services := make([]*entity.Service, 0)
+
+	for _, v := range values {
+		var service entity.Service
+
+		if err = json.Unmarshal(v, &service); err != nil {
+			return nil, ErrMarshallingFailed
+		}
+
+		if filter(&service) {
+			services = append(services, &service)
+		}
+	}
+
+	return services, nil
+}
+
+// ForEachService invokes visit for each stored service in the order of their IDs.
+// All services are read at once, see getAll.
+func (rs *RedisStore) ForEachService(visit ServiceVisitor) error {
+	values, err := rs.getAll(serviceBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		var service entity.Service
+
+		if err := json.Unmarshal(v, &service); err != nil {
+			return ErrMarshallingFailed
+		}
+
+		if stop, err := visit(&service); stop || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rs *RedisStore) FindService(id string) (*entity.Service, error) {
+	value, err := rs.get(serviceBucket, id)
+	if value == nil || err != nil {
+		return nil, err
+	}
+
+	var service entity.Service
+
+	if err = json.Unmarshal(value, &service); err != nil {
+		return nil, ErrMarshallingFailed
+	}
+
+	return &service, nil
+}
+
+func (rs *RedisStore) UpdateService(id string, source *entity.Service) error {
+	return rs.CreateService(source)
+}
+
+func (rs *RedisStore) DeleteService(id string) error {
+	return rs.delete(serviceBucket, id)
+}
+
+func (rs *RedisStore) CreateInstance(instance *entity.Instance) error {
+	return rs.set(instanceBucket, instance.ID, instance)
+}
+
+func (rs *RedisStore) FindInstances(filter InstanceFilter) ([]*entity.Instance, error) {
+	values, err := rs.getAll(instanceBucket)
+	if len(values) == 0 || err != nil {
+		return nil, err
+	}
+
+	instances := make([]*entity.Instance, 0)
+
+	for _, v := range values {
+		var instance entity.Instance
+
+		if err = json.Unmarshal(v, &instance); err != nil {
+			return nil, ErrMarshallingFailed
+		}
+
+		if filter(&instance) {
+			instances = append(instances, &instance)
+		}
+	}
+
+	return instances, nil
+}
+
+// ForEachInstance invokes visit for each stored instance in the order of their IDs.
+// All instances are read at once, see getAll.
+func (rs *RedisStore) ForEachInstance(visit InstanceVisitor) error {
+	values, err := rs.getAll(instanceBucket)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range values {
+		var instance entity.Instance
+
+		if err := json.Unmarshal(v, &instance); err != nil {
+			return ErrMarshallingFailed
+		}
+
+		if stop, err := visit(&instance); stop || err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rs *RedisStore) FindInstance(id string) (*entity.Instance, error) {
+	value, err := rs.get(instanceBucket, id)
+	if value == nil || err != nil {
+		return nil, err
+	}
+
+	var instance entity.Instance
+
+	if err = json.Unmarshal(value, &instance); err != nil {
+		return nil, ErrMarshallingFailed
+	}
+
+	return &instance, nil
+}
+
+func (rs *RedisStore) UpdateInstance(id string, source *entity.Instance) error {
+	return rs.CreateInstance(source)
+}
+
+// UpdateInstances updates all given instances using a single MSET command,
+// which sets all keys atomically.
+func (rs *RedisStore) UpdateInstances(sources []*entity.Instance) error {
+	if len(sources) == 0 {
+		return nil
+	}
+
+	args := make([]string, 0, 1+2*len(sources))
+	args = append(args, "MSET")
+
+	for _, instance := range sources {
+		value, err := json.Marshal(instance)
+		if err != nil {
+			return ErrMarshallingFailed
+		}
+		args = append(args, rs.key(instanceBucket, instance.ID), string(value))
+	}
+
+	_, err := rs.client.do(args...)
+	return err
+}
+
+func (rs *RedisStore) DeleteInstance(id string) error {
+	return rs.delete(instanceBucket, id)
+}
+
+func (rs *RedisStore) Close() error {
+	return rs.client.close()
+}
+
+// key returns the namespaced key of an entity.
+func (rs *RedisStore) key(bucket Bucket, id string) string {
+	return strings.Join([]string{rs.namespace, string(bucket), id}, ":")
+}
+
+func (rs *RedisStore) set(bucket Bucket, key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return ErrMarshallingFailed
+	}
+
+	_, err = rs.client.do("SET", rs.key(bucket, key), string(value))
+	return err
+}
+
+func (rs *RedisStore) get(bucket Bucket, key string) ([]byte, error) {
+	reply, err := rs.client.do("GET", rs.key(bucket, key))
+	if reply == nil || err != nil {
+		return nil, err
+	}
+
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, ErrInvalidReply
+	}
+
+	return value, nil
+}
+
+// getAll returns all values of a bucket, ordered by their keys just like
+// the values returned by KVStore. The keys are obtained using SCAN, which
+// may return a key multiple times, so duplicate keys are skipped.
+func (rs *RedisStore) getAll(bucket Bucket) ([][]byte, error) {
+	pattern := rs.key(bucket, "*")
+	cursor := "0"
+	keys := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for {
+		reply, err := rs.client.do("SCAN", cursor, "MATCH", pattern, "COUNT", scanCount)
+		if err != nil {
+			return nil, err
+		}
+
+		result, ok := reply.([]interface{})
+		if !ok || len(result) != 2 {
+			return nil, ErrInvalidReply
+		}
+
+		next, ok := result[0].([]byte)
+		if !ok {
+			return nil, ErrInvalidReply
+		}
+
+		matches, _ := result[1].([]interface{})
+
+		for _, m := range matches {
+			if key, ok := m.([]byte); ok && !seen[string(key)] {
+				seen[string(key)] = true
+				keys = append(keys, string(key))
+			}
+		}
+
+		if cursor = string(next); cursor == "0" {
+			break
+		}
+	}
+
+	if len(keys) == 0 {
+		return nil, nil
+	}
+
+	sort.Strings(keys)
+
+	reply, err := rs.client.do(append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	result, ok := reply.([]interface{})
+	if !ok {
+		return nil, ErrInvalidReply
+	}
+
+	values := make([][]byte, 0, len(result))
+
+	// Keys that have been deleted after the SCAN are returned as nil.
+	for _, r := range result {
+		if value, ok := r.([]byte); ok {
+			values = append(values, value)
+		}
+	}
+
+	return values, nil
+}
+
+func (rs *RedisStore) delete(bucket Bucket, key string) error {
+	_, err := rs.client.do("DEL", rs.key(bucket, key))
+	return err
+}
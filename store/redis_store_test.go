@@ -0,0 +1,322 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/dominikbraun/dice/entity"
+	"github.com/dominikbraun/dice/types"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testRedisServer is a fake Redis server that understands the commands used
+// by RedisStore. It keeps all values in memory. If duplicateScan is set,
+// SCAN returns each key twice, as Redis may do while rehashing.
+type testRedisServer struct {
+	listener      net.Listener
+	values        map[string]string
+	conns         []net.Conn
+	duplicateScan bool
+	mutex         sync.Mutex
+}
+
+func newTestRedisServer(t *testing.T) *testRedisServer {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trs := testRedisServer{
+		listener: listener,
+		values:   make(map[string]string),
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			trs.mutex.Lock()
+			trs.conns = append(trs.conns, conn)
+			trs.mutex.Unlock()
+
+			go trs.serve(conn)
+		}
+	}()
+
+	return &trs
+}
+
+// dropConnections closes all client connections, simulating a connection
+// loss on the client side.
+func (trs *testRedisServer) dropConnections() {
+	trs.mutex.Lock()
+	defer trs.mutex.Unlock()
+
+	for _, conn := range trs.conns {
+		_ = conn.Close()
+	}
+	trs.conns = nil
+}
+
+func (trs *testRedisServer) close() {
+	_ = trs.listener.Close()
+	trs.dropConnections()
+}
+
+func (trs *testRedisServer) serve(conn net.Conn) {
+	reader := bufio.NewReader(conn)
+
+	for {
+		args, err := readTestCommand(reader)
+		if err != nil {
+			return
+		}
+
+		trs.mutex.Lock()
+		reply := trs.execute(args)
+		trs.mutex.Unlock()
+
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+func (trs *testRedisServer) execute(args []string) string {
+	switch strings.ToUpper(args[0]) {
+	case "PING":
+		return "+PONG\r\n"
+	case "SET":
+		trs.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "MSET":
+		for i := 1; i+1 < len(args); i += 2 {
+			trs.values[args[i]] = args[i+1]
+		}
+		return "+OK\r\n"
+	case "GET":
+		return bulk(trs.values[args[1]], trs.values[args[1]] != "")
+	case "DEL":
+		delete(trs.values, args[1])
+		return ":1\r\n"
+	case "SCAN":
+		prefix := strings.TrimSuffix(args[3], "*")
+		keys := make([]string, 0)
+
+		for k := range trs.values {
+			if strings.HasPrefix(k, prefix) {
+				keys = append(keys, bulk(k, true))
+				if trs.duplicateScan {
+					keys = append(keys, bulk(k, true))
+				}
+			}
+		}
+
+		return fmt.Sprintf("*2\r\n%s*%d\r\n%s", bulk("0", true), len(keys), strings.Join(keys, ""))
+	case "MGET":
+		values := make([]string, 0)
+
+		for _, k := range args[1:] {
+			values = append(values, bulk(trs.values[k], trs.values[k] != ""))
+		}
+
+		return fmt.Sprintf("*%d\r\n%s", len(values), strings.Join(values, ""))
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func bulk(value string, exists bool) string {
+	if !exists {
+		return "$-1\r\n"
+	}
+	return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+}
+
+func readTestCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	args := make([]string, n)
+
+	for i := range args {
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+
+		arg, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+
+	return args, nil
+}
+
+func TestRedisStore_Service(t *testing.T) {
+	server := newTestRedisServer(t)
+	defer server.close()
+
+	redisStore, err := NewRedisStore(RedisConfig{
+		Address:   server.listener.Addr().String(),
+		Namespace: "dice",
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redisStore.Close()
+
+	service1, _ := entity.NewService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"})
+	service2, _ := entity.NewService("s2", types.ServiceCreateOptions{URLs: "s2.example.com"})
+
+	for _, s := range []*entity.Service{service1, service2} {
+		if err := redisStore.CreateService(s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	service1.IsEnabled = true
+
+	if err := redisStore.UpdateService(service1.ID, service1); err != nil {
+		t.Error(err)
+	}
+
+	storedService, err := redisStore.FindService(service1.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if storedService == nil || !storedService.IsEnabled {
+		t.Errorf("got service %v, expected enabled service %s", storedService, service1.ID)
+	}
+
+	// Further commands have to succeed after the connection has been lost.
+	server.dropConnections()
+
+	enabledServices, err := redisStore.FindServices(func(s *entity.Service) bool {
+		return s.IsEnabled
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(enabledServices) != 1 || enabledServices[0].ID != service1.ID {
+		t.Errorf("got %d enabled services, expected service %s", len(enabledServices), service1.ID)
+	}
+
+	if err := redisStore.DeleteService(service1.ID); err != nil {
+		t.Error(err)
+	}
+
+	deletedService, err := redisStore.FindService(service1.ID)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if deletedService != nil {
+		t.Errorf("got service %v, expected nil", deletedService.ID)
+	}
+}
+
+func TestRedisStore_UpdateInstances(t *testing.T) {
+	server := newTestRedisServer(t)
+	defer server.close()
+
+	redisStore, err := NewRedisStore(RedisConfig{
+		Address:   server.listener.Addr().String(),
+		Namespace: "dice",
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redisStore.Close()
+
+	instances := make([]*entity.Instance, 0)
+
+	for _, url := range []string{"10.0.0.1:8080", "10.0.0.2:8080"} {
+		instance, _ := entity.NewInstance("s1", "n1", url, types.InstanceCreateOptions{})
+		instance.IsAttached = true
+		instances = append(instances, instance)
+	}
+
+	if err := redisStore.UpdateInstances(instances); err != nil {
+		t.Fatal(err)
+	}
+
+	attached, err := redisStore.FindInstances(func(i *entity.Instance) bool {
+		return i.IsAttached
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(attached) != len(instances) {
+		t.Errorf("got %d attached instances, expected %d", len(attached), len(instances))
+	}
+}
+
+// TestRedisStore_duplicateScan tests that entities are only returned once
+// if SCAN returns their keys multiple times.
+func TestRedisStore_duplicateScan(t *testing.T) {
+	server := newTestRedisServer(t)
+	defer server.close()
+
+	server.mutex.Lock()
+	server.duplicateScan = true
+	server.mutex.Unlock()
+
+	redisStore, err := NewRedisStore(RedisConfig{
+		Address:   server.listener.Addr().String(),
+		Namespace: "dice",
+		Timeout:   time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer redisStore.Close()
+
+	service, _ := entity.NewService("s1", types.ServiceCreateOptions{URLs: "s1.example.com"})
+
+	if err := redisStore.CreateService(service); err != nil {
+		t.Fatal(err)
+	}
+
+	services, err := redisStore.FindServices(func(s *entity.Service) bool {
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(services) != 1 {
+		t.Errorf("got %d services, expected 1", len(services))
+	}
+}
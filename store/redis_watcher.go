@@ -0,0 +1,162 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// resubscribeDelay is the time to wait before re-establishing a lost
+// subscription.
+const resubscribeDelay = time.Second
+
+// RedisWatcher is a StoreWatcher that distributes changes using the Redis
+// pub/sub mechanism. All changes are published to the `<namespace>:changes`
+// channel of the Redis server that is used by the RedisStore.
+//
+// If a subscription gets lost, it will be re-established. Changes published
+// in the meantime are not received.
+type RedisWatcher struct {
+	config      RedisConfig
+	channel     string
+	publisher   *redisClient
+	subscribers []*redisClient
+	closed      bool
+	mutex       sync.Mutex
+}
+
+// NewRedisWatcher creates a new RedisWatcher instance.
+func NewRedisWatcher(config RedisConfig) *RedisWatcher {
+	rw := RedisWatcher{
+		config:    config,
+		channel:   config.Namespace + ":changes",
+		publisher: &redisClient{config: config},
+	}
+
+	return &rw
+}
+
+// Publish implements StoreWatcher.Publish.
+func (rw *RedisWatcher) Publish(change Change) error {
+	payload, err := json.Marshal(change)
+	if err != nil {
+		return ErrMarshallingFailed
+	}
+
+	_, err = rw.publisher.do("PUBLISH", rw.channel, string(payload))
+	return err
+}
+
+// Subscribe implements StoreWatcher.Subscribe. The subscription uses its own
+// connection, which is kept open until the watcher is closed.
+func (rw *RedisWatcher) Subscribe() (<-chan Change, error) {
+	subscriber := &redisClient{config: rw.config}
+
+	if err := rw.subscribe(subscriber); err != nil {
+		return nil, err
+	}
+
+	rw.mutex.Lock()
+	rw.subscribers = append(rw.subscribers, subscriber)
+	rw.mutex.Unlock()
+
+	changes := make(chan Change, watcherBufferSize)
+	go rw.receive(subscriber, changes)
+
+	return changes, nil
+}
+
+// Close implements StoreWatcher.Close. It closes all connections, causing
+// the subscriber channels to be closed as well.
+func (rw *RedisWatcher) Close() error {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	rw.closed = true
+
+	for _, s := range rw.subscribers {
+		_ = s.close()
+	}
+
+	return rw.publisher.close()
+}
+
+// subscribe connects the client and subscribes to the changes channel.
+func (rw *RedisWatcher) subscribe(subscriber *redisClient) error {
+	subscriber.mutex.Lock()
+	defer subscriber.mutex.Unlock()
+
+	if _, err := subscriber.send([]string{"SUBSCRIBE", rw.channel}); err != nil {
+		subscriber.disconnect()
+		return err
+	}
+
+	// Messages may arrive at any time, so the connection must not expire.
+	return subscriber.conn.SetDeadline(time.Time{})
+}
+
+// receive reads all messages from the subscription and sends the changes to
+// the provided channel. Lost subscriptions will be re-established until the
+// watcher is closed.
+func (rw *RedisWatcher) receive(subscriber *redisClient, changes chan<- Change) {
+	defer close(changes)
+
+	for {
+		reply, err := subscriber.read()
+
+		if err != nil {
+			if rw.isClosed() {
+				return
+			}
+
+			_ = subscriber.close()
+
+			for rw.subscribe(subscriber) != nil {
+				if rw.isClosed() {
+					return
+				}
+				time.Sleep(resubscribeDelay)
+			}
+			continue
+		}
+
+		message, ok := reply.([]interface{})
+		if !ok || len(message) != 3 || string(toBytes(message[0])) != "message" {
+			continue
+		}
+
+		var change Change
+
+		if err := json.Unmarshal(toBytes(message[2]), &change); err == nil {
+			changes <- change
+		}
+	}
+}
+
+// isClosed indicates whether the watcher has been closed.
+func (rw *RedisWatcher) isClosed() bool {
+	rw.mutex.Lock()
+	defer rw.mutex.Unlock()
+
+	return rw.closed
+}
+
+// toBytes returns the value of a bulk string reply or nil.
+func toBytes(reply interface{}) []byte {
+	value, _ := reply.([]byte)
+	return value
+}
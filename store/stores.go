@@ -22,6 +22,15 @@ type (
 	InstanceFilter func(instance *entity.Instance) bool
 )
 
+// NodeVisitor, ServiceVisitor and InstanceVisitor are invoked for each
+// entity by the ForEach* methods of a store. Returning true for stop ends
+// the iteration, returning an error aborts it and passes the error on.
+type (
+	NodeVisitor     func(node *entity.Node) (stop bool, err error)
+	ServiceVisitor  func(service *entity.Service) (stop bool, err error)
+	InstanceVisitor func(instance *entity.Instance) (stop bool, err error)
+)
+
 var (
 	AllNodesFilter     NodeFilter     = func(node *entity.Node) bool { return true }
 	AllServicesFilter  ServiceFilter  = func(service *entity.Service) bool { return true }
@@ -38,6 +47,7 @@ type EntityStore interface {
 type NodeStore interface {
 	CreateNode(node *entity.Node) error
 	FindNodes(filter NodeFilter) ([]*entity.Node, error)
+	ForEachNode(visit NodeVisitor) error
 	FindNode(id string) (*entity.Node, error)
 	UpdateNode(id string, source *entity.Node) error
 	DeleteNode(id string) error
@@ -46,6 +56,7 @@ type NodeStore interface {
 type ServiceStore interface {
 	CreateService(service *entity.Service) error
 	FindServices(filter ServiceFilter) ([]*entity.Service, error)
+	ForEachService(visit ServiceVisitor) error
 	FindService(id string) (*entity.Service, error)
 	UpdateService(id string, source *entity.Service) error
 	DeleteService(id string) error
@@ -54,7 +65,9 @@ type ServiceStore interface {
 type InstanceStore interface {
 	CreateInstance(instance *entity.Instance) error
 	FindInstances(filter InstanceFilter) ([]*entity.Instance, error)
+	ForEachInstance(visit InstanceVisitor) error
 	FindInstance(id string) (*entity.Instance, error)
 	UpdateInstance(id string, source *entity.Instance) error
+	UpdateInstances(sources []*entity.Instance) error
 	DeleteInstance(id string) error
 }
@@ -0,0 +1,96 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import "sync"
+
+// watcherBufferSize is the number of changes that can be queued for a
+// subscriber before publishing blocks.
+const watcherBufferSize = 64
+
+// EntityType describes the type of a stored entity.
+type EntityType string
+
+const (
+	NodeEntity     EntityType = "node"
+	ServiceEntity  EntityType = "service"
+	InstanceEntity EntityType = "instance"
+)
+
+// Change describes a change of a stored entity. It only identifies the
+// entity, so that the receiver has to load the current entity itself.
+type Change struct {
+	// Origin identifies the Dice instance that has changed the entity.
+	Origin     string     `json:"origin"`
+	EntityType EntityType `json:"entity_type"`
+	ID         string     `json:"id"`
+}
+
+// StoreWatcher distributes changes of stored entities between multiple Dice
+// instances sharing the same EntityStore. Each change that is published will
+// be received by all subscribers, including the publisher itself.
+type StoreWatcher interface {
+	Publish(change Change) error
+	Subscribe() (<-chan Change, error)
+	Close() error
+}
+
+// MemoryWatcher is a StoreWatcher that distributes changes within a single
+// process. It can be shared by multiple Dice instances using a MemoryStore.
+type MemoryWatcher struct {
+	subscribers []chan Change
+	mutex       sync.Mutex
+}
+
+// NewMemoryWatcher creates a new MemoryWatcher instance.
+func NewMemoryWatcher() *MemoryWatcher {
+	return &MemoryWatcher{}
+}
+
+// Publish implements StoreWatcher.Publish.
+func (mw *MemoryWatcher) Publish(change Change) error {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	for _, s := range mw.subscribers {
+		s <- change
+	}
+
+	return nil
+}
+
+// Subscribe implements StoreWatcher.Subscribe.
+func (mw *MemoryWatcher) Subscribe() (<-chan Change, error) {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	subscriber := make(chan Change, watcherBufferSize)
+	mw.subscribers = append(mw.subscribers, subscriber)
+
+	return subscriber, nil
+}
+
+// Close implements StoreWatcher.Close. It closes all subscriber channels.
+func (mw *MemoryWatcher) Close() error {
+	mw.mutex.Lock()
+	defer mw.mutex.Unlock()
+
+	for _, s := range mw.subscribers {
+		close(s)
+	}
+	mw.subscribers = nil
+
+	return nil
+}
@@ -56,6 +56,15 @@ type ServiceURL struct {
 	ServiceURLOptions
 }
 
+// ServiceReplace is a type exclusively used for the REST API. It holds all
+// information required to replace the instances of a service.
+//
+// For further information about its usage, see the docs for NodeCreate.
+type ServiceReplace struct {
+	Version string `json:"version"`
+	ServiceReplaceOptions
+}
+
 // InstanceCreate is a type exclusively used for the REST API. It holds all
 // information required to create a new instance.
 //
@@ -67,14 +76,35 @@ type InstanceCreate struct {
 	InstanceCreateOptions
 }
 
+// InstancesCreate is a type exclusively used for the REST API. It holds all
+// information required to create an instance on each of multiple nodes.
+type InstancesCreate struct {
+	ServiceRef  string `json:"service_ref"`
+	URLTemplate string `json:"url_template"`
+	NodeSelectOptions
+	InstanceCreateOptions
+}
+
+// InstanceClone is a type exclusively used for the REST API. It holds the
+// target node and the URL of the clone of an instance.
+type InstanceClone struct {
+	NodeRef string `json:"node_ref"`
+	URL     string `json:"url"`
+}
+
 // Response represents an API response that will be returned to the client.
 //
 // All *Response types wrap this basic response and a specific *Output type,
 // forming an API response for a specific command.
+//
+// RequestID identifies the request in the logs of the Dice daemon. It is
+// also sent in the X-Request-Id header.
 type Response struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data"`
+	Success   bool          `json:"success"`
+	Message   string        `json:"message"`
+	Category  ErrorCategory `json:"category,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+	Data      interface{}   `json:"data"`
 }
 
 // NodeInfoResponse is an API response that carries a NodeInfoOutput.
@@ -96,6 +126,27 @@ type ServiceInfoResponse struct {
 	Data ServiceInfoOutput `json:"data"`
 }
 
+// ServiceResultsResponse is an API response that carries the results of an
+// operation that has been applied to multiple services.
+type ServiceResultsResponse struct {
+	Response
+	Data []ServiceResultOutput `json:"data"`
+}
+
+// ServiceHealthResponse is an API response that carries the health status
+// of all services.
+type ServiceHealthResponse struct {
+	Response
+	Data []ServiceHealthOutput `json:"data"`
+}
+
+// ServiceMetricsResponse is an API response that carries the proxy metrics
+// of a service.
+type ServiceMetricsResponse struct {
+	Response
+	Data ServiceMetricsOutput `json:"data"`
+}
+
 // ServiceListResponse is an API response that carries a list of services.
 // At the moment, this is a list of ServiceInfoOutputs as returned by the
 // Dice core.
@@ -104,6 +155,39 @@ type ServiceListResponse struct {
 	Data []ServiceInfoOutput `json:"data"`
 }
 
+// InstanceResultsResponse is an API response that carries the results of
+// creating instances on multiple nodes.
+type InstanceResultsResponse struct {
+	Response
+	Data []InstanceResultOutput `json:"data"`
+}
+
+// RouteListResponse is an API response that carries a list of routes as
+// returned by the Dice core.
+type RouteListResponse struct {
+	Response
+	Data []RouteInfoOutput `json:"data"`
+}
+
+// RouteTestResponse is an API response that carries a RouteTestOutput.
+type RouteTestResponse struct {
+	Response
+	Data RouteTestOutput `json:"data"`
+}
+
+// ConfigResponse is an API response that carries the effective configuration
+// of the Dice daemon.
+type ConfigResponse struct {
+	Response
+	Data []ConfigEntryOutput `json:"data"`
+}
+
+// PruneResponse is an API response that carries a PruneOutput.
+type PruneResponse struct {
+	Response
+	Data PruneOutput `json:"data"`
+}
+
 // InstanceInfoResponse carrying a InstanceInfoOutput.
 type InstanceInfoResponse struct {
 	Response
@@ -117,3 +201,52 @@ type InstanceListResponse struct {
 	Response
 	Data []InstanceInfoOutput `json:"data"`
 }
+
+// InstanceDescribeResponse is an API response that carries the enriched
+// information for an instance.
+type InstanceDescribeResponse struct {
+	Response
+	Data InstanceDescribeOutput `json:"data"`
+}
+
+// InstanceStatsResponse is an API response that carries the proxy stats of
+// an instance.
+type InstanceStatsResponse struct {
+	Response
+	Data InstanceStatsOutput `json:"data"`
+}
+
+// HealthCheckResponse is an API response that carries the results of a
+// manual health check, one HealthCheckOutput for each checked instance.
+type HealthCheckResponse struct {
+	Response
+	Data []HealthCheckOutput `json:"data"`
+}
+
+// EventListResponse is an API response that carries the state changes of
+// an entity, the latest event coming last.
+type EventListResponse struct {
+	Response
+	Data []EventOutput `json:"data"`
+}
+
+// AuditLogResponse is an API response that carries a list of audit log
+// entries, the latest entry coming last.
+type AuditLogResponse struct {
+	Response
+	Data []AuditEntryOutput `json:"data"`
+}
+
+// LogsResponse is an API response that carries the last lines of the proxy
+// logfile.
+type LogsResponse struct {
+	Response
+	Data []string `json:"data"`
+}
+
+// VersionResponse is an API response that carries the build information of
+// the Dice daemon.
+type VersionResponse struct {
+	Response
+	Data VersionOutput `json:"data"`
+}
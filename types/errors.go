@@ -0,0 +1,76 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package types provides common types shared across packages.
+package types
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorCategory classifies an error, so that API clients can distinguish
+// particular kinds of errors without parsing the error message.
+type ErrorCategory string
+
+const (
+	NotFoundError ErrorCategory = "not_found"
+	ConflictError ErrorCategory = "conflict"
+)
+
+// Error is an error that belongs to an ErrorCategory. The category will be
+// sent to the client as part of the API response.
+type Error struct {
+	Category ErrorCategory
+	Message  string
+}
+
+// NewError creates a new error with the given category and message.
+func NewError(category ErrorCategory, message string) error {
+	return &Error{
+		Category: category,
+		Message:  message,
+	}
+}
+
+// Error implements the error interface.
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// CategoryOf returns the category of err or any error wrapped by err. If
+// err doesn't have a category, an empty category will be returned.
+func CategoryOf(err error) ErrorCategory {
+	var categorized *Error
+
+	if errors.As(err, &categorized) {
+		return categorized.Category
+	}
+
+	return ""
+}
+
+// Err creates an error from an unsuccessful API response. The error keeps
+// the category of the response. If the response carries a request ID, it
+// is included in the message, so that the error can be correlated with the
+// logs of the Dice daemon.
+func (r Response) Err() error {
+	message := r.Message
+
+	if r.RequestID != "" {
+		message = fmt.Sprintf("%s (request ID: %s)", message, r.RequestID)
+	}
+
+	return NewError(r.Category, message)
+}
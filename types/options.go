@@ -15,11 +15,18 @@
 // Package types provides common types shared across packages.
 package types
 
+import "time"
+
 // NodeCreateOptions combines all user options for creating a new node.
 // It serves as a Data Transfer Object for the Dice core.
+//
+// If IDKey is set, the node ID will be derived from it instead of being
+// generated randomly. The same applies to services and instances.
 type NodeCreateOptions struct {
-	Weight uint8 `json:"weight"`
-	Attach bool  `json:"attach"`
+	Weight uint8  `json:"weight"`
+	Attach bool   `json:"attach"`
+	Zone   string `json:"zone"`
+	IDKey  string `json:"id_key"`
 }
 
 // NodeRemoveOptions combines all user options for removing a node.
@@ -27,6 +34,12 @@ type NodeRemoveOptions struct {
 	Force bool `json:"force"`
 }
 
+// NodeDrainOptions combines all user options for draining a node. Interval
+// is the pause between detaching two instances of the node.
+type NodeDrainOptions struct {
+	Interval time.Duration `json:"interval"`
+}
+
 // NodeInfoOptions combines all user options for printing information
 // about a node.
 type NodeInfoOptions struct {
@@ -34,16 +47,35 @@ type NodeInfoOptions struct {
 }
 
 // NodeInfoOptions combines all user options for listing nodes.
+//
+// AliveOnly and DeadOnly restrict the list to alive or dead nodes. They
+// can't be combined.
 type NodeListOptions struct {
-	All bool `json:"all"`
+	All       bool `json:"all"`
+	AliveOnly bool `json:"alive_only"`
+	DeadOnly  bool `json:"dead_only"`
 }
 
 // ServiceCreateOptions combines all user options for creating a new
 // service. It serves as a Data Transfer Object for the Dice core.
+//
+// If Force is set, URLs that are used by another service are reassigned
+// to the new service instead of rejecting it.
 type ServiceCreateOptions struct {
 	URLs      string `json:"urls"`
 	Balancing string `json:"balancing"`
 	Enable    bool   `json:"enable"`
+	IDKey     string `json:"id_key"`
+	Force     bool   `json:"force"`
+}
+
+// ServicePatch combines all user options for partially updating a service.
+// Fields that are `nil` are left unchanged. URLs is a comma-separated list
+// replacing all URLs of the service.
+type ServicePatch struct {
+	Balancing          *string        `json:"balancing,omitempty"`
+	URLs               *string        `json:"urls,omitempty"`
+	HealthCheckTimeout *time.Duration `json:"healthcheck_timeout,omitempty"`
 }
 
 // ServiceInfoOptions combines all user options for printing information
@@ -57,17 +89,180 @@ type ServiceListOptions struct {
 	All bool `json:"all"`
 }
 
+// ServiceSelectOptions combines all user options for selecting multiple
+// services at once. All services whose name starts with Selector are
+// selected.
+type ServiceSelectOptions struct {
+	Selector string `json:"selector"`
+}
+
+// ServiceHealthCheckOptions combines all user options for configuring the
+// health checks of a service. Unset values fall back to the global config.
+type ServiceHealthCheckOptions struct {
+	Path               string        `json:"path"`
+	Interval           time.Duration `json:"interval"`
+	Timeout            time.Duration `json:"timeout"`
+	HealthyThreshold   int           `json:"healthy_threshold"`
+	UnhealthyThreshold int           `json:"unhealthy_threshold"`
+}
+
+// ServiceMaintenanceOptions combines all user options for turning the
+// maintenance mode of a service on or off.
+type ServiceMaintenanceOptions struct {
+	Enable  bool   `json:"enable"`
+	Message string `json:"message"`
+}
+
+// ServiceScheduleOptions combines all user options for scheduling the
+// enabling and disabling of a service. A transition is only scheduled if its
+// time has been set. If Every is set, the transitions repeat in that interval.
+// Clear removes all transitions that have been scheduled before.
+type ServiceScheduleOptions struct {
+	EnableAt  time.Time     `json:"enable_at"`
+	DisableAt time.Time     `json:"disable_at"`
+	Every     time.Duration `json:"every"`
+	Clear     bool          `json:"clear"`
+}
+
+// ServiceStickyOptions combines all user options for turning sticky sessions
+// for a service on or off.
+type ServiceStickyOptions struct {
+	Enable bool `json:"enable"`
+}
+
+// ServiceCORSOptions combines all user options for configuring CORS for a
+// service. Origins, methods and headers are comma-separated lists. Setting
+// no origins disables CORS.
+type ServiceCORSOptions struct {
+	Origins     string `json:"origins"`
+	Methods     string `json:"methods"`
+	Headers     string `json:"headers"`
+	Credentials bool   `json:"credentials"`
+}
+
+// ServiceAuthOptions combines all user options for configuring basic auth
+// for a service. Setting no username disables basic auth.
+type ServiceAuthOptions struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// ServiceOutliersOptions combines all user options for configuring the
+// outlier detection for a service. A threshold of 0 disables it.
+type ServiceOutliersOptions struct {
+	Threshold    int           `json:"threshold"`
+	EjectionTime time.Duration `json:"ejection_time"`
+}
+
+// ServiceMirrorOptions combines all user options for configuring traffic
+// mirroring for a service. A fraction of 0 disables it.
+type ServiceMirrorOptions struct {
+	Version  string  `json:"version"`
+	Fraction float64 `json:"fraction"`
+}
+
+// ServiceAccessLogOptions combines all user options for configuring the
+// access log of a service. A sample rate of N logs 1 in N requests, where 0
+// and 1 log every request.
+type ServiceAccessLogOptions struct {
+	Disable    bool `json:"disable"`
+	SampleRate int  `json:"sample_rate"`
+}
+
+// ServiceAllowListOptions combines all user options for restricting the
+// requests forwarded to a service. Methods and paths are comma-separated
+// lists. Setting neither methods nor paths allows all requests.
+type ServiceAllowListOptions struct {
+	Methods string `json:"methods"`
+	Paths   string `json:"paths"`
+}
+
+// ServiceReplaceOptions combines all user options for replacing the instances
+// of a service with instances of a new version.
+//
+// URLs maps the URL of each instance to be replaced to the URL of its new
+// instance. MaxUnavailable is the number of instances replaced at once, and
+// Timeout is the time each new instance has for becoming alive.
+type ServiceReplaceOptions struct {
+	URLs           map[string]string `json:"urls"`
+	MaxUnavailable int               `json:"max_unavailable"`
+	Timeout        time.Duration     `json:"timeout"`
+}
+
+// AuditLogOptions combines all user options for reading the audit log.
+type AuditLogOptions struct {
+	Limit int `json:"limit"`
+}
+
+// LogsOptions combines all user options for reading the proxy logfile. If
+// Follow is set, new lines will be streamed until the client disconnects.
+type LogsOptions struct {
+	Lines  int  `json:"lines"`
+	Follow bool `json:"follow"`
+}
+
+// HealthCheckRunOptions combines all user options for running a manual health
+// check. If Timeout is set, the check is aborted when the timeout expires.
+type HealthCheckRunOptions struct {
+	Timeout time.Duration `json:"timeout"`
+}
+
 // ServiceURLOptions combines all user options for setting service URLs.
 type ServiceURLOptions struct {
 	Delete bool `json:"delete"`
 }
 
+// PruneOptions combines all user options for pruning orphaned instances
+// and unused services. If DryRun is set, nothing will be removed.
+type PruneOptions struct {
+	DryRun bool `json:"dry_run"`
+}
+
 // InstanceCreateOptions combines all user options for creating a new
-// instance. It serves as a Data Transfer Object for the Dice core.
+// instance. It serves as a Data Transfer Object for the Dice core. A
+// MaxConnections value of 0 doesn't limit the concurrent requests. Standby
+// instances only receive requests if no other instance is available.
 type InstanceCreateOptions struct {
-	Name    string `json:"name"`
-	Version string `json:"version"`
-	Attach  bool   `json:"attach"`
+	Name           string `json:"name"`
+	Version        string `json:"version"`
+	Attach         bool   `json:"attach"`
+	IDKey          string `json:"id_key"`
+	MaxConnections int    `json:"max_connections"`
+	Standby        bool   `json:"standby"`
+}
+
+// RouteTestOptions combines all user options for testing which instance a
+// request to Host would be routed to. Host may also be a URL including a
+// path, like http://example.com/users. Headers are the request headers, for
+// example a Cookie header carrying an affinity token.
+//
+// If Probe is set, a HEAD request is sent to the selected instance.
+type RouteTestOptions struct {
+	Host    string            `json:"host"`
+	Headers map[string]string `json:"headers"`
+	Probe   bool              `json:"probe"`
+}
+
+// RouteWeightsOptions combines all user options for splitting the requests
+// to URL across multiple services. Weights maps service references to the
+// weights of the services. Empty Weights remove the split.
+type RouteWeightsOptions struct {
+	URL     string         `json:"url"`
+	Weights map[string]int `json:"weights"`
+}
+
+// NodeSelectOptions combines all user options for selecting multiple nodes
+// at once. All nodes whose name starts with Selector are selected, or all
+// nodes if All is set.
+type NodeSelectOptions struct {
+	Selector string `json:"selector"`
+	All      bool   `json:"all"`
+}
+
+// InstanceAttachOptions combines all user options for attaching an
+// instance.
+type InstanceAttachOptions struct {
+	Force bool `json:"force"`
 }
 
 // InstanceRemoveOptions combines all user options for removing an
@@ -83,6 +278,13 @@ type InstanceInfoOptions struct {
 }
 
 // InstanceListOptions combines all user options for listing instances.
+//
+// AliveOnly and DeadOnly restrict the list to alive or dead instances. They
+// can't be combined. Outdated restricts the list to instances that don't run
+// the target version of their service.
 type InstanceListOptions struct {
-	All bool `json:"all"`
+	All       bool `json:"all"`
+	AliveOnly bool `json:"alive_only"`
+	DeadOnly  bool `json:"dead_only"`
+	Outdated  bool `json:"outdated"`
 }
@@ -15,12 +15,16 @@
 // Package types provides common types shared across packages.
 package types
 
+import "time"
+
 // NodeInfoOutput is the output printed by the `node info` command.
 type NodeInfoOutput struct {
-	ID         string `json:"id"`
-	Name       string `json:"name"`
-	IsAttached bool   `json:"is_attached"`
-	IsAlive    bool   `json:"is_alive"`
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Zone          string `json:"zone"`
+	IsAttached    bool   `json:"is_attached"`
+	IsAlive       bool   `json:"is_alive"`
+	Unschedulable bool   `json:"unschedulable"`
 }
 
 // ServiceInfoOutput is the output printed by the `service info` command.
@@ -31,9 +35,128 @@ type ServiceInfoOutput struct {
 	TargetVersion   string   `json:"target_version"`
 	BalancingMethod string   `json:"balancing_method"`
 	IsEnabled       bool     `json:"is_enabled"`
+	IsInMaintenance bool     `json:"is_in_maintenance"`
+	IsReachable     bool     `json:"is_reachable"`
+	InstanceCount   int      `json:"instance_count"`
+	AliveCount      int      `json:"alive_count"`
+}
+
+// ServiceResultOutput is the result of an operation applied to multiple
+// services, such as `service enable --selector`, for a single service. If
+// the operation has failed for the service, Error contains the reason.
+type ServiceResultOutput struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// HealthStatus describes whether a service is able to serve requests.
+type HealthStatus string
+
+const (
+	HealthyStatus  HealthStatus = "healthy"
+	DegradedStatus HealthStatus = "degraded"
+	DownStatus     HealthStatus = "down"
+)
+
+// ServiceHealthOutput is the output printed by the `service health` command
+// for each service. Alive is the number of instances that are able to serve
+// requests, Total is the number of all instances of the service.
+type ServiceHealthOutput struct {
+	ID     string       `json:"id"`
+	Name   string       `json:"name"`
+	Status HealthStatus `json:"status"`
+	Alive  int          `json:"alive"`
+	Total  int          `json:"total"`
+}
+
+// InstanceResultOutput is the result of creating an instance on a single
+// node, such as with `instance create --selector`. If the instance couldn't
+// be created, Error contains the reason.
+type InstanceResultOutput struct {
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	URL      string `json:"url"`
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
+// RouteInfoOutput is the output printed by the `route list` command for
+// each route registered in the service registry.
+type RouteInfoOutput struct {
+	Route       string `json:"route"`
+	ServiceID   string `json:"service_id"`
+	ServiceName string `json:"service_name"`
+	IsEnabled   bool   `json:"is_enabled"`
+}
+
+// RouteTestOutput is the output printed by the `route test` command. It
+// explains which service a host matched and which instance a request would
+// be routed to. If no instance would be selected, Reason explains why.
+//
+// If the selected instance has been probed, ProbeStatus is the status code
+// of its response. If it couldn't be reached, ProbeError explains why.
+// SchedulerCounters are the internal counters of the service's scheduler.
+type RouteTestOutput struct {
+	Host              string                 `json:"host"`
+	IsMatched         bool                   `json:"is_matched"`
+	ServiceID         string                 `json:"service_id,omitempty"`
+	ServiceName       string                 `json:"service_name,omitempty"`
+	Candidates        []RouteCandidateOutput `json:"candidates"`
+	Selected          string                 `json:"selected,omitempty"`
+	Reason            string                 `json:"reason,omitempty"`
+	SchedulerCounters map[string]int         `json:"scheduler_counters,omitempty"`
+	IsProbed          bool                   `json:"is_probed"`
+	ProbeStatus       int                    `json:"probe_status,omitempty"`
+	ProbeError        string                 `json:"probe_error,omitempty"`
+}
+
+// RouteCandidateOutput describes a deployment that has been considered when
+// testing a route, see RouteTestOutput.
+type RouteCandidateOutput struct {
+	InstanceID         string `json:"instance_id"`
+	InstanceURL        string `json:"instance_url"`
+	NodeID             string `json:"node_id"`
+	NodeName           string `json:"node_name"`
+	IsInstanceAttached bool   `json:"is_instance_attached"`
+	IsInstanceAlive    bool   `json:"is_instance_alive"`
+	IsNodeAttached     bool   `json:"is_node_attached"`
+	IsNodeAlive        bool   `json:"is_node_alive"`
+}
+
+// ConfigEntryOutput is the output printed by the `config print` command
+// for each configuration key. Source is one of default, file and env.
+type ConfigEntryOutput struct {
+	Key    string      `json:"key"`
+	Value  interface{} `json:"value"`
+	Source string      `json:"source"`
+}
+
+// ConflictOutput describes a conflict in the key-value store, such as a
+// route that is claimed by multiple services. Kind is the kind of the
+// conflicting value and IDs are the IDs of all entities claiming it.
+type ConflictOutput struct {
+	Kind  string   `json:"kind"`
+	Value string   `json:"value"`
+	IDs   []string `json:"ids"`
+}
+
+// PruneOutput is the output printed by the `prune` command. It contains
+// the IDs of all instances and services that have been pruned or, in case
+// of a dry run, would have been pruned.
+type PruneOutput struct {
+	Instances []string `json:"instances"`
+	Services  []string `json:"services"`
+	DryRun    bool     `json:"dry_run"`
 }
 
 // InstanceInfoOutput is the output printed by the `instance info` command.
+//
+// IsUpdated indicates whether the instance runs the target version of its
+// service. IsServing indicates whether the proxy forwards requests to the
+// instance. If it doesn't, Reason explains why, for example because the node
+// of the instance is detached.
 type InstanceInfoOutput struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
@@ -41,6 +164,90 @@ type InstanceInfoOutput struct {
 	NodeID     string `json:"node_id"`
 	URL        string `json:"url"`
 	Version    string `json:"version"`
+	IsUpdated  bool   `json:"is_updated"`
 	IsAttached bool   `json:"is_attached"`
 	IsAlive    bool   `json:"is_alive"`
+	IsStandby  bool   `json:"is_standby"`
+	IsServing  bool   `json:"is_serving"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// InstanceDescribeOutput is the output printed by the `instance describe`
+// command. In addition to the instance data, it contains the names of the
+// service and node as well as the live status from the service registry.
+//
+// IsRegistered indicates whether the instance has been found in the service
+// registry. If not, the status is taken from the key-value store.
+type InstanceDescribeOutput struct {
+	ID             string `json:"id"`
+	Name           string `json:"name"`
+	URL            string `json:"url"`
+	Version        string `json:"version"`
+	ServiceID      string `json:"service_id"`
+	ServiceName    string `json:"service_name"`
+	NodeID         string `json:"node_id"`
+	NodeName       string `json:"node_name"`
+	NodeZone       string `json:"node_zone"`
+	IsRegistered   bool   `json:"is_registered"`
+	IsAttached     bool   `json:"is_attached"`
+	IsAlive        bool   `json:"is_alive"`
+	NodeIsAttached bool   `json:"node_is_attached"`
+	NodeIsAlive    bool   `json:"node_is_alive"`
+}
+
+// InstanceStatsOutput is the output printed by the `instance stats` command.
+// All values except InFlight are cumulative since the proxy has been started.
+type InstanceStatsOutput struct {
+	InstanceID  string        `json:"instance_id"`
+	Requests    uint64        `json:"requests"`
+	Errors      uint64        `json:"errors"`
+	ErrorRate   float64       `json:"error_rate"`
+	LastLatency time.Duration `json:"last_latency"`
+	InFlight    int           `json:"in_flight"`
+}
+
+// ServiceMetricsOutput is the output printed by the `service metrics`
+// command. The latency percentiles are estimates. All values are cumulative
+// since the proxy has been started.
+type ServiceMetricsOutput struct {
+	ServiceID  string        `json:"service_id"`
+	Requests   uint64        `json:"requests"`
+	LatencyP50 time.Duration `json:"latency_p50"`
+	LatencyP95 time.Duration `json:"latency_p95"`
+	BytesIn    uint64        `json:"bytes_in"`
+	BytesOut   uint64        `json:"bytes_out"`
+}
+
+// HealthCheckOutput is the output printed by the `healthcheck run` command.
+type HealthCheckOutput struct {
+	ServiceID  string `json:"service_id"`
+	NodeID     string `json:"node_id"`
+	InstanceID string `json:"instance_id"`
+	IsAlive    bool   `json:"is_alive"`
+}
+
+// AuditEntryOutput is the output printed by the `audit log` command.
+type AuditEntryOutput struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Action        string    `json:"action"`
+	EntityType    string    `json:"entity_type"`
+	EntityID      string    `json:"entity_id"`
+	EntityName    string    `json:"entity_name"`
+	RequestID     string    `json:"request_id"`
+	RemoteAddress string    `json:"remote_address"`
+}
+
+// EventOutput is the output printed by the `instance events` and `node events`
+// commands for each state change of the entity.
+type EventOutput struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+}
+
+// VersionOutput is the output printed by the `version` command.
+type VersionOutput struct {
+	Version     string   `json:"version"`
+	Commit      string   `json:"commit"`
+	BuildDate   string   `json:"build_date"`
+	APIVersions []string `json:"api_versions"`
 }
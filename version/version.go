@@ -0,0 +1,41 @@
+// Copyright 2019 The Dice Authors. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     https://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version provides the build information of the Dice binaries.
+//
+// The values are injected at build time using -ldflags, for example:
+//
+//	go build -ldflags="-X github.com/dominikbraun/dice/version.Version=1.0.0"
+//
+// This version is not related to the API versions, which determine the API
+// routes. The API versions are negotiated by the client, see APIVersions.
+package version
+
+import "fmt"
+
+var (
+	Version   = "dev"
+	Commit    = "none"
+	BuildDate = "unknown"
+)
+
+// APIVersions are the API versions supported by this build, ordered from
+// the oldest to the latest version. The daemon serves the API routes under
+// each of these versions, and the client can talk to any of them.
+var APIVersions = []string{"v1"}
+
+// String returns the build information in a human-readable form.
+func String() string {
+	return fmt.Sprintf("%s (commit %s, built %s)", Version, Commit, BuildDate)
+}